@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/achievements"
+	achievements2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/achievements"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+	"os"
+)
+
+// achievements evaluates the achievements of existing users against everything they logged,
+// e.g. after new definitions were added. Awarding is idempotent, so it can be run any time.
+func main() {
+	var envPath string
+	var userID, batchSize int
+
+	flag.StringVar(&envPath, "env-path", "", "path of .env file")
+	flag.IntVar(&userID, "user", 0, "evaluate a single user, all users when 0")
+	flag.IntVar(&batchSize, "batch", 500, "number of user ids loaded at once")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	cfg := config.MustLoad(envPath)
+	db, err := database.New(cfg.DbCfg)
+	if err != nil {
+		log.Error("cannot to connect to db", sl.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	userStore := users.NewStore(db)
+	// Nothing listens on this bus: retroactive awards are not announced.
+	engine := achievements.NewEngine(achievements2.NewStore(db), events.New(), log)
+
+	var evaluated, awarded, failed int
+	evaluate := func(id int) {
+		list, err := engine.Evaluate(id)
+		if err != nil {
+			log.Error("failed to evaluate user", sl.Err(err), slog.Int("user_id", id))
+			failed++
+			return
+		}
+		evaluated++
+		awarded += len(list)
+		for _, a := range list {
+			log.Info("achievement awarded", slog.Int("user_id", id), slog.String("code", a.Code))
+		}
+	}
+
+	if userID != 0 {
+		evaluate(userID)
+	} else {
+		lastID := 0
+		for {
+			ids, err := userStore.GetUserIDs(lastID, batchSize)
+			if err != nil {
+				log.Error("cannot to get users", sl.Err(err))
+				os.Exit(1)
+			}
+			for _, id := range ids {
+				evaluate(id)
+				lastID = id
+			}
+			if len(ids) < batchSize {
+				break
+			}
+		}
+	}
+
+	log.Info(
+		"achievements evaluated", slog.Int("users", evaluated), slog.Int("awarded", awarded),
+		slog.Int("failed", failed),
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
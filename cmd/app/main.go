@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/api"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
@@ -17,7 +18,12 @@ const (
 )
 
 func main() {
-	cfg := config.Envs
+	var envPath string
+
+	flag.StringVar(&envPath, "env-path", "", "path to .env file")
+	flag.Parse()
+
+	cfg := config.MustLoad(envPath)
 
 	log := setupLogger(cfg.Env)
 
@@ -25,18 +31,31 @@ func main() {
 
 	db, err := database.New(cfg.DbCfg)
 	if err != nil {
-		log.Error("cannot to connect to db", sl.Err(err), cfg.DbCfg)
-
+		log.Error("cannot to connect to db", sl.Err(err), slog.String("host", cfg.DbCfg.Host))
+		os.Exit(1)
 	}
 
 	if err := db.Ping(); err != nil {
-		log.Error("cannot to ping to db", err)
+		log.Error("cannot to ping to db", sl.Err(err))
+		os.Exit(1)
 	}
 
 	log.Info("database successfully connected")
+
+	version, pending, err := database.Migrate(db, cfg.AutoMigrate)
+	if err != nil {
+		log.Error("cannot to migrate db", sl.Err(err))
+		os.Exit(1)
+	}
+	if pending {
+		log.Warn("database has pending migrations, run the migrator", slog.Uint64("version", uint64(version)))
+	} else {
+		log.Info("database schema is up to date", slog.Uint64("version", uint64(version)))
+	}
+
 	server := api.NewServer(db, log)
 	if err := server.Run(); err != nil {
-		log.Error("cannot to run api server ", err)
+		log.Error("cannot to run api server ", sl.Err(err))
 	}
 
 }
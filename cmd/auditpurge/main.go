@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// auditpurge deletes the audit events older than the retention period, AUDIT_RETENTION unless
+// -older-than is given. It is meant to run from cron.
+func main() {
+	var envPath string
+	var olderThan time.Duration
+	var batchSize int
+
+	flag.StringVar(&envPath, "env-path", "", "path of .env file")
+	flag.DurationVar(&olderThan, "older-than", 0, "delete events older than this, AUDIT_RETENTION when 0")
+	flag.IntVar(&batchSize, "batch", 1000, "number of events deleted per transaction")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	cfg := config.MustLoad(envPath)
+	if olderThan == 0 {
+		olderThan = cfg.Audit.Retention
+	}
+	if olderThan <= 0 || batchSize <= 0 {
+		log.Error("older-than and batch must be positive")
+		os.Exit(1)
+	}
+
+	db, err := database.New(cfg.DbCfg)
+	if err != nil {
+		log.Error("cannot to connect to db", sl.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	before := time.Now().Add(-olderThan)
+	deleted, err := audit.NewStore(db).Purge(before, batchSize)
+	if err != nil {
+		log.Error("failed to purge audit events", sl.Err(err), slog.Int64("deleted", deleted))
+		os.Exit(1)
+	}
+
+	log.Info("audit events purged", slog.Time("before", before), slog.Int64("deleted", deleted))
+}
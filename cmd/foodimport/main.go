@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+// product is the subset of an Open Food Facts product used by the importer.
+// Nutrient keys keep the dump naming, e.g. "proteins_100g".
+type product struct {
+	Code      string
+	Name      string
+	Brands    string
+	Allergens []string
+	Analysis  []string
+	Nutrients map[string]string
+}
+
+type stats struct {
+	read, imported, skipped int
+}
+
+func main() {
+	var envPath, filePath, format string
+	var batchSize int
+
+	flag.StringVar(&envPath, "env-path", "", "path of .env file")
+	flag.StringVar(&filePath, "file", "", "path of the Open Food Facts CSV/TSV or JSONL dump, optionally gzipped")
+	flag.StringVar(&format, "format", "", "dump format: csv or jsonl, detected from the file name when empty")
+	flag.IntVar(&batchSize, "batch", 1000, "number of foods saved per transaction")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if filePath == "" {
+		log.Error("file is required")
+		os.Exit(1)
+	}
+	if format == "" {
+		format = detectFormat(filePath)
+	}
+	if format != formatCSV && format != formatJSONL {
+		log.Error("unknown format, use -format=csv or -format=jsonl", slog.String("format", format))
+		os.Exit(1)
+	}
+
+	cfg := config.MustLoad(envPath)
+	db, err := database.New(cfg.DbCfg)
+	if err != nil {
+		log.Error("cannot to connect to db", sl.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		log.Error("cannot to open file", sl.Err(err))
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(filePath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			log.Error("cannot to open gzip stream", sl.Err(err))
+			os.Exit(1)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	store := foods.NewStore(db)
+	var st stats
+	batch := make([]models.Food, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.UpsertFoods(batch); err != nil {
+			return err
+		}
+		st.imported += len(batch)
+		batch = batch[:0]
+		log.Info("progress", slog.Int("read", st.read), slog.Int("imported", st.imported))
+		return nil
+	}
+
+	handle := func(p product) error {
+		st.read++
+		food, ok := toFood(p)
+		if !ok {
+			st.skipped++
+			return nil
+		}
+		batch = append(batch, food)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	if format == formatCSV {
+		err = readCSV(r, handle)
+	} else {
+		err = readJSONL(r, handle)
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.Error("import failed", sl.Err(err), slog.Int("read", st.read), slog.Int("imported", st.imported))
+		os.Exit(1)
+	}
+
+	log.Info(
+		"import finished",
+		slog.Int("read", st.read), slog.Int("imported", st.imported), slog.Int("skipped", st.skipped),
+	)
+}
+
+func detectFormat(path string) string {
+	path = strings.TrimSuffix(path, ".gz")
+	switch {
+	case strings.HasSuffix(path, ".jsonl"), strings.HasSuffix(path, ".ndjson"):
+		return formatJSONL
+	case strings.HasSuffix(path, ".csv"), strings.HasSuffix(path, ".tsv"):
+		return formatCSV
+	}
+	return ""
+}
+
+// toFood keeps products with a valid barcode, a name and plausible nutrients.
+func toFood(p product) (models.Food, bool) {
+	barcode, ok := nutrition.NormalizeBarcode(p.Code)
+	if !ok {
+		return models.Food{}, false
+	}
+	name := strings.TrimSpace(p.Name)
+	if name == "" {
+		return models.Food{}, false
+	}
+	n, ok := nutrition.FromOpenFoodFacts(p.Nutrients)
+	if !ok {
+		return models.Food{}, false
+	}
+
+	brand, _, _ := strings.Cut(p.Brands, ",")
+	return models.Food{
+		Barcode:   &barcode,
+		Name:      name,
+		Brand:     strings.TrimSpace(brand),
+		Source:    models.FoodSourceOpenFoodFacts,
+		Nutrients: n,
+		Allergens: nutrition.AllergensFromTags(p.Allergens),
+		Diets:     nutrition.DietsFromTags(p.Analysis),
+	}, true
+}
+
+// readCSV reads the Open Food Facts CSV export, which is actually tab separated;
+// comma separated files are accepted too.
+func readCSV(r io.Reader, handle func(product) error) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	first, err := br.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+
+	cr := csv.NewReader(br)
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	if line, _, _ := strings.Cut(string(first), "\n"); strings.Contains(line, "\t") {
+		cr.Comma = '\t'
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+	for _, required := range []string{"code", "product_name"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("column %s is missing", required)
+		}
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+			return err
+		}
+
+		get := func(col string) string {
+			i, ok := columns[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return rec[i]
+		}
+
+		p := product{
+			Code:      get("code"),
+			Name:      get("product_name"),
+			Brands:    get("brands"),
+			Allergens: strings.Split(get("allergens_tags"), ","),
+			Analysis:  strings.Split(get("ingredients_analysis_tags"), ","),
+			Nutrients: map[string]string{},
+		}
+		for col := range columns {
+			if strings.HasSuffix(col, "_100g") {
+				p.Nutrients[col] = get(col)
+			}
+		}
+		if err := handle(p); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONL(r io.Reader, handle func(product) error) error {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, 1<<20))
+	dec.UseNumber()
+
+	for {
+		var raw struct {
+			Code       any            `json:"code"`
+			Name       string         `json:"product_name"`
+			Brands     string         `json:"brands"`
+			Allergens  []string       `json:"allergens_tags"`
+			Analysis   []string       `json:"ingredients_analysis_tags"`
+			Nutriments map[string]any `json:"nutriments"`
+		}
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p := product{
+			Code:      stringify(raw.Code),
+			Name:      raw.Name,
+			Brands:    raw.Brands,
+			Allergens: raw.Allergens,
+			Analysis:  raw.Analysis,
+			Nutrients: map[string]string{},
+		}
+		for k, v := range raw.Nutriments {
+			if strings.HasSuffix(k, "_100g") {
+				p.Nutrients[k] = stringify(v)
+			}
+		}
+		if err := handle(p); err != nil {
+			return err
+		}
+	}
+}
+
+func stringify(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return ""
+}
@@ -1,75 +1,455 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const usage = `usage: migrator [flags] <command> [args]
+
+commands:
+  status          print the current version, the dirty flag and the pending migrations
+  up [N]          apply all or the next N pending migrations
+  down [N]        roll back all or the last N migrations
+  goto VERSION    migrate up or down to VERSION
+  force VERSION   set the version without running migrations, to recover from a dirty state
+  create NAME     create the up and down files of a new migration
+
+flags:
+`
+
+// versionFormat names the migration files, e.g. 20241016101122_add-sessions.up.sql.
+const versionFormat = "20060102150405"
+
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// step is a migration to run in one direction.
+type step struct {
+	version uint
+	name    string
+	up      bool
+}
+
+func (s step) String() string {
+	direction := "down"
+	if s.up {
+		direction = "up"
+	}
+	return fmt.Sprintf("%d_%s.%s.sql", s.version, s.name, direction)
+}
+
+type migrator struct {
+	m      *migrate.Migrate
+	src    source.Driver
+	dryRun bool
+	yes    bool
+}
+
+// migrator applies the SQL migrations embedded into the binary, or those of -migrations-path.
+// Flags may also follow the command, e.g. "migrator down 1 --yes". It reads the DB_* settings
+// only, so it runs from plain environment variables as well as from a .env file.
 func main() {
 	var migrationsPath, envPath string
-	var forceVersion int
+	var dryRun, yes bool
 
-	flag.StringVar(&migrationsPath, "migrations-path", "", "migrations path")
-	flag.StringVar(&envPath, "env-path", "", "path of .env file")
-	flag.IntVar(&forceVersion, "force", 0, "force set version")
-	flag.Parse()
-	if migrationsPath == "" {
-		panic("migrations path is required")
+	flag.StringVar(
+		&migrationsPath, "migrations-path", "", "migrations path, the migrations embedded into the binary by default",
+	)
+	flag.StringVar(&envPath, "env-path", "", "path of .env file, the DB_* environment variables alone without one")
+	flag.BoolVar(&dryRun, "dry-run", false, "print the SQL to be executed instead of executing it")
+	flag.BoolVar(&yes, "yes", false, "do not ask for confirmation of destructive operations")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	args := parseArgs(os.Args[1:])
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+
+	if cmd == "create" {
+		if len(args) != 1 {
+			fail(errors.New("create needs a NAME"))
+		}
+		if migrationsPath == "" {
+			migrationsPath = "migrations"
+		}
+		if err := create(migrationsPath, args[0]); err != nil {
+			fail(err)
+		}
+		return
 	}
-	if envPath == "" {
-		panic("env path is required")
+
+	dbCfg, err := config.LoadDB(envPath)
+	if err != nil {
+		fail(err)
 	}
 
-	err := godotenv.Load(envPath)
+	var src source.Driver
+	if migrationsPath == "" {
+		src, err = database.Source()
+	} else {
+		src, err = source.Open("file://" + migrationsPath)
+	}
 	if err != nil {
-		panic("Error loading .env file" + envPath + err.Error())
+		fail(fmt.Errorf("cannot open migrations: %w", err))
 	}
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbHost := os.Getenv("DB_HOST")
-	dbName := os.Getenv("DB_NAME")
-	dbURL := "postgres://" + dbUser + ":" + dbPassword + "@" + dbHost + "/" + dbName + "?sslmode=disable"
+	m, err := migrate.NewWithSourceInstance("migrations", src, database.URL(dbCfg))
+	if err != nil {
+		fail(fmt.Errorf("cannot connect to db: %w", err))
+	}
+	defer m.Close()
 
-	m, err := migrate.New(
-		fmt.Sprintf("file://%s", migrationsPath),
-		dbURL,
-	)
+	mg := &migrator{m: m, src: src, dryRun: dryRun, yes: yes}
+
+	switch cmd {
+	case "status":
+		err = mg.status()
+	case "up":
+		err = mg.up(args)
+	case "down":
+		err = mg.down(args)
+	case "goto":
+		err = mg.goTo(args)
+	case "force":
+		err = mg.force(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 	if err != nil {
-		panic(err)
+		m.Close()
+		fail(err)
 	}
+}
 
-	if forceVersion != 0 {
-		if err := m.Force(forceVersion); err != nil {
-			panic(err)
+// parseArgs parses the flags wherever they are and returns the other arguments.
+func parseArgs(list []string) []string {
+	var args []string
+	for {
+		_ = flag.CommandLine.Parse(list)
+		if flag.NArg() == 0 {
+			return args
 		}
-		fmt.Println("Forced version", forceVersion)
-		return
+		args = append(args, flag.Arg(0))
+		list = flag.Args()[1:]
+	}
+}
+
+func (mg *migrator) status() error {
+	version, dirty, hasVersion, err := mg.version()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !hasVersion:
+		fmt.Println("version: none")
+	case dirty:
+		fmt.Printf("version: %d (dirty)\n", version)
+	default:
+		fmt.Printf("version: %d\n", version)
+	}
+
+	pending, err := mg.upSteps(version, hasVersion, -1, 0)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Println("no pending migrations")
+		return nil
+	}
+	fmt.Printf("%d pending:\n", len(pending))
+	for _, s := range pending {
+		fmt.Printf("  %d %s\n", s.version, s.name)
+	}
+	return nil
+}
+
+func (mg *migrator) up(args []string) error {
+	n, err := count(args)
+	if err != nil {
+		return err
+	}
+	version, _, hasVersion, err := mg.version()
+	if err != nil {
+		return err
+	}
+
+	steps, err := mg.upSteps(version, hasVersion, n, 0)
+	if err != nil {
+		return err
+	}
+	if n > len(steps) {
+		return fmt.Errorf("only %d pending migrations", len(steps))
+	}
+	return mg.run(steps, func() error { return mg.m.Steps(len(steps)) })
+}
+
+func (mg *migrator) down(args []string) error {
+	n, err := count(args)
+	if err != nil {
+		return err
+	}
+	version, _, hasVersion, err := mg.version()
+	if err != nil {
+		return err
+	}
+
+	steps, err := mg.downSteps(version, hasVersion, n, 0)
+	if err != nil {
+		return err
+	}
+	if n > len(steps) {
+		return fmt.Errorf("only %d applied migrations", len(steps))
+	}
+	return mg.run(steps, func() error { return mg.m.Steps(-len(steps)) })
+}
+
+func (mg *migrator) goTo(args []string) error {
+	target, err := versionArg(args)
+	if err != nil {
+		return err
+	}
+	if _, _, err := mg.read(step{version: target, up: true}); err != nil {
+		return fmt.Errorf("no migration %d", target)
+	}
+	version, _, hasVersion, err := mg.version()
+	if err != nil {
+		return err
 	}
 
-	if len(os.Args) > 1 && os.Args[1] == "down" {
-		if err := m.Down(); err != nil {
-			if errors.Is(err, migrate.ErrNoChange) {
-				fmt.Println("no migrations to roll back")
-				return
+	var steps []step
+	switch {
+	case !hasVersion || target > version:
+		steps, err = mg.upSteps(version, hasVersion, -1, target)
+	case target < version:
+		steps, err = mg.downSteps(version, hasVersion, -1, target)
+	}
+	if err != nil {
+		return err
+	}
+	return mg.run(steps, func() error { return mg.m.Migrate(target) })
+}
+
+func (mg *migrator) force(args []string) error {
+	target, err := versionArg(args)
+	if err != nil {
+		return err
+	}
+	if mg.dryRun {
+		fmt.Printf("would set the version to %d\n", target)
+		return nil
+	}
+	if !mg.yes && !confirm(fmt.Sprintf("Set the version to %d without running migrations?", target)) {
+		return errors.New("aborted")
+	}
+
+	if err := mg.m.Force(int(target)); err != nil {
+		return err
+	}
+	fmt.Println("forced version", target)
+	return nil
+}
+
+// run prints the SQL of the steps on a dry run, otherwise it executes them. Rolling back asks for
+// confirmation first.
+func (mg *migrator) run(steps []step, execute func() error) error {
+	if len(steps) == 0 {
+		fmt.Println("no change")
+		return nil
+	}
+
+	if mg.dryRun {
+		for _, s := range steps {
+			_, sql, err := mg.read(s)
+			if err != nil {
+				return err
 			}
-			panic(err)
+			fmt.Printf("-- %s\n%s\n", s, strings.TrimSpace(sql))
+		}
+		return nil
+	}
+
+	if !steps[0].up && !mg.yes {
+		fmt.Println("to roll back:")
+		for _, s := range steps {
+			fmt.Printf("  %d %s\n", s.version, s.name)
 		}
-		fmt.Println("migrations rolled back")
+		if !confirm(fmt.Sprintf("Roll back %d migrations? Their data may be lost.", len(steps))) {
+			return errors.New("aborted")
+		}
+	}
+
+	if err := execute(); err != nil {
+		return err
+	}
+	for _, s := range steps {
+		fmt.Println("ran", s)
+	}
+	return nil
+}
+
+// version returns the current version; hasVersion is false before the first migration.
+func (mg *migrator) version() (version uint, dirty bool, hasVersion bool, err error) {
+	version, dirty, err = mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, err
+	}
+	return version, dirty, true, nil
+}
+
+// upSteps lists the migrations after the version, at most n of them when n >= 0 and none after
+// until when until is set.
+func (mg *migrator) upSteps(version uint, hasVersion bool, n int, until uint) ([]step, error) {
+	var steps []step
+
+	next, err := mg.src.First()
+	if hasVersion {
+		next, err = mg.src.Next(version)
+	}
+	for ; err == nil; next, err = mg.src.Next(next) {
+		if n >= 0 && len(steps) == n || until != 0 && next > until {
+			return steps, nil
+		}
+		s := step{version: next, up: true}
+		if s.name, _, err = mg.read(s); err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// downSteps lists the applied migrations from the version backwards, at most n of them when
+// n >= 0 and none down to until when until is set.
+func (mg *migrator) downSteps(version uint, hasVersion bool, n int, until uint) ([]step, error) {
+	var steps []step
+	if !hasVersion {
+		return steps, nil
+	}
+
+	var err error
+	for prev := version; err == nil; prev, err = mg.src.Prev(prev) {
+		if n >= 0 && len(steps) == n || prev <= until {
+			return steps, nil
+		}
+		s := step{version: prev}
+		if s.name, _, err = mg.read(s); err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// read returns the name and the SQL of the step.
+func (mg *migrator) read(s step) (string, string, error) {
+	var r io.ReadCloser
+	var name string
+	var err error
+	if s.up {
+		r, name, err = mg.src.ReadUp(s.version)
 	} else {
-		if err := m.Up(); err != nil {
-			if errors.Is(err, migrate.ErrNoChange) {
-				fmt.Println("no migrations to apply")
-				return
-			}
-			panic(err)
+		r, name, err = mg.src.ReadDown(s.version)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read %s: %w", s, err)
+	}
+	defer r.Close()
+
+	sql, err := io.ReadAll(r)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read %s: %w", s, err)
+	}
+	return name, string(sql), nil
+}
+
+// create writes empty up and down files named after the current time.
+func create(dir string, name string) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid name %q, use lowercase letters, digits, - and _", name)
+	}
+
+	version := time.Now().UTC().Format(versionFormat)
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
 		}
-		fmt.Println("migrations applied")
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Println("created", path)
 	}
+	return nil
+}
+
+// count reads the optional N of up and down, -1 meaning all.
+func count(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return -1, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+		return n, nil
+	default:
+		return 0, errors.New("too many arguments")
+	}
+}
+
+func versionArg(args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errors.New("a VERSION is required")
+	}
+	v, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+	return uint(v), nil
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrator:", err)
+	os.Exit(1)
 }
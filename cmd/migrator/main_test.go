@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"github.com/golang-migrate/migrate/v4/source"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testMigrator reads the migrations 1_users, 2_workouts and 5_diary from a temporary directory.
+func testMigrator(t *testing.T) *migrator {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"1_users", "2_workouts", "5_diary"} {
+		for _, direction := range []string{"up", "down"} {
+			sql := "-- " + direction + " " + name + "\n"
+			if err := os.WriteFile(filepath.Join(dir, name+"."+direction+".sql"), []byte(sql), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	src, err := source.Open("file://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { src.Close() })
+	return &migrator{src: src}
+}
+
+func names(steps []step) []string {
+	list := make([]string, len(steps))
+	for i, s := range steps {
+		list[i] = s.String()
+	}
+	return list
+}
+
+func TestUpSteps(t *testing.T) {
+	mg := testMigrator(t)
+	tests := []struct {
+		name       string
+		version    uint
+		hasVersion bool
+		n          int
+		until      uint
+		want       []string
+	}{
+		{name: "all from none", n: -1, want: []string{"1_users.up.sql", "2_workouts.up.sql", "5_diary.up.sql"}},
+		{
+			name: "all from 1", version: 1, hasVersion: true, n: -1,
+			want: []string{"2_workouts.up.sql", "5_diary.up.sql"},
+		},
+		{name: "next one", version: 1, hasVersion: true, n: 1, want: []string{"2_workouts.up.sql"}},
+		{name: "until 2", n: -1, until: 2, want: []string{"1_users.up.sql", "2_workouts.up.sql"}},
+		{name: "up to date", version: 5, hasVersion: true, n: -1, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				steps, err := mg.upSteps(tt.version, tt.hasVersion, tt.n, tt.until)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := names(steps); !slices.Equal(got, tt.want) {
+					t.Errorf("upSteps() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestDownSteps(t *testing.T) {
+	mg := testMigrator(t)
+	tests := []struct {
+		name       string
+		version    uint
+		hasVersion bool
+		n          int
+		until      uint
+		want       []string
+	}{
+		{
+			name: "all", version: 5, hasVersion: true, n: -1,
+			want: []string{"5_diary.down.sql", "2_workouts.down.sql", "1_users.down.sql"},
+		},
+		{name: "last one", version: 5, hasVersion: true, n: 1, want: []string{"5_diary.down.sql"}},
+		{
+			name: "down to 1", version: 5, hasVersion: true, n: -1, until: 1,
+			want: []string{"5_diary.down.sql", "2_workouts.down.sql"},
+		},
+		{name: "nothing applied", n: -1, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				steps, err := mg.downSteps(tt.version, tt.hasVersion, tt.n, tt.until)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := names(steps); !slices.Equal(got, tt.want) {
+					t.Errorf("downSteps() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+// stdout returns what fn prints.
+func stdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestGoToUnknownVersion(t *testing.T) {
+	mg := testMigrator(t)
+
+	// The version is checked before the database is read, which the test has none of.
+	if err := mg.goTo([]string{"3"}); err == nil || err.Error() != "no migration 3" {
+		t.Errorf("goTo(3) error = %v, want no migration 3", err)
+	}
+}
+
+func TestDryRunPrintsSQL(t *testing.T) {
+	mg := testMigrator(t)
+	mg.dryRun = true
+	steps, err := mg.downSteps(5, true, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := stdout(
+		t, func() {
+			err = mg.run(
+				steps, func() error {
+					t.Error("dry run executed the migrations")
+					return nil
+				},
+			)
+		},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-- 5_diary.down.sql\n-- down 5_diary\n-- 2_workouts.down.sql\n-- down 2_workouts\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"Add-Users", "add users", "-add", "../add"} {
+		if err := create(dir, name); err == nil {
+			t.Errorf("create(%q) succeeded", name)
+		}
+	}
+
+	stdout(
+		t, func() {
+			if err := create(dir, "add-users_2"); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !strings.HasSuffix(files[0], "_add-users_2.down.sql") ||
+		!strings.HasSuffix(files[1], "_add-users_2.up.sql") {
+		t.Errorf("created %v, want the down and up files", files)
+	}
+	if version := strings.SplitN(filepath.Base(files[0]), "_", 2)[0]; len(version) != len(versionFormat) {
+		t.Errorf("version = %q, want the %s format", version, versionFormat)
+	}
+}
+
+func TestParseArgsFlagsAfterCommand(t *testing.T) {
+	orig := flag.CommandLine
+	defer func() { flag.CommandLine = orig }()
+	flag.CommandLine = flag.NewFlagSet("migrator", flag.ContinueOnError)
+	yes := flag.Bool("yes", false, "")
+	dryRun := flag.Bool("dry-run", false, "")
+
+	args := parseArgs([]string{"down", "1", "--yes", "-dry-run"})
+
+	if !slices.Equal(args, []string{"down", "1"}) {
+		t.Errorf("args = %v, want [down 1]", args)
+	}
+	if !*yes || !*dryRun {
+		t.Errorf("yes = %v, dry-run = %v, want both set", *yes, *dryRun)
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: nil, want: -1},
+		{args: []string{"3"}, want: 3},
+		{args: []string{"0"}, wantErr: true},
+		{args: []string{"-2"}, wantErr: true},
+		{args: []string{"all"}, wantErr: true},
+		{args: []string{"1", "2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := count(tt.args)
+		if (err != nil) != tt.wantErr || err == nil && got != tt.want {
+			t.Errorf("count(%v) = %d, %v, want %d, error %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
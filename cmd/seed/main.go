@@ -0,0 +1,609 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"time"
+)
+
+// emailDomain marks the seeded accounts, so a second run without -reset is refused.
+const emailDomain = "seed.atom-fit.test"
+
+// size is how many users are seeded and how many days of history each one gets.
+type size struct {
+	users int
+	days  int
+}
+
+var sizes = map[string]size{
+	"small":  {users: 25, days: 30},
+	"medium": {users: 250, days: 90},
+	"large":  {users: 2500, days: 365},
+}
+
+// batchUsers is the number of users whose rows are copied at once.
+const batchUsers = 100
+
+type food struct {
+	id                        int
+	barcode, name, brand      string
+	kcal, protein, carbs, fat float64
+	meals                     []string
+	minGrams, maxGrams        float64
+}
+
+// foods are per 100 g.
+var foods = []food{
+	{barcode: "0000000000017", name: "Rolled oats", kcal: 379, protein: 13.2, carbs: 67.7, fat: 6.5,
+		meals: []string{"breakfast"}, minGrams: 40, maxGrams: 90},
+	{barcode: "0000000000024", name: "Greek yogurt", brand: "Fage", kcal: 97, protein: 9, carbs: 3.9, fat: 5,
+		meals: []string{"breakfast", "snack"}, minGrams: 150, maxGrams: 250},
+	{barcode: "0000000000031", name: "Banana", kcal: 89, protein: 1.1, carbs: 22.8, fat: 0.3,
+		meals: []string{"breakfast", "snack"}, minGrams: 100, maxGrams: 150},
+	{barcode: "0000000000048", name: "Eggs", kcal: 143, protein: 12.6, carbs: 0.7, fat: 9.5,
+		meals: []string{"breakfast"}, minGrams: 100, maxGrams: 200},
+	{barcode: "0000000000055", name: "Whole wheat bread", kcal: 247, protein: 13, carbs: 41, fat: 3.4,
+		meals: []string{"breakfast", "lunch"}, minGrams: 50, maxGrams: 120},
+	{barcode: "0000000000062", name: "Chicken breast", kcal: 165, protein: 31, carbs: 0, fat: 3.6,
+		meals: []string{"lunch", "dinner"}, minGrams: 120, maxGrams: 250},
+	{barcode: "0000000000079", name: "White rice, cooked", kcal: 130, protein: 2.7, carbs: 28.2, fat: 0.3,
+		meals: []string{"lunch", "dinner"}, minGrams: 150, maxGrams: 300},
+	{barcode: "0000000000086", name: "Salmon fillet", kcal: 208, protein: 20, carbs: 0, fat: 13,
+		meals: []string{"dinner"}, minGrams: 120, maxGrams: 220},
+	{barcode: "0000000000093", name: "Broccoli", kcal: 34, protein: 2.8, carbs: 6.6, fat: 0.4,
+		meals: []string{"lunch", "dinner"}, minGrams: 80, maxGrams: 200},
+	{barcode: "0000000000109", name: "Pasta, cooked", kcal: 158, protein: 5.8, carbs: 30.9, fat: 0.9,
+		meals: []string{"lunch", "dinner"}, minGrams: 150, maxGrams: 350},
+	{barcode: "0000000000116", name: "Lean ground beef", kcal: 176, protein: 20, carbs: 0, fat: 10,
+		meals: []string{"lunch", "dinner"}, minGrams: 100, maxGrams: 200},
+	{barcode: "0000000000123", name: "Apple", kcal: 52, protein: 0.3, carbs: 13.8, fat: 0.2,
+		meals: []string{"snack"}, minGrams: 120, maxGrams: 200},
+	{barcode: "0000000000130", name: "Almonds", kcal: 579, protein: 21.2, carbs: 21.6, fat: 49.9,
+		meals: []string{"snack"}, minGrams: 20, maxGrams: 50},
+	{barcode: "0000000000147", name: "Whey protein", brand: "Atom", kcal: 400, protein: 80, carbs: 8, fat: 6,
+		meals: []string{"snack"}, minGrams: 25, maxGrams: 40},
+	{barcode: "0000000000154", name: "Dark chocolate", kcal: 546, protein: 4.9, carbs: 61, fat: 31,
+		meals: []string{"snack"}, minGrams: 15, maxGrams: 40},
+}
+
+type exercise struct {
+	id       int
+	name     string
+	category string
+}
+
+// routines are the workouts users pick from, by exercise name.
+var routines = []struct {
+	name      string
+	exercises []string
+}{
+	{name: "Push", exercises: []string{"Bench Press", "Overhead Press", "Push-up"}},
+	{name: "Pull", exercises: []string{"Deadlift", "Barbell Row", "Pull-up"}},
+	{name: "Legs", exercises: []string{"Back Squat", "Deadlift"}},
+	{name: "Run", exercises: []string{"Running"}},
+	{name: "Ride", exercises: []string{"Cycling"}},
+	{name: "Walk", exercises: []string{"Walking"}},
+}
+
+var (
+	maleNames   = []string{"Alex", "Andrei", "Ben", "Daniel", "Ion", "James", "Luca", "Mihai", "Noah", "Tom"}
+	femaleNames = []string{"Ana", "Elena", "Emma", "Ioana", "Laura", "Maria", "Mia", "Olivia", "Sara", "Zoe"}
+	lastNames   = []string{"Ciobanu", "Popescu", "Rusu", "Smith", "Brown", "Garcia", "Muller", "Rossi", "Novak", "Silva"}
+	goals       = []string{"lose", "maintain", "gain"}
+)
+
+type seeder struct {
+	tx        *sqlx.Tx
+	rnd       *rand.Rand
+	size      size
+	today     time.Time
+	passHash  []byte
+	exercises map[string]exercise
+	active    []int
+	rows      map[string]*rows
+}
+
+// rows are the buffered rows of a table.
+type rows struct {
+	columns []string
+	values  [][]any
+}
+
+// seed fills a development database with fixtures. The same -seed produces the same users and
+// history, with dates relative to the day it runs. Every seeded account uses -password; the
+// superuser is admin@seed.atom-fit.test. Derived data such as personal records and achievements
+// is left to the app.
+func main() {
+	var envPath, sizeName, password string
+	var seed int64
+	var reset bool
+
+	flag.StringVar(&envPath, "env-path", "", "path of .env file")
+	flag.Int64Var(&seed, "seed", 1, "seed of the generator, the same seed gives the same fixtures")
+	flag.StringVar(&sizeName, "size", "small", "fixture size: small, medium or large")
+	flag.BoolVar(&reset, "reset", false, "delete the users and everything that belongs to them first")
+	flag.StringVar(&password, "password", "password", "password of every seeded account")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	sz, ok := sizes[sizeName]
+	if !ok {
+		log.Error("unknown size, use small, medium or large", slog.String("size", sizeName))
+		os.Exit(1)
+	}
+
+	cfg := config.MustLoad(envPath)
+	db, err := database.New(cfg.DbCfg)
+	if err != nil {
+		log.Error("cannot to connect to db", sl.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Hashing once keeps large sizes fast; the accounts are throwaway anyway.
+	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("cannot to hash password", sl.Err(err))
+		os.Exit(1)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		log.Error("cannot to begin transaction", sl.Err(err))
+		os.Exit(1)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	s := &seeder{
+		tx:       tx,
+		rnd:      rand.New(rand.NewSource(seed)),
+		size:     sz,
+		today:    time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		passHash: passHash,
+		rows:     map[string]*rows{},
+	}
+
+	start := time.Now()
+	if err := s.run(reset, log); err != nil {
+		log.Error("seed failed", sl.Err(err))
+		os.Exit(1)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error("cannot to commit", sl.Err(err))
+		os.Exit(1)
+	}
+
+	log.Info(
+		"seed finished",
+		slog.String("size", sizeName), slog.Int64("seed", seed), slog.Int("users", sz.users+1),
+		slog.String("superuser", "admin@"+emailDomain), slog.Duration("took", time.Since(start)),
+	)
+}
+
+func (s *seeder) run(reset bool, log *slog.Logger) error {
+	if reset {
+		if err := s.reset(); err != nil {
+			return fmt.Errorf("reset: %w", err)
+		}
+		log.Info("users deleted")
+	} else {
+		var seeded bool
+		err := s.tx.Get(&seeded, "SELECT EXISTS(SELECT 1 FROM users WHERE email LIKE '%@' || $1)", emailDomain)
+		if err != nil {
+			return err
+		}
+		if seeded {
+			return errors.New("the database is already seeded, run again with -reset")
+		}
+	}
+
+	if err := s.loadExercises(); err != nil {
+		return err
+	}
+	if err := s.seedFoods(); err != nil {
+		return err
+	}
+
+	ids, err := s.reserve("users", s.size.users+1)
+	if err != nil {
+		return err
+	}
+	if err := s.seedSuperuser(ids[0]); err != nil {
+		return err
+	}
+	users := ids[1:]
+
+	for start := 0; start < len(users); start += batchUsers {
+		batch := users[start:min(start+batchUsers, len(users))]
+		for _, id := range batch {
+			if err := s.seedUser(id); err != nil {
+				return err
+			}
+		}
+		if err := s.flush(); err != nil {
+			return err
+		}
+		log.Info("progress", slog.Int("users", start+len(batch)), slog.Int("total", len(users)))
+	}
+
+	// Follows reference users of any batch, so they are copied once every user is.
+	for _, id := range s.active {
+		s.seedFollows(id)
+	}
+	return s.flush()
+}
+
+// reset deletes the users, which cascades to everything they own, their foods included, and the
+// public foods of the fixtures. Catalogs such as exercises, roles, achievements and the imported
+// Open Food Facts foods are kept.
+func (s *seeder) reset() error {
+	if _, err := s.tx.Exec("TRUNCATE audit_events, idempotency_keys"); err != nil {
+		return err
+	}
+	if _, err := s.tx.Exec("DELETE FROM users"); err != nil {
+		return err
+	}
+
+	barcodes := make([]string, len(foods))
+	for i, f := range foods {
+		barcodes[i] = f.barcode
+	}
+	_, err := s.tx.Exec(
+		"DELETE FROM foods WHERE owner_id IS NULL AND source = 'user' AND barcode = ANY($1)", pq.Array(barcodes),
+	)
+	return err
+}
+
+func (s *seeder) loadExercises() error {
+	var list []struct {
+		ID       int    `db:"id"`
+		Name     string `db:"name"`
+		Category string `db:"category"`
+	}
+	if err := s.tx.Select(&list, "SELECT id, name, category FROM exercises"); err != nil {
+		return fmt.Errorf("load exercises: %w", err)
+	}
+
+	s.exercises = make(map[string]exercise, len(list))
+	for _, e := range list {
+		s.exercises[e.Name] = exercise{id: e.ID, name: e.Name, category: e.Category}
+	}
+	for _, r := range routines {
+		for _, name := range r.exercises {
+			if _, ok := s.exercises[name]; !ok {
+				return fmt.Errorf("exercise %q is missing, are the migrations applied?", name)
+			}
+		}
+	}
+	return nil
+}
+
+// seedFoods saves the public foods, updating them when a previous run already did.
+func (s *seeder) seedFoods() error {
+	for i := range foods {
+		f := &foods[i]
+		err := s.tx.Get(
+			&f.id,
+			"INSERT INTO foods (barcode, name, brand, kcal, protein, carbs, fat, source) "+
+				"VALUES ($1, $2, $3, $4, $5, $6, $7, 'user') "+
+				"ON CONFLICT (barcode) WHERE owner_id IS NULL AND barcode IS NOT NULL "+
+				"DO UPDATE SET name = EXCLUDED.name RETURNING id",
+			f.barcode, f.name, f.brand, f.kcal, f.protein, f.carbs, f.fat,
+		)
+		if err != nil {
+			return fmt.Errorf("seed foods: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedSuperuser(id int) error {
+	_, err := s.tx.Exec(
+		"INSERT INTO users (id, email, username, password, is_active, activation_code) "+
+			"VALUES ($1, $2, 'admin', $3, true, $4)",
+		id, "admin@"+emailDomain, s.passHash, s.uuid(),
+	)
+	if err != nil {
+		return fmt.Errorf("seed superuser: %w", err)
+	}
+	if _, err := s.tx.Exec("INSERT INTO user_roles (user_id, role) VALUES ($1, 'admin')", id); err != nil {
+		return fmt.Errorf("seed superuser: %w", err)
+	}
+	return nil
+}
+
+// seedUser generates a user and their history. About one user in eight has not activated the
+// account yet and has no history.
+func (s *seeder) seedUser(id int) error {
+	male := s.rnd.Intn(2) == 0
+	first := pick(s.rnd, femaleNames)
+	height := 155 + s.rnd.Intn(25)
+	if male {
+		first = pick(s.rnd, maleNames)
+		height = 167 + s.rnd.Intn(25)
+	}
+	last := pick(s.rnd, lastNames)
+	age := 18 + s.rnd.Intn(53)
+	goal := pick(s.rnd, goals)
+	bmi := 19 + s.rnd.Float64()*13
+	weight := bmi * float64(height*height) / 10000
+	weightGoal := weight
+	switch goal {
+	case "lose":
+		weightGoal = weight - 3 - s.rnd.Float64()*12
+	case "gain":
+		weightGoal = weight + 2 + s.rnd.Float64()*6
+	}
+	active := s.rnd.Intn(8) != 0
+
+	s.add("users",
+		[]string{
+			"id", "email", "username", "password", "is_active", "is_male", "age", "height", "weight", "goal",
+			"weight_goal", "activation_code",
+		},
+		id, fmt.Sprintf("user%05d@%s", id, emailDomain), first+" "+last, s.passHash, active, male, age, height,
+		int(math.Round(weight)), goal, int(math.Round(weightGoal)), s.uuid(),
+	)
+	if !active {
+		return nil
+	}
+
+	s.seedWeights(id, weight, weightGoal)
+	s.seedMetrics(id)
+	s.seedDiary(id, male, goal)
+	if err := s.seedWorkouts(id); err != nil {
+		return err
+	}
+	s.seedGoal(id, weight, weightGoal, goal)
+	s.active = append(s.active, id)
+	return nil
+}
+
+// seedWeights logs a weekly weigh-in drifting towards the weight goal.
+func (s *seeder) seedWeights(id int, weight, weightGoal float64) {
+	start := s.today.AddDate(0, 0, -s.size.days)
+	weeks := s.size.days / 7
+	for week := 0; week < weeks; week++ {
+		progress := float64(week) / float64(max(weeks, 1)) * 0.4
+		w := weight + (weightGoal-weight)*progress + s.rnd.NormFloat64()*0.4
+		s.add("weight_entries", []string{"user_id", "measured_at", "weight"},
+			id, start.AddDate(0, 0, week*7).Add(7*time.Hour+s.minutes(60)), round(w, 1),
+		)
+	}
+}
+
+func (s *seeder) seedMetrics(id int) {
+	for day := s.size.days; day > 0; day-- {
+		d := s.today.AddDate(0, 0, -day)
+		columns := []string{"user_id", "day", "metric", "value"}
+		s.add("daily_metrics", columns, id, d, "steps", 3000+s.rnd.Intn(10000))
+		s.add("daily_metrics", columns, id, d, "water_ml", 1000+250*s.rnd.Intn(8))
+		s.add("daily_metrics", columns, id, d, "sleep_minutes", 330+s.rnd.Intn(180))
+	}
+	columns := []string{"user_id", "metric", "target"}
+	s.add("metric_goals", columns, id, "steps", 8000+1000*s.rnd.Intn(5))
+	s.add("metric_goals", columns, id, "water_ml", 2000+500*s.rnd.Intn(3))
+}
+
+// seedDiary logs three meals most days and a snack on some, sized for the sex and the goal.
+func (s *seeder) seedDiary(id int, male bool, goal string) {
+	portion := 1.0
+	if male {
+		portion += 0.2
+	}
+	switch goal {
+	case "lose":
+		portion -= 0.15
+	case "gain":
+		portion += 0.15
+	}
+
+	hours := map[string]int{"breakfast": 8, "lunch": 13, "dinner": 19, "snack": 16}
+	columns := []string{"user_id", "eaten_at", "meal", "name", "kcal", "protein", "carbs", "fat", "food_id", "grams"}
+	for day := s.size.days; day > 0; day-- {
+		d := s.today.AddDate(0, 0, -day)
+		for _, meal := range []string{"breakfast", "lunch", "dinner", "snack"} {
+			if s.rnd.Intn(10) == 0 || meal == "snack" && s.rnd.Intn(2) == 0 {
+				continue
+			}
+			eatenAt := d.Add(time.Duration(hours[meal])*time.Hour + s.minutes(60))
+			for n := 1 + s.rnd.Intn(2); n > 0; n-- {
+				f := s.food(meal)
+				grams := round((f.minGrams+s.rnd.Float64()*(f.maxGrams-f.minGrams))*portion, 0)
+				s.add("diary_entries", columns,
+					id, eatenAt, meal, f.name, round(f.kcal*grams/100, 1), round(f.protein*grams/100, 1),
+					round(f.carbs*grams/100, 1), round(f.fat*grams/100, 1), f.id, grams,
+				)
+			}
+		}
+	}
+}
+
+// seedWorkouts trains two to five times a week on a routine, adding weight slowly over time.
+func (s *seeder) seedWorkouts(id int) error {
+	perWeek := 2 + s.rnd.Intn(4)
+	strength := 0.6 + s.rnd.Float64()*0.8
+
+	var days []int
+	for day := s.size.days; day > 0; day-- {
+		if s.rnd.Intn(7) < perWeek {
+			days = append(days, day)
+		}
+	}
+	ids, err := s.reserve("workouts", len(days))
+	if err != nil {
+		return err
+	}
+
+	base := map[string]float64{
+		"Bench Press": 60, "Overhead Press": 40, "Deadlift": 100, "Barbell Row": 55, "Back Squat": 80,
+	}
+	workoutColumns := []string{"id", "user_id", "name", "started_at", "duration", "distance"}
+	setColumns := []string{"workout_id", "exercise_id", "position", "reps", "weight", "rpe", "duration", "distance"}
+	for i, day := range days {
+		routine := routines[s.rnd.Intn(len(routines))]
+		startedAt := s.today.AddDate(0, 0, -day).Add(17*time.Hour + s.minutes(180))
+		progress := 1 + 0.15*float64(s.size.days-day)/float64(max(s.size.days, 1))
+
+		duration, distance, position := 0, 0, 0
+		for _, name := range routine.exercises {
+			e := s.exercises[name]
+			if e.category == "cardio" {
+				seconds := 1200 + s.rnd.Intn(2400)
+				metres := seconds * map[string]int{"Running": 3, "Cycling": 7, "Walking": 1}[name]
+				duration += seconds
+				distance += metres
+				s.add("workout_sets", setColumns, ids[i], e.id, position, 0, 0, nil, seconds, metres)
+				position++
+				continue
+			}
+			for set := 0; set < 3; set++ {
+				reps, weight := 6+s.rnd.Intn(7), 0.0
+				if e.category == "strength" {
+					weight = math.Round(base[name]*strength*progress/2.5) * 2.5
+				}
+				rpe := round(6.5+s.rnd.Float64()*3, 0)
+				s.add("workout_sets", setColumns, ids[i], e.id, position, reps, weight, rpe, 0, 0)
+				position++
+				duration += 180
+			}
+		}
+		s.add("workouts", workoutColumns, ids[i], id, routine.name, startedAt, duration, distance)
+	}
+	return nil
+}
+
+func (s *seeder) seedGoal(id int, weight, weightGoal float64, goal string) {
+	starts := s.today.AddDate(0, 0, -s.size.days)
+	columns := []string{"user_id", "kind", "target", "start_value", "starts_on", "ends_on"}
+	if goal == "maintain" {
+		s.add("goals", columns, id, "workouts_per_week", 3, nil, starts, s.today.AddDate(0, 3, 0))
+		return
+	}
+	s.add(
+		"goals", columns, id, "target_weight", round(weightGoal, 1), round(weight, 1), starts, s.today.AddDate(0, 6, 0),
+	)
+}
+
+// seedFollows follows a few other active users; most follows were accepted, some are still pending.
+func (s *seeder) seedFollows(id int) {
+	followed := []int{id}
+	for n := s.rnd.Intn(6); n > 0 && len(s.active) > 1; n-- {
+		followee := pick(s.rnd, s.active)
+		if slices.Contains(followed, followee) {
+			continue
+		}
+		followed = append(followed, followee)
+
+		createdAt := s.today.AddDate(0, 0, -1-s.rnd.Intn(s.size.days)).Add(s.minutes(12 * 60))
+		status, acceptedAt := "accepted", any(createdAt.Add(s.minutes(12*60)))
+		if s.rnd.Intn(5) == 0 {
+			status, acceptedAt = "pending", nil
+		}
+		s.add("follows", []string{"follower_id", "followee_id", "status", "created_at", "accepted_at"},
+			id, followee, status, createdAt, acceptedAt,
+		)
+	}
+}
+
+// reserve takes n ids from the sequence of the table, so rows can reference each other before
+// they are copied.
+func (s *seeder) reserve(table string, n int) ([]int, error) {
+	var ids []int
+	err := s.tx.Select(
+		&ids, "SELECT nextval(pg_get_serial_sequence($1, 'id')) FROM generate_series(1, $2)", table, n,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reserve %s ids: %w", table, err)
+	}
+	return ids, nil
+}
+
+// add buffers a row until the next flush. Rows of the same table must use the same columns.
+func (s *seeder) add(table string, columns []string, values ...any) {
+	r, ok := s.rows[table]
+	if !ok {
+		r = &rows{columns: columns}
+		s.rows[table] = r
+	}
+	r.values = append(r.values, values)
+}
+
+// flush copies the buffered rows, parents first.
+func (s *seeder) flush() error {
+	order := []string{
+		"users", "weight_entries", "daily_metrics", "metric_goals", "diary_entries", "workouts", "workout_sets",
+		"goals", "follows",
+	}
+	for _, table := range order {
+		r, ok := s.rows[table]
+		if !ok {
+			continue
+		}
+		if err := s.copy(table, r); err != nil {
+			return err
+		}
+	}
+	clear(s.rows)
+	return nil
+}
+
+func (s *seeder) copy(table string, r *rows) error {
+	stmt, err := s.tx.Prepare(pq.CopyIn(table, r.columns...))
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range r.values {
+		if _, err := stmt.Exec(row...); err != nil {
+			return fmt.Errorf("copy %s: %w", table, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+	return nil
+}
+
+// food picks a food eaten at the meal.
+func (s *seeder) food(meal string) food {
+	for {
+		f := foods[s.rnd.Intn(len(foods))]
+		if slices.Contains(f.meals, meal) {
+			return f
+		}
+	}
+}
+
+func (s *seeder) minutes(spread int) time.Duration {
+	return time.Duration(s.rnd.Intn(spread)) * time.Minute
+}
+
+func (s *seeder) uuid() string {
+	return uuid.Must(uuid.NewRandomFromReader(s.rnd)).String()
+}
+
+func pick[T any](rnd *rand.Rand, list []T) T {
+	return list[rnd.Intn(len(list))]
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestSeeder(seed int64) *seeder {
+	return &seeder{
+		rnd:   rand.New(rand.NewSource(seed)),
+		size:  size{users: 3, days: 28},
+		today: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		rows:  map[string]*rows{},
+	}
+}
+
+// history buffers the history of a user that needs no database.
+func history(s *seeder, id int) map[string]*rows {
+	s.seedWeights(id, 80, 72)
+	s.seedMetrics(id)
+	s.seedDiary(id, true, "lose")
+	s.seedGoal(id, 80, 72, "lose")
+	return s.rows
+}
+
+func TestSameSeedSameFixtures(t *testing.T) {
+	first, second := history(newTestSeeder(42), 7), history(newTestSeeder(42), 7)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("the same seed gave different fixtures")
+	}
+	if reflect.DeepEqual(first, history(newTestSeeder(43), 7)) {
+		t.Error("another seed gave the same fixtures")
+	}
+}
+
+func TestHistoryCoversTheDays(t *testing.T) {
+	s := newTestSeeder(1)
+	rows := history(s, 7)
+
+	if n := len(rows["weight_entries"].values); n != 4 {
+		t.Errorf("%d weigh-ins, want one a week for 4 weeks", n)
+	}
+	if n := len(rows["daily_metrics"].values); n != 3*28 {
+		t.Errorf("%d daily metrics, want 3 a day for 28 days", n)
+	}
+	for _, row := range rows["daily_metrics"].values {
+		if day := row[1].(time.Time); !day.Before(s.today) || day.Before(s.today.AddDate(0, 0, -28)) {
+			t.Fatalf("metric on %s, want one of the 28 days before today", day)
+		}
+	}
+	if goal := rows["goals"].values[0]; goal[1] != "target_weight" || goal[2] != 72.0 || goal[3] != 80.0 {
+		t.Errorf("goal = %v, want a target weight of 72 from 80", goal)
+	}
+}
+
+func TestDiaryMatchesFoods(t *testing.T) {
+	s := newTestSeeder(1)
+	s.seedDiary(7, false, "maintain")
+
+	byName := map[string]food{}
+	for _, f := range foods {
+		byName[f.name] = f
+	}
+	columns := s.rows["diary_entries"].columns
+	if len(s.rows["diary_entries"].values) == 0 {
+		t.Fatal("no diary entries")
+	}
+	for _, row := range s.rows["diary_entries"].values {
+		entry := map[string]any{}
+		for i, c := range columns {
+			entry[c] = row[i]
+		}
+		f, ok := byName[entry["name"].(string)]
+		if !ok {
+			t.Fatalf("entry of unknown food %v", entry["name"])
+		}
+		grams := entry["grams"].(float64)
+		if !slices.Contains(f.meals, entry["meal"].(string)) {
+			t.Errorf("%s eaten at %s", f.name, entry["meal"])
+		}
+		if grams < f.minGrams || grams > f.maxGrams {
+			t.Errorf("%v g of %s, want between %v and %v", grams, f.name, f.minGrams, f.maxGrams)
+		}
+		if kcal := round(f.kcal*grams/100, 1); entry["kcal"] != kcal {
+			t.Errorf("%v g of %s = %v kcal, want %v", grams, f.name, entry["kcal"], kcal)
+		}
+	}
+}
+
+func TestFollows(t *testing.T) {
+	s := newTestSeeder(1)
+	for id := 1; id <= 40; id++ {
+		s.active = append(s.active, id)
+	}
+	for _, id := range s.active {
+		s.seedFollows(id)
+	}
+
+	seen := map[[2]int]bool{}
+	for _, row := range s.rows["follows"].values {
+		pair := [2]int{row[0].(int), row[1].(int)}
+		if pair[0] == pair[1] {
+			t.Errorf("user %d follows themselves", pair[0])
+		}
+		if seen[pair] {
+			t.Errorf("user %d follows %d twice", pair[0], pair[1])
+		}
+		seen[pair] = true
+		if pending := row[2] == "pending"; pending != (row[4] == nil) {
+			t.Errorf("follow %v: status %s with accepted_at %v", pair, row[2], row[4])
+		}
+	}
+	if len(seen) == 0 {
+		t.Error("nobody follows anybody")
+	}
+}
+
+// TestRun seeds the database of TEST_DATABASE_URL in a transaction it rolls back, and is skipped
+// without one.
+func TestRun(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+	var seeded bool
+	err = db.Get(&seeded, "SELECT EXISTS(SELECT 1 FROM users WHERE email LIKE '%@' || $1)", emailDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeded {
+		t.Skip("the database is already seeded")
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	s := newTestSeeder(1)
+	s.tx, s.today, s.passHash = tx, time.Now().UTC().Truncate(24*time.Hour), []byte("hash")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := s.run(false, log); err != nil {
+		t.Fatal(err)
+	}
+
+	var users, admins int
+	if err := tx.Get(&users, "SELECT COUNT(*) FROM users WHERE email LIKE '%@' || $1", emailDomain); err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Get(
+		&admins,
+		"SELECT COUNT(*) FROM user_roles r JOIN users u ON u.id = r.user_id WHERE u.email = $1 AND r.role = 'admin'",
+		"admin@"+emailDomain,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users != s.size.users+1 || admins != 1 {
+		t.Errorf("seeded %d users and %d admins, want %d and 1", users, admins, s.size.users+1)
+	}
+
+	if err := s.run(false, log); err == nil {
+		t.Error("second run without reset succeeded")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/webhook"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+)
+
+// webhookstub is a local receiver to develop webhooks against. It logs every delivery, checks the
+// signature when -secret is given and fails a share of the deliveries to exercise the retries.
+// The server needs WEBHOOK_ALLOW_HTTP=true and WEBHOOK_ALLOW_PRIVATE=true to deliver to it.
+func main() {
+	var addr string
+	var secret string
+	var failRate float64
+	var tolerance time.Duration
+
+	flag.StringVar(&addr, "addr", ":9090", "address to listen on")
+	flag.StringVar(&secret, "secret", "", "webhook secret, signatures are not checked when empty")
+	flag.Float64Var(&failRate, "fail-rate", 0, "share of deliveries answered with 500, between 0 and 1")
+	flag.DurationVar(&tolerance, "tolerance", 5*time.Minute, "maximum age of a signature")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	http.HandleFunc(
+		"/", func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(
+				slog.String("event", r.Header.Get(webhook.EventHeader)),
+				slog.String("delivery", r.Header.Get(webhook.DeliveryHeader)),
+			)
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.Error("failed to read body", sl.Err(err))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if secret != "" {
+				err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, tolerance, time.Now())
+				if err != nil {
+					log.Warn("rejected delivery", sl.Err(err))
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+			}
+
+			if rand.Float64() < failRate {
+				log.Info("failing delivery on purpose")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			log.Info("delivery received", slog.String("body", string(body)))
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+
+	log.Info("Listening on", slog.String("addr", addr))
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Error("failed to start server", sl.Err(err))
+		os.Exit(1)
+	}
+}
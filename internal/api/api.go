@@ -5,11 +5,61 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jmoiron/sqlx"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	mwIdempotency "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/idempotency"
 	mwLogger "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/logger"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/achievements"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/admin"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/body"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/challenges"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/coaching"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/devicesync"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/energy"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/exports"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/foods"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/goals"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/imports"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/mealplans"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/metrics"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/recipes"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/sessions"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/tokens"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/services/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/webhooks"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/workouts"
+	achievements2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/achievements"
+	audit2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	body2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	challenges2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	coaching2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	devicesync2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/devicesync"
+	diary2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	exports2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/exports"
+	foods2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	goals2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	idempotency2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/idempotency"
+	imports2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/imports"
+	mealplans2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/mealplans"
+	metrics2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/metrics"
+	oauth2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/preferences"
+	recipes2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/roles"
+	sessions2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	social2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	tokens2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
 	users2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	webhooks2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/webhooks"
+	workouts2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
 	"log/slog"
 	"net/http"
 	"os"
@@ -41,12 +91,334 @@ func (s *Server) Run() error {
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	bus := events.New()
+
+	storage, err := blob.New(s.cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	auditStore := audit2.NewStore(s.db)
+	recorder := audit.NewRecorder(auditStore, s.log)
+	auditHandlers := audit.NewHandler(auditStore, s.log)
+
+	sessionStore := sessions2.NewStore(s.db)
+	sessionCache := sessions.NewCache(sessionStore, s.log)
+	if err := sessionCache.Load(); err != nil {
+		return err
+	}
+	go sessionCache.Run(workersCtx)
+	sessionHandlers := sessions.NewHandler(sessionStore, sessionCache, recorder, s.log)
+
 	userStore := users2.NewStore(s.db)
-	userHandlers := users.NewHandler(userStore, s.log)
+	prefsStore := preferences.NewStore(s.db)
+	roleStore := roles.NewStore(s.db)
+	userHandlers := users.NewHandler(userStore, prefsStore, roleStore, sessionStore, recorder, s.log)
+	adminHandlers := admin.NewHandler(roleStore, userStore, recorder, s.log)
+
+	workoutStore := workouts2.NewStore(s.db)
+	workoutHandlers := workouts.NewHandler(workoutStore, bus, s.log)
+
+	foodStore := foods2.NewStore(s.db)
+	foodHandlers := foods.NewHandler(foodStore, recorder, s.log)
+
+	recipeStore := recipes2.NewStore(s.db)
+	recipeHandlers := recipes.NewHandler(recipeStore, foodStore, s.log)
+
+	mealPlanStore := mealplans2.NewStore(s.db)
+	mealPlanHandlers := mealplans.NewHandler(mealPlanStore, foodStore, recipeStore, prefsStore, s.log)
+
+	diaryStore := diary2.NewStore(s.db)
+	diaryHandlers := diary.NewHandler(diaryStore, foodStore, recipeStore, bus, s.log)
+
+	metricStore := metrics2.NewStore(s.db)
+	metricHandlers := metrics.NewHandler(metricStore, bus, s.log)
 
-	router.Post("/api/register", userHandlers.HandleRegister)
+	bodyStore := body2.NewStore(s.db)
+	bodyHandlers := body.NewHandler(bodyStore, userStore, storage, bus, s.log)
+
+	goalStore := goals2.NewStore(s.db)
+	goalEvaluator := goals.NewEvaluator(
+		goalStore, userStore, workoutStore, diaryStore, metricStore, bodyStore, bus, s.log,
+	)
+	goalEvaluator.Subscribe(bus)
+	goals.NewNotifier(userStore, s.log).Subscribe(bus)
+	goalHandlers := goals.NewHandler(goalStore, goalEvaluator, s.log)
+	goalWorker := goals.NewWorker(goalStore, goalEvaluator, s.log)
+	go goalWorker.Run(workersCtx)
+
+	achievementStore := achievements2.NewStore(s.db)
+	achievements.NewEngine(achievementStore, bus, s.log).Subscribe(bus)
+	achievementHandlers := achievements.NewHandler(achievementStore, s.log)
+
+	socialStore := social2.NewStore(s.db)
+	social.NewPublisher(socialStore, workoutStore, s.log).Subscribe(bus)
+	socialHandlers := social.NewHandler(socialStore, userStore, s.log)
+
+	challengeStore := challenges2.NewStore(s.db)
+	challenges.NewScorer(challengeStore, s.log).Subscribe(bus)
+	challengeHandlers := challenges.NewHandler(challengeStore, s.log)
+	challengeWorker := challenges.NewWorker(challengeStore, s.log)
+	go challengeWorker.Run(workersCtx)
+
+	coachingStore := coaching2.NewStore(s.db)
+	coachingHandlers := coaching.NewHandler(coachingStore, userStore, workoutStore, diaryStore, recorder, s.log)
+
+	syncHandlers := devicesync.NewHandler(
+		devicesync2.NewStore(s.db), workouts.NewRecordTracker(workoutStore, bus), bus, s.log,
+	)
+
+	energyHandlers := energy.NewHandler(workoutStore, diaryStore, s.log)
+
+	importStore := imports2.NewStore(s.db)
+	importWorker := imports.NewWorker(importStore, workoutStore, bus, s.log)
+	importHandlers := imports.NewHandler(importStore, importWorker, s.log)
+	go importWorker.Run(workersCtx)
+
+	webhookStore := webhooks2.NewStore(s.db)
+	webhookWorker := webhooks.NewWorker(webhookStore, s.log)
+	webhooks.NewDispatcher(webhookStore, webhookWorker, s.log).Subscribe(bus)
+	webhookHandlers := webhooks.NewHandler(webhookStore, webhookWorker, recorder, s.log)
+	go webhookWorker.Run(workersCtx)
+
+	oauthStore := oauth2.NewStore(s.db)
+	oauthHandlers := oauth.NewHandler(oauthStore, userStore, recorder, s.log)
+
+	tokenStore := tokens2.NewStore(s.db)
+	tokenUsage := tokens.NewUsage(tokenStore, s.log)
+	go tokenUsage.Run(workersCtx)
+	tokenHandlers := tokens.NewHandler(tokenStore, tokenUsage, recorder, s.log)
+
+	exportStore := exports2.NewStore(s.db)
+	exportCollector := exports.NewCollector(
+		userStore, prefsStore, workoutStore, diaryStore, recipeStore, mealPlanStore, metricStore, bodyStore, goalStore,
+		socialStore, coachingStore, sessionStore, tokenStore, auditStore,
+	)
+	exportWorker := exports.NewWorker(exportStore, userStore, exportCollector, storage, s.log)
+	exportHandlers := exports.NewHandler(exportStore, exportWorker, storage, recorder, s.log)
+	go exportWorker.Run(workersCtx)
+
+	idempotencyStore := idempotency2.NewStore(s.db)
+	idempotent := mwIdempotency.New(idempotencyStore, s.cfg.Idempotency.TTL, s.log)
+	go mwIdempotency.NewCleaner(idempotencyStore, s.log).Run(workersCtx)
+
+	router.With(idempotent).Post("/api/register", userHandlers.HandleRegister)
 	router.Post("/api/login", userHandlers.HandleLogin)
 	router.Post("/api/activate", userHandlers.ActivateUserHandler)
+	router.Get("/api/exports/{token}", exportHandlers.HandleDownload)
+	router.Get("/api/shared/recipes/{token}", recipeHandlers.HandleGetSharedRecipe)
+	router.Get("/api/photos/{id}/{variant}", bodyHandlers.HandleServePhoto)
+	router.Post("/api/oauth/token", oauthHandlers.HandleToken)
+	router.Post("/api/oauth/introspect", oauthHandlers.HandleIntrospect)
+	router.Post("/api/oauth/revoke", oauthHandlers.HandleRevoke)
+
+	router.Group(
+		func(r chi.Router) {
+			r.Use(mwAuth.NewScoped(userStore, sessionCache, oauthStore, tokenStore, tokenUsage, s.log))
+			r.Use(idempotent)
+
+			r.With(mwAuth.RequireScope(models.ScopeProfile)).Get("/api/me/profile", userHandlers.HandleGetProfile)
+			r.With(mwAuth.RequireScope(models.ScopeWorkoutsRead)).Get("/api/workouts", workoutHandlers.HandleGetWorkouts)
+			r.With(mwAuth.RequireScope(models.ScopeWorkoutsRead)).
+				Get("/api/exercises/{id}/history", workoutHandlers.HandleGetExerciseHistory)
+			r.With(mwAuth.RequireScope(models.ScopeWorkoutsRead)).Get("/api/me/records", workoutHandlers.HandleGetRecords)
+			r.With(mwAuth.RequireScope(models.ScopeNutritionRead)).Get("/api/diary", diaryHandlers.HandleGetEntries)
+			r.With(mwAuth.RequireScope(models.ScopeWeightWrite)).
+				Post("/api/me/weight", bodyHandlers.HandleCreateWeightEntry)
+		},
+	)
+
+	router.Group(
+		func(r chi.Router) {
+			r.Use(mwAuth.New(userStore, sessionCache, s.log))
+			r.Use(idempotent)
+
+			r.Post("/api/workouts", workoutHandlers.HandleCreateWorkout)
+			r.Get("/api/exercises", workoutHandlers.HandleGetExercises)
+
+			r.Get("/api/foods", foodHandlers.HandleSearchFoods)
+			r.Post("/api/foods", foodHandlers.HandleCreateFood)
+			r.Get("/api/foods/barcode/{ean}", foodHandlers.HandleGetFoodByBarcode)
+			r.Get("/api/foods/{id}", foodHandlers.HandleGetFood)
+			r.With(mwAuth.Require(models.PermFoodsPublish)).
+				Post("/api/foods/{id}/publish", foodHandlers.HandlePublishFood)
+
+			r.Post("/api/recipes", recipeHandlers.HandleCreateRecipe)
+			r.Get("/api/recipes", recipeHandlers.HandleGetRecipes)
+			r.Get("/api/recipes/{id}", recipeHandlers.HandleGetRecipe)
+			r.Put("/api/recipes/{id}", recipeHandlers.HandleUpdateRecipe)
+			r.Delete("/api/recipes/{id}", recipeHandlers.HandleDeleteRecipe)
+			r.Post("/api/recipes/{id}/share", recipeHandlers.HandleShareRecipe)
+			r.Delete("/api/recipes/{id}/share", recipeHandlers.HandleUnshareRecipe)
+
+			r.Put("/api/me/profile", userHandlers.HandleUpdateProfile)
+			r.Put("/api/me/password", userHandlers.HandleChangePassword)
+			r.Get("/api/me/preferences", userHandlers.HandleGetPreferences)
+			r.Put("/api/me/preferences", userHandlers.HandleUpdatePreferences)
+
+			r.Post("/api/meal-plans", mealPlanHandlers.HandleCreatePlan)
+			r.Get("/api/meal-plans/{id}", mealPlanHandlers.HandleGetPlan)
+			r.Post("/api/meal-plans/{id}/swap", mealPlanHandlers.HandleSwapItem)
+
+			r.Post("/api/diary", diaryHandlers.HandleCreateEntry)
+			r.Delete("/api/diary/{id}", diaryHandlers.HandleDeleteEntry)
+
+			r.Post("/api/sync/push", syncHandlers.HandlePush)
+			r.Get("/api/sync/pull", syncHandlers.HandlePull)
+
+			r.Get("/api/me/energy", energyHandlers.HandleGetEnergy)
+
+			r.Get("/api/me/metrics", metricHandlers.HandleGetMetrics)
+			r.Put("/api/me/metrics/{date}", metricHandlers.HandleSaveDay)
+			r.Post("/api/me/metrics/sync", metricHandlers.HandleSync)
+			r.Get("/api/me/metrics/summary", metricHandlers.HandleGetSummary)
+			r.Get("/api/me/metrics/streaks", metricHandlers.HandleGetStreaks)
+			r.Get("/api/me/metrics/goals", metricHandlers.HandleGetGoals)
+			r.Put("/api/me/metrics/goals", metricHandlers.HandleSaveGoals)
+			r.Delete("/api/me/metrics/goals/{metric}", metricHandlers.HandleDeleteGoal)
+
+			r.Get("/api/me/body", bodyHandlers.HandleGetSummary)
+			r.Get("/api/me/weight", bodyHandlers.HandleGetWeightEntries)
+			r.Delete("/api/me/weight/{id}", bodyHandlers.HandleDeleteWeightEntry)
+			r.Post("/api/me/measurements", bodyHandlers.HandleCreateMeasurement)
+			r.Get("/api/me/measurements", bodyHandlers.HandleGetMeasurements)
+			r.Delete("/api/me/measurements/{id}", bodyHandlers.HandleDeleteMeasurement)
+			r.Post("/api/me/photos", bodyHandlers.HandleUploadPhoto)
+			r.Get("/api/me/photos", bodyHandlers.HandleGetPhotos)
+			r.Delete("/api/me/photos/{id}", bodyHandlers.HandleDeletePhoto)
+
+			r.Post("/api/me/goals", goalHandlers.HandleCreateGoal)
+			r.Get("/api/me/goals", goalHandlers.HandleGetGoals)
+			r.Get("/api/me/goals/{id}", goalHandlers.HandleGetGoal)
+			r.Delete("/api/me/goals/{id}", goalHandlers.HandleAbandonGoal)
+
+			r.Get("/api/me/achievements", achievementHandlers.HandleGetAchievements)
+
+			r.Get("/api/me/social", socialHandlers.HandleGetSettings)
+			r.Put("/api/me/social", socialHandlers.HandleUpdateSettings)
+			r.Get("/api/me/followers", socialHandlers.HandleGetFollowers)
+			r.Get("/api/me/following", socialHandlers.HandleGetFollowing)
+			r.Get("/api/me/follow-requests", socialHandlers.HandleGetFollowRequests)
+			r.Post("/api/me/follow-requests/{id}", socialHandlers.HandleApproveFollowRequest)
+			r.Delete("/api/me/follow-requests/{id}", socialHandlers.HandleRejectFollowRequest)
+			r.Delete("/api/me/followers/{id}", socialHandlers.HandleRejectFollowRequest)
+			r.Post("/api/users/{id}/follow", socialHandlers.HandleFollow)
+			r.Delete("/api/users/{id}/follow", socialHandlers.HandleUnfollow)
+			r.Post("/api/users/{id}/block", socialHandlers.HandleBlock)
+			r.Delete("/api/users/{id}/block", socialHandlers.HandleUnblock)
+			r.Get("/api/users/{id}/activities", socialHandlers.HandleGetUserActivities)
+			r.Get("/api/feed", socialHandlers.HandleGetFeed)
+			r.Get("/api/activities/{id}", socialHandlers.HandleGetActivity)
+			r.Patch("/api/activities/{id}", socialHandlers.HandleUpdateActivity)
+			r.Post("/api/activities/{id}/kudos", socialHandlers.HandleAddKudos)
+			r.Delete("/api/activities/{id}/kudos", socialHandlers.HandleRemoveKudos)
+			r.Get("/api/activities/{id}/comments", socialHandlers.HandleGetComments)
+			r.Post("/api/activities/{id}/comments", socialHandlers.HandleCreateComment)
+			r.Delete("/api/comments/{id}", socialHandlers.HandleDeleteComment)
+
+			r.Post("/api/challenges", challengeHandlers.HandleCreateChallenge)
+			r.Get("/api/challenges", challengeHandlers.HandleGetChallenges)
+			r.Get("/api/challenges/{id}", challengeHandlers.HandleGetChallenge)
+			r.Post("/api/challenges/{id}/join", challengeHandlers.HandleJoinChallenge)
+			r.Post("/api/challenges/{id}/leave", challengeHandlers.HandleLeaveChallenge)
+			r.Get("/api/challenges/{id}/leaderboard", challengeHandlers.HandleGetLeaderboard)
+
+			r.Get("/api/me/coaches", coachingHandlers.HandleGetCoaches)
+			r.Post("/api/me/coaches/{id}", coachingHandlers.HandleAcceptCoach)
+			r.Put("/api/me/coaches/{id}", coachingHandlers.HandleUpdateConsent)
+			r.Delete("/api/me/coaches/{id}", coachingHandlers.HandleEndCoach)
+			r.Get("/api/me/coaches/{id}/notes", coachingHandlers.HandleGetCoachNotes)
+			r.Get("/api/me/assignments", coachingHandlers.HandleGetAssignments)
+			r.Post("/api/me/assignments/{id}/complete", coachingHandlers.HandleCompleteAssignment)
+			r.Get("/api/programs", coachingHandlers.HandleGetPrograms)
+
+			r.Group(
+				func(r chi.Router) {
+					r.Use(coachingHandlers.RequireCoach)
+
+					r.Post("/api/coach/invitations", coachingHandlers.HandleInviteClient)
+					r.Get("/api/coach/invitations", coachingHandlers.HandleGetInvitations)
+					r.Get("/api/coach/clients", coachingHandlers.HandleGetClients)
+					r.Delete("/api/coach/clients/{id}", coachingHandlers.HandleEndClient)
+					r.Get("/api/coach/clients/{id}/workouts", coachingHandlers.HandleGetClientWorkouts)
+					r.Get("/api/coach/clients/{id}/diary", coachingHandlers.HandleGetClientDiary)
+					r.Post("/api/coach/clients/{id}/assignments", coachingHandlers.HandleAssignTemplate)
+					r.Get("/api/coach/clients/{id}/assignments", coachingHandlers.HandleGetClientAssignments)
+					r.Post("/api/coach/clients/{id}/notes", coachingHandlers.HandleCreateNote)
+					r.Get("/api/coach/clients/{id}/notes", coachingHandlers.HandleGetNotes)
+					r.Post("/api/coach/templates", coachingHandlers.HandleCreateTemplate)
+					r.Get("/api/coach/templates", coachingHandlers.HandleGetTemplates)
+					r.With(mwAuth.Require(models.PermProgramsPublish)).
+						Post("/api/coach/templates/{id}/publish", coachingHandlers.HandlePublishTemplate)
+				},
+			)
+
+			r.Group(
+				func(r chi.Router) {
+					r.Use(mwAuth.Require(models.PermUsersRead))
+
+					r.Get("/api/admin/permissions", adminHandlers.HandleGetPermissions)
+					r.Get("/api/admin/roles", adminHandlers.HandleGetRoles)
+					r.Get("/api/admin/users/{id}", adminHandlers.HandleGetUser)
+					r.Get("/api/admin/apps", webhookHandlers.HandleGetApps)
+					r.Get("/api/admin/apps/{app}/clients", oauthHandlers.HandleGetClients)
+					r.Get("/api/admin/apps/{app}/webhooks", webhookHandlers.HandleGetWebhooks)
+					r.Get("/api/admin/apps/{app}/webhooks/{id}/deliveries", webhookHandlers.HandleGetDeliveries)
+				},
+			)
+
+			r.Group(
+				func(r chi.Router) {
+					r.Use(mwAuth.Require(models.PermUsersWrite))
+
+					r.Post("/api/admin/roles", adminHandlers.HandleCreateRole)
+					r.Put("/api/admin/roles/{name}", adminHandlers.HandleUpdateRole)
+					r.Delete("/api/admin/roles/{name}", adminHandlers.HandleDeleteRole)
+					r.Put("/api/admin/users/{id}/roles/{role}", adminHandlers.HandleGrantRole)
+					r.Delete("/api/admin/users/{id}/roles/{role}", adminHandlers.HandleRevokeRole)
+					r.Post("/api/admin/coaches/{id}", coachingHandlers.HandleGrantCoach)
+					r.Delete("/api/admin/coaches/{id}", coachingHandlers.HandleRevokeCoach)
+					r.Post("/api/admin/apps", webhookHandlers.HandleCreateApp)
+					r.Post("/api/admin/apps/{app}/clients", oauthHandlers.HandleCreateClient)
+					r.Delete("/api/admin/apps/{app}/clients/{client}", oauthHandlers.HandleDeleteClient)
+					r.Post("/api/admin/apps/{app}/webhooks", webhookHandlers.HandleCreateWebhook)
+					r.Delete("/api/admin/apps/{app}/webhooks/{id}", webhookHandlers.HandleDeleteWebhook)
+					r.Post("/api/admin/apps/{app}/webhooks/{id}/ping", webhookHandlers.HandlePing)
+				},
+			)
+
+			r.With(mwAuth.Require(models.PermAuditRead)).Get("/api/admin/audit", auditHandlers.HandleGetEvents)
+
+			r.Post("/api/imports", importHandlers.HandleCreateImport)
+			r.Get("/api/imports/{id}", importHandlers.HandleGetImport)
+
+			r.Post("/api/me/export", exportHandlers.HandleCreateExport)
+			r.Get("/api/me/exports", exportHandlers.HandleGetExports)
+
+			r.Get("/api/me/sessions", sessionHandlers.HandleGetSessions)
+			r.Delete("/api/me/sessions/{id}", sessionHandlers.HandleDeleteSession)
+
+			r.Post("/api/me/tokens", tokenHandlers.HandleCreateToken)
+			r.Get("/api/me/tokens", tokenHandlers.HandleGetTokens)
+			r.Delete("/api/me/tokens/{id}", tokenHandlers.HandleDeleteToken)
+
+			r.Get("/api/oauth/authorize", oauthHandlers.HandleGetAuthorize)
+			r.Post("/api/oauth/authorize", oauthHandlers.HandleAuthorize)
+
+			r.Get("/api/apps", webhookHandlers.HandleGetApps)
+			r.Get("/api/me/apps", webhookHandlers.HandleGetConnectedApps)
+			r.Put("/api/me/apps/{app}", webhookHandlers.HandleConnectApp)
+			r.Delete("/api/me/apps/{app}", webhookHandlers.HandleDisconnectApp)
+			r.Post("/api/me/webhooks", webhookHandlers.HandleCreateWebhook)
+			r.Get("/api/me/webhooks", webhookHandlers.HandleGetWebhooks)
+			r.Delete("/api/me/webhooks/{id}", webhookHandlers.HandleDeleteWebhook)
+			r.Post("/api/me/webhooks/{id}/ping", webhookHandlers.HandlePing)
+			r.Get("/api/me/webhooks/{id}/deliveries", webhookHandlers.HandleGetDeliveries)
+		},
+	)
 
 	s.log.Info("Listening on", slog.String("addr", s.cfg.HttpServer.Addr))
 	done := make(chan os.Signal, 1)
@@ -70,6 +442,7 @@ func (s *Server) Run() error {
 
 	<-done
 	s.log.Info("stopping server")
+	stopWorkers()
 
 	// TODO: move timeout to config
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type (
+	ctxKey     struct{}
+	permsKey   struct{}
+	sessionKey struct{}
+	clientKey  struct{}
+	scopesKey  struct{}
+)
+
+// SessionChecker tells whether the session of a token was revoked and records the activity of
+// the session. It is called on every request, so it must not query the database.
+type SessionChecker interface {
+	Revoked(id int64) bool
+	Touch(id int64)
+}
+
+// New rejects requests without a valid token and stores the authenticated user in the request context.
+// Tokens issued to OAuth clients and personal access tokens are refused, see NewScoped.
+func New(store users.UserStore, sessions SessionChecker, log *slog.Logger) func(next http.Handler) http.Handler {
+	return authenticate(store, sessions, nil, nil, nil, log)
+}
+
+// NewScoped works like New but also accepts the tokens of OAuth clients as long as their grant is
+// active, and personal access tokens. The routes behind it declare the scope they need with RequireScope.
+func NewScoped(
+	store users.UserStore, sessions SessionChecker, grants oauth.OAuthStore, tokenStore tokens.TokenStore,
+	usage auth.TokenUsage, log *slog.Logger,
+) func(next http.Handler) http.Handler {
+	return authenticate(store, sessions, grants, tokenStore, usage, log)
+}
+
+func authenticate(
+	store users.UserStore, sessions SessionChecker, grants oauth.OAuthStore, tokenStore tokens.TokenStore,
+	usage auth.TokenUsage, log *slog.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		log.Info("auth middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var user *models.User
+			var claims *jwt.Claims
+			var err error
+
+			personal := strings.HasPrefix(auth.TokenFromRequest(r), models.PersonalTokenPrefix)
+			switch {
+			case personal && tokenStore == nil:
+				resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "not available to personal tokens"})
+				return
+			case personal:
+				user, claims, err = auth.AuthenticatePersonal(r, store, tokenStore, usage)
+			default:
+				user, claims, err = auth.Authenticate(r, store)
+			}
+			if err != nil {
+				if errors.Is(err, auth.ErrTokenNotFound) || errors.Is(err, auth.ErrInvalidToken) ||
+					errors.Is(err, auth.ErrUserNotFound) {
+					resp.JSON(w, r, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+					return
+				}
+				log.Error(
+					"failed to authenticate user", sl.Err(err),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				resp.Internal(w, r)
+				return
+			}
+
+			if claims.ClientID != "" {
+				if grants == nil {
+					resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "not available to apps"})
+					return
+				}
+				active, err := grants.IsActive(claims.GrantID)
+				if err != nil {
+					log.Error(
+						"failed to check grant", sl.Err(err),
+						slog.String("request_id", middleware.GetReqID(r.Context())),
+					)
+					resp.Internal(w, r)
+					return
+				}
+				if !active {
+					resp.JSON(w, r, http.StatusUnauthorized, map[string]string{"error": auth.ErrInvalidToken.Error()})
+					return
+				}
+			}
+
+			// Every login opens a session. A login token without one was issued before sessions were
+			// tracked and could not be revoked, so it is refused like an expired one.
+			if !personal && claims.ClientID == "" && claims.SessionID == 0 {
+				resp.JSON(w, r, http.StatusUnauthorized, map[string]string{"error": auth.ErrInvalidToken.Error()})
+				return
+			}
+			if claims.SessionID != 0 {
+				if sessions.Revoked(claims.SessionID) {
+					resp.JSON(w, r, http.StatusUnauthorized, map[string]string{"error": "session revoked"})
+					return
+				}
+				sessions.Touch(claims.SessionID)
+			}
+
+			ctx := context.WithValue(r.Context(), ctxKey{}, user)
+			ctx = context.WithValue(ctx, permsKey{}, claims.Permissions)
+			ctx = context.WithValue(ctx, sessionKey{}, claims.SessionID)
+			ctx = context.WithValue(ctx, clientKey{}, claims.ClientID)
+			if personal || claims.ClientID != "" {
+				ctx = context.WithValue(ctx, scopesKey{}, claims.Scopes)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// User returns the user stored by the auth middleware.
+func User(ctx context.Context) *models.User {
+	u, _ := ctx.Value(ctxKey{}).(*models.User)
+	return u
+}
+
+// Permissions returns the permissions carried by the token of the request.
+func Permissions(ctx context.Context) []string {
+	perms, _ := ctx.Value(permsKey{}).([]string)
+	return perms
+}
+
+// Can reports whether the token of the request carries the permission.
+func Can(ctx context.Context, permission string) bool {
+	return slices.Contains(Permissions(ctx), permission)
+}
+
+// Require rejects the requests whose token lacks one of the permissions. It goes after New.
+func Require(permissions ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range permissions {
+				if !Can(r.Context(), p) {
+					resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "missing permission " + p})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Session returns the session of the token of the request, 0 when the token has none.
+func Session(ctx context.Context) int64 {
+	id, _ := ctx.Value(sessionKey{}).(int64)
+	return id
+}
+
+// Client returns the OAuth client the token of the request was issued to, empty for the
+// tokens of the user.
+func Client(ctx context.Context) string {
+	c, _ := ctx.Value(clientKey{}).(string)
+	return c
+}
+
+// RequireScope rejects the tokens of OAuth clients and the personal access tokens that lack the
+// scope. The tokens the user logged in with have every scope. It goes after NewScoped.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if scopes, scoped := r.Context().Value(scopesKey{}).([]string); scoped {
+				if !slices.Contains(scopes, scope) {
+					resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "missing scope " + scope})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func TestMain(m *testing.M) {
+	config.Envs.JwtCfg.Secret = testSecret
+	os.Exit(m.Run())
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	if id != 7 {
+		return nil, users.UserNotFound
+	}
+	return &models.User{ID: id, Username: "ann"}, nil
+}
+
+// fakeTokens holds personal tokens by secret. Like the Postgres store, it does not find expired
+// tokens, and a revoked token is deleted.
+type fakeTokens struct {
+	tokens.TokenStore
+	bySecret map[string]*models.PersonalToken
+}
+
+func (s fakeTokens) GetTokenBySecret(token string) (*models.PersonalToken, error) {
+	t, ok := s.bySecret[token]
+	if !ok || t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return nil, tokens.TokenNotFound
+	}
+	return t, nil
+}
+
+type fakeSessions struct{ revoked map[int64]bool }
+
+func (s fakeSessions) Revoked(id int64) bool {
+	return s.revoked[id]
+}
+
+func (fakeSessions) Touch(int64) {}
+
+type fakeUsage struct{ touched []int }
+
+func (u *fakeUsage) Touch(id int) {
+	u.touched = append(u.touched, id)
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func request(h http.Handler, token string) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func loginToken(t *testing.T, sessionID int64, permissions []string) string {
+	t.Helper()
+	token, err := jwt.NewToken(models.User{ID: 7}, sessionID, permissions, time.Minute, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestPersonalTokens(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	store := fakeTokens{
+		bySecret: map[string]*models.PersonalToken{
+			"afp_read":    {ID: 1, UserID: 7, Scopes: []string{models.ScopeWorkoutsRead}},
+			"afp_both":    {ID: 2, UserID: 7, Scopes: []string{models.ScopeWeightWrite, models.ScopeWorkoutsRead}},
+			"afp_later":   {ID: 3, UserID: 7, Scopes: []string{models.ScopeWorkoutsRead}, ExpiresAt: &later},
+			"afp_expired": {ID: 4, UserID: 7, Scopes: []string{models.ScopeWorkoutsRead}, ExpiresAt: &expired},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		scope      string
+		wantStatus int
+	}{
+		{name: "within scope", token: "afp_read", scope: models.ScopeWorkoutsRead, wantStatus: http.StatusOK},
+		{name: "missing scope", token: "afp_read", scope: models.ScopeWeightWrite, wantStatus: http.StatusForbidden},
+		{name: "one of several scopes", token: "afp_both", scope: models.ScopeWeightWrite, wantStatus: http.StatusOK},
+		{name: "not expired yet", token: "afp_later", scope: models.ScopeWorkoutsRead, wantStatus: http.StatusOK},
+		{name: "expired", token: "afp_expired", scope: models.ScopeWorkoutsRead, wantStatus: http.StatusUnauthorized},
+		{name: "revoked", token: "afp_deleted", scope: models.ScopeWorkoutsRead, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				usage := &fakeUsage{}
+				mw := NewScoped(fakeUsers{}, fakeSessions{}, nil, store, usage, discard)
+				h := mw(RequireScope(tt.scope)(http.HandlerFunc(ok)))
+
+				if got := request(h, tt.token); got != tt.wantStatus {
+					t.Errorf("status = %d, want %d", got, tt.wantStatus)
+				}
+				if touched := len(usage.touched) > 0; touched != (tt.wantStatus != http.StatusUnauthorized) {
+					t.Errorf("usage recorded = %v for status %d", touched, tt.wantStatus)
+				}
+			},
+		)
+	}
+}
+
+func TestPersonalTokenRefusedByNew(t *testing.T) {
+	h := New(fakeUsers{}, fakeSessions{}, discard)(http.HandlerFunc(ok))
+
+	if got := request(h, "afp_read"); got != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", got)
+	}
+}
+
+func TestLoginTokenHasEveryScope(t *testing.T) {
+	mw := NewScoped(fakeUsers{}, fakeSessions{}, nil, fakeTokens{}, &fakeUsage{}, discard)
+
+	for _, scope := range models.OAuthScopes {
+		h := mw(RequireScope(scope)(http.HandlerFunc(ok)))
+		if got := request(h, loginToken(t, 1, nil)); got != http.StatusOK {
+			t.Errorf("scope %s: status = %d, want 200", scope, got)
+		}
+	}
+}
+
+func TestLoginTokenSession(t *testing.T) {
+	sessions := fakeSessions{revoked: map[int64]bool{2: true}}
+	h := New(fakeUsers{}, sessions, discard)(http.HandlerFunc(ok))
+
+	tests := []struct {
+		name       string
+		sessionID  int64
+		wantStatus int
+	}{
+		{name: "active session", sessionID: 1, wantStatus: http.StatusOK},
+		{name: "revoked session", sessionID: 2, wantStatus: http.StatusUnauthorized},
+		{name: "no session", sessionID: 0, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := request(h, loginToken(t, tt.sessionID, nil)); got != tt.wantStatus {
+					t.Errorf("status = %d, want %d", got, tt.wantStatus)
+				}
+			},
+		)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	mw := New(fakeUsers{}, fakeSessions{}, discard)
+
+	tests := []struct {
+		name        string
+		permissions []string
+		require     string
+		wantStatus  int
+	}{
+		{
+			name:        "granted",
+			permissions: []string{models.PermUsersRead, models.PermUsersWrite},
+			require:     models.PermUsersWrite,
+			wantStatus:  http.StatusOK,
+		},
+		{name: "no permissions", require: models.PermUsersRead, wantStatus: http.StatusForbidden},
+		{
+			name:        "another permission",
+			permissions: []string{models.PermUsersRead},
+			require:     models.PermAuditRead,
+			wantStatus:  http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				h := mw(Require(tt.require)(http.HandlerFunc(ok)))
+
+				if got := request(h, loginToken(t, 1, tt.permissions)); got != tt.wantStatus {
+					t.Errorf("status = %d, want %d", got, tt.wantStatus)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/idempotency"
+	"log/slog"
+	"time"
+)
+
+const cleanInterval = time.Hour
+
+// Cleaner deletes the expired keys. Until then they are only taken over when reused.
+type Cleaner struct {
+	store idempotency.IdempotencyStore
+	log   *slog.Logger
+}
+
+func NewCleaner(store idempotency.IdempotencyStore, log *slog.Logger) *Cleaner {
+	return &Cleaner{store: store, log: log.With(slog.String("component", "idempotency/cleaner"))}
+}
+
+func (c *Cleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := c.store.DeleteExpired()
+		if err != nil {
+			c.log.Error("failed to delete expired keys", sl.Err(err))
+		} else if n > 0 {
+			c.log.Info("expired keys deleted", slog.Int64("keys", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/idempotency"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+	maxKeyLength   = 255
+	// maxBodySize bounds the body buffered to hash the request. It is the largest body any route
+	// accepts, a track file import; the handlers still apply their own, smaller, limits.
+	maxBodySize = 32 << 20
+	// lockTimeout is how long a request holds its key before a retry may take it over.
+	lockTimeout = time.Minute
+)
+
+var inProgress = map[string]string{"error": "request with this idempotency key is in progress"}
+
+// New makes mutating requests carrying an Idempotency-Key header safe to retry. The first request
+// with a key is handled and its response kept for ttl; retries with the same method, path and body
+// get that response replayed, while reusing the key for a different request is rejected with 422.
+// A retry arriving while the first request is still running gets 409, until lockTimeout passed.
+// Keys are scoped to the authenticated user, so behind mwAuth.New it must go after it; anonymous
+// requests share one scope and must use UUID keys, which other clients cannot collide with.
+func New(store idempotency.IdempotencyStore, ttl time.Duration, log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/idempotency"),
+		)
+
+		log.Info("idempotency middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+			if key == "" || safe {
+				next.ServeHTTP(w, r)
+				return
+			}
+			log := log.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+
+			if len(key) > maxKeyLength {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "idempotency key is too long"})
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					resp.JSON(
+						w, r, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body is too large"},
+					)
+					return
+				}
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := "anonymous"
+			if user := mwAuth.User(r.Context()); user != nil {
+				scope = "user:" + strconv.Itoa(user.ID)
+			} else if _, err := uuid.Parse(key); err != nil {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "idempotency key must be a UUID"})
+				return
+			}
+			hash := requestHash(r, body)
+
+			now := time.Now()
+			stored, reserved, err := store.Reserve(scope, key, hash, now.Add(lockTimeout), now.Add(ttl))
+			if err != nil {
+				if errors.Is(err, idempotency.KeyNotFound) {
+					resp.JSON(w, r, http.StatusConflict, inProgress)
+					return
+				}
+				log.Error("failed to reserve idempotency key", sl.Err(err))
+				resp.Internal(w, r)
+				return
+			}
+			if !reserved {
+				switch {
+				case stored.RequestHash != hash:
+					resp.JSON(
+						w, r, http.StatusUnprocessableEntity,
+						map[string]string{"error": "idempotency key was used for a different request"},
+					)
+				case stored.StatusCode == nil:
+					resp.JSON(w, r, http.StatusConflict, inProgress)
+				default:
+					replay(w, stored, log)
+				}
+				return
+			}
+
+			completed := false
+			defer func() {
+				// The request failed or panicked: free the key for the retry.
+				if !completed {
+					if err := store.Release(stored); err != nil {
+						log.Error("failed to release idempotency key", sl.Err(err))
+					}
+				}
+			}()
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			header, err := json.Marshal(w.Header())
+			if err != nil {
+				log.Error("failed to marshal response header", sl.Err(err))
+				return
+			}
+			if err := store.Complete(stored, status, header, buf.Bytes()); err != nil {
+				log.Error("failed to save idempotent response", sl.Err(err))
+				return
+			}
+			completed = true
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// requestHash identifies a request by what it asks for, so that only exact retries are replayed.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, stored *models.IdempotencyKey, log *slog.Logger) {
+	var header http.Header
+	if err := json.Unmarshal(stored.Header, &header); err != nil {
+		log.Error("failed to unmarshal stored header", sl.Err(err))
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(*stored.StatusCode)
+	if _, err := w.Write(stored.Body); err != nil {
+		log.Error("failed to write stored response", sl.Err(err))
+	}
+}
@@ -0,0 +1,260 @@
+package idempotency
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/idempotency"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testKey = "5f0c6a4e-7d1b-4b8e-9a43-0e6f1c2d3b4a"
+	testTTL = time.Hour
+)
+
+// fakeStore keeps the keys in memory with the semantics of the Postgres store, against a clock
+// the tests move.
+type fakeStore struct {
+	mu   sync.Mutex
+	now  time.Time
+	keys map[string]*models.IdempotencyKey
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{now: time.Now(), keys: map[string]*models.IdempotencyKey{}}
+}
+
+func (s *fakeStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *fakeStore) Reserve(
+	scope string, key string, requestHash string, lockedUntil time.Time, expiresAt time.Time,
+) (*models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[scope+" "+key]
+	if ok && !k.ExpiresAt.Before(s.now) && (k.StatusCode != nil || k.LockedUntil.After(s.now)) {
+		held := *k
+		return &held, false, nil
+	}
+	// The middleware computes the deadlines from the real clock; shift them onto the fake one.
+	offset := s.now.Sub(time.Now())
+	k = &models.IdempotencyKey{
+		Scope: scope, Key: key, RequestHash: requestHash, CreatedAt: s.now,
+		LockedUntil: lockedUntil.Add(offset), ExpiresAt: expiresAt.Add(offset),
+	}
+	s.keys[scope+" "+key] = k
+	reserved := *k
+	return &reserved, true, nil
+}
+
+func (s *fakeStore) Complete(k *models.IdempotencyKey, statusCode int, header []byte, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if held, ok := s.keys[k.Scope+" "+k.Key]; ok && held.CreatedAt.Equal(k.CreatedAt) {
+		held.StatusCode, held.Header, held.Body = &statusCode, header, body
+	}
+	return nil
+}
+
+func (s *fakeStore) Release(k *models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if held, ok := s.keys[k.Scope+" "+k.Key]; ok && held.CreatedAt.Equal(k.CreatedAt) && held.StatusCode == nil {
+		delete(s.keys, k.Scope+" "+k.Key)
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteExpired() (int64, error) {
+	return 0, nil
+}
+
+var _ idempotency.IdempotencyStore = (*fakeStore)(nil)
+
+// counter answers 201 with the number of times it ran.
+type counter struct {
+	calls atomic.Int32
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := c.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, `{"call":`+strconv.Itoa(int(n))+`}`)
+}
+
+func newTestHandler(store *fakeStore, next http.Handler) http.Handler {
+	return New(store, testTTL, slog.New(slog.NewTextHandler(io.Discard, nil)))(next)
+}
+
+func send(h http.Handler, key string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/workouts", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	next := &counter{}
+	h := newTestHandler(newFakeStore(), next)
+
+	first := send(h, testKey, `{"name":"push"}`)
+	second := send(h, testKey, `{"name":"push"}`)
+
+	if first.Code != http.StatusCreated || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf(
+			"first response = %d replayed %q, want 201 not replayed", first.Code, first.Header().Get(ReplayedHeader),
+		)
+	}
+	if second.Code != http.StatusCreated || second.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry = %d replayed %q, want 201 replayed", second.Code, second.Header().Get(ReplayedHeader))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("retry body = %s, want %s", second.Body, first.Body)
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry Content-Type = %q, want the stored one", second.Header().Get("Content-Type"))
+	}
+	if n := next.calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestKeyReusedForDifferentRequest(t *testing.T) {
+	next := &counter{}
+	h := newTestHandler(newFakeStore(), next)
+
+	send(h, testKey, `{"name":"push"}`)
+	w := send(h, testKey, `{"name":"pull"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %d, want 422", w.Code)
+	}
+	if n := next.calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestRequestInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	next := &counter{}
+	slow := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+			next.ServeHTTP(w, r)
+		},
+	)
+	store := newFakeStore()
+	h := newTestHandler(store, slow)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- send(h, testKey, `{}`)
+	}()
+	<-entered
+
+	if w := send(h, testKey, `{}`); w.Code != http.StatusConflict {
+		t.Errorf("retry while in flight = %d, want 409", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("first request = %d, want 201", w.Code)
+	}
+	if w := send(h, testKey, `{}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry after completion = %d replayed %q, want 201 replayed", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if n := next.calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		// after is how long after the first request the retry arrives.
+		after     time.Duration
+		wantCalls int32
+	}{
+		{name: "within ttl", after: testTTL - time.Minute, wantCalls: 1},
+		{name: "after ttl", after: testTTL + time.Minute, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				next := &counter{}
+				store := newFakeStore()
+				h := newTestHandler(store, next)
+
+				send(h, testKey, `{}`)
+				store.advance(tt.after)
+				w := send(h, testKey, `{}`)
+
+				if w.Code != http.StatusCreated {
+					t.Errorf("retry = %d, want 201", w.Code)
+				}
+				if n := next.calls.Load(); n != tt.wantCalls {
+					t.Errorf("handler ran %d times, want %d", n, tt.wantCalls)
+				}
+				if replayed := w.Header().Get(ReplayedHeader) == "true"; replayed != (tt.wantCalls == 1) {
+					t.Errorf("retry replayed = %v, want %v", replayed, tt.wantCalls == 1)
+				}
+			},
+		)
+	}
+}
+
+func TestFailedRequestReleasesKey(t *testing.T) {
+	var calls atomic.Int32
+	failing := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		},
+	)
+	h := newTestHandler(newFakeStore(), failing)
+
+	if w := send(h, testKey, `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request = %d, want 500", w.Code)
+	}
+	if w := send(h, testKey, `{}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf(
+			"retry after a failure = %d replayed %q, want 201 not replayed", w.Code, w.Header().Get(ReplayedHeader),
+		)
+	}
+}
+
+func TestAnonymousKeyMustBeUUID(t *testing.T) {
+	next := &counter{}
+	h := newTestHandler(newFakeStore(), next)
+
+	if w := send(h, "retry-1", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("anonymous non-UUID key = %d, want 400", w.Code)
+	}
+	if n := next.calls.Load(); n != 0 {
+		t.Errorf("handler ran %d times, want 0", n)
+	}
+}
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
 	"os"
 	"strconv"
@@ -12,11 +12,21 @@ type Config struct {
 	DbCfg  DbConfig
 	JwtCfg JWTConfig
 	Env    string
+	// AppURL is the public base URL of the API, which the links given to users start with.
+	AppURL string
 	HttpServer
 	Email
+	Export
+	Storage
+	Audit
+	Idempotency
+	Webhooks
+	OAuth
+	Migrations
 }
 
-var Envs = initConfig()
+// Envs holds the configuration loaded by MustLoad.
+var Envs Config
 
 type DbConfig struct {
 	Host     string
@@ -44,12 +54,84 @@ type Email struct {
 	Password string
 }
 
-func initConfig() Config {
-	var envPath string
+type Export struct {
+	TTL time.Duration
+}
+
+// Storage selects where uploaded files live: "local" keeps them under Dir, "s3" sends them to
+// any S3-compatible service. URLTTL is the lifetime of the signed download links.
+type Storage struct {
+	Driver      string
+	Dir         string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+	URLTTL      time.Duration
+}
+
+// Audit holds how long audit events are kept before the purge command deletes them.
+type Audit struct {
+	Retention time.Duration
+}
+
+// Idempotency holds how long the responses of requests made with an Idempotency-Key are kept.
+type Idempotency struct {
+	TTL time.Duration
+}
+
+// Webhooks configures the deliveries: a delivery is given up after MaxAttempts, each attempt
+// waiting at most DeliveryTimeout. AllowHTTP accepts plain http URLs and AllowPrivate loopback
+// and private addresses, e.g. to test against a local stub.
+type Webhooks struct {
+	MaxAttempts     int
+	DeliveryTimeout time.Duration
+	AllowHTTP       bool
+	AllowPrivate    bool
+}
 
-	flag.StringVar(&envPath, "env-path", "", "path to .env file")
-	flag.Parse()
+// OAuth holds the lifetimes of the tokens issued to partner apps.
+type OAuth struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Migrations makes the app apply the pending migrations when it starts, instead of leaving
+// them to the migrator.
+type Migrations struct {
+	AutoMigrate bool
+}
+
+// LoadDB reads the database settings only, for commands that need nothing else. The .env file at
+// envPath is optional: without one the settings come from the environment alone.
+func LoadDB(envPath string) (DbConfig, error) {
+	if envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return DbConfig{}, fmt.Errorf("cannot load %s: %w", envPath, err)
+		}
+	}
+	return dbFromEnv()
+}
+
+func dbFromEnv() (DbConfig, error) {
+	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+	if err != nil {
+		return DbConfig{}, fmt.Errorf("invalid DB_PORT %q", os.Getenv("DB_PORT"))
+	}
+	return DbConfig{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     port,
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		Name:     os.Getenv("DB_NAME"),
+	}, nil
+}
 
+// MustLoad reads the .env file at envPath into Envs. Commands parse their own flags
+// and call it from main, so importing the package has no side effects.
+func MustLoad(envPath string) Config {
 	if envPath == "" {
 		panic("env-path is required")
 	}
@@ -59,18 +141,9 @@ func initConfig() Config {
 		panic("cannot to load the .env file")
 	}
 
-	dbCfg := DbConfig{
-		Host: os.Getenv("DB_HOST"),
-		Port: func() int {
-			port, err := strconv.Atoi(os.Getenv("DB_PORT"))
-			if err != nil {
-				panic("cannot to convert DB_PORT of database")
-			}
-			return port
-		}(),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		Name:     os.Getenv("DB_NAME"),
+	dbCfg, err := dbFromEnv()
+	if err != nil {
+		panic("cannot to convert DB_PORT of database")
 	}
 
 	jwtCfg := JWTConfig{
@@ -117,12 +190,121 @@ func initConfig() Config {
 		Password: os.Getenv("EMAIL_PASSWORD"),
 	}
 
+	export := Export{
+		TTL: func() time.Duration {
+			ttl, err := time.ParseDuration(os.Getenv("EXPORT_TTL"))
+			if err != nil {
+				return 48 * time.Hour
+			}
+			return ttl
+		}(),
+	}
+
+	storage := Storage{
+		Driver: func() string {
+			driver := os.Getenv("STORAGE_DRIVER")
+			if driver == "" {
+				return "local"
+			}
+			return driver
+		}(),
+		Dir: func() string {
+			dir := os.Getenv("STORAGE_DIR")
+			if dir == "" {
+				return "./uploads"
+			}
+			return dir
+		}(),
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		URLTTL: func() time.Duration {
+			ttl, err := time.ParseDuration(os.Getenv("STORAGE_URL_TTL"))
+			if err != nil {
+				return 15 * time.Minute
+			}
+			return ttl
+		}(),
+	}
+
+	audit := Audit{
+		Retention: func() time.Duration {
+			retention, err := time.ParseDuration(os.Getenv("AUDIT_RETENTION"))
+			if err != nil {
+				return 365 * 24 * time.Hour
+			}
+			return retention
+		}(),
+	}
+
+	idempotency := Idempotency{
+		TTL: func() time.Duration {
+			ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+			if err != nil {
+				return 24 * time.Hour
+			}
+			return ttl
+		}(),
+	}
+
+	webhooks := Webhooks{
+		MaxAttempts: func() int {
+			attempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+			if err != nil || attempts <= 0 {
+				return 8
+			}
+			return attempts
+		}(),
+		DeliveryTimeout: func() time.Duration {
+			timeout, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT"))
+			if err != nil {
+				return 10 * time.Second
+			}
+			return timeout
+		}(),
+		AllowHTTP:    os.Getenv("WEBHOOK_ALLOW_HTTP") == "true",
+		AllowPrivate: os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true",
+	}
+
+	oauth := OAuth{
+		AccessTTL: func() time.Duration {
+			ttl, err := time.ParseDuration(os.Getenv("OAUTH_ACCESS_TTL"))
+			if err != nil {
+				return time.Hour
+			}
+			return ttl
+		}(),
+		RefreshTTL: func() time.Duration {
+			ttl, err := time.ParseDuration(os.Getenv("OAUTH_REFRESH_TTL"))
+			if err != nil {
+				return 30 * 24 * time.Hour
+			}
+			return ttl
+		}(),
+	}
+
+	migrations := Migrations{
+		AutoMigrate: os.Getenv("AUTO_MIGRATE") == "true",
+	}
+
 	env := os.Getenv("ENV")
-	return Config{
-		DbCfg:      dbCfg,
-		JwtCfg:     jwtCfg,
-		Env:        env,
-		HttpServer: httpServer,
-		Email:      email,
+	Envs = Config{
+		DbCfg:       dbCfg,
+		JwtCfg:      jwtCfg,
+		Env:         env,
+		AppURL:      os.Getenv("APP_URL"),
+		HttpServer:  httpServer,
+		Email:       email,
+		Export:      export,
+		Storage:     storage,
+		Audit:       audit,
+		Idempotency: idempotency,
+		Webhooks:    webhooks,
+		OAuth:       oauth,
+		Migrations:  migrations,
 	}
+	return Envs
 }
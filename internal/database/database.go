@@ -1,20 +1,29 @@
 package database
 
 import (
-	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
-	"log"
+	"net"
+	"net/url"
+	"strconv"
 )
 
+// URL is the connection URL of the database, as used by the app and the migrator. The
+// credentials are escaped, so passwords may contain any character.
+func URL(dbConfig config.DbConfig) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(dbConfig.User, dbConfig.Password),
+		Host:     net.JoinHostPort(dbConfig.Host, strconv.Itoa(dbConfig.Port)),
+		Path:     "/" + dbConfig.Name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
 func New(dbConfig config.DbConfig) (*sqlx.DB, error) {
-	dbURL := fmt.Sprintf(
-		"postgres://%s:%s@%s:5432/%s?sslmode=disable", dbConfig.User, dbConfig.Password, dbConfig.Host,
-		dbConfig.Name,
-	)
-	log.Print(dbURL)
-	db, err := sqlx.Connect("postgres", dbURL)
+	db, err := sqlx.Connect("postgres", URL(dbConfig))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/migrations"
+	"io/fs"
+)
+
+// migrationLock is the key of the advisory lock held while the schema is checked and migrated,
+// so replicas starting together do not race.
+const migrationLock = 4_148_270_301
+
+var (
+	ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+	ErrSchemaDirty  = errors.New("database schema is dirty, fix it with the migrator")
+)
+
+// Source opens the migrations embedded into the binary.
+func Source() (source.Driver, error) {
+	return iofs.New(migrations.FS, ".")
+}
+
+// Migrate compares the schema of the database with the embedded migrations and applies the
+// pending ones when apply is set. It fails with ErrSchemaTooNew when a newer binary already
+// migrated the database. It returns the version of the schema and whether migrations are pending.
+func Migrate(db *sqlx.DB, apply bool) (uint, bool, error) {
+	const op = "database.Migrate"
+	ctx := context.Background()
+
+	lock, err := db.Conn(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer lock.Close()
+
+	if _, err := lock.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return 0, false, fmt.Errorf("%s: cannot lock: %w", op, err)
+	}
+	defer lock.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	src, err := Source()
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	latest, err := latestVersion(src)
+	if err != nil {
+		src.Close()
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The driver closes its connection, which must not be the one holding the lock.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		src.Close()
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		src.Close()
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		src.Close()
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if version > latest {
+		return version, false, fmt.Errorf("%s: %w: version %d, latest known %d", op, ErrSchemaTooNew, version, latest)
+	}
+	if dirty {
+		return version, false, fmt.Errorf("%s: %w: version %d", op, ErrSchemaDirty, version)
+	}
+	if version == latest {
+		return version, false, nil
+	}
+	if !apply {
+		return version, true, nil
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return version, true, fmt.Errorf("%s: %w", op, err)
+	}
+	return latest, false, nil
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
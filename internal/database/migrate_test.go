@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	// addCoachingVersion is the migration before add-roles, which replaces the superuser flag.
+	addCoachingVersion = 20240919103044
+	addRolesVersion    = 20240923141207
+)
+
+// TestAddRolesMapsSuperusers runs the migrations up to add-roles in a schema of its own, so it does not
+// touch the tables of the database of TEST_DATABASE_URL. It is skipped without one.
+func TestAddRolesMapsSuperusers(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("roles_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "SET search_path TO "+schema); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Migrate(addCoachingVersion); err != nil {
+		t.Fatal(err)
+	}
+	var superuser, member int
+	err = conn.QueryRowContext(
+		ctx,
+		"INSERT INTO users(email, username, password, activation_code, is_superuser) "+
+			"VALUES('root@example.com', 'root', '\\x00', '', true) RETURNING id",
+	).Scan(&superuser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.QueryRowContext(
+		ctx,
+		"INSERT INTO users(email, username, password, activation_code) "+
+			"VALUES('ann@example.com', 'ann', '\\x00', '') RETURNING id",
+	).Scan(&member)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(addRolesVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[int]bool{superuser: true, member: false} {
+		var admin bool
+		err := conn.QueryRowContext(
+			ctx, "SELECT EXISTS (SELECT 1 FROM user_roles WHERE user_id = $1 AND role = 'admin')", id,
+		).Scan(&admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admin != want {
+			t.Errorf("user %d admin = %v, want %v", id, admin, want)
+		}
+	}
+
+	var perms int
+	err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM role_permissions WHERE role = 'admin'").Scan(&perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perms != 5 {
+		t.Errorf("admin has %d permissions, want all 5", perms)
+	}
+}
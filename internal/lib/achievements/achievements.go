@@ -0,0 +1,57 @@
+package achievements
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+// Value is what the rule measures in the stats, zero for unknown rules so that
+// definitions added for a newer version never unlock.
+func Value(rule string, s models.AchievementStats) float64 {
+	switch rule {
+	case models.AchievementRuleWorkoutCount:
+		return float64(s.Workouts)
+	case models.AchievementRuleRecordCount:
+		return float64(s.Records)
+	case models.AchievementRuleActivityStreak:
+		return float64(LongestStreak(s.ActivityDays))
+	case models.AchievementRuleDailySteps:
+		return s.MaxDailySteps
+	}
+	return 0
+}
+
+// Earned returns the definitions whose threshold the stats reach.
+func Earned(defs []models.Achievement, s models.AchievementStats) []models.Achievement {
+	var res []models.Achievement
+	for _, d := range defs {
+		if Value(d.Rule, s) >= d.Threshold {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// LongestStreak is the longest run of consecutive days in days, sorted YYYY-MM-DD dates.
+func LongestStreak(days []string) int {
+	longest, current := 0, 0
+	var prev time.Time
+	for _, d := range days {
+		t, err := time.Parse(daterange.Layout, d)
+		if err != nil {
+			continue
+		}
+		switch {
+		case current > 0 && t.Equal(prev):
+			continue
+		case current > 0 && t.Equal(prev.AddDate(0, 0, 1)):
+			current++
+		default:
+			current = 1
+		}
+		prev = t
+		longest = max(longest, current)
+	}
+	return longest
+}
@@ -0,0 +1,46 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"io"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Storage keeps uploaded files. Keys are slash separated relative paths such as "photos/7/abc.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage selected by the configuration.
+func New(cfg config.Storage) (Storage, error) {
+	switch cfg.Driver {
+	case DriverLocal:
+		return NewLocal(cfg.Dir), nil
+	case DriverS3:
+		return NewS3(
+			S3Config{
+				Endpoint:  cfg.S3Endpoint,
+				Region:    cfg.S3Region,
+				Bucket:    cfg.S3Bucket,
+				AccessKey: cfg.S3AccessKey,
+				SecretKey: cfg.S3SecretKey,
+				PathStyle: cfg.S3PathStyle,
+			},
+		)
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
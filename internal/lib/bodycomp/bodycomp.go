@@ -0,0 +1,44 @@
+package bodycomp
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"math"
+)
+
+// Indicators derives the body composition indicators from a measurement, the weight in kg
+// at that time and the height in cm. Ratios need the matching circumferences and the lean
+// mass needs a body fat percentage, otherwise they are left out.
+func Indicators(height int, weight float64, m models.BodyMeasurement) models.BodyIndicators {
+	res := models.BodyIndicators{Weight: weight, BMI: BMI(height, weight)}
+
+	if m.Waist != nil && height > 0 {
+		v := round(*m.Waist/float64(height), 2)
+		res.WaistToHeight = &v
+	}
+	if m.Waist != nil && m.Hips != nil && *m.Hips > 0 {
+		v := round(*m.Waist / *m.Hips, 2)
+		res.WaistToHip = &v
+	}
+	if m.BodyFat != nil {
+		fat := weight * *m.BodyFat / 100
+		lean := round(weight-fat, 1)
+		fat = round(fat, 1)
+		res.FatMass = &fat
+		res.LeanMass = &lean
+	}
+	return res
+}
+
+// BMI returns the body mass index in kg/m², rounded to one decimal.
+func BMI(height int, weight float64) float64 {
+	if height <= 0 {
+		return 0
+	}
+	m := float64(height) / 100
+	return round(weight/(m*m), 1)
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,44 @@
+package coalesce
+
+import "sync"
+
+// Group runs at most one call per key at a time. Calls made for a key while it runs are folded
+// into a single run that follows, so bursts of events cause two runs instead of one per event.
+type Group struct {
+	mu sync.Mutex
+	// dirty holds the running keys, true when another run was requested in the meantime.
+	dirty map[int]bool
+}
+
+func (g *Group) Do(key int, fn func()) {
+	g.mu.Lock()
+	if g.dirty == nil {
+		g.dirty = make(map[int]bool)
+	}
+	if _, running := g.dirty[key]; running {
+		g.dirty[key] = true
+		g.mu.Unlock()
+		return
+	}
+	g.dirty[key] = false
+	g.mu.Unlock()
+
+	// A panicking fn must not leave the key running, or no later call would run it again.
+	defer func() {
+		g.mu.Lock()
+		delete(g.dirty, key)
+		g.mu.Unlock()
+	}()
+
+	for {
+		fn()
+
+		g.mu.Lock()
+		if !g.dirty[key] {
+			g.mu.Unlock()
+			return
+		}
+		g.dirty[key] = false
+		g.mu.Unlock()
+	}
+}
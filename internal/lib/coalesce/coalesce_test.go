@@ -0,0 +1,84 @@
+package coalesce
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDoFoldsCallsMadeWhileRunning(t *testing.T) {
+	var g Group
+	runs := 0
+	started, release := make(chan struct{}), make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do(
+			1, func() {
+				runs++
+				if runs == 1 {
+					close(started)
+					<-release
+				}
+			},
+		)
+	}()
+
+	<-started
+	for i := 0; i < 5; i++ {
+		g.Do(1, func() { t.Error("a call made while the key runs ran on its own") })
+	}
+	close(release)
+	<-done
+
+	if runs != 2 {
+		t.Errorf("runs = %d, want 2", runs)
+	}
+}
+
+func TestDoRunsKeysIndependently(t *testing.T) {
+	var g Group
+	var mu sync.Mutex
+	ran := map[int]int{}
+
+	var wg sync.WaitGroup
+	for key := 0; key < 3; key++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			g.Do(
+				key, func() {
+					mu.Lock()
+					ran[key]++
+					mu.Unlock()
+				},
+			)
+		}(key)
+	}
+	wg.Wait()
+
+	for key := 0; key < 3; key++ {
+		if ran[key] != 1 {
+			t.Errorf("key %d ran %d times, want 1", key, ran[key])
+		}
+	}
+}
+
+func TestDoReleasesKeyAfterPanic(t *testing.T) {
+	var g Group
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic of fn was not propagated")
+			}
+		}()
+		g.Do(1, func() { panic("boom") })
+	}()
+
+	ran := false
+	g.Do(1, func() { ran = true })
+	if !ran {
+		t.Error("the key stayed running after fn panicked")
+	}
+}
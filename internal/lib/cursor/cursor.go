@@ -0,0 +1,47 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor points after an item of a list sorted by time then id, both descending.
+type Cursor struct {
+	At time.Time
+	ID int64
+}
+
+// Encode returns an opaque string for the client to send back to get the next page.
+func Encode(c Cursor) string {
+	raw := strconv.FormatInt(c.At.UnixMicro(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a cursor from Encode. An empty string decodes to nil, meaning the first page.
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalid
+	}
+	micros, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return &Cursor{At: time.UnixMicro(micros).UTC(), ID: n}, nil
+}
@@ -0,0 +1,85 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+		want   Cursor
+	}{
+		{
+			name:   "utc",
+			cursor: Cursor{At: time.Date(2024, 5, 1, 7, 30, 0, 123456000, time.UTC), ID: 42},
+			want:   Cursor{At: time.Date(2024, 5, 1, 7, 30, 0, 123456000, time.UTC), ID: 42},
+		},
+		{
+			name:   "nanoseconds are truncated to the precision of postgres",
+			cursor: Cursor{At: time.Date(2024, 5, 1, 7, 30, 0, 123456789, time.UTC), ID: 1},
+			want:   Cursor{At: time.Date(2024, 5, 1, 7, 30, 0, 123456000, time.UTC), ID: 1},
+		},
+		{
+			name:   "other zones decode to utc",
+			cursor: Cursor{At: time.Date(2024, 5, 1, 9, 30, 0, 0, time.FixedZone("EET", 2*60*60)), ID: 7},
+			want:   Cursor{At: time.Date(2024, 5, 1, 7, 30, 0, 0, time.UTC), ID: 7},
+		},
+		{
+			name:   "before the epoch",
+			cursor: Cursor{At: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC), ID: 9007199254740993},
+			want:   Cursor{At: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC), ID: 9007199254740993},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Decode(Encode(tt.cursor))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if *got != tt.want {
+					t.Errorf("Decode(Encode()) = %+v, want %+v", *got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name    string
+		s       string
+		wantErr error
+	}{
+		{name: "not base64", s: "cursor!", wantErr: ErrInvalid},
+		{name: "padded base64", s: base64.URLEncoding.EncodeToString([]byte("1:22")), wantErr: ErrInvalid},
+		{name: "no separator", s: encode("1714548600000000"), wantErr: ErrInvalid},
+		{name: "time is not a number", s: encode("yesterday:2"), wantErr: ErrInvalid},
+		{name: "id is not a number", s: encode("1714548600000000:two"), wantErr: ErrInvalid},
+		{name: "id overflows", s: encode("1714548600000000:9223372036854775808"), wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if _, err := Decode(tt.s); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Decode(%q) = %v, want %v", tt.s, err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	c, err := Decode("")
+	if c != nil || err != nil {
+		t.Errorf("Decode(\"\") = %v, %v, want the first page", c, err)
+	}
+}
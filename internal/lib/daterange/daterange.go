@@ -0,0 +1,86 @@
+package daterange
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"time"
+)
+
+const Layout = "2006-01-02"
+
+var ErrInvalidRange = errors.New("invalid date range")
+
+// Range is a span of whole days. From is the first day at midnight, To is the midnight after the last day.
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// FromQuery reads the "from", "to" (inclusive, YYYY-MM-DD) and "tz" (IANA name) query parameters.
+// Missing bounds default to the last defaultDays days ending today.
+func FromQuery(q url.Values, defaultDays int, maxDays int) (Range, error) {
+	today, err := Today(q)
+	if err != nil {
+		return Range{}, err
+	}
+	loc := today.Location()
+
+	to := today.AddDate(0, 0, 1)
+	if str := q.Get("to"); str != "" {
+		t, err := time.ParseInLocation(Layout, str, loc)
+		if err != nil {
+			return Range{}, ErrInvalidRange
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -defaultDays)
+	if str := q.Get("from"); str != "" {
+		t, err := time.ParseInLocation(Layout, str, loc)
+		if err != nil {
+			return Range{}, ErrInvalidRange
+		}
+		from = t
+	}
+
+	r := Range{From: from, To: to}
+	if !from.Before(to) || r.Len() > maxDays {
+		return Range{}, ErrInvalidRange
+	}
+	return r, nil
+}
+
+// Today returns the midnight of the current day in the time zone of the "tz" query parameter, UTC by default.
+func Today(q url.Values) (time.Time, error) {
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, ErrInvalidRange
+		}
+		loc = l
+	}
+
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// Len returns the number of days in the range.
+func (r Range) Len() int {
+	return int(math.Round(r.To.Sub(r.From).Hours() / 24))
+}
+
+// Days returns the midnight of every day in the range.
+func (r Range) Days() []time.Time {
+	var days []time.Time
+	for d := r.From; d.Before(r.To); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// Key formats t as the day it falls on in the range's time zone.
+func (r Range) Key(t time.Time) string {
+	return t.In(r.From.Location()).Format(Layout)
+}
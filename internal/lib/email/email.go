@@ -7,9 +7,15 @@ import (
 	"gopkg.in/gomail.v2"
 	"html/template"
 	"log"
+	"time"
 )
 
-const userVerificationTemplPath = "./internal/lib/email/templates/verify-email.html"
+const (
+	userVerificationTemplPath = "./internal/lib/email/templates/verify-email.html"
+	dataExportTemplPath       = "./internal/lib/email/templates/data-export.html"
+	goalMilestoneTemplPath    = "./internal/lib/email/templates/goal-milestone.html"
+	goalOffTrackTemplPath     = "./internal/lib/email/templates/goal-off-track.html"
+)
 
 func send(to []string, subject string, body string) error {
 	const op = "email.send"
@@ -51,3 +57,81 @@ func SendVerifyUser(username, email, code string) error {
 
 	return nil
 }
+
+func SendDataExport(username, email, link string, expiresAt time.Time) error {
+	const op = "email.SendDataExport"
+
+	t, err := template.ParseFiles(dataExportTemplPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var body bytes.Buffer
+	err = t.Execute(
+		&body, struct {
+			Name      string
+			Link      string
+			ExpiresAt string
+		}{Name: username, Link: link, ExpiresAt: expiresAt.UTC().Format("2006-01-02 15:04 MST")},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return send([]string{email}, "Your AtomFit data export", body.String())
+}
+
+func SendGoalMilestone(username, email, goal string, percent int) error {
+	const op = "email.SendGoalMilestone"
+
+	t, err := template.ParseFiles(goalMilestoneTemplPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var body bytes.Buffer
+	err = t.Execute(
+		&body, struct {
+			Name    string
+			Goal    string
+			Percent int
+		}{Name: username, Goal: goal, Percent: percent},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	subject := fmt.Sprintf("You reached %d%% of your goal", percent)
+	if percent == 100 {
+		subject = "Goal completed"
+	}
+	return send([]string{email}, subject, body.String())
+}
+
+func SendGoalOffTrack(username, email, goal string, progress, expected float64, endsOn string) error {
+	const op = "email.SendGoalOffTrack"
+
+	t, err := template.ParseFiles(goalOffTrackTemplPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var body bytes.Buffer
+	err = t.Execute(
+		&body, struct {
+			Name     string
+			Goal     string
+			Progress string
+			Expected string
+			EndsOn   string
+		}{
+			Name: username, Goal: goal, Progress: fmt.Sprintf("%.0f", progress),
+			Expected: fmt.Sprintf("%.0f", expected), EndsOn: endsOn,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return send([]string{email}, "Your goal is off track", body.String())
+}
@@ -0,0 +1,105 @@
+package energy
+
+import "math"
+
+const (
+	ProfileWalking = "walking"
+	ProfileRunning = "running"
+	ProfileCycling = "cycling"
+)
+
+// SedentaryFactor scales the basal metabolic rate to the energy spent on a day without exercise.
+const SedentaryFactor = 1.2
+
+// paceStep maps a minimum speed in km/h to the MET value of the Compendium of Physical Activities.
+type paceStep struct {
+	speed float64
+	met   float64
+}
+
+var paceTables = map[string][]paceStep{
+	ProfileWalking: {{0, 2.0}, {3.2, 2.8}, {4.0, 3.0}, {4.8, 3.5}, {5.6, 4.3}, {6.4, 5.0}, {7.2, 7.0}},
+	ProfileRunning: {
+		{0, 6.0}, {6.4, 6.0}, {8.0, 8.3}, {8.4, 9.0}, {9.7, 9.8}, {10.8, 10.5}, {11.3, 11.0}, {12.1, 11.5},
+		{12.9, 11.8}, {13.8, 12.3}, {14.5, 12.8}, {16.1, 14.5}, {17.7, 16.0}, {19.3, 19.0}, {20.9, 19.8},
+		{22.5, 23.0},
+	},
+	ProfileCycling: {{0, 3.5}, {16.1, 6.8}, {19.3, 8.0}, {22.5, 10.0}, {25.7, 12.0}, {30.6, 15.8}},
+}
+
+// Activity is a single logged effort: a set or a cardio entry.
+type Activity struct {
+	MET      float64
+	Profile  string
+	Duration int // seconds
+	Distance int // meters
+}
+
+// BMR returns the basal metabolic rate in kcal/day using the Mifflin-St Jeor equation.
+func BMR(isMale bool, age int, height int, weight float64) float64 {
+	bmr := 10*weight + 6.25*float64(height) - 5*float64(age)
+	if isMale {
+		return bmr + 5
+	}
+	return bmr - 161
+}
+
+// PaceMET returns the MET value for the average speed of a cardio activity. It falls back to
+// the given base MET when the profile has no pace table or distance/duration are missing.
+func PaceMET(profile string, distance int, duration int, base float64) float64 {
+	table, ok := paceTables[profile]
+	if !ok || distance <= 0 || duration <= 0 {
+		return base
+	}
+
+	speed := float64(distance) / 1000 / (float64(duration) / 3600)
+	met := table[0].met
+	for _, step := range table {
+		if speed < step.speed {
+			break
+		}
+		met = step.met
+	}
+	return met
+}
+
+// Kcal returns the energy spent during an activity: MET * weight(kg) * hours.
+func Kcal(met float64, weight float64, duration int) float64 {
+	return met * weight * float64(duration) / 3600
+}
+
+// WorkoutKcal estimates the energy burned during a workout on top of resting. It uses net METs
+// (MET - 1) since the resting energy of the workout is already part of the daily BMR.
+// Activities without their own duration (typical for strength sets) share whatever is left
+// of the workout duration.
+func WorkoutKcal(weight float64, workoutDuration int, activities []Activity) float64 {
+	var timed, untimed int
+	for _, a := range activities {
+		if a.Duration > 0 {
+			timed += a.Duration
+		} else {
+			untimed++
+		}
+	}
+
+	share := 0
+	if untimed > 0 && workoutDuration > timed {
+		share = (workoutDuration - timed) / untimed
+	}
+
+	var kcal float64
+	for _, a := range activities {
+		duration := a.Duration
+		if duration == 0 {
+			duration = share
+		}
+		met := PaceMET(a.Profile, a.Distance, a.Duration, a.MET)
+		kcal += Kcal(max(met-1, 0), weight, duration)
+	}
+	return Round(kcal)
+}
+
+// Round rounds kcal to one decimal place.
+func Round(kcal float64) float64 {
+	return math.Round(kcal*10) / 10
+}
@@ -0,0 +1,73 @@
+package energy
+
+import "testing"
+
+func TestBMR(t *testing.T) {
+	tests := []struct {
+		name   string
+		isMale bool
+		age    int
+		height int
+		weight float64
+		want   float64
+	}{
+		{name: "man", isMale: true, age: 30, height: 180, weight: 80, want: 1780},
+		{name: "woman", age: 25, height: 165, weight: 60, want: 1345.25},
+	}
+	for _, tt := range tests {
+		if got := BMR(tt.isMale, tt.age, tt.height, tt.weight); got != tt.want {
+			t.Errorf("BMR() of a %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPaceMET(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  string
+		distance int
+		duration int
+		want     float64
+	}{
+		{name: "running 10 km/h", profile: ProfileRunning, distance: 10000, duration: 3600, want: 9.8},
+		{name: "cycling 25 km/h", profile: ProfileCycling, distance: 25000, duration: 3600, want: 10},
+		{name: "walking slowly", profile: ProfileWalking, distance: 2000, duration: 3600, want: 2},
+		{name: "no distance", profile: ProfileRunning, duration: 3600, want: 7},
+		{name: "no pace table", profile: "rowing", distance: 10000, duration: 3600, want: 7},
+	}
+	for _, tt := range tests {
+		if got := PaceMET(tt.profile, tt.distance, tt.duration, 7); got != tt.want {
+			t.Errorf("PaceMET() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWorkoutKcal(t *testing.T) {
+	run := Activity{MET: 7, Profile: ProfileRunning, Duration: 1800, Distance: 5000}
+	set := Activity{MET: 3.5}
+
+	tests := []struct {
+		name       string
+		duration   int
+		activities []Activity
+		want       float64
+	}{
+		{name: "no activities", duration: 3600, want: 0},
+		// 2.5 net METs * 80 kg * 1 h.
+		{name: "sets share the workout", duration: 3600, activities: []Activity{set, set, set}, want: 200},
+		// 8.8 net METs for 10 km/h * 80 kg * 0.5 h, plus two sets of 15 minutes.
+		{name: "timed and untimed", duration: 3600, activities: []Activity{run, set, set}, want: 452},
+		{name: "no time left for sets", duration: 1200, activities: []Activity{run, set}, want: 352},
+		{name: "resting pace burns nothing extra", duration: 3600, activities: []Activity{{MET: 1}}, want: 0},
+		{name: "below resting", duration: 3600, activities: []Activity{{MET: 0.8}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := WorkoutKcal(80, tt.duration, tt.activities); got != tt.want {
+					t.Errorf("WorkoutKcal() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	WorkoutCreated      = "workout.created"
+	WorkoutUpdated      = "workout.updated"
+	WorkoutDeleted      = "workout.deleted"
+	RecordBroken        = "record.broken"
+	MetricsLogged       = "metrics.logged"
+	WeightLogged        = "weight.logged"
+	WeightUpdated       = "weight.updated"
+	WeightDeleted       = "weight.deleted"
+	DiaryLogged         = "diary.logged"
+	DiaryUpdated        = "diary.updated"
+	DiaryDeleted        = "diary.deleted"
+	GoalMilestone       = "goal.milestone"
+	GoalCompleted       = "goal.completed"
+	GoalOffTrack        = "goal.off_track"
+	AchievementUnlocked = "achievement.unlocked"
+)
+
+// All subscribes a handler to every published event.
+const All = "*"
+
+type Event struct {
+	Type       string
+	UserID     int
+	Payload    any
+	OccurredAt time.Time
+}
+
+type Handler func(e Event)
+
+// Bus is an in-process publisher of domain events. Handlers run in their own goroutines,
+// so publishing never blocks the request that produced the event.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func New() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Bus) Publish(eventType string, userID int, payload any) {
+	e := Event{Type: eventType, UserID: userID, Payload: payload, OccurredAt: time.Now()}
+
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[eventType]...), b.handlers[All]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		go h(e)
+	}
+}
@@ -0,0 +1,200 @@
+package goal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"math"
+	"time"
+)
+
+// Milestones are the progress percentages that are celebrated, in ascending order.
+var Milestones = []int{25, 50, 75, 100}
+
+const (
+	// offTrackMargin is how many percentage points a goal may lag behind a linear schedule.
+	offTrackMargin = 15
+	// graceDays keeps new goals from being reported off track before there is anything to judge.
+	graceDays = 7
+	maxDays   = 2 * 366
+	day       = 24 * time.Hour
+)
+
+var (
+	ErrInvalidTarget = errors.New("invalid goal target")
+	ErrInvalidPeriod = errors.New("invalid goal period")
+)
+
+// Data is what was logged during the goal: the latest weight, the start time of the workouts
+// and the daily step counts or protein intakes keyed by YYYY-MM-DD.
+type Data struct {
+	Weight   *float64
+	Workouts []time.Time
+	Daily    map[string]float64
+}
+
+type Result struct {
+	Progress float64
+	Current  float64
+}
+
+// Validate checks the target and the period of a new goal. Target weight goals need
+// the start weight to be set.
+func Validate(g models.Goal) error {
+	from, to, err := Span(g)
+	if err != nil {
+		return err
+	}
+	if !to.After(from) || to.Sub(from) > maxDays*day {
+		return fmt.Errorf("%w: ends_on must be after starts_on and at most %d days later", ErrInvalidPeriod, maxDays)
+	}
+
+	switch g.Kind {
+	case models.GoalTargetWeight:
+		if g.Target < 20 || g.Target > 500 {
+			return fmt.Errorf("%w: target weight must be between 20 and 500 kg", ErrInvalidTarget)
+		}
+		if g.StartValue == nil || *g.StartValue <= 0 {
+			return fmt.Errorf("%w: log your current weight first", ErrInvalidTarget)
+		}
+		if math.Abs(*g.StartValue-g.Target) < 0.1 {
+			return fmt.Errorf("%w: target weight equals the current weight", ErrInvalidTarget)
+		}
+	case models.GoalWorkoutsPerWeek:
+		if g.Target != math.Trunc(g.Target) || g.Target > 14 {
+			return fmt.Errorf("%w: workouts per week must be a whole number up to 14", ErrInvalidTarget)
+		}
+	case models.GoalDailySteps:
+		if g.Target > 200000 {
+			return fmt.Errorf("%w: daily steps must be at most 200000", ErrInvalidTarget)
+		}
+	case models.GoalProteinPerDay:
+		if g.Target > 500 {
+			return fmt.Errorf("%w: protein per day must be at most 500 g", ErrInvalidTarget)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %s", ErrInvalidTarget, g.Kind)
+	}
+	return nil
+}
+
+// Span returns the midnight (UTC) of the first day and the midnight after the last day of the goal.
+func Span(g models.Goal) (time.Time, time.Time, error) {
+	from, err := time.Parse(daterange.Layout, g.StartsOn)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %w", ErrInvalidPeriod, err)
+	}
+	to, err := time.Parse(daterange.Layout, g.EndsOn)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %w", ErrInvalidPeriod, err)
+	}
+	return from, to.Add(day), nil
+}
+
+// Evaluate computes the progress of the goal in percent. A weight goal progresses with the distance
+// covered from the start weight, a habit goal with the share of the workouts or days it requires.
+func Evaluate(g models.Goal, d Data) Result {
+	from, to, err := Span(g)
+	if err != nil {
+		return Result{}
+	}
+	days := int(to.Sub(from) / day)
+
+	switch g.Kind {
+	case models.GoalTargetWeight:
+		if g.StartValue == nil || d.Weight == nil {
+			return Result{}
+		}
+		done := (*g.StartValue - *d.Weight) / (*g.StartValue - g.Target)
+		return Result{Progress: percent(done), Current: *d.Weight}
+
+	case models.GoalWorkoutsPerWeek:
+		// Extra workouts in one week do not make up for a missed week.
+		weeks := (days + 6) / 7
+		perWeek := make([]float64, weeks)
+		for _, t := range d.Workouts {
+			if t.Before(from) || !t.Before(to) {
+				continue
+			}
+			perWeek[int(t.Sub(from)/day)/7]++
+		}
+		var counted float64
+		for _, n := range perWeek {
+			counted += min(n, g.Target)
+		}
+		required := math.Ceil(g.Target * float64(days) / 7)
+		return Result{Progress: percent(counted / required), Current: counted}
+
+	case models.GoalDailySteps, models.GoalProteinPerDay:
+		var met float64
+		for t := from; t.Before(to); t = t.Add(day) {
+			if d.Daily[t.Format(daterange.Layout)] >= g.Target {
+				met++
+			}
+		}
+		return Result{Progress: percent(met / float64(days)), Current: met}
+	}
+	return Result{}
+}
+
+// Expected is the progress a goal would have by today if it advanced at a steady pace.
+// Today itself is not counted since it is not over yet.
+func Expected(g models.Goal, today time.Time) float64 {
+	from, to, err := Span(g)
+	if err != nil {
+		return 0
+	}
+	elapsed := dayOf(today).Sub(from)
+	return percent(float64(elapsed) / float64(to.Sub(from)))
+}
+
+// OffTrack reports whether the goal lags too far behind the steady pace to be reached in time.
+func OffTrack(g models.Goal, progress float64, today time.Time) bool {
+	from, _, err := Span(g)
+	if err != nil || dayOf(today).Sub(from) < graceDays*day {
+		return false
+	}
+	return progress < Expected(g, today)-offTrackMargin
+}
+
+// Ended reports whether the last day of the goal is over.
+func Ended(g models.Goal, today time.Time) bool {
+	_, to, err := Span(g)
+	return err == nil && !dayOf(today).Before(to)
+}
+
+// Reached returns the milestones covered by progress.
+func Reached(progress float64) []int {
+	var res []int
+	for _, m := range Milestones {
+		if progress >= float64(m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// Title describes the goal in a sentence, e.g. for notification emails.
+func Title(g models.Goal) string {
+	switch g.Kind {
+	case models.GoalTargetWeight:
+		return fmt.Sprintf("Reach %g kg by %s", g.Target, g.EndsOn)
+	case models.GoalWorkoutsPerWeek:
+		return fmt.Sprintf("Work out %g times a week until %s", g.Target, g.EndsOn)
+	case models.GoalDailySteps:
+		return fmt.Sprintf("Walk %g steps a day until %s", g.Target, g.EndsOn)
+	case models.GoalProteinPerDay:
+		return fmt.Sprintf("Eat %g g of protein a day until %s", g.Target, g.EndsOn)
+	}
+	return g.Kind
+}
+
+func percent(share float64) float64 {
+	return math.Round(math.Max(0, math.Min(1, share))*10000) / 100
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
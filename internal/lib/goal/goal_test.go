@@ -0,0 +1,288 @@
+package goal
+
+import (
+	"errors"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func weight(v float64) *float64 {
+	return &v
+}
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		goal    models.Goal
+		wantErr error
+	}{
+		{
+			name: "target weight",
+			goal: models.Goal{
+				Kind: models.GoalTargetWeight, Target: 75, StartValue: weight(80), StartsOn: "2024-01-01",
+				EndsOn: "2024-03-31",
+			},
+		},
+		{
+			name: "single day",
+			goal: models.Goal{Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-01-01", EndsOn: "2024-01-01"},
+		},
+		{
+			name: "malformed date",
+			goal: models.Goal{
+				Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-01-01", EndsOn: "31.03.2024",
+			},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name: "ends before it starts",
+			goal: models.Goal{
+				Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-03-01", EndsOn: "2024-02-01",
+			},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name: "longer than two years",
+			goal: models.Goal{
+				Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-01-01", EndsOn: "2026-01-05",
+			},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name: "implausible weight",
+			goal: models.Goal{
+				Kind: models.GoalTargetWeight, Target: 600, StartValue: weight(80), StartsOn: "2024-01-01",
+				EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "weight without a start weight",
+			goal: models.Goal{
+				Kind: models.GoalTargetWeight, Target: 75, StartsOn: "2024-01-01", EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "weight already reached",
+			goal: models.Goal{
+				Kind: models.GoalTargetWeight, Target: 80, StartValue: weight(80.05), StartsOn: "2024-01-01",
+				EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "fractional workouts",
+			goal: models.Goal{
+				Kind: models.GoalWorkoutsPerWeek, Target: 2.5, StartsOn: "2024-01-01", EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "too many workouts",
+			goal: models.Goal{
+				Kind: models.GoalWorkoutsPerWeek, Target: 15, StartsOn: "2024-01-01", EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "too many steps",
+			goal: models.Goal{
+				Kind: models.GoalDailySteps, Target: 300000, StartsOn: "2024-01-01", EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name: "too much protein",
+			goal: models.Goal{
+				Kind: models.GoalProteinPerDay, Target: 600, StartsOn: "2024-01-01", EndsOn: "2024-03-31",
+			},
+			wantErr: ErrInvalidTarget,
+		},
+		{
+			name:    "unknown kind",
+			goal:    models.Goal{Kind: "sleep", Target: 8, StartsOn: "2024-01-01", EndsOn: "2024-03-31"},
+			wantErr: ErrInvalidTarget,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if err := Validate(tt.goal); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	twoWeeks := func(kind string, target float64) models.Goal {
+		return models.Goal{Kind: kind, Target: target, StartsOn: "2024-01-01", EndsOn: "2024-01-14"}
+	}
+	lose := models.Goal{
+		Kind: models.GoalTargetWeight, Target: 70, StartValue: weight(80), StartsOn: "2024-01-01",
+		EndsOn: "2024-03-31",
+	}
+	gain := lose
+	gain.Target, gain.StartValue = 70, weight(60)
+
+	workouts := []time.Time{
+		date("2023-12-31"),
+		// Five workouts in the first week count as three.
+		date("2024-01-01"), date("2024-01-02"), date("2024-01-03"), date("2024-01-05"), date("2024-01-07"),
+		date("2024-01-10"),
+		date("2024-01-15"),
+	}
+
+	tests := []struct {
+		name string
+		goal models.Goal
+		data Data
+		want Result
+	}{
+		{name: "halfway down", goal: lose, data: Data{Weight: weight(75)}, want: Result{Progress: 50, Current: 75}},
+		{name: "overshoot", goal: lose, data: Data{Weight: weight(68)}, want: Result{Progress: 100, Current: 68}},
+		{name: "wrong way", goal: lose, data: Data{Weight: weight(82)}, want: Result{Progress: 0, Current: 82}},
+		{name: "no weight logged", goal: lose, data: Data{}, want: Result{}},
+		{name: "gaining", goal: gain, data: Data{Weight: weight(63)}, want: Result{Progress: 30, Current: 63}},
+		{
+			name: "workouts per week",
+			goal: twoWeeks(models.GoalWorkoutsPerWeek, 3),
+			data: Data{Workouts: workouts},
+			want: Result{Progress: 66.67, Current: 4},
+		},
+		{
+			name: "daily steps",
+			goal: twoWeeks(models.GoalDailySteps, 10000),
+			data: Data{
+				Daily: map[string]float64{
+					"2023-12-31": 20000, "2024-01-01": 12000, "2024-01-02": 9999, "2024-01-03": 10000,
+					"2024-01-14": 15000, "2024-01-15": 15000,
+				},
+			},
+			want: Result{Progress: 21.43, Current: 3},
+		},
+		{
+			name: "protein per day",
+			goal: twoWeeks(models.GoalProteinPerDay, 100),
+			data: Data{Daily: map[string]float64{}},
+			want: Result{},
+		},
+		{
+			name: "invalid period",
+			goal: models.Goal{Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-01-01"},
+			data: Data{Daily: map[string]float64{"2024-01-01": 20000}},
+			want: Result{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Evaluate(tt.goal, tt.data); got != tt.want {
+					t.Errorf("Evaluate() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	g := models.Goal{Kind: models.GoalDailySteps, Target: 10000, StartsOn: "2024-01-01", EndsOn: "2024-01-10"}
+
+	tests := []struct {
+		name         string
+		today        time.Time
+		progress     float64
+		wantExpected float64
+		wantOffTrack bool
+		wantEnded    bool
+	}{
+		{name: "before the start", today: date("2023-12-20"), wantExpected: 0},
+		{name: "first day", today: time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), wantExpected: 0},
+		{name: "behind within the grace days", today: date("2024-01-06"), wantExpected: 50},
+		{name: "behind", today: date("2024-01-09"), progress: 64.99, wantExpected: 80, wantOffTrack: true},
+		{name: "within the margin", today: date("2024-01-09"), progress: 65, wantExpected: 80},
+		{
+			// One in the morning of the 11th in EET is still the 10th in UTC.
+			name:         "last day in UTC",
+			today:        time.Date(2024, 1, 11, 1, 0, 0, 0, time.FixedZone("EET", 2*60*60)),
+			progress:     100,
+			wantExpected: 90,
+		},
+		{
+			name: "ended", today: date("2024-01-11"), progress: 80, wantExpected: 100, wantOffTrack: true,
+			wantEnded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Expected(g, tt.today); got != tt.wantExpected {
+					t.Errorf("Expected() = %v, want %v", got, tt.wantExpected)
+				}
+				if got := OffTrack(g, tt.progress, tt.today); got != tt.wantOffTrack {
+					t.Errorf("OffTrack() = %v, want %v", got, tt.wantOffTrack)
+				}
+				if got := Ended(g, tt.today); got != tt.wantEnded {
+					t.Errorf("Ended() = %v, want %v", got, tt.wantEnded)
+				}
+			},
+		)
+	}
+}
+
+func TestReached(t *testing.T) {
+	tests := []struct {
+		progress float64
+		want     []int
+	}{
+		{progress: 0, want: nil},
+		{progress: 24.99, want: nil},
+		{progress: 25, want: []int{25}},
+		{progress: 74.5, want: []int{25, 50}},
+		{progress: 100, want: []int{25, 50, 75, 100}},
+	}
+	for _, tt := range tests {
+		if got := Reached(tt.progress); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Reached(%v) = %v, want %v", tt.progress, got, tt.want)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		goal models.Goal
+		want string
+	}{
+		{
+			goal: models.Goal{Kind: models.GoalTargetWeight, Target: 72.5, EndsOn: "2024-06-30"},
+			want: "Reach 72.5 kg by 2024-06-30",
+		},
+		{
+			goal: models.Goal{Kind: models.GoalWorkoutsPerWeek, Target: 3, EndsOn: "2024-06-30"},
+			want: "Work out 3 times a week until 2024-06-30",
+		},
+		{
+			goal: models.Goal{Kind: models.GoalDailySteps, Target: 10000, EndsOn: "2024-06-30"},
+			want: "Walk 10000 steps a day until 2024-06-30",
+		},
+		{
+			goal: models.Goal{Kind: models.GoalProteinPerDay, Target: 120, EndsOn: "2024-06-30"},
+			want: "Eat 120 g of protein a day until 2024-06-30",
+		},
+		{goal: models.Goal{Kind: "sleep"}, want: "sleep"},
+	}
+	for _, tt := range tests {
+		if got := Title(tt.goal); got != tt.want {
+			t.Errorf("Title() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package habits
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+var (
+	ErrOutOfRange    = errors.New("metric value out of range")
+	ErrInvalidPeriod = errors.New("period must be week or month")
+)
+
+// Definition describes the plausible values of a metric and how its daily goal is met.
+type Definition struct {
+	Min float64
+	Max float64
+	// DefaultGoal applies to users who did not set a goal, zero meaning no goal.
+	DefaultGoal   float64
+	LowerIsBetter bool
+}
+
+var Definitions = map[string]Definition{
+	models.MetricWater:            {Min: 0, Max: 20000, DefaultGoal: 2000},
+	models.MetricSleepMinutes:     {Min: 0, Max: 1440, DefaultGoal: 420},
+	models.MetricSleepQuality:     {Min: 1, Max: 5},
+	models.MetricSteps:            {Min: 0, Max: 200000, DefaultGoal: 10000},
+	models.MetricRestingHeartRate: {Min: 20, Max: 250, LowerIsBetter: true},
+}
+
+// Metrics lists the metric names in a stable order.
+var Metrics = []string{
+	models.MetricWater, models.MetricSleepMinutes, models.MetricSleepQuality, models.MetricSteps,
+	models.MetricRestingHeartRate,
+}
+
+func Validate(metric string, value float64) error {
+	d, ok := Definitions[metric]
+	if !ok {
+		return fmt.Errorf("%w: unknown metric %s", ErrOutOfRange, metric)
+	}
+	if value < d.Min || value > d.Max {
+		return fmt.Errorf("%w: %s must be between %g and %g", ErrOutOfRange, metric, d.Min, d.Max)
+	}
+	return nil
+}
+
+// Goals completes the user's goals with the defaults.
+func Goals(own []models.MetricGoal) []models.MetricGoal {
+	res := make([]models.MetricGoal, 0, len(Metrics))
+	for _, metric := range Metrics {
+		i := slices.IndexFunc(own, func(g models.MetricGoal) bool { return g.Metric == metric })
+		switch {
+		case i >= 0:
+			res = append(res, own[i])
+		case Definitions[metric].DefaultGoal > 0:
+			res = append(res, models.MetricGoal{Metric: metric, Target: Definitions[metric].DefaultGoal, Default: true})
+		}
+	}
+	return res
+}
+
+func Met(metric string, value float64, goal float64) bool {
+	if Definitions[metric].LowerIsBetter {
+		return value <= goal
+	}
+	return value >= goal
+}
+
+// Streak counts consecutive days meeting the goal. values maps YYYY-MM-DD to the day's value.
+// The current streak ends today, or yesterday when today's goal is not met yet: the day is not over.
+func Streak(metric string, values map[string]float64, goal float64, today time.Time) models.MetricStreak {
+	s := models.MetricStreak{Metric: metric, Goal: goal}
+
+	met := func(day time.Time) bool {
+		v, ok := values[day.Format(daterange.Layout)]
+		return ok && Met(metric, v, goal)
+	}
+
+	day := today
+	s.TodayMet = met(day)
+	if !s.TodayMet {
+		day = day.AddDate(0, 0, -1)
+	}
+	for met(day) {
+		s.Current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	dates := make([]string, 0, len(values))
+	for date := range values {
+		dates = append(dates, date)
+	}
+	slices.Sort(dates)
+
+	run := 0
+	var prev time.Time
+	for _, date := range dates {
+		d, err := time.Parse(daterange.Layout, date)
+		if err != nil || !Met(metric, values[date], goal) {
+			run = 0
+			continue
+		}
+		if run > 0 && d.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = d
+		s.Longest = max(s.Longest, run)
+	}
+	return s
+}
+
+// PeriodStart returns the first day of the week (Monday) or month containing day.
+func PeriodStart(period string, day time.Time) (time.Time, error) {
+	switch period {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case PeriodMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()), nil
+	}
+	return time.Time{}, ErrInvalidPeriod
+}
+
+// Summarize aggregates daily values per period and metric, oldest period first. Only the
+// logged days count: averages are per logged day.
+func Summarize(period string, list []models.DailyMetric, goals []models.MetricGoal) ([]models.MetricSummary, error) {
+	type key struct{ start, metric string }
+	byKey := map[key]*models.MetricSummary{}
+	var keys []key
+
+	for _, m := range list {
+		day, err := time.Parse(daterange.Layout, m.Date)
+		if err != nil {
+			return nil, err
+		}
+		start, err := PeriodStart(period, day)
+		if err != nil {
+			return nil, err
+		}
+
+		k := key{start.Format(daterange.Layout), m.Metric}
+		s, ok := byKey[k]
+		if !ok {
+			s = &models.MetricSummary{Start: k.start, Metric: m.Metric, Min: m.Value, Max: m.Value}
+			byKey[k] = s
+			keys = append(keys, k)
+		}
+		s.DaysLogged++
+		s.Total += m.Value
+		s.Min = math.Min(s.Min, m.Value)
+		s.Max = math.Max(s.Max, m.Value)
+		if i := slices.IndexFunc(goals, func(g models.MetricGoal) bool { return g.Metric == m.Metric }); i >= 0 {
+			if Met(m.Metric, m.Value, goals[i].Target) {
+				s.GoalDays++
+			}
+		}
+	}
+
+	slices.SortFunc(
+		keys, func(a, b key) int {
+			if a.start != b.start {
+				if a.start < b.start {
+					return -1
+				}
+				return 1
+			}
+			return slices.Index(Metrics, a.metric) - slices.Index(Metrics, b.metric)
+		},
+	)
+
+	res := make([]models.MetricSummary, 0, len(keys))
+	for _, k := range keys {
+		s := byKey[k]
+		s.Average = math.Round(s.Total/float64(s.DaysLogged)*100) / 100
+		s.Total = math.Round(s.Total*100) / 100
+		res = append(res, *s)
+	}
+	return res, nil
+}
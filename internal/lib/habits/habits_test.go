@@ -0,0 +1,210 @@
+package habits
+
+import (
+	"errors"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreak(t *testing.T) {
+	today := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		metric string
+		values map[string]float64
+		goal   float64
+		want   models.MetricStreak
+	}{
+		{
+			name:   "no values",
+			metric: models.MetricSteps,
+			values: map[string]float64{},
+			goal:   10000,
+			want:   models.MetricStreak{Metric: models.MetricSteps, Goal: 10000},
+		},
+		{
+			name:   "streak including today",
+			metric: models.MetricSteps,
+			values: map[string]float64{"2024-05-13": 12000, "2024-05-14": 10000, "2024-05-15": 11000},
+			goal:   10000,
+			want: models.MetricStreak{
+				Metric: models.MetricSteps, Goal: 10000, Current: 3, Longest: 3, TodayMet: true,
+			},
+		},
+		{
+			name:   "today is not over yet",
+			metric: models.MetricSteps,
+			values: map[string]float64{"2024-05-13": 12000, "2024-05-14": 10000, "2024-05-15": 2000},
+			goal:   10000,
+			want:   models.MetricStreak{Metric: models.MetricSteps, Goal: 10000, Current: 2, Longest: 2},
+		},
+		{
+			name:   "missed yesterday breaks the streak",
+			metric: models.MetricWater,
+			values: map[string]float64{"2024-05-12": 2500, "2024-05-13": 2000, "2024-05-14": 500},
+			goal:   2000,
+			want:   models.MetricStreak{Metric: models.MetricWater, Goal: 2000, Longest: 2},
+		},
+		{
+			name:   "gap of a day splits the longest run",
+			metric: models.MetricWater,
+			values: map[string]float64{
+				"2024-04-01": 2000, "2024-04-02": 2000, "2024-04-03": 2000, "2024-04-05": 2000,
+				"2024-05-15": 2000,
+			},
+			goal: 2000,
+			want: models.MetricStreak{Metric: models.MetricWater, Goal: 2000, Current: 1, Longest: 3, TodayMet: true},
+		},
+		{
+			name:   "lower is better",
+			metric: models.MetricRestingHeartRate,
+			values: map[string]float64{"2024-05-14": 58, "2024-05-15": 61},
+			goal:   60,
+			want:   models.MetricStreak{Metric: models.MetricRestingHeartRate, Goal: 60, Current: 1, Longest: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Streak(tt.metric, tt.values, tt.goal, today); got != tt.want {
+					t.Errorf("Streak() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	tests := []struct {
+		period  string
+		day     string
+		want    string
+		wantErr error
+	}{
+		{period: PeriodWeek, day: "2024-05-15", want: "2024-05-13"},
+		{period: PeriodWeek, day: "2024-05-13", want: "2024-05-13"},
+		{period: PeriodWeek, day: "2024-05-19", want: "2024-05-13"},
+		{period: PeriodWeek, day: "2024-01-03", want: "2024-01-01"},
+		{period: PeriodMonth, day: "2024-02-29", want: "2024-02-01"},
+		{period: "year", day: "2024-05-15", wantErr: ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		day, _ := time.Parse(time.DateOnly, tt.day)
+		got, err := PeriodStart(tt.period, day)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("PeriodStart(%s, %s): err = %v, want %v", tt.period, tt.day, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Format(time.DateOnly) != tt.want {
+			t.Errorf("PeriodStart(%s, %s) = %s, want %s", tt.period, tt.day, got.Format(time.DateOnly), tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	list := []models.DailyMetric{
+		{Date: "2024-05-14", Metric: models.MetricSteps, Value: 12000},
+		{Date: "2024-05-13", Metric: models.MetricWater, Value: 1500},
+		{Date: "2024-05-13", Metric: models.MetricSteps, Value: 8000},
+		{Date: "2024-05-15", Metric: models.MetricSteps, Value: 10001},
+		{Date: "2024-05-06", Metric: models.MetricSleepQuality, Value: 4},
+	}
+	goals := []models.MetricGoal{{Metric: models.MetricSteps, Target: 10000}}
+
+	tests := []struct {
+		name   string
+		period string
+		want   []models.MetricSummary
+	}{
+		{
+			name:   "weeks",
+			period: PeriodWeek,
+			want: []models.MetricSummary{
+				{
+					Start: "2024-05-06", Metric: models.MetricSleepQuality, DaysLogged: 1, Total: 4, Average: 4,
+					Min: 4, Max: 4,
+				},
+				{
+					Start: "2024-05-13", Metric: models.MetricWater, DaysLogged: 1, Total: 1500, Average: 1500,
+					Min: 1500, Max: 1500,
+				},
+				{
+					Start: "2024-05-13", Metric: models.MetricSteps, DaysLogged: 3, Total: 30001, Average: 10000.33,
+					Min: 8000, Max: 12000, GoalDays: 2,
+				},
+			},
+		},
+		{
+			name:   "months",
+			period: PeriodMonth,
+			want: []models.MetricSummary{
+				{
+					Start: "2024-05-01", Metric: models.MetricWater, DaysLogged: 1, Total: 1500, Average: 1500,
+					Min: 1500, Max: 1500,
+				},
+				{
+					Start: "2024-05-01", Metric: models.MetricSleepQuality, DaysLogged: 1, Total: 4, Average: 4,
+					Min: 4, Max: 4,
+				},
+				{
+					Start: "2024-05-01", Metric: models.MetricSteps, DaysLogged: 3, Total: 30001, Average: 10000.33,
+					Min: 8000, Max: 12000, GoalDays: 2,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Summarize(tt.period, list, goals)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+
+	if _, err := Summarize("year", list, goals); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("err = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestGoals(t *testing.T) {
+	got := Goals(
+		[]models.MetricGoal{{Metric: models.MetricSteps, Target: 6000}, {Metric: models.MetricSleepQuality, Target: 4}},
+	)
+	want := []models.MetricGoal{
+		{Metric: models.MetricWater, Target: 2000, Default: true},
+		{Metric: models.MetricSleepMinutes, Target: 420, Default: true},
+		{Metric: models.MetricSleepQuality, Target: 4},
+		{Metric: models.MetricSteps, Target: 6000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Goals() = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		metric  string
+		value   float64
+		wantErr error
+	}{
+		{metric: models.MetricWater, value: 0},
+		{metric: models.MetricSleepQuality, value: 5},
+		{metric: models.MetricSleepQuality, value: 0, wantErr: ErrOutOfRange},
+		{metric: models.MetricRestingHeartRate, value: 300, wantErr: ErrOutOfRange},
+		{metric: "mood", value: 3, wantErr: ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.metric, tt.value); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Validate(%s, %v) = %v, want %v", tt.metric, tt.value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// MaxPixels rejects decompression bombs before the pixels are decoded.
+	MaxPixels = 40_000_000
+	MaxSide   = 2048
+	ThumbSide = 320
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image format, use JPEG or PNG")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Process decodes an uploaded photo and encodes it again, which drops every metadata block
+// (EXIF with GPS position and camera serial, XMP, comments). The EXIF orientation is applied to
+// the pixels first so the photo still displays upright. It returns the photo, downscaled to
+// MaxSide when larger, and a ThumbSide thumbnail.
+func Process(data []byte) (Encoded, Encoded, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return Encoded{}, Encoded{}, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Encoded{}, Encoded{}, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Encoded{}, Encoded{}, ErrUnsupported
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	if format == "jpeg" {
+		rgba = orient(rgba, exifOrientation(data))
+	}
+
+	photo := fit(rgba, MaxSide)
+	thumb := fit(photo, ThumbSide)
+
+	encodedPhoto, err := encode(photo, format, 90)
+	if err != nil {
+		return Encoded{}, Encoded{}, err
+	}
+	encodedThumb, err := encode(thumb, format, 80)
+	if err != nil {
+		return Encoded{}, Encoded{}, err
+	}
+	return encodedPhoto, encodedThumb, nil
+}
+
+func encode(img *image.RGBA, format string, quality int) (Encoded, error) {
+	var buf bytes.Buffer
+	res := Encoded{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	if format == "png" {
+		res.ContentType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return Encoded{}, err
+		}
+	} else {
+		res.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return Encoded{}, err
+		}
+	}
+
+	res.Data = buf.Bytes()
+	return res, nil
+}
+
+// fit downscales the image so that its longest side is at most maxSide, averaging the source
+// pixels covered by every destination pixel.
+func fit(src *image.RGBA, maxSide int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return src
+	}
+
+	dw, dh := maxSide, sh*maxSide/sw
+	if sh > sw {
+		dw, dh = sw*maxSide/sh, maxSide
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0 := dy * sh / dh
+		y1 := max((dy+1)*sh/dh, y0+1)
+		for dx := 0; dx < dw; dx++ {
+			x0 := dx * sw / dw
+			x1 := max((dx+1)*sw/dw, x0+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					a += int(row[i+3])
+					n++
+				}
+			}
+
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) to the pixels.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
+
+// exifOrientation reads the orientation tag of the APP1 EXIF segment of a JPEG file,
+// returning 1 (upright) when there is none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan or end of image: no more metadata
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		off := ifd + 2 + e*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:]) == 0x0112 {
+			v := int(order.Uint16(tiff[off+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
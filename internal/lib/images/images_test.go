@@ -0,0 +1,239 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifSegment builds an APP1 segment holding a TIFF header whose first IFD has the orientation.
+func exifSegment(order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("II")
+	if order == binary.BigEndian {
+		tiff = []byte("MM")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 2)
+	// An unrelated entry first, then the orientation: SHORT, one value.
+	tiff = order.AppendUint16(tiff, 0x010F)
+	tiff = order.AppendUint16(tiff, 2)
+	tiff = order.AppendUint32(tiff, 4)
+	tiff = order.AppendUint32(tiff, 0)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = order.AppendUint16(tiff, 0)
+	tiff = order.AppendUint32(tiff, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func jfifSegment() []byte {
+	payload := []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	seg := []byte{0xFF, 0xE0}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func jpegWith(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestExifOrientation(t *testing.T) {
+	truncated := exifSegment(binary.LittleEndian, 6)
+	truncated = truncated[:len(truncated)-10]
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "not a jpeg", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "no exif", data: jpegWith(jfifSegment()), want: 1},
+		{name: "little endian", data: jpegWith(exifSegment(binary.LittleEndian, 6)), want: 6},
+		{name: "big endian", data: jpegWith(exifSegment(binary.BigEndian, 8)), want: 8},
+		{name: "after jfif", data: jpegWith(jfifSegment(), exifSegment(binary.BigEndian, 3)), want: 3},
+		{name: "invalid value", data: jpegWith(exifSegment(binary.LittleEndian, 9)), want: 1},
+		{name: "truncated segment", data: append([]byte{0xFF, 0xD8}, truncated...), want: 1},
+		{
+			name: "exif after the scan is ignored",
+			data: append(jpegWith(), exifSegment(binary.LittleEndian, 6)...),
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := exifOrientation(tt.data); got != tt.want {
+					t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+// letters renders an image whose pixels are told apart by their red value, one letter per pixel.
+func letters(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			img.Set(x, y, color.RGBA{R: row[x], A: 0xFF})
+		}
+	}
+	return img
+}
+
+func rows(img *image.RGBA) []string {
+	var res []string
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		var row []byte
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			row = append(row, img.RGBAAt(x, y).R)
+		}
+		res = append(res, string(row))
+	}
+	return res
+}
+
+func TestOrient(t *testing.T) {
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{orientation: 0, want: []string{"ABC", "DEF"}},
+		{orientation: 1, want: []string{"ABC", "DEF"}},
+		{orientation: 2, want: []string{"CBA", "FED"}},
+		{orientation: 3, want: []string{"FED", "CBA"}},
+		{orientation: 4, want: []string{"DEF", "ABC"}},
+		{orientation: 5, want: []string{"AD", "BE", "CF"}},
+		{orientation: 6, want: []string{"DA", "EB", "FC"}},
+		{orientation: 7, want: []string{"FC", "EB", "DA"}},
+		{orientation: 8, want: []string{"CF", "BE", "AD"}},
+		{orientation: 9, want: []string{"ABC", "DEF"}},
+	}
+	for _, tt := range tests {
+		got := rows(orient(letters("ABC", "DEF"), tt.orientation))
+		if len(got) != len(tt.want) {
+			t.Errorf("orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func encodeJPEG(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	res := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		res = append(res, s...)
+	}
+	return append(res, data[2:]...)
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader is the start of a PNG of the given size, enough for image.DecodeConfig.
+func pngHeader(w, h uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcess(t *testing.T) {
+	var gifData bytes.Buffer
+	paletted := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black})
+	if err := gif.Encode(&gifData, paletted, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		data           []byte
+		wantErr        error
+		wantType       string
+		photoW, photoH int
+		thumbW, thumbH int
+	}{
+		{
+			name: "small jpeg is kept", data: encodeJPEG(t, 300, 200), wantType: "image/jpeg",
+			photoW: 300, photoH: 200, thumbW: 300, thumbH: 200,
+		},
+		{
+			name: "rotated jpeg is turned upright", data: encodeJPEG(t, 400, 200, exifSegment(binary.BigEndian, 6)),
+			wantType: "image/jpeg", photoW: 200, photoH: 400, thumbW: 160, thumbH: 320,
+		},
+		{
+			name: "large png is downscaled", data: encodePNG(t, 4096, 1024), wantType: "image/png",
+			photoW: 2048, photoH: 512, thumbW: 320, thumbH: 80,
+		},
+		{name: "gif", data: gifData.Bytes(), wantErr: ErrUnsupported},
+		{name: "garbage", data: []byte("not an image"), wantErr: ErrUnsupported},
+		{name: "decompression bomb", data: pngHeader(10000, 10000), wantErr: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				photo, thumb, err := Process(tt.data)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if photo.ContentType != tt.wantType || thumb.ContentType != tt.wantType {
+					t.Errorf("content types = %s, %s, want %s", photo.ContentType, thumb.ContentType, tt.wantType)
+				}
+				if photo.Width != tt.photoW || photo.Height != tt.photoH {
+					t.Errorf("photo is %dx%d, want %dx%d", photo.Width, photo.Height, tt.photoW, tt.photoH)
+				}
+				if thumb.Width != tt.thumbW || thumb.Height != tt.thumbH {
+					t.Errorf("thumbnail is %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.thumbW, tt.thumbH)
+				}
+				if bytes.Contains(photo.Data, []byte("Exif\x00\x00")) {
+					t.Error("the photo kept its EXIF segment")
+				}
+			},
+		)
+	}
+}
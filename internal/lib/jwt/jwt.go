@@ -1,17 +1,44 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"strings"
 	"time"
 )
 
-func NewToken(user models.User, duration time.Duration, secret string) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims carry the permissions the user had when the token was issued. Changes to the roles
+// of the user apply to the tokens issued afterwards. SessionID names the login the token was
+// issued at; tokens issued before sessions were tracked have none and are refused.
+//
+// Tokens issued to an OAuth client name the client and the grant instead, and carry the scopes
+// the user consented to but no permissions.
+type Claims struct {
+	UserID      int
+	Email       string
+	Permissions []string
+	SessionID   int64
+	ClientID    string
+	GrantID     int64
+	Scopes      []string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+func NewToken(
+	user models.User, sessionID int64, permissions []string, duration time.Duration, secret string,
+) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.ID
+	claims["sid"] = sessionID
 	claims["email"] = user.Email
+	claims["perms"] = permissions
 	claims["exp"] = time.Now().Add(duration).Unix()
 
 	tokenString, err := token.SignedString([]byte(secret))
@@ -21,3 +48,79 @@ func NewToken(user models.User, duration time.Duration, secret string) (string,
 
 	return tokenString, nil
 }
+
+// NewClientToken issues an access token to an OAuth client, scope being space separated as in OAuth2.
+func NewClientToken(
+	user models.User, clientID string, grantID int64, scopes []string, duration time.Duration, secret string,
+) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+
+	now := time.Now()
+	claims := token.Claims.(jwt.MapClaims)
+	claims["uid"] = user.ID
+	claims["cid"] = clientID
+	claims["gid"] = grantID
+	claims["scope"] = strings.Join(scopes, " ")
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(duration).Unix()
+
+	return token.SignedString([]byte(secret))
+}
+
+func ParseToken(tokenString string, secret string) (*Claims, error) {
+	const op = "jwt.ParseToken"
+
+	token, err := jwt.Parse(
+		tokenString, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return []byte(secret), nil
+		},
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	uid, ok := claims["uid"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	email, _ := claims["email"].(string)
+
+	var perms []string
+	list, _ := claims["perms"].([]any)
+	for _, p := range list {
+		if perm, ok := p.(string); ok {
+			perms = append(perms, perm)
+		}
+	}
+
+	c := &Claims{UserID: int(uid), Email: email, Permissions: perms}
+	if sid, ok := claims["sid"].(float64); ok {
+		c.SessionID = int64(sid)
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		c.ExpiresAt = exp.Time
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		c.IssuedAt = iat.Time
+	}
+	if cid, _ := claims["cid"].(string); cid != "" {
+		gid, ok := claims["gid"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		scope, _ := claims["scope"].(string)
+		c.ClientID = cid
+		c.GrantID = int64(gid)
+		c.Scopes = strings.Fields(scope)
+	}
+
+	return c, nil
+}
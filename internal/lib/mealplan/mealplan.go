@@ -0,0 +1,444 @@
+package mealplan
+
+import (
+	"errors"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/energy"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// DefaultTolerance is the accepted relative deviation from the calorie target.
+const DefaultTolerance = 0.05
+
+// activityFactor assumes a lightly active day: plans are made ahead, before any workout is logged.
+const activityFactor = 1.375
+
+const (
+	// attempts is the number of item selections tried per day before keeping the best one.
+	attempts   = 12
+	maxMoves   = 1000
+	macroSlack = 0.5
+)
+
+var (
+	ErrNoCandidates = errors.New("no foods or recipes match the preferences")
+	ErrItemNotFound = errors.New("meal plan item not found")
+)
+
+// Candidate is a food or a recipe the planner may use. Unit holds the nutrients of one portion
+// unit: 100 g of a food or one serving of a recipe.
+type Candidate struct {
+	FoodID   *int
+	RecipeID *int
+	Name     string
+	Unit     models.Nutrients
+}
+
+func (c Candidate) same(o Candidate) bool {
+	if c.FoodID != nil && o.FoodID != nil {
+		return *c.FoodID == *o.FoodID
+	}
+	if c.RecipeID != nil && o.RecipeID != nil {
+		return *c.RecipeID == *o.RecipeID
+	}
+	return false
+}
+
+// Roles group candidates by what they bring to a meal, so every main meal gets a protein source.
+const (
+	roleProtein = iota
+	roleCarb
+	roleFat
+	roleOther
+	roleRecipe
+)
+
+func roleOf(c Candidate) int {
+	if c.RecipeID != nil {
+		return roleRecipe
+	}
+	if c.Unit.Kcal <= 0 {
+		return roleOther
+	}
+	switch {
+	case c.Unit.Protein*4/c.Unit.Kcal >= 0.3:
+		return roleProtein
+	case c.Unit.Carbs*4/c.Unit.Kcal >= 0.5:
+		return roleCarb
+	case c.Unit.Fat*9/c.Unit.Kcal >= 0.7:
+		return roleFat
+	}
+	return roleOther
+}
+
+type meal struct {
+	name  string
+	share float64
+	roles []int
+	// withRecipe replaces roles when recipes are available and the seed picks one.
+	withRecipe []int
+}
+
+var meals = []meal{
+	{name: models.MealBreakfast, share: 0.25, roles: []int{roleCarb, roleProtein}},
+	{
+		name: models.MealLunch, share: 0.35, roles: []int{roleProtein, roleCarb, roleFat},
+		withRecipe: []int{roleRecipe, roleOther},
+	},
+	{
+		name: models.MealDinner, share: 0.30, roles: []int{roleProtein, roleCarb, roleFat},
+		withRecipe: []int{roleRecipe, roleOther},
+	},
+	{name: models.MealSnack, share: 0.10, roles: []int{roleOther}},
+}
+
+func mealIndex(name string) int {
+	return slices.IndexFunc(meals, func(m meal) bool { return m.name == name })
+}
+
+// TargetsFor derives daily targets from the profile: the Mifflin-St Jeor BMR of a lightly active
+// day adjusted to the goal, protein per kg of body weight, 20-35 % of energy from fat and the
+// remaining energy from carbs.
+func TargetsFor(user models.User, tolerance float64) models.MealPlanTargets {
+	weight := float64(user.Weight)
+	kcal := energy.BMR(user.IsMale, user.Age, user.Height, weight) * activityFactor
+
+	protein := models.MacroRange{Min: 1.2 * weight, Max: 1.8 * weight}
+	switch user.Goal {
+	case "lose":
+		kcal -= 500
+		protein = models.MacroRange{Min: 1.6 * weight, Max: 2.2 * weight}
+	case "gain":
+		kcal += 300
+		protein = models.MacroRange{Min: 1.6 * weight, Max: 2.2 * weight}
+	}
+	floor := 1200.0
+	if user.IsMale {
+		floor = 1500
+	}
+	kcal = math.Max(kcal, floor)
+
+	fat := models.MacroRange{Min: 0.20 * kcal / 9, Max: 0.35 * kcal / 9}
+	carbs := models.MacroRange{
+		Min: math.Max(kcal-protein.Max*4-fat.Max*9, 0) / 4,
+		Max: math.Max(kcal-protein.Min*4-fat.Min*9, 0) / 4,
+	}
+
+	r := func(v float64) float64 { return math.Round(v) }
+	return models.MealPlanTargets{
+		Kcal:      r(kcal),
+		Tolerance: tolerance,
+		Protein:   models.MacroRange{Min: r(protein.Min), Max: r(protein.Max)},
+		Carbs:     models.MacroRange{Min: r(carbs.Min), Max: r(carbs.Max)},
+		Fat:       models.MacroRange{Min: r(fat.Min), Max: r(fat.Max)},
+	}
+}
+
+// item is a candidate with a portion in units. Fixed items keep their portion while rebalancing.
+type item struct {
+	c      Candidate
+	meal   int
+	amount float64
+	fixed  bool
+}
+
+// bounds returns the portion limits and step in units: 20-500 g by 10 g for foods, half to three
+// servings by half servings for recipes. A single food never brings more than 40 % of the day.
+func (it item) bounds(t models.MealPlanTargets) (lo, hi, step float64) {
+	if it.c.RecipeID != nil {
+		return 0.5, 3, 0.5
+	}
+	hi = 5
+	if it.c.Unit.Kcal > 0 {
+		hi = math.Min(hi, math.Floor(0.4*t.Kcal/it.c.Unit.Kcal*10)/10)
+	}
+	return 0.2, math.Max(hi, 0.2), 0.1
+}
+
+func (it item) snap(t models.MealPlanTargets, amount float64) float64 {
+	lo, hi, step := it.bounds(t)
+	amount = math.Round(amount/step) * step
+	return math.Min(math.Max(amount, lo), hi)
+}
+
+func totals(items []item) (models.Nutrients, []float64) {
+	var n models.Nutrients
+	perMeal := make([]float64, len(meals))
+	for _, it := range items {
+		portion := nutrition.Scale(it.c.Unit, it.amount*100)
+		n = nutrition.Add(n, portion)
+		perMeal[it.meal] += portion.Kcal
+	}
+	return n, perMeal
+}
+
+func outside(v float64, r models.MacroRange) float64 {
+	switch {
+	case v < r.Min:
+		return r.Min - v
+	case v > r.Max:
+		return v - r.Max
+	}
+	return 0
+}
+
+// cost is below one when the calories are within the tolerance. Every 2 g outside a macro range
+// weighs as much as a full tolerance, while a meal 10 % of the day away from its share weighs a quarter.
+func cost(items []item, t models.MealPlanTargets) float64 {
+	n, perMeal := totals(items)
+
+	sq := func(v float64) float64 { return v * v }
+	c := sq((n.Kcal - t.Kcal) / (t.Kcal * t.Tolerance))
+	c += sq(outside(n.Protein, t.Protein)/2) + sq(outside(n.Carbs, t.Carbs)/2) + sq(outside(n.Fat, t.Fat)/2)
+	for i, m := range meals {
+		c += 0.25 * sq((perMeal[i]-m.share*t.Kcal)/(0.1*t.Kcal))
+	}
+	return c
+}
+
+func onTarget(items []item, t models.MealPlanTargets) bool {
+	n, _ := totals(items)
+	return math.Abs(n.Kcal-t.Kcal) <= t.Kcal*t.Tolerance &&
+		outside(n.Protein, t.Protein) <= macroSlack &&
+		outside(n.Carbs, t.Carbs) <= macroSlack &&
+		outside(n.Fat, t.Fat) <= macroSlack
+}
+
+// rebalance adjusts the portions of the items that are not fixed by steepest descent, one
+// portion step at a time, until no step lowers the cost.
+func rebalance(items []item, t models.MealPlanTargets) float64 {
+	best := cost(items, t)
+	for range maxMoves {
+		bestItem, bestAmount := -1, 0.0
+		for i := range items {
+			if items[i].fixed {
+				continue
+			}
+			lo, hi, step := items[i].bounds(t)
+			current := items[i].amount
+			for _, next := range []float64{current + step, current - step} {
+				next = math.Round(next/step) * step
+				if next < lo-1e-9 || next > hi+1e-9 {
+					continue
+				}
+				items[i].amount = next
+				if c := cost(items, t); c < best-1e-9 {
+					best, bestItem, bestAmount = c, i, next
+				}
+			}
+			items[i].amount = current
+		}
+		if bestItem < 0 {
+			break
+		}
+		items[bestItem].amount = bestAmount
+	}
+	return best
+}
+
+type pools [][]Candidate
+
+func newPools(candidates []Candidate) pools {
+	p := make(pools, roleRecipe+1)
+	for _, c := range candidates {
+		role := roleOf(c)
+		p[role] = append(p[role], c)
+	}
+	return p
+}
+
+// pick draws a candidate of the role, avoiding the excluded ones when possible and falling back
+// to any food when the role has none.
+func (p pools) pick(rng *rand.Rand, role int, exclude ...[]Candidate) (Candidate, bool) {
+	pool := p[role]
+	if len(pool) == 0 && role != roleRecipe {
+		pool = slices.Concat(p[roleProtein], p[roleCarb], p[roleFat], p[roleOther])
+	}
+
+	for n := len(exclude); n >= 0; n-- {
+		excluded := slices.Concat(exclude[:n]...)
+		var allowed []Candidate
+		for _, c := range pool {
+			if !slices.ContainsFunc(excluded, c.same) {
+				allowed = append(allowed, c)
+			}
+		}
+		if len(allowed) > 0 {
+			return allowed[rng.IntN(len(allowed))], true
+		}
+	}
+	return Candidate{}, false
+}
+
+func candidates(items []item) []Candidate {
+	res := make([]Candidate, len(items))
+	for i, it := range items {
+		res[i] = it.c
+	}
+	return res
+}
+
+// draft selects items for every meal of a day, avoiding the items of the previous day, and gives
+// each item an equal part of its meal's calories.
+func draft(rng *rand.Rand, p pools, t models.MealPlanTargets, previous []Candidate) []item {
+	var items []item
+	for mi, m := range meals {
+		roles := m.roles
+		if m.withRecipe != nil && len(p[roleRecipe]) > 0 && rng.IntN(2) == 0 {
+			roles = m.withRecipe
+		}
+
+		for _, role := range roles {
+			c, ok := p.pick(rng, role, candidates(items), previous)
+			if !ok {
+				continue
+			}
+			it := item{c: c, meal: mi}
+			if c.Unit.Kcal > 0 {
+				it.amount = m.share * t.Kcal / float64(len(roles)) / c.Unit.Kcal
+			}
+			it.amount = it.snap(t, it.amount)
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// Generate plans the given number of days. The same seed and candidates always give the same plan.
+func Generate(t models.MealPlanTargets, list []Candidate, days int, seed int64) ([]models.MealPlanDay, error) {
+	if len(list) == 0 {
+		return nil, ErrNoCandidates
+	}
+	p := newPools(list)
+
+	res := make([]models.MealPlanDay, 0, days)
+	var previous []Candidate
+	for day := 1; day <= days; day++ {
+		rng := rand.New(rand.NewPCG(uint64(seed), uint64(day)))
+
+		var best []item
+		bestCost := math.Inf(1)
+		for range attempts {
+			items := draft(rng, p, t, previous)
+			if c := rebalance(items, t); c < bestCost {
+				best, bestCost = items, c
+			}
+			if onTarget(best, t) {
+				break
+			}
+		}
+
+		res = append(res, toDay(day, best, t))
+		previous = candidates(best)
+	}
+	return res, nil
+}
+
+// Swap replaces one item of a day by the replacement, or by a candidate of the same kind drawn
+// from the seed when replacement is nil. The new item takes over the calories of the old one and
+// the other portions of the day are rebalanced around it.
+func Swap(
+	t models.MealPlanTargets, day models.MealPlanDay, mealName string, index int, replacement *Candidate,
+	list []Candidate, seed int64,
+) (models.MealPlanDay, error) {
+	mi := mealIndex(mealName)
+	if mi < 0 {
+		return day, ErrItemNotFound
+	}
+
+	items, target := fromDay(day, mi, index)
+	if target < 0 {
+		return day, ErrItemNotFound
+	}
+	old := items[target]
+
+	if replacement == nil {
+		stream := uint64(day.Day)<<32 | uint64(mi)<<16 | uint64(index)
+		rng := rand.New(rand.NewPCG(uint64(seed), stream))
+		// The old item is the last one given up, when the day already has every other candidate.
+		c, ok := newPools(list).pick(rng, roleOf(old.c), []Candidate{old.c}, candidates(items))
+		if !ok {
+			return day, ErrNoCandidates
+		}
+		replacement = &c
+	}
+
+	it := item{c: *replacement, meal: mi, fixed: true}
+	if replacement.Unit.Kcal > 0 {
+		it.amount = old.amount * old.c.Unit.Kcal / replacement.Unit.Kcal
+	}
+	it.amount = it.snap(t, it.amount)
+	items[target] = it
+
+	rebalance(items, t)
+	return toDay(day.Day, items, t), nil
+}
+
+// fromDay turns a stored day back into items, recovering the nutrients per unit from the portions.
+// It also returns the position of the requested item, or -1.
+func fromDay(day models.MealPlanDay, mealIdx int, index int) ([]item, int) {
+	var items []item
+	target := -1
+	for _, pm := range day.Meals {
+		mi := mealIndex(pm.Name)
+		if mi < 0 {
+			continue
+		}
+		for i, pi := range pm.Items {
+			amount := pi.Servings
+			if pi.RecipeID == nil {
+				amount = pi.Grams / 100
+			}
+			if amount <= 0 {
+				continue
+			}
+			if mi == mealIdx && i == index {
+				target = len(items)
+			}
+			items = append(
+				items, item{
+					c: Candidate{
+						FoodID: pi.FoodID, RecipeID: pi.RecipeID, Name: pi.Name,
+						Unit: nutrition.Scale(pi.Nutrients, 100/amount),
+					},
+					meal:   mi,
+					amount: amount,
+				},
+			)
+		}
+	}
+	return items, target
+}
+
+func toDay(day int, items []item, t models.MealPlanTargets) models.MealPlanDay {
+	res := models.MealPlanDay{Day: day, OnTarget: onTarget(items, t)}
+
+	n, _ := totals(items)
+	res.Totals = nutrition.Round(n)
+
+	for mi, m := range meals {
+		pm := models.PlannedMeal{Name: m.name, Items: []models.PlannedItem{}}
+		for _, it := range items {
+			if it.meal != mi {
+				continue
+			}
+			pi := models.PlannedItem{
+				FoodID:    it.c.FoodID,
+				RecipeID:  it.c.RecipeID,
+				Name:      it.c.Name,
+				Nutrients: nutrition.Round(nutrition.Scale(it.c.Unit, it.amount*100)),
+			}
+			if it.c.RecipeID != nil {
+				pi.Servings = it.amount
+			} else {
+				pi.Grams = math.Round(it.amount * 100)
+			}
+			pm.Items = append(pm.Items, pi)
+		}
+		res.Meals = append(res.Meals, pm)
+	}
+	return res
+}
@@ -0,0 +1,209 @@
+package mealplan
+
+import (
+	"errors"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+	"testing"
+)
+
+func food(id int, name string, kcal, protein, carbs, fat float64) Candidate {
+	return Candidate{
+		FoodID: &id, Name: name, Unit: models.Nutrients{Kcal: kcal, Protein: protein, Carbs: carbs, Fat: fat},
+	}
+}
+
+func recipe(id int, name string, kcal, protein, carbs, fat float64) Candidate {
+	return Candidate{
+		RecipeID: &id, Name: name, Unit: models.Nutrients{Kcal: kcal, Protein: protein, Carbs: carbs, Fat: fat},
+	}
+}
+
+// testCandidates has a few foods of every role and two recipes, per 100 g and per serving.
+var testCandidates = []Candidate{
+	food(1, "Chicken breast", 165, 31, 0, 3.6),
+	food(2, "Tuna", 132, 28, 0, 1.3),
+	food(3, "Greek yogurt", 97, 9, 3.9, 5),
+	food(4, "Rice", 130, 2.7, 28, 0.3),
+	food(5, "Rolled oats", 379, 13.2, 67.7, 6.5),
+	food(6, "Banana", 89, 1.1, 22.8, 0.3),
+	food(7, "Olive oil", 884, 0, 0, 100),
+	food(8, "Walnuts", 654, 15, 14, 65),
+	food(9, "Dark chocolate", 546, 4.9, 61, 31),
+	food(10, "Whole milk", 61, 3.2, 4.8, 3.3),
+	recipe(11, "Chili con carne", 520, 38, 45, 18),
+	recipe(12, "Lentil curry", 430, 21, 60, 11),
+}
+
+var testTargets = models.MealPlanTargets{
+	Kcal: 2400, Tolerance: DefaultTolerance,
+	Protein: models.MacroRange{Min: 96, Max: 144},
+	Carbs:   models.MacroRange{Min: 254, Max: 394},
+	Fat:     models.MacroRange{Min: 53, Max: 93},
+}
+
+func TestTargetsFor(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		want models.MealPlanTargets
+	}{
+		{
+			name: "maintain",
+			user: models.User{IsMale: true, Age: 30, Height: 180, Weight: 80, Goal: "maintain"},
+			want: models.MealPlanTargets{
+				Kcal: 2448, Tolerance: 0.05,
+				Protein: models.MacroRange{Min: 96, Max: 144},
+				Carbs:   models.MacroRange{Min: 254, Max: 394},
+				Fat:     models.MacroRange{Min: 54, Max: 95},
+			},
+		},
+		{
+			name: "lose is floored",
+			user: models.User{Age: 40, Height: 160, Weight: 50, Goal: "lose"},
+			want: models.MealPlanTargets{
+				Kcal: 1200, Tolerance: 0.1,
+				Protein: models.MacroRange{Min: 80, Max: 110},
+				Carbs:   models.MacroRange{Min: 85, Max: 160},
+				Fat:     models.MacroRange{Min: 27, Max: 47},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := TargetsFor(tt.user, tt.want.Tolerance); got != tt.want {
+					t.Errorf("TargetsFor() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name string
+		seed int64
+		days int
+	}{
+		{name: "one day", seed: 1, days: 1},
+		{name: "a week", seed: 42, days: 7},
+		{name: "negative seed", seed: -7, days: 3},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				plan, err := Generate(testTargets, testCandidates, tt.days, tt.seed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				again, err := Generate(testTargets, testCandidates, tt.days, tt.seed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(plan, again) {
+					t.Fatal("the same seed gave different plans")
+				}
+
+				if len(plan) != tt.days {
+					t.Fatalf("got %d days, want %d", len(plan), tt.days)
+				}
+				for i, day := range plan {
+					if day.Day != i+1 {
+						t.Errorf("day %d is numbered %d", i+1, day.Day)
+					}
+					if len(day.Meals) != len(meals) {
+						t.Errorf("day %d has %d meals, want %d", day.Day, len(day.Meals), len(meals))
+					}
+					if !day.OnTarget {
+						t.Errorf("day %d is off target: %+v", day.Day, day.Totals)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestGenerateSeedsDiffer(t *testing.T) {
+	a, err := Generate(testTargets, testCandidates, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Generate(testTargets, testCandidates, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a, b) {
+		t.Error("different seeds gave the same plan")
+	}
+}
+
+func TestGenerateWithoutCandidates(t *testing.T) {
+	if _, err := Generate(testTargets, nil, 1, 1); !errors.Is(err, ErrNoCandidates) {
+		t.Errorf("err = %v, want ErrNoCandidates", err)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	plan, err := Generate(testTargets, testCandidates, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := plan[1]
+	lunch := day.Meals[mealIndex(models.MealLunch)]
+	oldName := lunch.Items[0].Name
+
+	swapped, err := Swap(testTargets, day, models.MealLunch, 0, nil, testCandidates, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Swap(testTargets, day, models.MealLunch, 0, nil, testCandidates, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(swapped, again) {
+		t.Fatal("the same seed swapped differently")
+	}
+	if got := swapped.Meals[mealIndex(models.MealLunch)].Items[0].Name; got == oldName {
+		t.Errorf("the item was swapped for itself, %q", got)
+	}
+	if swapped.Day != day.Day {
+		t.Errorf("Day = %d, want %d", swapped.Day, day.Day)
+	}
+
+	milk := testCandidates[9]
+	chosen, err := Swap(testTargets, day, models.MealSnack, 0, &milk, testCandidates, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chosen.Meals[mealIndex(models.MealSnack)].Items[0]; got.Name != milk.Name || got.Grams <= 0 {
+		t.Errorf("snack = %+v, want %s by grams", got, milk.Name)
+	}
+}
+
+func TestSwapNotFound(t *testing.T) {
+	plan, err := Generate(testTargets, testCandidates, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		meal  string
+		index int
+	}{
+		{name: "unknown meal", meal: "brunch", index: 0},
+		{name: "index out of range", meal: models.MealSnack, index: 9},
+		{name: "negative index", meal: models.MealLunch, index: -1},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := Swap(testTargets, plan[0], tt.meal, tt.index, nil, testCandidates, 3)
+				if !errors.Is(err, ErrItemNotFound) {
+					t.Errorf("err = %v, want ErrItemNotFound", err)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,259 @@
+package nutrition
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const kJPerKcal = 4.184
+
+// saltPerSodium converts grams of sodium to grams of salt, as used on EU labels.
+const saltPerSodium = 2.5
+
+// Scale returns the nutrients of the given amount of a food described per 100 g.
+func Scale(per100g models.Nutrients, grams float64) models.Nutrients {
+	f := grams / 100
+	return models.Nutrients{
+		Kcal:         per100g.Kcal * f,
+		Protein:      per100g.Protein * f,
+		Carbs:        per100g.Carbs * f,
+		Fat:          per100g.Fat * f,
+		SaturatedFat: per100g.SaturatedFat * f,
+		Sugars:       per100g.Sugars * f,
+		Fiber:        per100g.Fiber * f,
+		Sodium:       per100g.Sodium * f,
+		Calcium:      per100g.Calcium * f,
+		Iron:         per100g.Iron * f,
+		Potassium:    per100g.Potassium * f,
+		VitaminC:     per100g.VitaminC * f,
+	}
+}
+
+func Add(a, b models.Nutrients) models.Nutrients {
+	return models.Nutrients{
+		Kcal:         a.Kcal + b.Kcal,
+		Protein:      a.Protein + b.Protein,
+		Carbs:        a.Carbs + b.Carbs,
+		Fat:          a.Fat + b.Fat,
+		SaturatedFat: a.SaturatedFat + b.SaturatedFat,
+		Sugars:       a.Sugars + b.Sugars,
+		Fiber:        a.Fiber + b.Fiber,
+		Sodium:       a.Sodium + b.Sodium,
+		Calcium:      a.Calcium + b.Calcium,
+		Iron:         a.Iron + b.Iron,
+		Potassium:    a.Potassium + b.Potassium,
+		VitaminC:     a.VitaminC + b.VitaminC,
+	}
+}
+
+// Recipe sums the nutrients of the ingredients and splits them per serving and per 100 g,
+// using the cooked weight for the latter when it is known. Foods missing from the map are skipped.
+func Recipe(recipe *models.Recipe, foods map[int]models.Food) *models.RecipeNutrition {
+	var n models.RecipeNutrition
+	n.Total, n.Weight = recipeTotal(recipe, foods)
+	n.PerServing = Round(Scale(n.Total, 100/float64(max(recipe.Servings, 1))))
+
+	weight := n.Weight
+	if recipe.CookedWeight != nil {
+		weight = *recipe.CookedWeight
+	}
+	if weight > 0 {
+		n.Per100g = Round(Scale(n.Total, 100*100/weight))
+	}
+
+	n.Total = Round(n.Total)
+	n.Weight = math.Round(n.Weight*100) / 100
+	return &n
+}
+
+// PerServing returns the nutrients of one serving of the recipe unrounded, so that amounts of
+// several servings are scaled from the exact value and rounded once.
+func PerServing(recipe *models.Recipe, foods map[int]models.Food) models.Nutrients {
+	total, _ := recipeTotal(recipe, foods)
+	return Scale(total, 100/float64(max(recipe.Servings, 1)))
+}
+
+// recipeTotal sums the nutrients and the weight of the ingredients whose food is known.
+func recipeTotal(recipe *models.Recipe, foods map[int]models.Food) (models.Nutrients, float64) {
+	var total models.Nutrients
+	var weight float64
+	for _, ing := range recipe.Ingredients {
+		food, ok := foods[ing.FoodID]
+		if !ok {
+			continue
+		}
+		weight += ing.Grams
+		total = Add(total, Scale(food.Nutrients, ing.Grams))
+	}
+	return total, weight
+}
+
+// Round rounds every value to two decimals, the precision stored in the database.
+func Round(n models.Nutrients) models.Nutrients {
+	r := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return models.Nutrients{
+		Kcal:         r(n.Kcal),
+		Protein:      r(n.Protein),
+		Carbs:        r(n.Carbs),
+		Fat:          r(n.Fat),
+		SaturatedFat: r(n.SaturatedFat),
+		Sugars:       r(n.Sugars),
+		Fiber:        r(n.Fiber),
+		Sodium:       r(n.Sodium),
+		Calcium:      r(n.Calcium),
+		Iron:         r(n.Iron),
+		Potassium:    r(n.Potassium),
+		VitaminC:     r(n.VitaminC),
+	}
+}
+
+// FromOpenFoodFacts normalises the "<nutrient>_100g" values of an Open Food Facts product.
+// Energy falls back to kJ, sodium falls back to salt and minerals are converted from grams
+// to milligrams. It reports false when the values are missing or physically impossible.
+func FromOpenFoodFacts(values map[string]string) (models.Nutrients, bool) {
+	get := func(key string) (float64, bool) {
+		str := strings.TrimSpace(values[key+"_100g"])
+		if str == "" {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return 0, false
+		}
+		return v, true
+	}
+	opt := func(key string) float64 {
+		v, _ := get(key)
+		return v
+	}
+
+	kcal, ok := get("energy-kcal")
+	if !ok {
+		kj, ok := get("energy")
+		if !ok {
+			return models.Nutrients{}, false
+		}
+		kcal = kj / kJPerKcal
+	}
+
+	sodium, ok := get("sodium")
+	if !ok {
+		sodium = opt("salt") / saltPerSodium
+	}
+
+	n := models.Nutrients{
+		Kcal:         kcal,
+		Protein:      opt("proteins"),
+		Carbs:        opt("carbohydrates"),
+		Fat:          opt("fat"),
+		SaturatedFat: opt("saturated-fat"),
+		Sugars:       opt("sugars"),
+		Fiber:        opt("fiber"),
+		Sodium:       sodium * 1000,
+		Calcium:      opt("calcium") * 1000,
+		Iron:         opt("iron") * 1000,
+		Potassium:    opt("potassium") * 1000,
+		VitaminC:     opt("vitamin-c") * 1000,
+	}
+
+	if n.Kcal > 900 || n.Protein+n.Carbs+n.Fat+n.Fiber > 105 || n.SaturatedFat > n.Fat+0.5 ||
+		n.Sugars > n.Carbs+0.5 || n.Sodium > 100000 {
+		return models.Nutrients{}, false
+	}
+	return Round(n), true
+}
+
+// NormalizeBarcode strips everything but the ASCII digits, pads UPC-A codes to EAN-13 and verifies
+// the GS1 check digit of EAN-8, EAN-13 and GTIN-14 codes.
+func NormalizeBarcode(code string) (string, bool) {
+	code = strings.Map(
+		func(r rune) rune {
+			if '0' <= r && r <= '9' {
+				return r
+			}
+			return -1
+		}, code,
+	)
+
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 8 && len(code) != 13 && len(code) != 14 {
+		return "", false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	if (10-sum%10)%10 != int(code[len(code)-1]-'0') {
+		return "", false
+	}
+	return code, true
+}
+
+// AllergensFromTags keeps the known allergens of Open Food Facts tags such as "en:milk".
+func AllergensFromTags(tags []string) []string {
+	res := []string{}
+	for _, tag := range tags {
+		name := strings.TrimPrefix(strings.TrimSpace(tag), "en:")
+		if slices.Contains(models.Allergens, name) && !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// DietsFromTags reads the Open Food Facts ingredients analysis tags. A vegan food is vegetarian too.
+func DietsFromTags(tags []string) []string {
+	res := []string{}
+	for _, tag := range tags {
+		switch strings.TrimSpace(tag) {
+		case "en:vegan":
+			return []string{models.DietVegan, models.DietVegetarian}
+		case "en:vegetarian":
+			res = []string{models.DietVegetarian}
+		}
+	}
+	return res
+}
+
+// DietLabels returns the food labels compatible with a diet, nil meaning any food is.
+func DietLabels(diet string) []string {
+	switch diet {
+	case models.DietVegan:
+		return []string{models.DietVegan}
+	case models.DietVegetarian:
+		return []string{models.DietVegan, models.DietVegetarian}
+	case models.DietPescatarian:
+		return []string{models.DietVegan, models.DietVegetarian, models.DietPescatarian}
+	}
+	return nil
+}
+
+// Suits reports whether a food with the given labels and allergens fits the preferences.
+func Suits(prefs models.Preferences, diets []string, allergens []string) bool {
+	for _, a := range allergens {
+		if slices.Contains(prefs.Allergens, a) {
+			return false
+		}
+	}
+
+	labels := DietLabels(prefs.Diet)
+	if labels == nil {
+		return true
+	}
+	for _, d := range diets {
+		if slices.Contains(labels, d) {
+			return true
+		}
+	}
+	return false
+}
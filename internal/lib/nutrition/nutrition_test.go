@@ -0,0 +1,169 @@
+package nutrition
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeBarcode(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		want   string
+		wantOK bool
+	}{
+		{name: "ean-13", code: "4006381333931", want: "4006381333931", wantOK: true},
+		{name: "separators are stripped", code: " 400-6381 333931\n", want: "4006381333931", wantOK: true},
+		{name: "upc-a is padded", code: "036000291452", want: "0036000291452", wantOK: true},
+		{name: "ean-8", code: "96385074", want: "96385074", wantOK: true},
+		{name: "gtin-14", code: "10012345678902", want: "10012345678902", wantOK: true},
+		{name: "wrong check digit", code: "4006381333932"},
+		{name: "wrong upc-a check digit", code: "036000291453"},
+		{name: "too short", code: "12345"},
+		{name: "too long", code: "400638133393100"},
+		{name: "empty", code: ""},
+		{name: "non-ascii digits", code: "٤٠٠٦٣٨١٣٣٣٩٣١"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok := NormalizeBarcode(tt.code)
+				if got != tt.want || ok != tt.wantOK {
+					t.Errorf("NormalizeBarcode(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.wantOK)
+				}
+			},
+		)
+	}
+}
+
+func TestFromOpenFoodFacts(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   models.Nutrients
+		wantOK bool
+	}{
+		{
+			name: "kcal and sodium",
+			values: map[string]string{
+				"energy-kcal_100g": "389", "proteins_100g": "16.9", "carbohydrates_100g": "66.3", "fat_100g": "6.9",
+				"sodium_100g": "0.002", "iron_100g": "0.0047",
+			},
+			want:   models.Nutrients{Kcal: 389, Protein: 16.9, Carbs: 66.3, Fat: 6.9, Sodium: 2, Iron: 4.7},
+			wantOK: true,
+		},
+		{
+			name:   "energy in kJ and salt",
+			values: map[string]string{"energy_100g": "1000", "salt_100g": "1.25"},
+			want:   models.Nutrients{Kcal: 239.01, Sodium: 500},
+			wantOK: true,
+		},
+		{name: "no energy", values: map[string]string{"proteins_100g": "10"}},
+		{name: "negative energy", values: map[string]string{"energy-kcal_100g": "-5"}},
+		{name: "impossible energy", values: map[string]string{"energy-kcal_100g": "1200"}},
+		{
+			name:   "macros over 100 g",
+			values: map[string]string{"energy-kcal_100g": "500", "proteins_100g": "60", "fat_100g": "50"},
+		},
+		{
+			name:   "more sugars than carbs",
+			values: map[string]string{"energy-kcal_100g": "300", "carbohydrates_100g": "10", "sugars_100g": "20"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok := FromOpenFoodFacts(tt.values)
+				if got != tt.want || ok != tt.wantOK {
+					t.Errorf("FromOpenFoodFacts() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+				}
+			},
+		)
+	}
+}
+
+func TestTags(t *testing.T) {
+	if got, want := AllergensFromTags([]string{"en:milk", " en:gluten", "en:milk", "en:unknown"}),
+		[]string{"milk", "gluten"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllergensFromTags() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{tags: []string{"en:palm-oil-free", "en:vegan"}, want: []string{models.DietVegan, models.DietVegetarian}},
+		{tags: []string{"en:vegetarian", "en:non-vegan"}, want: []string{models.DietVegetarian}},
+		{tags: []string{"en:non-vegetarian"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := DietsFromTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DietsFromTags(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestRecipe(t *testing.T) {
+	foods := map[int]models.Food{
+		1: {ID: 1, Nutrients: models.Nutrients{Kcal: 100, Protein: 10}},
+		2: {ID: 2, Nutrients: models.Nutrients{Kcal: 50, Fat: 1}},
+	}
+	cooked := 200.0
+
+	tests := []struct {
+		name   string
+		recipe models.Recipe
+		want   models.RecipeNutrition
+	}{
+		{
+			name: "per serving and per 100 g",
+			recipe: models.Recipe{
+				Servings:    2,
+				Ingredients: []models.RecipeIngredient{{FoodID: 1, Grams: 150}, {FoodID: 2, Grams: 50}},
+			},
+			want: models.RecipeNutrition{
+				Weight:     200,
+				Total:      models.Nutrients{Kcal: 175, Protein: 15, Fat: 0.5},
+				PerServing: models.Nutrients{Kcal: 87.5, Protein: 7.5, Fat: 0.25},
+				Per100g:    models.Nutrients{Kcal: 87.5, Protein: 7.5, Fat: 0.25},
+			},
+		},
+		{
+			name: "cooked weight and unknown food",
+			recipe: models.Recipe{
+				CookedWeight: &cooked,
+				Ingredients:  []models.RecipeIngredient{{FoodID: 1, Grams: 400}, {FoodID: 9, Grams: 100}},
+			},
+			want: models.RecipeNutrition{
+				Weight:     400,
+				Total:      models.Nutrients{Kcal: 400, Protein: 40},
+				PerServing: models.Nutrients{Kcal: 400, Protein: 40},
+				Per100g:    models.Nutrients{Kcal: 200, Protein: 20},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Recipe(&tt.recipe, foods); *got != tt.want {
+					t.Errorf("Recipe() = %+v, want %+v", *got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+// TestPerServing checks that servings are scaled from the exact value: three servings of a
+// recipe split in three are the whole recipe, not three times a rounded third.
+func TestPerServing(t *testing.T) {
+	foods := map[int]models.Food{1: {ID: 1, Nutrients: models.Nutrients{Kcal: 100}}}
+	recipe := models.Recipe{Servings: 3, Ingredients: []models.RecipeIngredient{{FoodID: 1, Grams: 100}}}
+
+	if got := Recipe(&recipe, foods).PerServing.Kcal; got != 33.33 {
+		t.Errorf("Recipe().PerServing.Kcal = %v, want 33.33", got)
+	}
+	if got := Round(Scale(PerServing(&recipe, foods), 300)).Kcal; got != 100 {
+		t.Errorf("three servings = %v kcal, want 100", got)
+	}
+}
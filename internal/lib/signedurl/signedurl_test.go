@@ -0,0 +1,57 @@
+package signedurl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	signed := Sign("secret", "/api/v1/photos/7", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		secret  string
+		url     string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", secret: "secret", url: signed, now: now},
+		{name: "valid until the second it expires", secret: "secret", url: signed, now: now.Add(time.Hour)},
+		{name: "expired", secret: "secret", url: signed, now: now.Add(time.Hour + time.Second), wantErr: ErrExpired},
+		{name: "wrong secret", secret: "other", url: signed, now: now, wantErr: ErrSignature},
+		{
+			name: "other path", secret: "secret", url: strings.Replace(signed, "/7?", "/8?", 1), now: now,
+			wantErr: ErrSignature,
+		},
+		{
+			name: "extended expiry", secret: "secret", now: now, wantErr: ErrSignature,
+			url: strings.Replace(signed, "expires=1714", "expires=1814", 1),
+		},
+		{
+			name: "tampered signature", secret: "secret", url: signed[:len(signed)-1] + "A", now: now,
+			wantErr: ErrSignature,
+		},
+		{name: "unsigned", secret: "secret", url: "/api/v1/photos/7", now: now, wantErr: ErrSignature},
+		{
+			name: "expiry is not a number", secret: "secret", url: "/api/v1/photos/7?expires=soon&signature=x",
+			now: now, wantErr: ErrSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				u, err := url.Parse(tt.url)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := Verify(tt.secret, u, tt.now); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
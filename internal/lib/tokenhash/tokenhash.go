@@ -0,0 +1,15 @@
+// Package tokenhash derives what is stored of the random secrets handed out to clients, such as
+// personal access tokens, OAuth codes and refresh tokens, and export download links.
+package tokenhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sum returns the hex SHA-256 of token. The secrets are random with enough entropy that a plain
+// hash cannot be reversed, so no salt or slow hash is needed, and the hash can be looked up.
+func Sum(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
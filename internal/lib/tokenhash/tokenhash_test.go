@@ -0,0 +1,10 @@
+package tokenhash
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := Sum("abc"); got != want {
+		t.Errorf("Sum(abc) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package tracks
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// FIT global message numbers and field numbers used by the parser, see the Garmin FIT SDK profile.
+const (
+	fitMsgSession = 18
+	fitMsgRecord  = 20
+	fitMsgSport   = 12
+
+	fitFieldTimestamp        = 253
+	fitFieldPositionLat      = 0
+	fitFieldPositionLong     = 1
+	fitFieldAltitude         = 2
+	fitFieldHeartRate        = 3
+	fitFieldCadence          = 4
+	fitFieldDistance         = 5
+	fitFieldEnhancedAltitude = 78
+	fitFieldSessionSport     = 5
+	fitFieldSportSport       = 0
+)
+
+// fitEpoch is the FIT time origin, 1989-12-31T00:00:00Z, as a unix timestamp.
+const fitEpoch = 631065600
+
+var fitSports = map[uint64]string{1: SportRunning, 2: SportCycling, 11: SportWalking, 17: SportWalking}
+
+var (
+	ErrInvalidFIT = errors.New("invalid FIT file")
+	ErrFITCRC     = errors.New("FIT file checksum mismatch")
+)
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+type fitField struct {
+	num  byte
+	size int
+}
+
+type fitDefinition struct {
+	global   uint16
+	order    binary.ByteOrder
+	fields   []fitField
+	devBytes int
+}
+
+func parseFIT(r io.Reader) (*Track, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 {
+		return nil, ErrInvalidFIT
+	}
+
+	headerSize := int(data[0])
+	if (headerSize != 12 && headerSize != 14) || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return nil, ErrInvalidFIT
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if end+2 > len(data) {
+		return nil, ErrInvalidFIT
+	}
+	if crc := binary.LittleEndian.Uint16(data[end : end+2]); crc != 0 && crc != fitCRC(data[:end]) {
+		return nil, ErrFITCRC
+	}
+
+	t := &Track{}
+	defs := make(map[byte]*fitDefinition)
+	var lastTimestamp uint32
+
+	pos := headerSize
+	for pos < end {
+		h := data[pos]
+		pos++
+
+		var local byte
+		compressed := h&0x80 != 0
+		if compressed {
+			local = (h >> 5) & 0x03
+			offset := uint32(h & 0x1F)
+			ts := lastTimestamp&^0x1F + offset
+			if offset < lastTimestamp&0x1F {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+		} else {
+			local = h & 0x0F
+		}
+
+		if !compressed && h&0x40 != 0 {
+			def, n, err := readFITDefinition(data[pos:end], h&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			defs[local] = def
+			pos += n
+			continue
+		}
+
+		def, ok := defs[local]
+		if !ok {
+			return nil, ErrInvalidFIT
+		}
+
+		values := make(map[byte]uint64, len(def.fields))
+		for _, f := range def.fields {
+			if pos+f.size > end {
+				return nil, ErrInvalidFIT
+			}
+			if v, ok := readFITValue(data[pos:pos+f.size], def.order); ok {
+				values[f.num] = v
+			}
+			pos += f.size
+		}
+		pos += def.devBytes
+		if pos > end {
+			return nil, ErrInvalidFIT
+		}
+
+		if ts, ok := values[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		}
+
+		switch def.global {
+		case fitMsgRecord:
+			if _, ok := values[fitFieldTimestamp]; !ok && !compressed {
+				continue
+			}
+			t.Points = append(t.Points, fitPoint(values, lastTimestamp))
+		case fitMsgSession:
+			if sport, ok := fitSports[values[fitFieldSessionSport]]; ok && t.Sport == "" {
+				t.Sport = sport
+			}
+		case fitMsgSport:
+			if sport, ok := fitSports[values[fitFieldSportSport]]; ok && t.Sport == "" {
+				t.Sport = sport
+			}
+		}
+	}
+
+	return t, nil
+}
+
+func readFITDefinition(data []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, ErrInvalidFIT
+	}
+
+	def := &fitDefinition{order: binary.LittleEndian}
+	if data[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(data[2:4])
+
+	n := int(data[4])
+	pos := 5
+	if len(data) < pos+3*n {
+		return nil, 0, ErrInvalidFIT
+	}
+	for i := 0; i < n; i++ {
+		def.fields = append(def.fields, fitField{num: data[pos], size: int(data[pos+1])})
+		pos += 3
+	}
+
+	if hasDevFields {
+		if len(data) < pos+1 {
+			return nil, 0, ErrInvalidFIT
+		}
+		devN := int(data[pos])
+		pos++
+		if len(data) < pos+3*devN {
+			return nil, 0, ErrInvalidFIT
+		}
+		for i := 0; i < devN; i++ {
+			def.devBytes += int(data[pos+1])
+			pos += 3
+		}
+	}
+
+	return def, pos, nil
+}
+
+// readFITValue decodes 1, 2 and 4 byte scalar fields, reporting false for the FIT "invalid" markers
+// and for field sizes the parser does not use.
+func readFITValue(b []byte, order binary.ByteOrder) (uint64, bool) {
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), b[0] != 0xFF
+	case 2:
+		v := order.Uint16(b)
+		return uint64(v), v != 0xFFFF
+	case 4:
+		v := order.Uint32(b)
+		return uint64(v), v != 0xFFFFFFFF && v != 0x7FFFFFFF
+	}
+	return 0, false
+}
+
+func fitPoint(values map[byte]uint64, timestamp uint32) Point {
+	p := Point{Time: time.Unix(int64(timestamp)+fitEpoch, 0).UTC()}
+
+	lat, okLat := values[fitFieldPositionLat]
+	lon, okLon := values[fitFieldPositionLong]
+	if okLat && okLon {
+		p.HasPosition = true
+		p.Lat = semicircles(lat)
+		p.Lon = semicircles(lon)
+	}
+
+	if v, ok := values[fitFieldEnhancedAltitude]; ok {
+		ele := float64(v)/5 - 500
+		p.Elevation = &ele
+	} else if v, ok := values[fitFieldAltitude]; ok {
+		ele := float64(v)/5 - 500
+		p.Elevation = &ele
+	}
+
+	if v, ok := values[fitFieldDistance]; ok {
+		d := float64(v) / 100
+		p.Distance = &d
+	}
+	if v, ok := values[fitFieldHeartRate]; ok {
+		p.HeartRate = int(v)
+	}
+	if v, ok := values[fitFieldCadence]; ok {
+		p.Cadence = int(v)
+	}
+
+	return p
+}
+
+func semicircles(v uint64) float64 {
+	return float64(int32(uint32(v))) * (180 / math.Pow(2, 31))
+}
+
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
@@ -0,0 +1,61 @@
+package tracks
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type gpxFile struct {
+	Tracks []struct {
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+	HeartRate int      `xml:"extensions>TrackPointExtension>hr"`
+	Cadence   int      `xml:"extensions>TrackPointExtension>cad"`
+}
+
+func parseGPX(r io.Reader) (*Track, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	t := &Track{}
+	for _, trk := range f.Tracks {
+		if t.Sport == "" {
+			t.Sport = trk.Type
+		}
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				point := Point{
+					HasPosition: true,
+					Lat:         p.Lat,
+					Lon:         p.Lon,
+					Elevation:   p.Elevation,
+					HeartRate:   p.HeartRate,
+					Cadence:     p.Cadence,
+				}
+				if p.Time != "" {
+					tm, err := time.Parse(time.RFC3339, p.Time)
+					if err != nil {
+						return nil, err
+					}
+					point.Time = tm
+				}
+				t.Points = append(t.Points, point)
+			}
+		}
+	}
+
+	return t, nil
+}
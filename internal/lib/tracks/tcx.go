@@ -0,0 +1,75 @@
+package tracks
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			Tracks []struct {
+				Points []tcxPoint `xml:"Trackpoint"`
+			} `xml:"Track"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+type tcxPoint struct {
+	Time     string `xml:"Time"`
+	Position *struct {
+		Lat float64 `xml:"LatitudeDegrees"`
+		Lon float64 `xml:"LongitudeDegrees"`
+	} `xml:"Position"`
+	Altitude   *float64 `xml:"AltitudeMeters"`
+	Distance   *float64 `xml:"DistanceMeters"`
+	HeartRate  int      `xml:"HeartRateBpm>Value"`
+	Cadence    int      `xml:"Cadence"`
+	RunCadence int      `xml:"Extensions>TPX>RunCadence"`
+}
+
+func parseTCX(r io.Reader) (*Track, error) {
+	var f tcxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	t := &Track{}
+	for _, a := range f.Activities {
+		if t.Sport == "" {
+			t.Sport = a.Sport
+		}
+		for _, lap := range a.Laps {
+			for _, trk := range lap.Tracks {
+				for _, p := range trk.Points {
+					point := Point{
+						Elevation: p.Altitude,
+						Distance:  p.Distance,
+						HeartRate: p.HeartRate,
+						Cadence:   p.Cadence,
+					}
+					if point.Cadence == 0 {
+						point.Cadence = p.RunCadence
+					}
+					if p.Position != nil {
+						point.HasPosition = true
+						point.Lat = p.Position.Lat
+						point.Lon = p.Position.Lon
+					}
+					if p.Time != "" {
+						tm, err := time.Parse(time.RFC3339, p.Time)
+						if err != nil {
+							return nil, err
+						}
+						point.Time = tm
+					}
+					t.Points = append(t.Points, point)
+				}
+			}
+		}
+	}
+
+	return t, nil
+}
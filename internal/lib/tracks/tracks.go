@@ -0,0 +1,231 @@
+package tracks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+	FormatFIT = "fit"
+)
+
+const (
+	SportRunning = "running"
+	SportCycling = "cycling"
+	SportWalking = "walking"
+)
+
+// elevationThreshold filters GPS altitude noise out of the elevation gain, in meters.
+const elevationThreshold = 2.0
+
+const earthRadius = 6371008.8
+
+var (
+	ErrUnknownFormat = errors.New("unknown track format")
+	ErrEmptyTrack    = errors.New("track has no points")
+)
+
+type Point struct {
+	Time        time.Time
+	HasPosition bool
+	Lat         float64
+	Lon         float64
+	Elevation   *float64
+	Distance    *float64 // cumulative distance in meters as recorded by the device
+	HeartRate   int
+	Cadence     int
+}
+
+type Track struct {
+	Sport  string
+	Points []Point
+}
+
+type Summary struct {
+	Sport         string
+	StartedAt     time.Time
+	Duration      int // seconds
+	Distance      int // meters
+	ElevationGain int // meters
+	AvgHeartRate  int
+	MaxHeartRate  int
+	AvgCadence    int
+}
+
+// DetectFormat guesses the format from the file content, falling back to the file extension.
+func DetectFormat(filename string, data []byte) (string, error) {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return FormatFIT, nil
+	}
+
+	head := data[:min(len(data), 1024)]
+	switch {
+	case bytes.Contains(head, []byte("<gpx")):
+		return FormatGPX, nil
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return FormatTCX, nil
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")) {
+	case FormatGPX:
+		return FormatGPX, nil
+	case FormatTCX:
+		return FormatTCX, nil
+	case FormatFIT:
+		return FormatFIT, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func Parse(format string, r io.Reader) (*Track, error) {
+	const op = "tracks.Parse"
+
+	var (
+		t   *Track
+		err error
+	)
+	switch format {
+	case FormatGPX:
+		t, err = parseGPX(r)
+	case FormatTCX:
+		t, err = parseTCX(r)
+	case FormatFIT:
+		t, err = parseFIT(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(t.Points) == 0 {
+		return nil, ErrEmptyTrack
+	}
+	return t, nil
+}
+
+// Summarize computes the totals of a track. Points without a timestamp are ignored.
+func Summarize(t *Track) (Summary, error) {
+	points := make([]Point, 0, len(t.Points))
+	for _, p := range t.Points {
+		if !p.Time.IsZero() {
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return Summary{}, ErrEmptyTrack
+	}
+
+	s := Summary{
+		StartedAt: points[0].Time,
+		Duration:  int(points[len(points)-1].Time.Sub(points[0].Time).Seconds()),
+	}
+
+	var (
+		recorded, computed float64
+		prev               *Point
+		ref                *float64
+		gain               float64
+		hrSum, hrN         int
+		cadSum, cadN       int
+	)
+	for i := range points {
+		p := &points[i]
+
+		if p.Distance != nil {
+			recorded = max(recorded, *p.Distance)
+		}
+		if p.HasPosition {
+			if prev != nil {
+				computed += haversine(prev.Lat, prev.Lon, p.Lat, p.Lon)
+			}
+			prev = p
+		}
+
+		if p.Elevation != nil {
+			switch {
+			case ref == nil:
+				ref = p.Elevation
+			case *p.Elevation-*ref >= elevationThreshold:
+				gain += *p.Elevation - *ref
+				ref = p.Elevation
+			case *ref-*p.Elevation >= elevationThreshold:
+				ref = p.Elevation
+			}
+		}
+
+		if p.HeartRate > 0 {
+			hrSum += p.HeartRate
+			hrN++
+			s.MaxHeartRate = max(s.MaxHeartRate, p.HeartRate)
+		}
+		if p.Cadence > 0 {
+			cadSum += p.Cadence
+			cadN++
+		}
+	}
+
+	s.Distance = int(math.Round(computed))
+	if recorded > 0 {
+		s.Distance = int(math.Round(recorded))
+	}
+	s.ElevationGain = int(math.Round(gain))
+	if hrN > 0 {
+		s.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrN)))
+	}
+	if cadN > 0 {
+		s.AvgCadence = int(math.Round(float64(cadSum) / float64(cadN)))
+	}
+
+	s.Sport = normalizeSport(t.Sport)
+	if s.Sport == "" {
+		s.Sport = guessSport(s.Distance, s.Duration)
+	}
+
+	return s, nil
+}
+
+func normalizeSport(sport string) string {
+	sport = strings.ToLower(sport)
+	switch {
+	case strings.Contains(sport, "run"):
+		return SportRunning
+	case strings.Contains(sport, "bik"), strings.Contains(sport, "cycl"), strings.Contains(sport, "ride"):
+		return SportCycling
+	case strings.Contains(sport, "walk"), strings.Contains(sport, "hik"):
+		return SportWalking
+	}
+	return ""
+}
+
+// guessSport picks a sport from the average speed when the file does not name one.
+func guessSport(distance int, duration int) string {
+	if duration <= 0 {
+		return SportRunning
+	}
+	speed := float64(distance) / 1000 / (float64(duration) / 3600)
+	switch {
+	case speed >= 20:
+		return SportCycling
+	case speed >= 7:
+		return SportRunning
+	default:
+		return SportWalking
+	}
+}
+
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
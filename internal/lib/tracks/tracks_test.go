@@ -0,0 +1,293 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gpxSample = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <trk>
+    <type>running</type>
+    <trkseg>
+      <trkpt lat="0" lon="0"><ele>10</ele><time>2024-05-01T07:00:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr><gpxtpx:cad>80</gpxtpx:cad>
+        </gpxtpx:TrackPointExtension></extensions></trkpt>
+      <trkpt lat="0" lon="0.001"><ele>11</ele><time>2024-05-01T07:00:30Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr><gpxtpx:cad>84</gpxtpx:cad>
+        </gpxtpx:TrackPointExtension></extensions></trkpt>
+      <trkpt lat="0" lon="0.002"><ele>13</ele><time>2024-05-01T07:01:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>160</gpxtpx:hr><gpxtpx:cad>88</gpxtpx:cad>
+        </gpxtpx:TrackPointExtension></extensions></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+const tcxSample = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+  xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Id>2024-05-01T07:00:00Z</Id>
+      <Lap StartTime="2024-05-01T07:00:00Z">
+        <Track>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:00Z</Time>
+            <Position><LatitudeDegrees>45</LatitudeDegrees><LongitudeDegrees>7</LongitudeDegrees></Position>
+            <AltitudeMeters>200</AltitudeMeters>
+            <DistanceMeters>0</DistanceMeters>
+            <HeartRateBpm><Value>120</Value></HeartRateBpm>
+            <Extensions><ns3:TPX><ns3:RunCadence>70</ns3:RunCadence></ns3:TPX></Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-05-01T07:10:00Z</Time>
+            <AltitudeMeters>230</AltitudeMeters>
+            <DistanceMeters>5000</DistanceMeters>
+            <HeartRateBpm><Value>140</Value></HeartRateBpm>
+            <Cadence>90</Cadence>
+          </Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestDetectFormat(t *testing.T) {
+	fit := make([]byte, 14)
+	copy(fit[8:], ".FIT")
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		want     string
+		wantErr  error
+	}{
+		{name: "gpx content", filename: "track.xml", data: []byte(gpxSample), want: FormatGPX},
+		{name: "tcx content", filename: "track", data: []byte(tcxSample), want: FormatTCX},
+		{name: "fit signature", filename: "track.bin", data: fit, want: FormatFIT},
+		{name: "extension", filename: "Morning Run.TCX", data: []byte("garbage"), want: FormatTCX},
+		{name: "unknown", filename: "track.csv", data: []byte("lat,lon"), wantErr: ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := DetectFormat(tt.filename, tt.data)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("DetectFormat() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestParse(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		points int
+		want   Summary
+	}{
+		{
+			name:   "gpx",
+			format: FormatGPX,
+			data:   []byte(gpxSample),
+			points: 3,
+			// Two steps of 0.001° along the equator; the rise of 1 m stays under the threshold.
+			want: Summary{
+				Sport: SportRunning, StartedAt: start, Duration: 60, Distance: 222, ElevationGain: 3,
+				AvgHeartRate: 150, MaxHeartRate: 160, AvgCadence: 84,
+			},
+		},
+		{
+			name:   "tcx",
+			format: FormatTCX,
+			data:   []byte(tcxSample),
+			points: 2,
+			want: Summary{
+				Sport: SportCycling, StartedAt: start, Duration: 600, Distance: 5000, ElevationGain: 30,
+				AvgHeartRate: 130, MaxHeartRate: 140, AvgCadence: 80,
+			},
+		},
+		{
+			name:   "fit",
+			format: FormatFIT,
+			data:   fitSample(),
+			points: 3,
+			want: Summary{
+				Sport: SportCycling, StartedAt: time.Unix(fitEpoch+1000, 0).UTC(), Duration: 2, Distance: 10,
+				AvgHeartRate: 110, MaxHeartRate: 120,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				track, err := Parse(tt.format, bytes.NewReader(tt.data))
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if len(track.Points) != tt.points {
+					t.Fatalf("got %d points, want %d", len(track.Points), tt.points)
+				}
+				got, err := Summarize(track)
+				if err != nil {
+					t.Fatalf("Summarize: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestParseFITPoint(t *testing.T) {
+	track, err := Parse(FormatFIT, bytes.NewReader(fitSample()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := track.Points[0]
+	if !p.HasPosition || math.Abs(p.Lat-45) > 1e-6 || math.Abs(p.Lon+90) > 1e-6 {
+		t.Errorf("position = %v, %v, want 45, -90", p.Lat, p.Lon)
+	}
+	if p.Elevation == nil || *p.Elevation != 100 {
+		t.Errorf("elevation = %v, want 100", p.Elevation)
+	}
+	// The last record has a compressed timestamp header and no fields but the heart rate.
+	last := track.Points[2]
+	if want := time.Unix(fitEpoch+1002, 0).UTC(); !last.Time.Equal(want) || last.HasPosition {
+		t.Errorf("compressed record = %+v, want time %v without position", last, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	corrupt := fitSample()
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr error
+	}{
+		{name: "unknown format", format: "kml", data: gpxSample, wantErr: ErrUnknownFormat},
+		{name: "gpx without points", format: FormatGPX, data: "<gpx><trk></trk></gpx>", wantErr: ErrEmptyTrack},
+		{name: "fit too short", format: FormatFIT, data: "\x0e\x10", wantErr: ErrInvalidFIT},
+		{name: "fit checksum", format: FormatFIT, data: string(corrupt), wantErr: ErrFITCRC},
+		{name: "fit truncated", format: FormatFIT, data: string(fitSample()[:30]), wantErr: ErrInvalidFIT},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if _, err := Parse(tt.format, strings.NewReader(tt.data)); !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func TestSummarizeGuessesSport(t *testing.T) {
+	tests := []struct {
+		name     string
+		distance float64
+		want     string
+	}{
+		{name: "walking pace", distance: 5000, want: SportWalking},
+		{name: "running pace", distance: 10000, want: SportRunning},
+		{name: "cycling pace", distance: 30000, want: SportCycling},
+	}
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				zero, distance := 0.0, tt.distance
+				track := &Track{
+					Points: []Point{
+						{Time: start, Distance: &zero},
+						{Time: start.Add(time.Hour), Distance: &distance},
+					},
+				}
+				got, err := Summarize(track)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Sport != tt.want {
+					t.Errorf("Sport = %q, want %q", got.Sport, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestFITCRC(t *testing.T) {
+	if got := fitCRC([]byte("123456789")); got != 0xBB3D {
+		t.Errorf("fitCRC() = %#04x, want 0xbb3d", got)
+	}
+}
+
+// fitSample builds a FIT file with two records, a record with a compressed timestamp header and
+// a cycling session.
+func fitSample() []byte {
+	var body bytes.Buffer
+	le := binary.LittleEndian
+
+	// Local 0: record with timestamp, position, altitude, heart rate and distance.
+	body.Write([]byte{0x40, 0, 0})
+	body.Write(le.AppendUint16(nil, fitMsgRecord))
+	body.Write(
+		[]byte{
+			6,
+			fitFieldTimestamp, 4, 0x86,
+			fitFieldPositionLat, 4, 0x85,
+			fitFieldPositionLong, 4, 0x85,
+			fitFieldAltitude, 2, 0x84,
+			fitFieldHeartRate, 1, 0x02,
+			fitFieldDistance, 4, 0x86,
+		},
+	)
+	lat, lon := int32(536870912), int32(-1073741824)
+	for i, hr := range []byte{100, 110} {
+		body.WriteByte(0x00)
+		body.Write(le.AppendUint32(nil, uint32(1000+i)))
+		body.Write(le.AppendUint32(nil, uint32(lat)))
+		body.Write(le.AppendUint32(nil, uint32(lon)))
+		body.Write(le.AppendUint16(nil, (100+500)*5))
+		body.WriteByte(hr)
+		body.Write(le.AppendUint32(nil, uint32(i*1000)))
+	}
+
+	// Local 1: record with the heart rate only, sent with a compressed timestamp header.
+	body.Write([]byte{0x41, 0, 0})
+	body.Write(le.AppendUint16(nil, fitMsgRecord))
+	body.Write([]byte{1, fitFieldHeartRate, 1, 0x02})
+	body.Write([]byte{0x80 | 1<<5 | 10, 120})
+
+	// Local 2: session of sport 2, cycling.
+	body.Write([]byte{0x42, 0, 0})
+	body.Write(le.AppendUint16(nil, fitMsgSession))
+	body.Write([]byte{1, fitFieldSessionSport, 1, 0x00})
+	body.Write([]byte{0x02, 2})
+
+	header := []byte{12, 0x10}
+	header = le.AppendUint16(header, 2100)
+	header = le.AppendUint32(header, uint32(body.Len()))
+	header = append(header, ".FIT"...)
+
+	file := append(header, body.Bytes()...)
+	return le.AppendUint16(file, fitCRC(file))
+}
@@ -0,0 +1,156 @@
+package training
+
+import "math"
+
+const (
+	// DefaultIncrement is the smallest plate jump in kilograms used when suggesting a heavier load.
+	DefaultIncrement = 2.5
+	// MaxEstimateReps is the most reps a one-rep max is estimated from. Past it endurance, not
+	// strength, limits the set and the formulas overestimate wildly.
+	MaxEstimateReps = 12
+)
+
+type Set struct {
+	Weight float64
+	Reps   int
+	RPE    *float64
+}
+
+// Session holds the sets of one exercise performed in a single workout.
+type Session struct {
+	Sets []Set
+}
+
+type Records struct {
+	EstimatedOneRepMax float64
+	MaxWeight          float64
+	MaxReps            int
+	MaxVolume          float64
+}
+
+type Suggestion struct {
+	Weight float64 `json:"weight"`
+	Reps   int     `json:"reps"`
+	Reason string  `json:"reason"`
+}
+
+// Epley estimates a one-rep max as weight * (1 + reps/30).
+func Epley(weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+// Brzycki estimates a one-rep max as weight * 36 / (37 - reps). The formula breaks down
+// for very high repetitions, so reps are capped at 36.
+func Brzycki(weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	reps = min(reps, 36)
+	return weight * 36 / float64(37-reps)
+}
+
+// EstimatedOneRepMax averages the Epley and Brzycki estimates. Sets of more than MaxEstimateReps
+// reps give no estimate.
+func EstimatedOneRepMax(weight float64, reps int) float64 {
+	if reps > MaxEstimateReps {
+		return 0
+	}
+	return math.Round((Epley(weight, reps)+Brzycki(weight, reps))/2*100) / 100
+}
+
+// SessionRecords returns the best values reached within a single session.
+func SessionRecords(s Session) Records {
+	var rec Records
+	for _, set := range s.Sets {
+		rec.EstimatedOneRepMax = max(rec.EstimatedOneRepMax, EstimatedOneRepMax(set.Weight, set.Reps))
+		if set.Reps > 0 {
+			rec.MaxWeight = max(rec.MaxWeight, set.Weight)
+		}
+		rec.MaxReps = max(rec.MaxReps, set.Reps)
+		rec.MaxVolume += set.Weight * float64(set.Reps)
+	}
+	rec.MaxVolume = math.Round(rec.MaxVolume*100) / 100
+	return rec
+}
+
+// Suggest proposes the load for the next session from recent sessions ordered newest first.
+// When the last top set has an RPE it drives the decision, otherwise double progression is used:
+// add reps until the same reps were hit twice in a row, then add weight.
+func Suggest(sessions []Session, increment float64) Suggestion {
+	if increment <= 0 {
+		increment = DefaultIncrement
+	}
+
+	if len(sessions) == 0 {
+		return Suggestion{Reason: "no history for this exercise"}
+	}
+
+	last, ok := topSet(sessions[0])
+	if !ok {
+		return Suggestion{Reason: "no working sets in the last session"}
+	}
+	prev, hasPrev := Set{}, false
+	if len(sessions) > 1 {
+		prev, hasPrev = topSet(sessions[1])
+	}
+
+	if last.RPE != nil {
+		rpe := *last.RPE
+		switch {
+		case rpe <= 7:
+			return Suggestion{Weight: last.Weight + increment, Reps: last.Reps, Reason: "last session felt easy, add weight"}
+		case rpe <= 8.5:
+			if last.Reps >= 12 {
+				return Suggestion{
+					Weight: last.Weight + increment, Reps: max(last.Reps-4, 1),
+					Reason: "top of the rep range reached, add weight",
+				}
+			}
+			return Suggestion{Weight: last.Weight, Reps: last.Reps + 1, Reason: "add a rep at the same weight"}
+		case rpe < 9.5:
+			return Suggestion{Weight: last.Weight, Reps: last.Reps, Reason: "repeat the session to consolidate"}
+		default:
+			if hasPrev && prev.RPE != nil && *prev.RPE >= 9.5 && last.Weight >= prev.Weight && last.Reps <= prev.Reps {
+				return Suggestion{
+					Weight: round(last.Weight*0.9, increment), Reps: last.Reps,
+					Reason: "two maximal sessions without progress, deload by 10%",
+				}
+			}
+			return Suggestion{Weight: last.Weight, Reps: last.Reps, Reason: "last session was maximal, repeat it"}
+		}
+	}
+
+	if hasPrev && last.Weight == prev.Weight && last.Reps == prev.Reps {
+		return Suggestion{Weight: last.Weight + increment, Reps: last.Reps, Reason: "reps held for two sessions, add weight"}
+	}
+	return Suggestion{Weight: last.Weight, Reps: last.Reps + 1, Reason: "add a rep at the same weight"}
+}
+
+// topSet returns the heaviest set of the session, preferring more reps on equal weight.
+func topSet(s Session) (Set, bool) {
+	var top Set
+	found := false
+	for _, set := range s.Sets {
+		if set.Reps <= 0 {
+			continue
+		}
+		if !found || set.Weight > top.Weight || (set.Weight == top.Weight && set.Reps > top.Reps) {
+			top = set
+			found = true
+		}
+	}
+	return top, found
+}
+
+func round(v, step float64) float64 {
+	return math.Round(v/step) * step
+}
@@ -0,0 +1,139 @@
+package training
+
+import "testing"
+
+func rpe(v float64) *float64 {
+	return &v
+}
+
+func session(sets ...Set) Session {
+	return Session{Sets: sets}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessions  []Session
+		increment float64
+		want      Suggestion
+	}{
+		{
+			name: "no history",
+			want: Suggestion{Reason: "no history for this exercise"},
+		},
+		{
+			name:     "no working sets",
+			sessions: []Session{session(Set{Weight: 100, Reps: 0})},
+			want:     Suggestion{Reason: "no working sets in the last session"},
+		},
+		{
+			name:     "first session adds a rep",
+			sessions: []Session{session(Set{Weight: 60, Reps: 8})},
+			want:     Suggestion{Weight: 60, Reps: 9, Reason: "add a rep at the same weight"},
+		},
+		{
+			name: "top set is the heaviest, then the one with more reps",
+			sessions: []Session{
+				session(Set{Weight: 50, Reps: 12}, Set{Weight: 60, Reps: 5}, Set{Weight: 60, Reps: 6}),
+			},
+			want: Suggestion{Weight: 60, Reps: 7, Reason: "add a rep at the same weight"},
+		},
+		{
+			name:     "reps held twice add weight",
+			sessions: []Session{session(Set{Weight: 60, Reps: 8}), session(Set{Weight: 60, Reps: 8})},
+			want:     Suggestion{Weight: 62.5, Reps: 8, Reason: "reps held for two sessions, add weight"},
+		},
+		{
+			name:      "custom increment",
+			sessions:  []Session{session(Set{Weight: 20, Reps: 10}), session(Set{Weight: 20, Reps: 10})},
+			increment: 1,
+			want:      Suggestion{Weight: 21, Reps: 10, Reason: "reps held for two sessions, add weight"},
+		},
+		{
+			name:     "easy session adds weight",
+			sessions: []Session{session(Set{Weight: 80, Reps: 5, RPE: rpe(6.5)})},
+			want:     Suggestion{Weight: 82.5, Reps: 5, Reason: "last session felt easy, add weight"},
+		},
+		{
+			name:     "moderate session adds a rep",
+			sessions: []Session{session(Set{Weight: 80, Reps: 5, RPE: rpe(8)})},
+			want:     Suggestion{Weight: 80, Reps: 6, Reason: "add a rep at the same weight"},
+		},
+		{
+			name:     "moderate session at the top of the range adds weight",
+			sessions: []Session{session(Set{Weight: 40, Reps: 12, RPE: rpe(8.5)})},
+			want:     Suggestion{Weight: 42.5, Reps: 8, Reason: "top of the rep range reached, add weight"},
+		},
+		{
+			name:     "hard session is repeated",
+			sessions: []Session{session(Set{Weight: 80, Reps: 5, RPE: rpe(9)})},
+			want:     Suggestion{Weight: 80, Reps: 5, Reason: "repeat the session to consolidate"},
+		},
+		{
+			name:     "maximal session is repeated",
+			sessions: []Session{session(Set{Weight: 100, Reps: 3, RPE: rpe(10)})},
+			want:     Suggestion{Weight: 100, Reps: 3, Reason: "last session was maximal, repeat it"},
+		},
+		{
+			name: "two maximal sessions without progress deload",
+			sessions: []Session{
+				session(Set{Weight: 100, Reps: 3, RPE: rpe(10)}),
+				session(Set{Weight: 100, Reps: 3, RPE: rpe(9.5)}),
+			},
+			want: Suggestion{Weight: 90, Reps: 3, Reason: "two maximal sessions without progress, deload by 10%"},
+		},
+		{
+			name: "maximal session with more reps than the previous one is repeated",
+			sessions: []Session{
+				session(Set{Weight: 100, Reps: 4, RPE: rpe(10)}),
+				session(Set{Weight: 100, Reps: 3, RPE: rpe(10)}),
+			},
+			want: Suggestion{Weight: 100, Reps: 4, Reason: "last session was maximal, repeat it"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Suggest(tt.sessions, tt.increment); got != tt.want {
+					t.Errorf("Suggest() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestEstimatedOneRepMax(t *testing.T) {
+	tests := []struct {
+		weight float64
+		reps   int
+		want   float64
+	}{
+		{weight: 100, reps: 0, want: 0},
+		{weight: 100, reps: 1, want: 100},
+		{weight: 100, reps: 5, want: 114.58},
+		{weight: 60, reps: 10, want: 80},
+		{weight: 100, reps: MaxEstimateReps, want: 142},
+		{weight: 100, reps: MaxEstimateReps + 1, want: 0},
+		{weight: 20, reps: 50, want: 0},
+	}
+	for _, tt := range tests {
+		if got := EstimatedOneRepMax(tt.weight, tt.reps); got != tt.want {
+			t.Errorf("EstimatedOneRepMax(%v, %d) = %v, want %v", tt.weight, tt.reps, got, tt.want)
+		}
+	}
+}
+
+func TestSessionRecords(t *testing.T) {
+	got := SessionRecords(session(Set{Weight: 100, Reps: 5}, Set{Weight: 110, Reps: 2}, Set{Weight: 120, Reps: 0}))
+	want := Records{EstimatedOneRepMax: 115.24, MaxWeight: 110, MaxReps: 5, MaxVolume: 720}
+	if got != want {
+		t.Errorf("SessionRecords() = %+v, want %+v", got, want)
+	}
+
+	// A light set of many reps counts towards the other records but not the estimate.
+	got = SessionRecords(session(Set{Weight: 100, Reps: 5}, Set{Weight: 40, Reps: 30}))
+	want = Records{EstimatedOneRepMax: 114.58, MaxWeight: 100, MaxReps: 30, MaxVolume: 1700}
+	if got != want {
+		t.Errorf("SessionRecords() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when a delivery would connect to a non-public address.
+var ErrBlockedAddress = errors.New("destination address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice but not to netip.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddr reports whether deliveries may be sent to the address: it is none of loopback,
+// private, link-local (which holds the cloud metadata endpoints), multicast or unspecified.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// DialControl is a net.Dialer Control refusing connections to non-public addresses. It runs on
+// the resolved address right before connecting, so a host name resolving to an internal address,
+// at creation or later, cannot get past it.
+func DialControl(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+	if !PublicAddr(ap.Addr()) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
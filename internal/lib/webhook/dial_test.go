@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.10"},
+		{addr: "fd00::1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "224.0.0.1"},
+		{addr: "ff02::1"},
+		{addr: "ff01::1"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "100.64.0.1"},
+		{addr: "100.127.255.254"},
+		{addr: "100.128.0.1", want: true},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:169.254.169.254"},
+		{addr: "::ffff:93.184.216.34", want: true},
+	}
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+
+	if PublicAddr(netip.Addr{}) {
+		t.Error("the zero address is public")
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr error
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{address: "127.0.0.1:8080", wantErr: ErrBlockedAddress},
+		{address: "[::ffff:10.0.0.1]:443", wantErr: ErrBlockedAddress},
+		{address: "169.254.169.254:80", wantErr: ErrBlockedAddress},
+		{address: "example.com:443", wantErr: ErrBlockedAddress},
+		{address: "93.184.216.34", wantErr: ErrBlockedAddress},
+	}
+	for _, tt := range tests {
+		if err := DialControl("tcp", tt.address, nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("DialControl(%s) = %v, want %v", tt.address, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDialControlBlocksConnections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: (&net.Dialer{Control: DialControl}).DialContext}}
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("err = %v, want ErrBlockedAddress", err)
+	}
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "X-Atomfit-Signature"
+	EventHeader     = "X-Atomfit-Event"
+	DeliveryHeader  = "X-Atomfit-Delivery"
+)
+
+var (
+	ErrMalformed = errors.New("malformed signature header")
+	ErrSignature = errors.New("invalid signature")
+	ErrExpired   = errors.New("signature timestamp out of tolerance")
+)
+
+// Sign returns the signature header of a body sent at t: "t=<unix seconds>,v1=<hex>", where v1 is
+// the HMAC-SHA256 of "<unix seconds>.<body>" keyed with the webhook secret. Signing the timestamp
+// lets receivers reject replayed deliveries.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks a signature header made by Sign against the body, accepting timestamps at most
+// tolerance away from now.
+func Verify(secret string, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformed
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	if ts == "" || sig == "" {
+		return ErrMalformed
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrMalformed
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrExpired
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+func mac(secret string, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts + "."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	// Computed independently with Python's hmac module.
+	want := "t=1714550400,v1=c5897527d55a2d1812c60800fab584e6747bee75103fcd179f2b3d4fd411c81e"
+	if got := Sign("whsec", at, []byte(`{"event":"workout.created"}`)); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"workout.created"}`)
+	header := Sign("whsec", now, body)
+	_, sig, _ := strings.Cut(header, ",")
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", secret: "whsec", header: header, body: body, now: now},
+		{name: "within tolerance", secret: "whsec", header: header, body: body, now: now.Add(5 * time.Minute)},
+		{name: "clock behind", secret: "whsec", header: header, body: body, now: now.Add(-5 * time.Minute)},
+		{name: "spaces and unknown versions", secret: "whsec", header: header + ", v0=abc", body: body, now: now},
+		{
+			name: "replayed", secret: "whsec", header: header, body: body, now: now.Add(5*time.Minute + time.Second),
+			wantErr: ErrExpired,
+		},
+		{
+			name: "from the future", secret: "whsec", header: header, body: body, now: now.Add(-6 * time.Minute),
+			wantErr: ErrExpired,
+		},
+		{name: "wrong secret", secret: "other", header: header, body: body, now: now, wantErr: ErrSignature},
+		{
+			name: "tampered body", secret: "whsec", header: header, body: []byte(`{"event":"workout.deleted"}`),
+			now: now, wantErr: ErrSignature,
+		},
+		{
+			name: "new timestamp on an old signature", secret: "whsec", header: "t=1714550460," + sig, body: body,
+			now: now, wantErr: ErrSignature,
+		},
+		{name: "empty", secret: "whsec", header: "", body: body, now: now, wantErr: ErrMalformed},
+		{name: "no timestamp", secret: "whsec", header: sig, body: body, now: now, wantErr: ErrMalformed},
+		{name: "no signature", secret: "whsec", header: "t=1714550400", body: body, now: now, wantErr: ErrMalformed},
+		{
+			name: "timestamp is not a number", secret: "whsec", header: "t=now," + sig, body: body, now: now,
+			wantErr: ErrMalformed,
+		},
+		{name: "no key", secret: "whsec", header: header + ",v1", body: body, now: now, wantErr: ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	AchievementRuleWorkoutCount   = "workout_count"
+	AchievementRuleRecordCount    = "record_count"
+	AchievementRuleActivityStreak = "activity_streak"
+	AchievementRuleDailySteps     = "daily_steps"
+)
+
+// Achievement is unlocked once the value measured by Rule reaches Threshold.
+type Achievement struct {
+	Code        string  `db:"code" json:"code"`
+	Name        string  `db:"name" json:"name"`
+	Description string  `db:"description" json:"description"`
+	Rule        string  `db:"rule" json:"rule"`
+	Threshold   float64 `db:"threshold" json:"threshold"`
+	Position    int     `db:"position" json:"-"`
+}
+
+type AwardedAchievement struct {
+	UserID    int       `db:"user_id" json:"-"`
+	Code      string    `db:"code" json:"code"`
+	AwardedAt time.Time `db:"awarded_at" json:"awarded_at"`
+}
+
+// UserAchievement is an achievement as seen by a user, Value being how far the user got.
+type UserAchievement struct {
+	Achievement
+	Unlocked  bool       `json:"unlocked"`
+	AwardedAt *time.Time `json:"awarded_at,omitempty"`
+	Value     float64    `json:"value"`
+}
+
+// AchievementStats are the figures the rules are evaluated on. ActivityDays are the distinct
+// days (YYYY-MM-DD, ascending) with a workout, a diary entry or a weight entry.
+type AchievementStats struct {
+	Workouts      int
+	Records       int
+	MaxDailySteps float64
+	ActivityDays  []string
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions, named <area>.<what happened>.
+const (
+	AuditLoginSucceeded   = "auth.login_succeeded"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditRegistered       = "user.registered"
+	AuditActivated        = "user.activated"
+	AuditPasswordChanged  = "user.password_changed"
+	AuditProfileUpdated   = "user.profile_updated"
+	AuditRoleCreated      = "admin.role_created"
+	AuditRoleUpdated      = "admin.role_updated"
+	AuditRoleDeleted      = "admin.role_deleted"
+	AuditRoleGranted      = "admin.role_granted"
+	AuditRoleRevoked      = "admin.role_revoked"
+	AuditCoachGranted     = "admin.coach_granted"
+	AuditCoachRevoked     = "admin.coach_revoked"
+	AuditFoodPublished    = "admin.food_published"
+	AuditAppCreated       = "admin.app_created"
+	AuditClientCreated    = "admin.oauth_client_created"
+	AuditClientDeleted    = "admin.oauth_client_deleted"
+	AuditAppAuthorized    = "user.app_authorized"
+	AuditTokenCreated     = "user.token_created"
+	AuditTokenRevoked     = "user.token_revoked"
+	AuditSessionRevoked   = "user.session_revoked"
+	AuditExportRequested  = "export.requested"
+	AuditExportDownloaded = "export.downloaded"
+)
+
+// AuditEvent records who (ActorID, unknown for failed logins) did what to whom (SubjectID).
+// RequestID, IP and UserAgent are the ones of the request that caused it.
+type AuditEvent struct {
+	ID         int64           `db:"id" json:"id"`
+	OccurredAt time.Time       `db:"occurred_at" json:"occurred_at"`
+	Action     string          `db:"action" json:"action"`
+	ActorID    *int            `db:"actor_id" json:"actor_id,omitempty"`
+	SubjectID  *int            `db:"subject_id" json:"subject_id,omitempty"`
+	RequestID  string          `db:"request_id" json:"request_id"`
+	IP         string          `db:"ip" json:"ip"`
+	UserAgent  string          `db:"user_agent" json:"user_agent"`
+	Details    json.RawMessage `db:"details" json:"details"`
+}
+
+// AuditFilter selects events; zero values match everything. From is inclusive, To exclusive.
+type AuditFilter struct {
+	ActorID   *int
+	SubjectID *int
+	Action    string
+	From      *time.Time
+	To        *time.Time
+	BeforeID  int64
+}
+
+type AuditPage struct {
+	Items        []AuditEvent `json:"items"`
+	NextBeforeID *int64       `json:"next_before_id,omitempty"`
+}
+
+// FieldChange is the old and the new value of a changed field.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+const (
+	PoseFront = "front"
+	PoseSide  = "side"
+	PoseBack  = "back"
+)
+
+type WeightEntry struct {
+	ID         int       `db:"id" json:"id"`
+	UserID     int       `db:"user_id" json:"-"`
+	MeasuredAt time.Time `db:"measured_at" json:"measured_at"`
+	Weight     float64   `db:"weight" json:"weight"`
+	Note       string    `db:"note" json:"note"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreateWeightEntryPayload struct {
+	MeasuredAt time.Time `json:"measured_at" validate:"required"`
+	Weight     float64   `json:"weight" validate:"required,gt=20,lt=500"`
+	Note       string    `json:"note" validate:"max=500"`
+}
+
+// BodyMeasurement holds circumferences in centimetres and the body fat percentage with
+// the method used to estimate it. Every value is optional.
+type BodyMeasurement struct {
+	ID            int             `db:"id" json:"id"`
+	UserID        int             `db:"user_id" json:"-"`
+	MeasuredAt    time.Time       `db:"measured_at" json:"measured_at"`
+	Waist         *float64        `db:"waist" json:"waist,omitempty"`
+	Hips          *float64        `db:"hips" json:"hips,omitempty"`
+	Chest         *float64        `db:"chest" json:"chest,omitempty"`
+	Arm           *float64        `db:"arm" json:"arm,omitempty"`
+	Thigh         *float64        `db:"thigh" json:"thigh,omitempty"`
+	BodyFat       *float64        `db:"body_fat" json:"body_fat,omitempty"`
+	BodyFatMethod *string         `db:"body_fat_method" json:"body_fat_method,omitempty"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	Indicators    *BodyIndicators `db:"-" json:"indicators,omitempty"`
+}
+
+type CreateBodyMeasurementPayload struct {
+	MeasuredAt    time.Time `json:"measured_at" validate:"required"`
+	Waist         *float64  `json:"waist" validate:"omitempty,gt=20,lt=300"`
+	Hips          *float64  `json:"hips" validate:"omitempty,gt=20,lt=300"`
+	Chest         *float64  `json:"chest" validate:"omitempty,gt=20,lt=300"`
+	Arm           *float64  `json:"arm" validate:"omitempty,gt=5,lt=150"`
+	Thigh         *float64  `json:"thigh" validate:"omitempty,gt=10,lt=200"`
+	BodyFat       *float64  `json:"body_fat" validate:"required_with=BodyFatMethod,omitempty,gte=1,lte=75"`
+	BodyFatMethod *string   `json:"body_fat_method" validate:"required_with=BodyFat,omitempty,oneof=caliper bia dexa navy visual other"`
+}
+
+// BodyIndicators are derived from a measurement, the weight at that time and User.Height.
+type BodyIndicators struct {
+	Weight        float64  `json:"weight"`
+	BMI           float64  `json:"bmi"`
+	WaistToHeight *float64 `json:"waist_to_height,omitempty"`
+	WaistToHip    *float64 `json:"waist_to_hip,omitempty"`
+	LeanMass      *float64 `json:"lean_mass,omitempty"`
+	FatMass       *float64 `json:"fat_mass,omitempty"`
+}
+
+type ProgressPhoto struct {
+	ID          int       `db:"id" json:"id"`
+	UserID      int       `db:"user_id" json:"-"`
+	TakenAt     time.Time `db:"taken_at" json:"taken_at"`
+	Pose        string    `db:"pose" json:"pose"`
+	BlobKey     string    `db:"blob_key" json:"-"`
+	ThumbKey    string    `db:"thumb_key" json:"-"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Width       int       `db:"width" json:"width"`
+	Height      int       `db:"height" json:"height"`
+	Size        int       `db:"size" json:"size"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	URL         string    `db:"-" json:"url,omitempty"`
+	ThumbURL    string    `db:"-" json:"thumb_url,omitempty"`
+	// URLExpiresAt is when URL and ThumbURL stop working.
+	URLExpiresAt *time.Time `db:"-" json:"url_expires_at,omitempty"`
+}
+
+// BodySummary is the latest known state of the body: the weight comes from the weight log,
+// or from the profile when nothing was logged yet.
+type BodySummary struct {
+	Weight            float64          `json:"weight"`
+	WeightMeasuredAt  *time.Time       `json:"weight_measured_at,omitempty"`
+	Height            int              `json:"height"`
+	BMI               float64          `json:"bmi"`
+	LatestMeasurement *BodyMeasurement `json:"latest_measurement,omitempty"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Challenge metrics: the total distance of workouts in metres, the number of workouts
+// and the total of the daily steps.
+const (
+	ChallengeDistance = "distance"
+	ChallengeWorkouts = "workouts"
+	ChallengeSteps    = "steps"
+)
+
+const (
+	ChallengePublic     = "public"
+	ChallengeInviteOnly = "invite_only"
+)
+
+// Challenge runs from StartsOn to EndsOn (YYYY-MM-DD, inclusive, UTC days). InviteCode is
+// only shown to the owner, who shares it to let users join an invite-only challenge.
+type Challenge struct {
+	ID           int       `db:"id" json:"id"`
+	OwnerID      int       `db:"owner_id" json:"owner_id"`
+	Name         string    `db:"name" json:"name"`
+	Description  string    `db:"description" json:"description"`
+	Metric       string    `db:"metric" json:"metric"`
+	StartsOn     string    `db:"starts_on" json:"starts_on"`
+	EndsOn       string    `db:"ends_on" json:"ends_on"`
+	Visibility   string    `db:"visibility" json:"visibility"`
+	InviteCode   string    `db:"invite_code" json:"invite_code,omitempty"`
+	Participants int       `db:"participants" json:"participants"`
+	Joined       bool      `db:"joined" json:"joined"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreateChallengePayload struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+	Metric      string `json:"metric" validate:"required,oneof=distance workouts steps"`
+	StartsOn    string `json:"starts_on" validate:"required,datetime=2006-01-02"`
+	EndsOn      string `json:"ends_on" validate:"required,datetime=2006-01-02"`
+	Visibility  string `json:"visibility" validate:"required,oneof=public invite_only"`
+}
+
+type JoinChallengePayload struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// Standing is the position of a participant. Tied scores share the rank, the next rank
+// skipping as many places as there were ties (1, 1, 3).
+type Standing struct {
+	Rank     int     `db:"rank" json:"rank"`
+	UserID   int     `db:"user_id" json:"user_id"`
+	Username string  `db:"username" json:"username"`
+	Score    float64 `db:"score" json:"score"`
+}
+
+// Leaderboard holds the live standings, or the standings at the end of Date when it is set.
+type Leaderboard struct {
+	ChallengeID int        `json:"challenge_id"`
+	Date        string     `json:"date,omitempty"`
+	Standings   []Standing `json:"standings"`
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Scopes a client consents to when linked to a coach.
+const (
+	CoachScopeWorkouts = "view_workouts"
+	CoachScopeDiary    = "view_diary"
+	CoachScopePrograms = "assign_programs"
+)
+
+const (
+	CoachingPending  = "pending"
+	CoachingActive   = "active"
+	CoachingDeclined = "declined"
+	CoachingRevoked  = "revoked"
+)
+
+type Coach struct {
+	UserID    int       `db:"user_id" json:"user_id"`
+	Username  string    `db:"username" json:"username"`
+	Bio       string    `db:"bio" json:"bio"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type GrantCoachPayload struct {
+	Bio string `json:"bio" validate:"max=1000"`
+}
+
+// Coaching links a coach to a client. A coach only sees what the scopes allow, and only while
+// the link is active. Username is the name of the other side of the link.
+type Coaching struct {
+	ID         int            `db:"id" json:"id"`
+	CoachID    int            `db:"coach_id" json:"coach_id"`
+	ClientID   int            `db:"client_id" json:"client_id"`
+	Username   string         `db:"username" json:"username"`
+	Status     string         `db:"status" json:"status"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	InvitedAt  time.Time      `db:"invited_at" json:"invited_at"`
+	AcceptedAt *time.Time     `db:"accepted_at" json:"accepted_at,omitempty"`
+	EndedAt    *time.Time     `db:"ended_at" json:"ended_at,omitempty"`
+}
+
+type InviteClientPayload struct {
+	Email  string   `json:"email" validate:"required,email"`
+	Scopes []string `json:"scopes" validate:"required,min=1,unique,dive,oneof=view_workouts view_diary assign_programs"`
+}
+
+// ConsentPayload sets the scopes granted to a coach. On acceptance, leaving it out grants the requested scopes.
+type ConsentPayload struct {
+	Scopes []string `json:"scopes" validate:"omitempty,unique,dive,oneof=view_workouts view_diary assign_programs"`
+}
+
+// ClientSummary is a line of the coach dashboard. The figures cover the last Days days and are
+// only set when the client consented to the scope they come from.
+type ClientSummary struct {
+	ClientID             int            `db:"client_id" json:"client_id"`
+	Username             string         `db:"username" json:"username"`
+	Scopes               pq.StringArray `db:"scopes" json:"scopes"`
+	Since                time.Time      `db:"since" json:"since"`
+	Days                 int            `db:"-" json:"days"`
+	Workouts             *int           `db:"workouts" json:"workouts,omitempty"`
+	LastWorkoutAt        *time.Time     `db:"last_workout_at" json:"last_workout_at,omitempty"`
+	DiaryDays            *int           `db:"diary_days" json:"diary_days,omitempty"`
+	AssignmentsDue       *int           `db:"assignments_due" json:"assignments_due,omitempty"`
+	AssignmentsCompleted *int           `db:"assignments_completed" json:"assignments_completed,omitempty"`
+	// Adherence is the percentage of the assignments due in the period that were completed.
+	Adherence *float64 `db:"-" json:"adherence,omitempty"`
+}
+
+type WorkoutTemplate struct {
+	ID        int       `db:"id" json:"id"`
+	CoachID   int       `db:"coach_id" json:"coach_id"`
+	Name      string    `db:"name" json:"name"`
+	Notes     string    `db:"notes" json:"notes"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// PublishedAt is set once the template is part of the program catalog every user can browse.
+	PublishedAt *time.Time           `db:"published_at" json:"published_at,omitempty"`
+	Sets        []WorkoutTemplateSet `db:"-" json:"sets"`
+}
+
+type WorkoutTemplateSet struct {
+	TemplateID int     `db:"template_id" json:"-"`
+	Position   int     `db:"position" json:"position"`
+	ExerciseID int     `db:"exercise_id" json:"exercise_id"`
+	Reps       int     `db:"reps" json:"reps"`
+	Weight     float64 `db:"weight" json:"weight"`
+	Duration   int     `db:"duration" json:"duration"`
+	Distance   int     `db:"distance" json:"distance"`
+}
+
+type CreateTemplatePayload struct {
+	Name  string               `json:"name" validate:"required,max=100"`
+	Notes string               `json:"notes" validate:"max=2000"`
+	Sets  []TemplateSetPayload `json:"sets" validate:"required,min=1,max=100,dive"`
+}
+
+type TemplateSetPayload struct {
+	ExerciseID int     `json:"exercise_id" validate:"required"`
+	Reps       int     `json:"reps" validate:"gte=0"`
+	Weight     float64 `json:"weight" validate:"gte=0"`
+	Duration   int     `json:"duration" validate:"gte=0"`
+	Distance   int     `json:"distance" validate:"gte=0"`
+}
+
+// Assignment is a template a coach gave a client to do, completed by linking a logged workout.
+type Assignment struct {
+	ID          int              `db:"id" json:"id"`
+	TemplateID  int              `db:"template_id" json:"template_id"`
+	CoachID     int              `db:"coach_id" json:"coach_id"`
+	ClientID    int              `db:"client_id" json:"client_id"`
+	DueOn       *string          `db:"due_on" json:"due_on,omitempty"`
+	Note        string           `db:"note" json:"note"`
+	WorkoutID   *int             `db:"workout_id" json:"workout_id,omitempty"`
+	CompletedAt *time.Time       `db:"completed_at" json:"completed_at,omitempty"`
+	AssignedAt  time.Time        `db:"assigned_at" json:"assigned_at"`
+	Template    *WorkoutTemplate `db:"-" json:"template,omitempty"`
+}
+
+type AssignTemplatePayload struct {
+	TemplateID int    `json:"template_id" validate:"required"`
+	DueOn      string `json:"due_on" validate:"omitempty,datetime=2006-01-02"`
+	Note       string `json:"note" validate:"max=2000"`
+}
+
+type CompleteAssignmentPayload struct {
+	WorkoutID int `json:"workout_id" validate:"required"`
+}
+
+type CoachNote struct {
+	ID        int       `db:"id" json:"id"`
+	CoachID   int       `db:"coach_id" json:"coach_id"`
+	ClientID  int       `db:"client_id" json:"client_id"`
+	Body      string    `db:"body" json:"body"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreateCoachNotePayload struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
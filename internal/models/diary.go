@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type DiaryEntry struct {
+	ID        int       `db:"id" json:"id"`
+	UserID    int       `db:"user_id" json:"-"`
+	EatenAt   time.Time `db:"eaten_at" json:"eaten_at"`
+	Meal      string    `db:"meal" json:"meal"`
+	Name      string    `db:"name" json:"name"`
+	Kcal      float64   `db:"kcal" json:"kcal"`
+	Protein   float64   `db:"protein" json:"protein"`
+	Carbs     float64   `db:"carbs" json:"carbs"`
+	Fat       float64   `db:"fat" json:"fat"`
+	FoodID    *int      `db:"food_id" json:"food_id,omitempty"`
+	Grams     *float64  `db:"grams" json:"grams,omitempty"`
+	RecipeID  *int      `db:"recipe_id" json:"recipe_id,omitempty"`
+	Servings  *float64  `db:"servings" json:"servings,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// CreateDiaryEntryPayload references a food with the eaten grams or a recipe with the eaten
+// servings, in which case the name and macros are computed, or describes the meal manually.
+type CreateDiaryEntryPayload struct {
+	EatenAt  time.Time `json:"eaten_at" validate:"required"`
+	Meal     string    `json:"meal" validate:"required,oneof=breakfast lunch dinner snack"`
+	Name     string    `json:"name" validate:"required_without_all=FoodID RecipeID"`
+	Kcal     float64   `json:"kcal" validate:"gte=0"`
+	Protein  float64   `json:"protein" validate:"gte=0"`
+	Carbs    float64   `json:"carbs" validate:"gte=0"`
+	Fat      float64   `json:"fat" validate:"gte=0"`
+	FoodID   *int      `json:"food_id" validate:"excluded_with=RecipeID"`
+	Grams    *float64  `json:"grams" validate:"required_with=FoodID,omitempty,gt=0"`
+	RecipeID *int      `json:"recipe_id"`
+	Servings *float64  `json:"servings" validate:"required_with=RecipeID,omitempty,gt=0"`
+}
@@ -0,0 +1,18 @@
+package models
+
+type WorkoutEnergy struct {
+	WorkoutID int     `json:"workout_id"`
+	Kcal      float64 `json:"kcal"`
+}
+
+// EnergyDay is the energy balance of one calendar day. Expenditure is the sedentary
+// daily expenditure derived from BMR plus the energy burned in workouts.
+type EnergyDay struct {
+	Date        string          `json:"date"`
+	Intake      float64         `json:"intake_kcal"`
+	BMR         float64         `json:"bmr_kcal"`
+	Workouts    float64         `json:"workouts_kcal"`
+	Expenditure float64         `json:"expenditure_kcal"`
+	Balance     float64         `json:"balance_kcal"`
+	PerWorkout  []WorkoutEnergy `json:"per_workout"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+	ExportExpired    = "expired"
+)
+
+type DataExport struct {
+	ID         int        `db:"id" json:"id"`
+	UserID     int        `db:"user_id" json:"-"`
+	Status     string     `db:"status" json:"status"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	TokenHash  *string    `db:"token_hash" json:"-"`
+	BlobKey    string     `db:"blob_key" json:"-"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	FoodSourceUser          = "user"
+	FoodSourceOpenFoodFacts = "off"
+)
+
+const (
+	DietNone        = "none"
+	DietVegetarian  = "vegetarian"
+	DietVegan       = "vegan"
+	DietPescatarian = "pescatarian"
+)
+
+// Allergens are the 14 allergens of the EU labelling regulation, named like the Open Food Facts tags.
+var Allergens = []string{
+	"celery", "crustaceans", "eggs", "fish", "gluten", "lupin", "milk", "molluscs", "mustard", "nuts", "peanuts",
+	"sesame-seeds", "soybeans", "sulphur-dioxide-and-sulphites",
+}
+
+// Nutrients are amounts per 100 g: energy in kcal, macronutrients in grams and minerals
+// and vitamins in milligrams.
+type Nutrients struct {
+	Kcal         float64 `db:"kcal" json:"kcal" validate:"gte=0,lte=900"`
+	Protein      float64 `db:"protein" json:"protein" validate:"gte=0,lte=100"`
+	Carbs        float64 `db:"carbs" json:"carbs" validate:"gte=0,lte=100"`
+	Fat          float64 `db:"fat" json:"fat" validate:"gte=0,lte=100"`
+	SaturatedFat float64 `db:"saturated_fat" json:"saturated_fat" validate:"gte=0,lte=100"`
+	Sugars       float64 `db:"sugars" json:"sugars" validate:"gte=0,lte=100"`
+	Fiber        float64 `db:"fiber" json:"fiber" validate:"gte=0,lte=100"`
+	Sodium       float64 `db:"sodium" json:"sodium" validate:"gte=0"`
+	Calcium      float64 `db:"calcium" json:"calcium" validate:"gte=0"`
+	Iron         float64 `db:"iron" json:"iron" validate:"gte=0"`
+	Potassium    float64 `db:"potassium" json:"potassium" validate:"gte=0"`
+	VitaminC     float64 `db:"vitamin_c" json:"vitamin_c" validate:"gte=0"`
+}
+
+type Food struct {
+	ID        int     `db:"id" json:"id"`
+	OwnerID   *int    `db:"owner_id" json:"owner_id,omitempty"`
+	Barcode   *string `db:"barcode" json:"barcode,omitempty"`
+	Name      string  `db:"name" json:"name"`
+	Brand     string  `db:"brand" json:"brand"`
+	Source    string  `db:"source" json:"source"`
+	Nutrients `json:"per_100g"`
+	Allergens pq.StringArray `db:"allergens" json:"allergens"`
+	Diets     pq.StringArray `db:"diets" json:"diets"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+type CreateFoodPayload struct {
+	Name      string    `json:"name" validate:"required"`
+	Brand     string    `json:"brand"`
+	Barcode   *string   `json:"barcode" validate:"omitempty,numeric,min=8,max=14"`
+	Nutrients Nutrients `json:"per_100g"`
+	Allergens []string  `json:"allergens" validate:"dive,oneof=celery crustaceans eggs fish gluten lupin milk molluscs mustard nuts peanuts sesame-seeds soybeans sulphur-dioxide-and-sulphites"`
+	Diets     []string  `json:"diets" validate:"dive,oneof=vegetarian vegan pescatarian"`
+}
+
+// Recipe is private to its owner unless ShareToken is set, in which case anyone with the link can read it.
+type Recipe struct {
+	ID           int                `db:"id" json:"id"`
+	OwnerID      int                `db:"owner_id" json:"-"`
+	Name         string             `db:"name" json:"name"`
+	Servings     int                `db:"servings" json:"servings"`
+	CookedWeight *float64           `db:"cooked_weight" json:"cooked_weight,omitempty"`
+	ShareToken   *string            `db:"share_token" json:"share_token,omitempty"`
+	CreatedAt    time.Time          `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `db:"updated_at" json:"updated_at"`
+	Ingredients  []RecipeIngredient `db:"-" json:"ingredients"`
+	Nutrition    *RecipeNutrition   `db:"-" json:"nutrition,omitempty"`
+}
+
+// RecipeNutrition is computed from the ingredients. Weight is the raw weight of the ingredients,
+// Per100g refers to the cooked dish when the cooked weight is known.
+type RecipeNutrition struct {
+	Weight     float64   `json:"weight"`
+	Total      Nutrients `json:"total"`
+	PerServing Nutrients `json:"per_serving"`
+	Per100g    Nutrients `json:"per_100g"`
+}
+
+type RecipeIngredient struct {
+	RecipeID int     `db:"recipe_id" json:"-"`
+	Position int     `db:"position" json:"position"`
+	FoodID   int     `db:"food_id" json:"food_id"`
+	Grams    float64 `db:"grams" json:"grams"`
+}
+
+type CreateRecipePayload struct {
+	Name         string                    `json:"name" validate:"required"`
+	Servings     int                       `json:"servings" validate:"omitempty,gte=1,lte=100"`
+	CookedWeight *float64                  `json:"cooked_weight" validate:"omitempty,gt=0"`
+	Ingredients  []RecipeIngredientPayload `json:"ingredients" validate:"required,min=1,dive"`
+}
+
+type RecipeIngredientPayload struct {
+	FoodID int     `json:"food_id" validate:"required"`
+	Grams  float64 `json:"grams" validate:"gt=0"`
+}
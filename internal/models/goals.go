@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	GoalTargetWeight    = "target_weight"
+	GoalWorkoutsPerWeek = "workouts_per_week"
+	GoalDailySteps      = "daily_steps"
+	GoalProteinPerDay   = "protein_per_day"
+)
+
+const (
+	GoalStatusActive    = "active"
+	GoalStatusCompleted = "completed"
+	GoalStatusMissed    = "missed"
+	GoalStatusAbandoned = "abandoned"
+)
+
+// Goal is a target to reach between StartsOn and EndsOn (both YYYY-MM-DD, inclusive).
+// Target is a weight in kg, a number of workouts per week, steps per day or grams of protein per day.
+// StartValue is the weight when a target weight goal was set. Current is the latest weight
+// for weight goals and the number of workouts or days that count towards the goal otherwise.
+type Goal struct {
+	ID                 int             `db:"id" json:"id"`
+	UserID             int             `db:"user_id" json:"-"`
+	Kind               string          `db:"kind" json:"kind"`
+	Target             float64         `db:"target" json:"target"`
+	StartValue         *float64        `db:"start_value" json:"start_value,omitempty"`
+	StartsOn           string          `db:"starts_on" json:"starts_on"`
+	EndsOn             string          `db:"ends_on" json:"ends_on"`
+	Status             string          `db:"status" json:"status"`
+	Progress           float64         `db:"progress" json:"progress"`
+	Current            *float64        `db:"current_value" json:"current,omitempty"`
+	OffTrack           bool            `db:"off_track" json:"off_track"`
+	OffTrackNotifiedAt *time.Time      `db:"off_track_notified_at" json:"-"`
+	EvaluatedAt        *time.Time      `db:"evaluated_at" json:"evaluated_at,omitempty"`
+	CompletedAt        *time.Time      `db:"completed_at" json:"completed_at,omitempty"`
+	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
+	Milestones         []GoalMilestone `db:"-" json:"milestones,omitempty"`
+}
+
+type GoalMilestone struct {
+	GoalID    int       `db:"goal_id" json:"-"`
+	Percent   int       `db:"percent" json:"percent"`
+	ReachedAt time.Time `db:"reached_at" json:"reached_at"`
+}
+
+// CreateGoalPayload starts today unless StartsOn is given.
+type CreateGoalPayload struct {
+	Kind     string  `json:"kind" validate:"required,oneof=target_weight workouts_per_week daily_steps protein_per_day"`
+	Target   float64 `json:"target" validate:"gt=0"`
+	StartsOn string  `json:"starts_on" validate:"omitempty,datetime=2006-01-02"`
+	EndsOn   string  `json:"ends_on" validate:"required,datetime=2006-01-02"`
+}
+
+// GoalEvent is the payload of the goal events. Percent is the milestone that was reached
+// and Expected the progress the goal should have by now.
+type GoalEvent struct {
+	Goal     Goal
+	Percent  int
+	Expected float64
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyKey is a request made with an Idempotency-Key header. StatusCode is nil while the
+// first request is still being handled, afterwards the response is kept to be replayed. The key
+// is locked to the first request until LockedUntil only, so that a crashed one does not hold it.
+type IdempotencyKey struct {
+	Scope       string          `db:"scope"`
+	Key         string          `db:"key"`
+	RequestHash string          `db:"request_hash"`
+	StatusCode  *int            `db:"status_code"`
+	Header      json.RawMessage `db:"header"`
+	Body        []byte          `db:"body"`
+	CreatedAt   time.Time       `db:"created_at"`
+	LockedUntil time.Time       `db:"locked_until"`
+	ExpiresAt   time.Time       `db:"expires_at"`
+}
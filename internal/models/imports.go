@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	ImportPending    = "pending"
+	ImportProcessing = "processing"
+	ImportDone       = "done"
+	ImportDuplicate  = "duplicate"
+	ImportFailed     = "failed"
+)
+
+type Import struct {
+	ID         int        `db:"id" json:"id"`
+	UserID     int        `db:"user_id" json:"-"`
+	Filename   string     `db:"filename" json:"filename"`
+	Format     string     `db:"format" json:"format"`
+	Status     string     `db:"status" json:"status"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	WorkoutID  *int       `db:"workout_id" json:"workout_id,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+}
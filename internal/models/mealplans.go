@@ -0,0 +1,89 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	MealBreakfast = "breakfast"
+	MealLunch     = "lunch"
+	MealDinner    = "dinner"
+	MealSnack     = "snack"
+)
+
+type Preferences struct {
+	UserID    int            `db:"user_id" json:"-"`
+	Diet      string         `db:"diet" json:"diet"`
+	Allergens pq.StringArray `db:"allergens" json:"allergens"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+type UpdatePreferencesPayload struct {
+	Diet      string   `json:"diet" validate:"required,oneof=none vegetarian vegan pescatarian"`
+	Allergens []string `json:"allergens" validate:"dive,oneof=celery crustaceans eggs fish gluten lupin milk molluscs mustard nuts peanuts sesame-seeds soybeans sulphur-dioxide-and-sulphites"`
+}
+
+// MacroRange is an inclusive range in grams per day.
+type MacroRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// MealPlanTargets are daily targets. Tolerance is the accepted relative deviation from Kcal.
+type MealPlanTargets struct {
+	Kcal      float64    `json:"kcal"`
+	Tolerance float64    `json:"tolerance"`
+	Protein   MacroRange `json:"protein"`
+	Carbs     MacroRange `json:"carbs"`
+	Fat       MacroRange `json:"fat"`
+}
+
+type MealPlan struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"-"`
+	Seed      int64           `json:"seed"`
+	Targets   MealPlanTargets `json:"targets"`
+	Days      []MealPlanDay   `json:"days"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type MealPlanDay struct {
+	Day int `json:"day"`
+	// OnTarget reports whether the day hits the calories within the tolerance and every macro range.
+	OnTarget bool          `json:"on_target"`
+	Totals   Nutrients     `json:"totals"`
+	Meals    []PlannedMeal `json:"meals"`
+}
+
+type PlannedMeal struct {
+	Name  string        `json:"name"`
+	Items []PlannedItem `json:"items"`
+}
+
+// PlannedItem is either a food eaten by Grams or a recipe eaten by Servings.
+type PlannedItem struct {
+	FoodID    *int      `json:"food_id,omitempty"`
+	RecipeID  *int      `json:"recipe_id,omitempty"`
+	Name      string    `json:"name"`
+	Grams     float64   `json:"grams,omitempty"`
+	Servings  float64   `json:"servings,omitempty"`
+	Nutrients Nutrients `json:"nutrients"`
+}
+
+type CreateMealPlanPayload struct {
+	Days      int      `json:"days" validate:"required,gte=1,lte=7"`
+	Seed      *int64   `json:"seed"`
+	Tolerance *float64 `json:"tolerance" validate:"omitempty,gte=0.01,lte=0.2"`
+}
+
+// SwapMealPlanItemPayload replaces an item by the given food or recipe, or by a similar
+// candidate picked by the planner when neither is set.
+type SwapMealPlanItemPayload struct {
+	Day      int    `json:"day" validate:"required,gte=1"`
+	Meal     string `json:"meal" validate:"required,oneof=breakfast lunch dinner snack"`
+	Item     int    `json:"item" validate:"gte=0"`
+	FoodID   *int   `json:"food_id" validate:"omitempty,excluded_with=RecipeID"`
+	RecipeID *int   `json:"recipe_id"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+const (
+	MetricWater            = "water_ml"
+	MetricSleepMinutes     = "sleep_minutes"
+	MetricSleepQuality     = "sleep_quality"
+	MetricSteps            = "steps"
+	MetricRestingHeartRate = "resting_hr"
+)
+
+const MetricSourceManual = "manual"
+
+// DailyMetric is the value of a habit metric for a calendar day, Date being YYYY-MM-DD.
+type DailyMetric struct {
+	UserID    int       `db:"user_id" json:"-"`
+	Date      string    `db:"day" json:"date"`
+	Metric    string    `db:"metric" json:"metric"`
+	Value     float64   `db:"value" json:"value"`
+	Source    string    `db:"source" json:"source"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type MetricGoal struct {
+	Metric string  `db:"metric" json:"metric"`
+	Target float64 `db:"target" json:"target"`
+	// Default is set when the user has no goal of their own for the metric.
+	Default bool `db:"-" json:"default"`
+}
+
+// SaveDayMetricsPayload sets some metrics of one day, e.g. {"values": {"water_ml": 1500}}.
+type SaveDayMetricsPayload struct {
+	Values map[string]float64 `json:"values" validate:"required,min=1,dive,keys,oneof=water_ml sleep_minutes sleep_quality steps resting_hr,endkeys,gte=0"`
+	Source string             `json:"source" validate:"max=50"`
+}
+
+// SyncMetricsPayload is a batch from a wearable. Later entries win over earlier ones for the same day.
+type SyncMetricsPayload struct {
+	Source  string               `json:"source" validate:"required,max=50"`
+	Entries []MetricEntryPayload `json:"entries" validate:"required,min=1,max=1000,dive"`
+}
+
+type MetricEntryPayload struct {
+	Date   string  `json:"date" validate:"required,datetime=2006-01-02"`
+	Metric string  `json:"metric" validate:"required,oneof=water_ml sleep_minutes sleep_quality steps resting_hr"`
+	Value  float64 `json:"value" validate:"gte=0"`
+}
+
+type SaveMetricGoalsPayload struct {
+	Goals map[string]float64 `json:"goals" validate:"required,min=1,dive,keys,oneof=water_ml sleep_minutes sleep_quality steps resting_hr,endkeys,gt=0"`
+}
+
+type MetricStreak struct {
+	Metric  string  `json:"metric"`
+	Goal    float64 `json:"goal"`
+	Current int     `json:"current"`
+	Longest int     `json:"longest"`
+	// TodayMet tells whether the current streak already includes today.
+	TodayMet bool `json:"today_met"`
+}
+
+// MetricSummary aggregates a metric over a week or a month starting on Start.
+type MetricSummary struct {
+	Start      string  `json:"start"`
+	Metric     string  `json:"metric"`
+	DaysLogged int     `json:"days_logged"`
+	Total      float64 `json:"total"`
+	Average    float64 `json:"average"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	GoalDays   int     `json:"goal_days"`
+}
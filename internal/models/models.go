@@ -6,11 +6,10 @@ type User struct {
 	ID             int       `db:"id"`
 	Email          string    `db:"email"`
 	Username       string    `db:"username"`
-	Password       []byte    `db:"password"`
+	Password       []byte    `db:"password" audit:"-"`
 	CreatedAt      time.Time `db:"created_at"`
 	IsActive       bool      `db:"is_active"`
-	ActivationCode *string   `db:"activation_code"`
-	IsSuperuser    bool      `db:"is_superuser"`
+	ActivationCode *string   `db:"activation_code" audit:"-"`
 	IsMale         bool      `db:"is_male"`
 	Age            int       `db:"age"`
 	Height         int       `db:"height"`
@@ -32,10 +31,40 @@ type RegisterUserPayload struct {
 }
 
 type LoginUserPayload struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=3,max=30"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=3,max=30"`
+	DeviceName string `json:"device_name" validate:"max=100"`
 }
 
 type ActivationPayload struct {
 	ActivationCode string `json:"activation_code"`
 }
+
+// Profile is the user as shown to the user and to the apps with the profile scope.
+type Profile struct {
+	ID         int       `json:"id"`
+	Email      string    `json:"email"`
+	Username   string    `json:"username"`
+	IsMale     bool      `json:"isMale"`
+	Age        int       `json:"age"`
+	Height     int       `json:"height"`
+	Weight     int       `json:"weight"`
+	Goal       string    `json:"goal"`
+	WeightGoal int       `json:"weightGoal"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UpdateProfilePayload struct {
+	Username   string `json:"username" validate:"required,max=50"`
+	IsMale     bool   `json:"isMale"`
+	Age        int    `json:"age" validate:"required,gt=0,lt=150"`
+	Height     int    `json:"height" validate:"required,gt=0,lt=300"`
+	Weight     int    `json:"weight" validate:"required,gt=0,lt=700"`
+	Goal       string `json:"goal" validate:"required,oneof=lose maintain gain"`
+	WeightGoal int    `json:"weightGoal" validate:"required,gt=0,lt=700"`
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=30"`
+}
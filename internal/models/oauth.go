@@ -0,0 +1,105 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Scopes a partner app can ask for. Tokens without a scope are refused by the routes that need it.
+const (
+	ScopeProfile       = "profile"
+	ScopeWorkoutsRead  = "workouts:read"
+	ScopeNutritionRead = "nutrition:read"
+	ScopeWeightWrite   = "weight:write"
+)
+
+var OAuthScopes = []string{ScopeProfile, ScopeWorkoutsRead, ScopeNutritionRead, ScopeWeightWrite}
+
+// OAuthClient are the OAuth2 credentials of a partner app. Public clients, e.g. mobile apps, have
+// no secret and rely on PKCE alone.
+type OAuthClient struct {
+	ID           int            `db:"id" json:"-"`
+	AppID        int            `db:"app_id" json:"app_id"`
+	AppName      string         `db:"app_name" json:"app_name"`
+	ClientID     string         `db:"client_id" json:"client_id"`
+	SecretHash   *string        `db:"secret_hash" json:"-"`
+	RedirectURIs pq.StringArray `db:"redirect_uris" json:"redirect_uris"`
+	Confidential bool           `db:"confidential" json:"confidential"`
+	CreatedAt    time.Time      `db:"created_at" json:"created_at"`
+}
+
+type CreateOAuthClientPayload struct {
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,max=10,dive,url,max=2000"`
+	Confidential bool     `json:"confidential"`
+}
+
+// AuthorizeRequest are the parameters of the authorization-code flow. Only S256 PKCE challenges
+// are accepted.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" validate:"required,eq=code"`
+	ClientID            string `json:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri" validate:"required"`
+	Scope               string `json:"scope" validate:"required"`
+	State               string `json:"state" validate:"max=500"`
+	CodeChallenge       string `json:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" validate:"required,eq=S256"`
+}
+
+// AuthorizePayload is the answer of the user on the consent screen.
+type AuthorizePayload struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// OAuthConsent is what the consent screen shows. Granted tells that the user already consented
+// to all the scopes.
+type OAuthConsent struct {
+	AppName string   `json:"app_name"`
+	Scopes  []string `json:"scopes"`
+	Granted bool     `json:"granted"`
+}
+
+type OAuthCode struct {
+	CodeHash      string         `db:"code_hash"`
+	ClientID      int            `db:"client_id"`
+	UserID        int            `db:"user_id"`
+	RedirectURI   string         `db:"redirect_uri"`
+	Scopes        pq.StringArray `db:"scopes"`
+	CodeChallenge string         `db:"code_challenge"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	UsedAt        *time.Time     `db:"used_at"`
+}
+
+// OAuthToken is a grant of access to a client. Access tokens are JWTs naming the grant, the
+// refresh token is stored hashed. Revoking the grant invalidates both.
+type OAuthToken struct {
+	ID          int64          `db:"id"`
+	ClientID    int            `db:"client_id"`
+	UserID      int            `db:"user_id"`
+	Scopes      pq.StringArray `db:"scopes"`
+	RefreshHash string         `db:"refresh_hash"`
+	CodeHash    *string        `db:"code_hash"`
+	CreatedAt   time.Time      `db:"created_at"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+	RevokedAt   *time.Time     `db:"revoked_at"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// Introspection is the RFC 7662 answer about a token. Inactive tokens tell nothing else.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	PermUsersRead       = "users:read"
+	PermUsersWrite      = "users:write"
+	PermFoodsPublish    = "foods:publish"
+	PermProgramsPublish = "programs:publish"
+	PermAuditRead       = "audit:read"
+)
+
+// RoleAdmin has every permission. It replaces the former superuser flag and cannot be changed.
+const RoleAdmin = "admin"
+
+type Permission struct {
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
+
+type Role struct {
+	Name        string         `db:"name" json:"name"`
+	Description string         `db:"description" json:"description"`
+	Builtin     bool           `db:"builtin" json:"builtin"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+}
+
+type CreateRolePayload struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50,lowercase,alphanum"`
+	Description string   `json:"description" validate:"max=200"`
+	Permissions []string `json:"permissions" validate:"unique,dive,required"`
+}
+
+type UpdateRolePayload struct {
+	Description string   `json:"description" validate:"max=200"`
+	Permissions []string `json:"permissions" validate:"unique,dive,required"`
+}
+
+// UserAccess is a user as seen by administrators.
+type UserAccess struct {
+	ID          int       `json:"id"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	Roles       []string  `json:"roles"`
+	Permissions []string  `json:"permissions"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session is a login of the user on a device. The tokens issued at login name the session, so
+// revoking it logs the device out before the tokens expire.
+type Session struct {
+	ID           int64      `db:"id" json:"id"`
+	UserID       int        `db:"user_id" json:"-"`
+	DeviceName   string     `db:"device_name" json:"device_name"`
+	UserAgent    string     `db:"user_agent" json:"user_agent"`
+	IP           string     `db:"ip" json:"ip"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	LastActiveAt time.Time  `db:"last_active_at" json:"last_active_at"`
+	ExpiresAt    time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time `db:"revoked_at" json:"-"`
+	Current      bool       `db:"-" json:"current"`
+}
+
+// RevokedSession is an entry of the revocation list the auth middleware checks.
+type RevokedSession struct {
+	ID        int64     `db:"id"`
+	RevokedAt time.Time `db:"revoked_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityPrivate   = "private"
+)
+
+const (
+	FollowPending  = "pending"
+	FollowAccepted = "accepted"
+)
+
+const (
+	ActivityWorkout     = "workout"
+	ActivityRecord      = "record"
+	ActivityAchievement = "achievement"
+)
+
+// SocialSettings: a private profile approves its followers and shows nothing to other users.
+// DefaultVisibility applies to new activities.
+type SocialSettings struct {
+	UserID            int    `db:"user_id" json:"-"`
+	Private           bool   `db:"private" json:"private"`
+	DefaultVisibility string `db:"default_visibility" json:"default_visibility"`
+}
+
+type UpdateSocialSettingsPayload struct {
+	Private           *bool  `json:"private"`
+	DefaultVisibility string `json:"default_visibility" validate:"omitempty,oneof=public followers private"`
+}
+
+// Follow is a follow relation. Username is the name of the other side of the relation
+// from the point of view of the list it is part of.
+type Follow struct {
+	FollowerID int        `db:"follower_id" json:"follower_id"`
+	FolloweeID int        `db:"followee_id" json:"followee_id"`
+	Username   string     `db:"username" json:"username"`
+	Status     string     `db:"status" json:"status"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	AcceptedAt *time.Time `db:"accepted_at" json:"accepted_at,omitempty"`
+}
+
+// Activity is a shareable event of a user: a workout, the records set in a workout or an achievement.
+// Kudoed tells whether the reading user gave kudos.
+type Activity struct {
+	ID            int64           `db:"id" json:"id"`
+	UserID        int             `db:"user_id" json:"user_id"`
+	Username      string          `db:"username" json:"username"`
+	Kind          string          `db:"kind" json:"kind"`
+	Ref           string          `db:"ref" json:"-"`
+	Visibility    string          `db:"visibility" json:"visibility"`
+	Payload       json.RawMessage `db:"payload" json:"payload"`
+	OccurredAt    time.Time       `db:"occurred_at" json:"occurred_at"`
+	KudosCount    int             `db:"kudos_count" json:"kudos_count"`
+	CommentsCount int             `db:"comments_count" json:"comments_count"`
+	Kudoed        bool            `db:"kudoed" json:"kudoed"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+}
+
+type ActivityPage struct {
+	Items      []Activity `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type UpdateActivityPayload struct {
+	Visibility string `json:"visibility" validate:"required,oneof=public followers private"`
+}
+
+type Comment struct {
+	ID         int64     `db:"id" json:"id"`
+	ActivityID int64     `db:"activity_id" json:"activity_id"`
+	UserID     int       `db:"user_id" json:"user_id"`
+	Username   string    `db:"username" json:"username"`
+	Body       string    `db:"body" json:"body"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreateCommentPayload struct {
+	Body string `json:"body" validate:"required,max=1000"`
+}
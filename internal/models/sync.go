@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Synced record types.
+const (
+	SyncWorkout     = "workout"
+	SyncWorkoutSet  = "workout_set"
+	SyncDiaryEntry  = "diary_entry"
+	SyncWeightEntry = "weight_entry"
+)
+
+const (
+	SyncUpsert = "upsert"
+	SyncDelete = "delete"
+)
+
+// Decisions taken on pushed changes. A change is applied when it is newer than the server's
+// version of the record, ignored when the server already has it, and loses to the server's
+// version otherwise. Rejected changes point to a record that does not exist or is not theirs.
+const (
+	SyncApplied    = "applied"
+	SyncIgnored    = "ignored"
+	SyncServerWins = "server_wins"
+	SyncRejected   = "rejected"
+)
+
+type SyncPushPayload struct {
+	DeviceID string       `json:"device_id" validate:"required,max=100"`
+	Changes  []SyncChange `json:"changes" validate:"required,min=1,max=500,dive"`
+}
+
+// SyncChange is a change made on a device. UpdatedAt is when it was made there and decides
+// which of two conflicting changes wins.
+type SyncChange struct {
+	Entity    string          `json:"entity" validate:"required,oneof=workout workout_set diary_entry weight_entry"`
+	ID        string          `json:"id" validate:"required,uuid"`
+	Op        string          `json:"op" validate:"required,oneof=upsert delete"`
+	UpdatedAt time.Time       `json:"updated_at" validate:"required"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	// Record is Data decoded into the type of the entity.
+	Record any `json:"-"`
+}
+
+type SyncResult struct {
+	Entity   string      `json:"entity"`
+	ID       string      `json:"id"`
+	Decision string      `json:"decision"`
+	Reason   string      `json:"reason,omitempty"`
+	Server   *SyncRecord `json:"server,omitempty"`
+}
+
+type SyncPushResult struct {
+	Results []SyncResult `json:"results"`
+	// Created and Updated are the records the push created and changed, as they are after it;
+	// Deleted the ones it removed, as they were before.
+	Created SyncedRecords `json:"-"`
+	Updated SyncedRecords `json:"-"`
+	Deleted SyncedRecords `json:"-"`
+}
+
+// SyncedRecords are records touched by a push. Changing a set changes its workout.
+type SyncedRecords struct {
+	Workouts      []Workout
+	DiaryEntries  []DiaryEntry
+	WeightEntries []WeightEntry
+}
+
+// SyncRecord is the server's version of a record. Data is nil for deleted records.
+type SyncRecord struct {
+	Entity    string    `json:"entity"`
+	ID        string    `json:"id"`
+	Deleted   bool      `json:"deleted"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Data      any       `json:"data,omitempty"`
+}
+
+type SyncPage struct {
+	Changes []SyncRecord `json:"changes"`
+	Cursor  int64        `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+}
+
+type SyncWorkoutData struct {
+	Name      string    `db:"name" json:"name"`
+	StartedAt time.Time `db:"started_at" json:"started_at" validate:"required"`
+	Duration  int       `db:"duration" json:"duration" validate:"gte=0"`
+	Notes     string    `db:"notes" json:"notes"`
+}
+
+// SyncWorkoutSetData belongs to the workout with the WorkoutID uuid.
+type SyncWorkoutSetData struct {
+	WorkoutID  string   `db:"workout_id" json:"workout_id" validate:"required,uuid"`
+	ExerciseID int      `db:"exercise_id" json:"exercise_id" validate:"required"`
+	Position   int      `db:"position" json:"position" validate:"gte=0"`
+	Reps       int      `db:"reps" json:"reps" validate:"gte=0"`
+	Weight     float64  `db:"weight" json:"weight" validate:"gte=0"`
+	RPE        *float64 `db:"rpe" json:"rpe,omitempty" validate:"omitempty,gte=1,lte=10"`
+	Duration   int      `db:"duration" json:"duration" validate:"gte=0"`
+	Distance   int      `db:"distance" json:"distance" validate:"gte=0"`
+}
+
+// SyncDiaryEntryData carries the macros as computed on the device.
+type SyncDiaryEntryData struct {
+	EatenAt  time.Time `db:"eaten_at" json:"eaten_at" validate:"required"`
+	Meal     string    `db:"meal" json:"meal" validate:"required,oneof=breakfast lunch dinner snack"`
+	Name     string    `db:"name" json:"name" validate:"required"`
+	Kcal     float64   `db:"kcal" json:"kcal" validate:"gte=0"`
+	Protein  float64   `db:"protein" json:"protein" validate:"gte=0"`
+	Carbs    float64   `db:"carbs" json:"carbs" validate:"gte=0"`
+	Fat      float64   `db:"fat" json:"fat" validate:"gte=0"`
+	FoodID   *int      `db:"food_id" json:"food_id,omitempty" validate:"excluded_with=RecipeID"`
+	Grams    *float64  `db:"grams" json:"grams,omitempty" validate:"required_with=FoodID,omitempty,gt=0"`
+	RecipeID *int      `db:"recipe_id" json:"recipe_id,omitempty"`
+	Servings *float64  `db:"servings" json:"servings,omitempty" validate:"required_with=RecipeID,omitempty,gt=0"`
+}
+
+type SyncWeightEntryData struct {
+	MeasuredAt time.Time `db:"measured_at" json:"measured_at" validate:"required"`
+	Weight     float64   `db:"weight" json:"weight" validate:"required,gt=20,lt=500"`
+	Note       string    `db:"note" json:"note" validate:"max=500"`
+}
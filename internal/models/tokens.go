@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// PersonalTokenPrefix starts every personal access token, which tells them apart from the JWTs
+// and lets secret scanners find leaked ones.
+const PersonalTokenPrefix = "afp_"
+
+// PersonalToken lets scripts act for the user within the scopes of the token. Hint is the start
+// of the token, to recognize it in the list; the token itself is only shown at creation.
+type PersonalToken struct {
+	ID         int            `db:"id" json:"id"`
+	UserID     int            `db:"user_id" json:"-"`
+	Name       string         `db:"name" json:"name"`
+	Hint       string         `db:"hint" json:"hint"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time     `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
+
+type CreatePersonalTokenPayload struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=profile workouts:read nutrition:read weight:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
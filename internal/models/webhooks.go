@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/lib/pq"
+	"time"
+)
+
+// WebhookPing is sent by the test-ping endpoint only, the other webhook events are domain events
+// of the same name.
+const WebhookPing = "ping"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// PartnerApp is a third-party integration, e.g. a gym or an insurer. It receives the events of
+// the users who connected it.
+type PartnerApp struct {
+	ID        int       `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreatePartnerAppPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// WebhookOwner is either a user, who gets their own events, or a partner app.
+type WebhookOwner struct {
+	UserID *int
+	AppID  *int
+}
+
+// Webhook subscribes a URL to events. Secret signs the deliveries and is only shown at creation.
+type Webhook struct {
+	ID        int            `db:"id" json:"id"`
+	UserID    *int           `db:"user_id" json:"user_id,omitempty"`
+	AppID     *int           `db:"app_id" json:"app_id,omitempty"`
+	URL       string         `db:"url" json:"url"`
+	Secret    string         `db:"secret" json:"-"`
+	Events    pq.StringArray `db:"events" json:"events"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,url,max=2000"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=workout.created weight.logged goal.completed"`
+}
+
+// WebhookDelivery is the log of sending an event to a webhook.
+type WebhookDelivery struct {
+	ID             int64           `db:"id" json:"id"`
+	WebhookID      int             `db:"webhook_id" json:"webhook_id"`
+	Event          string          `db:"event" json:"event"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      string          `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// DueDelivery is a delivery claimed for sending with where to send it.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// WebhookEnvelope is the body of every delivery. ID is the same for all deliveries of an event.
+type WebhookEnvelope struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	UserID     int       `json:"user_id,omitempty"`
+	Data       any       `json:"data"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+const (
+	RecordEstimatedOneRepMax = "estimated_1rm"
+	RecordMaxWeight          = "max_weight"
+	RecordMaxReps            = "max_reps"
+	RecordMaxVolume          = "max_volume"
+)
+
+type Exercise struct {
+	ID         int     `db:"id" json:"id"`
+	Name       string  `db:"name" json:"name"`
+	Category   string  `db:"category" json:"category"`
+	MET        float64 `db:"met" json:"met"`
+	METProfile *string `db:"met_profile" json:"met_profile,omitempty"`
+}
+
+const WorkoutSourceManual = "manual"
+
+type Workout struct {
+	ID            int          `db:"id" json:"id"`
+	UserID        int          `db:"user_id" json:"user_id"`
+	Name          string       `db:"name" json:"name"`
+	StartedAt     time.Time    `db:"started_at" json:"started_at"`
+	Duration      int          `db:"duration" json:"duration"`
+	Notes         string       `db:"notes" json:"notes"`
+	Source        string       `db:"source" json:"source"`
+	Distance      int          `db:"distance" json:"distance"`
+	ElevationGain int          `db:"elevation_gain" json:"elevation_gain"`
+	AvgHeartRate  *int         `db:"avg_heart_rate" json:"avg_heart_rate,omitempty"`
+	MaxHeartRate  *int         `db:"max_heart_rate" json:"max_heart_rate,omitempty"`
+	AvgCadence    *int         `db:"avg_cadence" json:"avg_cadence,omitempty"`
+	CreatedAt     time.Time    `db:"created_at" json:"created_at"`
+	Sets          []WorkoutSet `db:"-" json:"sets"`
+}
+
+type WorkoutSet struct {
+	ID         int      `db:"id" json:"id"`
+	WorkoutID  int      `db:"workout_id" json:"workout_id"`
+	ExerciseID int      `db:"exercise_id" json:"exercise_id"`
+	Position   int      `db:"position" json:"position"`
+	Reps       int      `db:"reps" json:"reps"`
+	Weight     float64  `db:"weight" json:"weight"`
+	RPE        *float64 `db:"rpe" json:"rpe,omitempty"`
+	Duration   int      `db:"duration" json:"duration"`
+	Distance   int      `db:"distance" json:"distance"`
+}
+
+type PersonalRecord struct {
+	UserID     int       `db:"user_id" json:"-"`
+	ExerciseID int       `db:"exercise_id" json:"exercise_id"`
+	Kind       string    `db:"kind" json:"kind"`
+	Value      float64   `db:"value" json:"value"`
+	WorkoutID  *int      `db:"workout_id" json:"workout_id,omitempty"`
+	AchievedAt time.Time `db:"achieved_at" json:"achieved_at"`
+}
+
+// ExerciseSession groups the sets of one exercise done in a single workout.
+type ExerciseSession struct {
+	WorkoutID int          `json:"workout_id"`
+	StartedAt time.Time    `json:"started_at"`
+	Sets      []WorkoutSet `json:"sets"`
+}
+
+type CreateWorkoutPayload struct {
+	Name      string              `json:"name"`
+	StartedAt time.Time           `json:"started_at" validate:"required"`
+	Duration  int                 `json:"duration" validate:"gte=0"`
+	Notes     string              `json:"notes"`
+	Sets      []WorkoutSetPayload `json:"sets" validate:"required,min=1,dive"`
+}
+
+type WorkoutSetPayload struct {
+	ExerciseID int      `json:"exercise_id" validate:"required"`
+	Reps       int      `json:"reps" validate:"gte=0"`
+	Weight     float64  `json:"weight" validate:"gte=0"`
+	RPE        *float64 `json:"rpe" validate:"omitempty,gte=1,lte=10"`
+	Duration   int      `json:"duration" validate:"gte=0"`
+	Distance   int      `json:"distance" validate:"gte=0"`
+}
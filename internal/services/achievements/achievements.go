@@ -0,0 +1,74 @@
+package achievements
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/achievements"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	achievements2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/achievements"
+	"log/slog"
+	"net/http"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+type Handler struct {
+	store achievements2.AchievementStore
+	log   *slog.Logger
+	cfg   config.Config
+}
+
+func NewHandler(store achievements2.AchievementStore, log *slog.Logger) *Handler {
+	return &Handler{store: store, log: log, cfg: config.Envs}
+}
+
+// HandleGetAchievements lists every achievement with whether the user unlocked it and how far they got.
+func (h *Handler) HandleGetAchievements(w http.ResponseWriter, r *http.Request) {
+	const op = "achievements.HandleGetAchievements"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	defs, err := h.store.GetDefinitions()
+	if err != nil {
+		log.Error("failed to get achievements", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	awarded, err := h.store.GetAwarded(user.ID)
+	if err != nil {
+		log.Error("failed to get awarded achievements", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	stats, err := h.store.GetStats(user.ID)
+	if err != nil {
+		log.Error("failed to get achievement stats", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	byCode := make(map[string]models.AwardedAchievement, len(awarded))
+	for _, a := range awarded {
+		byCode[a.Code] = a
+	}
+
+	list := make([]models.UserAchievement, 0, len(defs))
+	for _, d := range defs {
+		ua := models.UserAchievement{Achievement: d, Value: min(achievements.Value(d.Rule, *stats), d.Threshold)}
+		if a, ok := byCode[d.Code]; ok {
+			ua.Unlocked = true
+			ua.AwardedAt = &a.AwardedAt
+			ua.Value = d.Threshold
+		}
+		list = append(list, ua)
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
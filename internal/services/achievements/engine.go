@@ -0,0 +1,74 @@
+package achievements
+
+import (
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/achievements"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/coalesce"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	achievements2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/achievements"
+	"log/slog"
+)
+
+// Engine awards the achievements whose rules the user's logged data satisfies.
+type Engine struct {
+	store   achievements2.AchievementStore
+	bus     *events.Bus
+	log     *slog.Logger
+	running coalesce.Group
+}
+
+func NewEngine(store achievements2.AchievementStore, bus *events.Bus, log *slog.Logger) *Engine {
+	return &Engine{store: store, bus: bus, log: log.With(slog.String("component", "achievements/engine"))}
+}
+
+// Subscribe evaluates the user's achievements whenever a workout, a record, a meal, a weight
+// or a metric is logged, and whenever a workout, a meal or a weight is changed or deleted.
+func (e *Engine) Subscribe(bus *events.Bus) {
+	for _, t := range []string{
+		events.WorkoutCreated, events.WorkoutUpdated, events.WorkoutDeleted, events.RecordBroken, events.DiaryLogged,
+		events.DiaryUpdated, events.DiaryDeleted, events.WeightLogged, events.WeightUpdated, events.WeightDeleted,
+		events.MetricsLogged,
+	} {
+		bus.Subscribe(t, func(ev events.Event) { e.Refresh(ev.UserID) })
+	}
+}
+
+// Refresh evaluates the user's achievements, folding concurrent calls for the same user into one run.
+func (e *Engine) Refresh(userID int) {
+	e.running.Do(
+		userID, func() {
+			if _, err := e.Evaluate(userID); err != nil {
+				e.log.Error("failed to evaluate achievements", sl.Err(err), slog.Int("user_id", userID))
+			}
+		},
+	)
+}
+
+// Evaluate awards every earned achievement the user does not have yet and returns those.
+func (e *Engine) Evaluate(userID int) ([]models.Achievement, error) {
+	const op = "achievements.Evaluate"
+
+	defs, err := e.store.GetDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	stats, err := e.store.GetStats(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var awarded []models.Achievement
+	for _, a := range achievements.Earned(defs, *stats) {
+		added, err := e.store.Award(userID, a.Code)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if added {
+			awarded = append(awarded, a)
+			e.bus.Publish(events.AchievementUnlocked, userID, a)
+		}
+	}
+	return awarded, nil
+}
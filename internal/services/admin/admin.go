@@ -0,0 +1,337 @@
+package admin
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/roles"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// Handler manages roles and their permissions. The routes are guarded by mwAuth.Require,
+// reading needs users:read and changing needs users:write. Every change is recorded in the audit log.
+type Handler struct {
+	store     roles.RoleStore
+	userStore users.UserStore
+	recorder  *audit.Recorder
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(
+	store roles.RoleStore, userStore users.UserStore, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, userStore: userStore, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleGetPermissions(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleGetPermissions"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetPermissions()
+	if err != nil {
+		log.Error("failed to get permissions", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetRoles(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleGetRoles"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetRoles()
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleCreateRole(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleCreateRole"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateRolePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	role := models.Role{Name: payload.Name, Description: payload.Description, Permissions: payload.Permissions}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	if err := h.store.CreateRole(&role); err != nil {
+		switch {
+		case errors.Is(err, roles.RoleExists):
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, roles.PermissionNotFound):
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			log.Error("failed to create role", sl.Err(err))
+			resp.Internal(w, r)
+		}
+		return
+	}
+
+	h.recorder.Record(r, models.AuditRoleCreated, user.ID, 0, role)
+	log.Info("role created", slog.String("role", role.Name), slog.Any("permissions", role.Permissions))
+	resp.JSON(w, r, http.StatusCreated, role)
+}
+
+// HandleUpdateRole replaces the description and the permissions of a role. Users get the new
+// permissions with their next token.
+func (h *Handler) HandleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleUpdateRole"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.UpdateRolePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	role := models.Role{Name: chi.URLParam(r, "name"), Description: payload.Description, Permissions: payload.Permissions}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	if err := h.store.UpdateRole(&role); err != nil {
+		switch {
+		case errors.Is(err, roles.RoleNotFound):
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, roles.RoleBuiltin):
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, roles.PermissionNotFound):
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			log.Error("failed to update role", sl.Err(err))
+			resp.Internal(w, r)
+		}
+		return
+	}
+
+	h.recorder.Record(r, models.AuditRoleUpdated, user.ID, 0, role)
+	log.Info("role updated", slog.String("role", role.Name), slog.Any("permissions", role.Permissions))
+	resp.JSON(w, r, http.StatusOK, role)
+}
+
+func (h *Handler) HandleDeleteRole(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleDeleteRole"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	name := chi.URLParam(r, "name")
+	if err := h.store.DeleteRole(name); err != nil {
+		switch {
+		case errors.Is(err, roles.RoleNotFound):
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, roles.RoleBuiltin):
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Error("failed to delete role", sl.Err(err))
+			resp.Internal(w, r)
+		}
+		return
+	}
+
+	h.recorder.Record(r, models.AuditRoleDeleted, user.ID, 0, map[string]string{"role": name})
+	log.Info("role deleted", slog.String("role", name))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetUser returns the account of a user with the roles and the permissions they grant.
+func (h *Handler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleGetUser"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	u, ok := h.userParam(w, r, log)
+	if !ok {
+		return
+	}
+
+	access := models.UserAccess{
+		ID: u.ID, Email: u.Email, Username: u.Username, IsActive: u.IsActive, CreatedAt: u.CreatedAt,
+	}
+	var err error
+	if access.Roles, err = h.store.GetUserRoles(u.ID); err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if access.Permissions, err = h.store.GetUserPermissions(u.ID); err != nil {
+		log.Error("failed to get permissions", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, access)
+}
+
+func (h *Handler) HandleGrantRole(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleGrantRole"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	u, ok := h.userParam(w, r, log)
+	if !ok {
+		return
+	}
+
+	role := chi.URLParam(r, "role")
+	if err := h.store.GrantRole(u.ID, role); err != nil {
+		if errors.Is(err, roles.RoleNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to grant role", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditRoleGranted, user.ID, u.ID, map[string]string{"role": role})
+	log.Info("role granted", slog.Int("target_id", u.ID), slog.String("role", role))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleRevokeRole(w http.ResponseWriter, r *http.Request) {
+	const op = "admin.HandleRevokeRole"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	u, ok := h.userParam(w, r, log)
+	if !ok {
+		return
+	}
+
+	role := chi.URLParam(r, "role")
+	if err := h.store.RevokeRole(u.ID, role); err != nil {
+		switch {
+		case errors.Is(err, roles.RoleNotFound):
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, roles.LastAdmin):
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Error("failed to revoke role", sl.Err(err))
+			resp.Internal(w, r)
+		}
+		return
+	}
+
+	h.recorder.Record(r, models.AuditRoleRevoked, user.ID, u.ID, map[string]string{"role": role})
+	log.Info("role revoked", slog.Int("target_id", u.ID), slog.String("role", role))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// userParam loads the user of the {id} URL parameter.
+func (h *Handler) userParam(w http.ResponseWriter, r *http.Request, log *slog.Logger) (*models.User, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return nil, false
+	}
+
+	u, err := h.userStore.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return nil, false
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+	return u, true
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Handler lets admins holding audit:read browse the audit log.
+type Handler struct {
+	store audit.AuditStore
+	log   *slog.Logger
+	cfg   config.Config
+}
+
+func NewHandler(store audit.AuditStore, log *slog.Logger) *Handler {
+	return &Handler{store: store, log: log, cfg: config.Envs}
+}
+
+// HandleGetEvents returns the events newest first, filtered by ?actor_id=, ?subject_id=,
+// ?action= and the RFC 3339 ?from= and ?to=. The next page starts at ?before_id=next_before_id.
+func (h *Handler) HandleGetEvents(w http.ResponseWriter, r *http.Request) {
+	const op = "audit.HandleGetEvents"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{Action: query.Get("action")}
+
+	for name, dst := range map[string]**int{"actor_id": &filter.ActorID, "subject_id": &filter.SubjectID} {
+		if str := query.Get(name); str != "" {
+			id, err := strconv.Atoi(str)
+			if err != nil {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+				return
+			}
+			*dst = &id
+		}
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if str := query.Get(name); str != "" {
+			t, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+				return
+			}
+			*dst = &t
+		}
+	}
+	if str := query.Get("before_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || id <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid before_id"})
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	limit := defaultPageSize
+	if str := query.Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxPageSize)
+	}
+
+	list, err := h.store.GetEvents(filter, limit)
+	if err != nil {
+		log.Error("failed to get audit events", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	page := models.AuditPage{Items: list}
+	if len(list) == limit {
+		page.NextBeforeID = &list[len(list)-1].ID
+	}
+	resp.JSON(w, r, http.StatusOK, page)
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+)
+
+// Diff returns the fields that differ between two values of the same struct type, keyed by their
+// db tag. Fields tagged audit:"-" hold secrets and are never part of the result.
+func Diff(before any, after any) map[string]models.FieldChange {
+	b, a := reflect.Indirect(reflect.ValueOf(before)), reflect.Indirect(reflect.ValueOf(after))
+	if b.Kind() != reflect.Struct || b.Type() != a.Type() {
+		return nil
+	}
+
+	changes := map[string]models.FieldChange{}
+	t := b.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("audit") == "-" {
+			continue
+		}
+		name := field.Tag.Get("db")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+
+		from, to := b.Field(i).Interface(), a.Field(i).Interface()
+		if !reflect.DeepEqual(from, to) {
+			changes[name] = models.FieldChange{From: from, To: to}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"testing"
+)
+
+func TestDiffHidesSecrets(t *testing.T) {
+	code := "123456"
+	before := models.User{ID: 7, Username: "ann", Password: []byte("old"), ActivationCode: &code, Weight: 70}
+	after := before
+	after.Password = []byte("new")
+	after.ActivationCode = nil
+	after.Weight = 68
+
+	changes := Diff(&before, after)
+
+	if len(changes) != 1 {
+		t.Fatalf("changes = %v, want the weight only", changes)
+	}
+	if c := changes["weight"]; c.From != 70 || c.To != 68 {
+		t.Errorf("weight change = %v, want 70 to 68", c)
+	}
+	if Diff(before, models.Workout{}) != nil {
+		t.Error("values of different types are compared")
+	}
+}
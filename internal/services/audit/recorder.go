@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// Recorder appends security-relevant events to the audit log together with the request that
+// caused them. A failure to record is logged but never fails the request.
+type Recorder struct {
+	store audit.AuditStore
+	log   *slog.Logger
+}
+
+func NewRecorder(store audit.AuditStore, log *slog.Logger) *Recorder {
+	return &Recorder{store: store, log: log.With(slog.String("component", "audit/recorder"))}
+}
+
+// Record appends an event. actorID and subjectID are 0 when unknown; details is marshalled to
+// JSON and may be nil.
+func (rec *Recorder) Record(r *http.Request, action string, actorID int, subjectID int, details any) {
+	log := rec.log.With(
+		slog.String("action", action),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	event := models.AuditEvent{
+		Action:    action,
+		ActorID:   optionalID(actorID),
+		SubjectID: optionalID(subjectID),
+		RequestID: middleware.GetReqID(r.Context()),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			log.Error("failed to marshal details", sl.Err(err))
+		}
+		event.Details = data
+	}
+
+	if err := rec.store.Append(&event); err != nil {
+		log.Error("failed to record audit event", sl.Err(err))
+	}
+}
+
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// clientIP is the host part of RemoteAddr, the address mwLogger logs as remote_addr.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
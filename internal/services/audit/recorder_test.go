@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeStore struct {
+	audit.AuditStore
+	events []models.AuditEvent
+	err    error
+}
+
+func (s *fakeStore) Append(event *models.AuditEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// record runs Record inside the request ID middleware, like the handlers of the API do.
+func record(rec *Recorder, r *http.Request, action string, actorID int, subjectID int, details any) {
+	h := middleware.RequestID(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				rec.Record(r, action, actorID, subjectID, details)
+			},
+		),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestRecord(t *testing.T) {
+	store := &fakeStore{}
+	rec := NewRecorder(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/users/9/roles/admin", nil)
+	r.RemoteAddr = "203.0.113.7:52114"
+	r.Header.Set("User-Agent", "curl/8.5.0")
+	r.Header.Set("X-Request-Id", "req-1")
+
+	record(rec, r, models.AuditRoleGranted, 1, 9, map[string]string{"role": "admin"})
+	record(rec, r, models.AuditLoginFailed, 0, 0, nil)
+
+	if len(store.events) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(store.events))
+	}
+	e := store.events[0]
+	if e.Action != models.AuditRoleGranted || e.ActorID == nil || *e.ActorID != 1 || e.SubjectID == nil ||
+		*e.SubjectID != 9 {
+		t.Errorf("event = %+v, want role granted by 1 to 9", e)
+	}
+	if e.RequestID != "req-1" || e.IP != "203.0.113.7" || e.UserAgent != "curl/8.5.0" {
+		t.Errorf("request = %q %q %q, want req-1 from 203.0.113.7 with curl", e.RequestID, e.IP, e.UserAgent)
+	}
+	if string(e.Details) != `{"role":"admin"}` {
+		t.Errorf("details = %s", e.Details)
+	}
+	if e := store.events[1]; e.ActorID != nil || e.SubjectID != nil || e.Details != nil {
+		t.Errorf("event without actor = %+v, want no actor, subject or details", e)
+	}
+}
+
+func TestRecordFailureDoesNotFailRequest(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	rec := NewRecorder(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	h := middleware.RequestID(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				rec.Record(r, models.AuditLoginFailed, 0, 0, nil)
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want the handler's 204", w.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrUserNotFound  = errors.New("user not found")
+)
+
+// GetAuthenticatedUser resolves the user from the "Authorization: Bearer <token>" header. Tokens
+// issued to OAuth clients are refused.
+func GetAuthenticatedUser(r *http.Request, store users.UserStore) (*models.User, error) {
+	u, claims, err := Authenticate(r, store)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID != "" {
+		return nil, ErrInvalidToken
+	}
+	return u, nil
+}
+
+// Authenticate resolves the user and the claims of the token from the "Authorization: Bearer <token>" header.
+func Authenticate(r *http.Request, store users.UserStore) (*models.User, *jwt.Claims, error) {
+	const op = "auth.Authenticate"
+
+	tokenString := TokenFromRequest(r)
+	if tokenString == "" {
+		return nil, nil, ErrTokenNotFound
+	}
+
+	claims, err := jwt.ParseToken(tokenString, config.Envs.JwtCfg.Secret)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	u, err := store.GetUserByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u, claims, nil
+}
+
+// TokenUsage records the use of personal access tokens. It is called on every request, so it
+// must not query the database.
+type TokenUsage interface {
+	Touch(id int)
+}
+
+// AuthenticatePersonal resolves the user of a personal access token and records its use. The
+// claims carry the scopes of the token and no permissions.
+func AuthenticatePersonal(
+	r *http.Request, store users.UserStore, tokenStore tokens.TokenStore, usage TokenUsage,
+) (*models.User, *jwt.Claims, error) {
+	const op = "auth.AuthenticatePersonal"
+
+	tokenString := TokenFromRequest(r)
+	if tokenString == "" {
+		return nil, nil, ErrTokenNotFound
+	}
+
+	t, err := tokenStore.GetTokenBySecret(tokenString)
+	if err != nil {
+		if errors.Is(err, tokens.TokenNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u, err := store.GetUserByID(t.UserID)
+	if err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	usage.Touch(t.ID)
+
+	return u, &jwt.Claims{UserID: u.ID, Email: u.Email, Scopes: t.Scopes}, nil
+}
+
+func TokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,352 @@
+package body
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/bodycomp"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultBodyDays = 90
+	maxBodyDays     = 3660
+)
+
+// allTimeFrom bounds the weight log lookups made to find the weight at a given time.
+var allTimeFrom = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type Handler struct {
+	store     body.BodyStore
+	userStore users.UserStore
+	storage   blob.Storage
+	bus       *events.Bus
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(
+	store body.BodyStore, userStore users.UserStore, storage blob.Storage, bus *events.Bus, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, userStore: userStore, storage: storage, bus: bus, log: log, cfg: config.Envs}
+}
+
+// HandleCreateWeightEntry logs a weight. The latest entry also becomes the profile weight
+// used by the energy and meal plan computations.
+func (h *Handler) HandleCreateWeightEntry(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleCreateWeightEntry"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateWeightEntryPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	entry, err := h.store.CreateWeightEntry(user.ID, payload)
+	if err != nil {
+		log.Error("failed to save weight entry", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.syncProfileWeight(user); err != nil {
+		log.Error("failed to update profile weight", sl.Err(err))
+	}
+	h.bus.Publish(events.WeightLogged, user.ID, *entry)
+
+	resp.JSON(w, r, http.StatusCreated, entry)
+}
+
+func (h *Handler) HandleGetWeightEntries(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleGetWeightEntries"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultBodyDays, maxBodyDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	list, err := h.store.GetWeightEntries(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get weight entries", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleDeleteWeightEntry(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleDeleteWeightEntry"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid weight entry id"})
+		return
+	}
+
+	if err := h.store.DeleteWeightEntry(user.ID, id); err != nil {
+		if errors.Is(err, body.WeightEntryNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete weight entry", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.syncProfileWeight(user); err != nil {
+		log.Error("failed to update profile weight", sl.Err(err))
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// syncProfileWeight copies the latest logged weight to the profile, which stores whole kilograms.
+func (h *Handler) syncProfileWeight(user *models.User) error {
+	latest, err := h.store.GetLatestWeightEntry(user.ID)
+	if errors.Is(err, body.WeightEntryNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	weight := int(math.Round(latest.Weight))
+	if weight == user.Weight {
+		return nil
+	}
+	user.Weight = weight
+	return h.userStore.UpdateUser(user.ID, *user)
+}
+
+func (h *Handler) HandleCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleCreateMeasurement"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateBodyMeasurementPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	m, err := h.store.CreateMeasurement(user.ID, payload)
+	if err != nil {
+		log.Error("failed to save measurement", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	list := []models.BodyMeasurement{*m}
+	if err := h.withIndicators(user, list); err != nil {
+		log.Error("failed to compute indicators", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusCreated, list[0])
+}
+
+func (h *Handler) HandleGetMeasurements(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleGetMeasurements"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultBodyDays, maxBodyDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	list, err := h.store.GetMeasurements(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get measurements", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if err := h.withIndicators(user, list); err != nil {
+		log.Error("failed to compute indicators", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleDeleteMeasurement"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid measurement id"})
+		return
+	}
+
+	if err := h.store.DeleteMeasurement(user.ID, id); err != nil {
+		if errors.Is(err, body.MeasurementNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete measurement", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleGetSummary(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleGetSummary"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	summary := models.BodySummary{Weight: float64(user.Weight), Height: user.Height}
+
+	latest, err := h.store.GetLatestWeightEntry(user.ID)
+	if err != nil && !errors.Is(err, body.WeightEntryNotFound) {
+		log.Error("failed to get weight entry", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if latest != nil {
+		summary.Weight = latest.Weight
+		summary.WeightMeasuredAt = &latest.MeasuredAt
+	}
+	summary.BMI = bodycomp.BMI(user.Height, summary.Weight)
+
+	m, err := h.store.GetLatestMeasurement(user.ID)
+	if err != nil && !errors.Is(err, body.MeasurementNotFound) {
+		log.Error("failed to get measurement", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if m != nil {
+		list := []models.BodyMeasurement{*m}
+		if err := h.withIndicators(user, list); err != nil {
+			log.Error("failed to compute indicators", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		summary.LatestMeasurement = &list[0]
+	}
+
+	resp.JSON(w, r, http.StatusOK, summary)
+}
+
+// withIndicators computes the indicators of every measurement with the last weight logged
+// before it, or the profile weight when there is none.
+func (h *Handler) withIndicators(user *models.User, list []models.BodyMeasurement) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	entries, err := h.store.GetWeightEntries(user.ID, allTimeFrom, list[len(list)-1].MeasuredAt.Add(time.Second))
+	if err != nil {
+		return err
+	}
+
+	for i := range list {
+		weight := float64(user.Weight)
+		for _, e := range entries {
+			if e.MeasuredAt.After(list[i].MeasuredAt) {
+				break
+			}
+			weight = e.Weight
+		}
+		indicators := bodycomp.Indicators(user.Height, weight, list[i])
+		list[i].Indicators = &indicators
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package body
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/images"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/signedurl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const maxPhotoSize = 15 << 20
+
+const (
+	variantFull  = "full"
+	variantThumb = "thumb"
+)
+
+// HandleUploadPhoto accepts a multipart upload with a JPEG or PNG in the "photo" field and optional
+// "taken_at" (RFC 3339) and "pose" fields. Only the re-encoded image is stored, never the original bytes.
+func (h *Handler) HandleUploadPhoto(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleUploadPhoto"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize)
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		log.Warn("failed to read uploaded photo", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "field photo is required"})
+		return
+	}
+	defer file.Close()
+
+	takenAt := time.Now()
+	if str := r.FormValue("taken_at"); str != "" {
+		if takenAt, err = time.Parse(time.RFC3339, str); err != nil {
+			resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "invalid taken_at"})
+			return
+		}
+	}
+	pose := r.FormValue("pose")
+	if pose == "" {
+		pose = models.PoseFront
+	}
+	if !slices.Contains([]string{models.PoseFront, models.PoseSide, models.PoseBack}, pose) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "pose must be front, side or back"})
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Warn("failed to read uploaded photo", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to read photo"})
+		return
+	}
+
+	photo, thumb, err := images.Process(data)
+	if err != nil {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		log.Error("failed to generate photo key", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	ext := "jpg"
+	if photo.ContentType == "image/png" {
+		ext = "png"
+	}
+	base := fmt.Sprintf("photos/%d/%s", user.ID, hex.EncodeToString(name))
+
+	p := models.ProgressPhoto{
+		UserID:      user.ID,
+		TakenAt:     takenAt,
+		Pose:        pose,
+		BlobKey:     base + "." + ext,
+		ThumbKey:    base + "_thumb." + ext,
+		ContentType: photo.ContentType,
+		Width:       photo.Width,
+		Height:      photo.Height,
+		Size:        len(photo.Data),
+	}
+
+	if err := h.storage.Put(r.Context(), p.BlobKey, photo.Data, photo.ContentType); err != nil {
+		log.Error("failed to store photo", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if err := h.storage.Put(r.Context(), p.ThumbKey, thumb.Data, thumb.ContentType); err != nil {
+		log.Error("failed to store thumbnail", sl.Err(err))
+		h.deleteBlobs(r, log, p)
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.CreatePhoto(&p); err != nil {
+		log.Error("failed to save photo", sl.Err(err))
+		h.deleteBlobs(r, log, p)
+		resp.Internal(w, r)
+		return
+	}
+
+	h.sign(&p)
+	log.Info("progress photo uploaded", slog.Int("photo_id", p.ID), slog.Int("size", p.Size))
+	resp.JSON(w, r, http.StatusCreated, p)
+}
+
+func (h *Handler) HandleGetPhotos(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleGetPhotos"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultBodyDays, maxBodyDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	list, err := h.store.GetPhotos(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get photos", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	for i := range list {
+		h.sign(&list[i])
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleDeletePhoto(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleDeletePhoto"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid photo id"})
+		return
+	}
+
+	p, err := h.store.DeletePhoto(user.ID, id)
+	if err != nil {
+		if errors.Is(err, body.PhotoNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete photo", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	h.deleteBlobs(r, log, *p)
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleServePhoto is public: the signature and expiry in the query string, issued by the
+// authenticated listing, are what grant access to the file.
+func (h *Handler) HandleServePhoto(w http.ResponseWriter, r *http.Request) {
+	const op = "body.HandleServePhoto"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	if err := signedurl.Verify(h.cfg.JwtCfg.Secret, r.URL, time.Now()); err != nil {
+		resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": body.PhotoNotFound.Error()})
+		return
+	}
+
+	p, err := h.store.GetPhoto(id)
+	if err != nil {
+		if errors.Is(err, body.PhotoNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get photo", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	key := p.BlobKey
+	if chi.URLParam(r, "variant") == variantThumb {
+		key = p.ThumbKey
+	}
+
+	f, err := h.storage.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": body.PhotoNotFound.Error()})
+			return
+		}
+		log.Error("failed to read photo", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", p.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(h.cfg.Storage.URLTTL.Seconds())))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Warn("failed to send photo", sl.Err(err))
+	}
+}
+
+// sign sets the expiring links to the photo and its thumbnail.
+func (h *Handler) sign(p *models.ProgressPhoto) {
+	expires := time.Now().Add(h.cfg.Storage.URLTTL).Truncate(time.Second)
+	path := "/api/photos/" + strconv.Itoa(p.ID) + "/"
+	p.URL = h.cfg.AppURL + signedurl.Sign(h.cfg.JwtCfg.Secret, path+variantFull, expires)
+	p.ThumbURL = h.cfg.AppURL + signedurl.Sign(h.cfg.JwtCfg.Secret, path+variantThumb, expires)
+	p.URLExpiresAt = &expires
+}
+
+func (h *Handler) deleteBlobs(r *http.Request, log *slog.Logger, p models.ProgressPhoto) {
+	for _, key := range []string{p.BlobKey, p.ThumbKey} {
+		if err := h.storage.Delete(r.Context(), key); err != nil {
+			log.Warn("failed to delete blob", slog.String("key", key), sl.Err(err))
+		}
+	}
+}
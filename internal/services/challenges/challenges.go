@@ -0,0 +1,341 @@
+package challenges
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	maxChallengeDays        = 366
+	defaultLeaderboardLimit = 50
+	maxLeaderboardLimit     = 500
+)
+
+type Handler struct {
+	store challenges.ChallengeStore
+	log   *slog.Logger
+	cfg   config.Config
+}
+
+func NewHandler(store challenges.ChallengeStore, log *slog.Logger) *Handler {
+	return &Handler{store: store, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleCreateChallenge(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleCreateChallenge"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateChallengePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	startsOn, _ := time.Parse(daterange.Layout, payload.StartsOn)
+	endsOn, _ := time.Parse(daterange.Layout, payload.EndsOn)
+	days := daterange.Range{From: startsOn, To: endsOn.AddDate(0, 0, 1)}.Len()
+	if days < 1 || days > maxChallengeDays {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid challenge period"})
+		return
+	}
+	if payload.EndsOn < time.Now().UTC().Format(daterange.Layout) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "challenge ends in the past"})
+		return
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("failed to generate invite code", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	c := models.Challenge{
+		OwnerID:     user.ID,
+		Name:        payload.Name,
+		Description: payload.Description,
+		Metric:      payload.Metric,
+		StartsOn:    payload.StartsOn,
+		EndsOn:      payload.EndsOn,
+		Visibility:  payload.Visibility,
+		InviteCode:  hex.EncodeToString(b),
+	}
+	if err := h.store.CreateChallenge(&c); err != nil {
+		log.Error("failed to create challenge", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("challenge created", slog.Int("challenge_id", c.ID), slog.String("metric", c.Metric))
+	resp.JSON(w, r, http.StatusCreated, c)
+}
+
+// HandleGetChallenges lists the challenges the user joined and the running public ones,
+// or only the joined ones with ?scope=mine.
+func (h *Handler) HandleGetChallenges(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleGetChallenges"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	scope := r.URL.Query().Get("scope")
+	if scope != "" && scope != "mine" {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid scope"})
+		return
+	}
+
+	today := time.Now().UTC().Format(daterange.Layout)
+	list, err := h.store.GetChallenges(user.ID, scope == "mine", today)
+	if err != nil {
+		log.Error("failed to get challenges", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	for i := range list {
+		hideInviteCode(&list[i], user.ID)
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetChallenge(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleGetChallenge"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	c, ok := h.visibleChallenge(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+	hideInviteCode(c, user.ID)
+
+	resp.JSON(w, r, http.StatusOK, c)
+}
+
+// HandleJoinChallenge adds the user to a challenge that has not ended yet. Invite-only challenges
+// need the invite code.
+func (h *Handler) HandleJoinChallenge(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleJoinChallenge"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid challenge id"})
+		return
+	}
+
+	var payload models.JoinChallengePayload
+	err = render.DecodeJSON(r.Body, &payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	c, err := h.store.GetChallenge(user.ID, id)
+	if err != nil {
+		if errors.Is(err, challenges.ChallengeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get challenge", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if c.Joined {
+		resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+		return
+	}
+	if c.Visibility == models.ChallengeInviteOnly &&
+		subtle.ConstantTimeCompare([]byte(payload.InviteCode), []byte(c.InviteCode)) != 1 {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": challenges.ChallengeNotFound.Error()})
+		return
+	}
+	if c.EndsOn < time.Now().UTC().Format(daterange.Layout) {
+		resp.JSON(w, r, http.StatusConflict, map[string]string{"error": "challenge has ended"})
+		return
+	}
+
+	if err := h.store.Join(c.ID, user.ID); err != nil {
+		if errors.Is(err, challenges.ChallengeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to join challenge", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("challenge joined", slog.Int("challenge_id", c.ID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleLeaveChallenge removes the user and their score. The owner cannot leave their challenge.
+func (h *Handler) HandleLeaveChallenge(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleLeaveChallenge"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	c, ok := h.visibleChallenge(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+	if c.OwnerID == user.ID {
+		resp.JSON(w, r, http.StatusConflict, map[string]string{"error": "the owner cannot leave the challenge"})
+		return
+	}
+
+	if err := h.store.Leave(c.ID, user.ID); err != nil {
+		if errors.Is(err, challenges.NotParticipant) {
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to leave challenge", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("challenge left", slog.Int("challenge_id", c.ID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetLeaderboard returns the live standings, or the snapshot taken at the end of ?date=.
+func (h *Handler) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	const op = "challenges.HandleGetLeaderboard"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	c, ok := h.visibleChallenge(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+
+	limit := defaultLeaderboardLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxLeaderboardLimit)
+	}
+
+	board := models.Leaderboard{ChallengeID: c.ID}
+	var err error
+	if date := r.URL.Query().Get("date"); date != "" {
+		if _, err := time.Parse(daterange.Layout, date); err != nil {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid date"})
+			return
+		}
+		board.Date = date
+		board.Standings, err = h.store.GetSnapshot(c.ID, date, limit)
+	} else {
+		board.Standings, err = h.store.GetStandings(c.ID, limit)
+	}
+	if err != nil {
+		log.Error("failed to get standings", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, board)
+}
+
+// visibleChallenge loads the challenge of the {id} URL parameter. Invite-only challenges are only
+// visible to their participants, others get a not found like for a missing challenge.
+func (h *Handler) visibleChallenge(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, userID int,
+) (*models.Challenge, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid challenge id"})
+		return nil, false
+	}
+
+	c, err := h.store.GetChallenge(userID, id)
+	if err != nil {
+		if errors.Is(err, challenges.ChallengeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return nil, false
+		}
+		log.Error("failed to get challenge", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+	if c.Visibility == models.ChallengeInviteOnly && !c.Joined && c.OwnerID != userID {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": challenges.ChallengeNotFound.Error()})
+		return nil, false
+	}
+	return c, true
+}
+
+func hideInviteCode(c *models.Challenge, userID int) {
+	if c.OwnerID != userID {
+		c.InviteCode = ""
+	}
+}
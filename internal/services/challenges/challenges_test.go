@@ -0,0 +1,182 @@
+package challenges
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-secret"
+	testUserID = 7
+	inviteCode = "0123456789abcdef"
+)
+
+func TestMain(m *testing.M) {
+	config.Envs.JwtCfg.Secret = testSecret
+	os.Exit(m.Run())
+}
+
+// challengeStore holds one challenge and who joined it.
+type challengeStore struct {
+	challenges.ChallengeStore
+	challenge models.Challenge
+	joined    map[int]bool
+}
+
+func (s *challengeStore) GetChallenge(userID int, id int) (*models.Challenge, error) {
+	if id != s.challenge.ID {
+		return nil, challenges.ChallengeNotFound
+	}
+	c := s.challenge
+	c.Joined = s.joined[userID]
+	return &c, nil
+}
+
+func (s *challengeStore) Join(_ int, userID int) error {
+	s.joined[userID] = true
+	return nil
+}
+
+func (s *challengeStore) Leave(_ int, userID int) error {
+	if !s.joined[userID] {
+		return challenges.NotParticipant
+	}
+	delete(s.joined, userID)
+	return nil
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) Revoked(int64) bool { return false }
+
+func (fakeSessions) Touch(int64) {}
+
+func newChallengeStore(visibility string, endsOn string) *challengeStore {
+	return &challengeStore{
+		challenge: models.Challenge{
+			ID: 1, OwnerID: 1, Metric: models.ChallengeWorkouts, StartsOn: "2024-09-01", EndsOn: endsOn,
+			Visibility: visibility, InviteCode: inviteCode,
+		},
+		joined: map[int]bool{1: true},
+	}
+}
+
+// send makes the request as testUserID, through the routes of the API.
+func send(t *testing.T, store *challengeStore, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := jwt.NewToken(models.User{ID: testUserID}, 1, nil, time.Minute, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewHandler(store, log)
+
+	router := chi.NewRouter()
+	router.Use(mwAuth.New(fakeUsers{}, fakeSessions{}, log))
+	router.Get("/api/challenges/{id}", h.HandleGetChallenge)
+	router.Post("/api/challenges/{id}/join", h.HandleJoinChallenge)
+	router.Post("/api/challenges/{id}/leave", h.HandleLeaveChallenge)
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestJoinChallenge(t *testing.T) {
+	running := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	tests := []struct {
+		name       string
+		visibility string
+		endsOn     string
+		body       string
+		wantStatus int
+	}{
+		{name: "public", visibility: models.ChallengePublic, endsOn: running, wantStatus: http.StatusOK},
+		{
+			name: "invite only with the code", visibility: models.ChallengeInviteOnly, endsOn: running,
+			body: `{"invite_code":"` + inviteCode + `"}`, wantStatus: http.StatusOK,
+		},
+		{
+			name: "invite only without a code", visibility: models.ChallengeInviteOnly, endsOn: running,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "invite only with another code", visibility: models.ChallengeInviteOnly, endsOn: running,
+			body: `{"invite_code":"fedcba9876543210"}`, wantStatus: http.StatusNotFound,
+		},
+		{name: "ended", visibility: models.ChallengePublic, endsOn: "2024-09-30", wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := newChallengeStore(tt.visibility, tt.endsOn)
+
+				w := send(t, store, http.MethodPost, "/api/challenges/1/join", tt.body)
+
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+				}
+				if joined := store.joined[testUserID]; joined != (tt.wantStatus == http.StatusOK) {
+					t.Errorf("joined = %v for status %d", joined, w.Code)
+				}
+			},
+		)
+	}
+}
+
+func TestInviteOnlyChallengeVisibility(t *testing.T) {
+	store := newChallengeStore(models.ChallengeInviteOnly, "2024-09-30")
+
+	if w := send(t, store, http.MethodGet, "/api/challenges/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status for a non participant = %d, want 404", w.Code)
+	}
+
+	store.joined[testUserID] = true
+	w := send(t, store, http.MethodGet, "/api/challenges/1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status for a participant = %d, want 200", w.Code)
+	}
+	var c models.Challenge
+	if err := json.NewDecoder(w.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.InviteCode != "" {
+		t.Error("invite code is shown to a participant who does not own the challenge")
+	}
+}
+
+func TestOwnerCannotLeave(t *testing.T) {
+	store := newChallengeStore(models.ChallengePublic, "2024-09-30")
+	store.challenge.OwnerID = testUserID
+	store.joined[testUserID] = true
+
+	if w := send(t, store, http.MethodPost, "/api/challenges/1/leave", ""); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", w.Code)
+	}
+	if !store.joined[testUserID] {
+		t.Error("owner left the challenge")
+	}
+}
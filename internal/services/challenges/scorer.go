@@ -0,0 +1,114 @@
+package challenges
+
+import (
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	"log/slog"
+)
+
+// Scorer adds what users log to the scores of the challenges they take part in. Every workout
+// and every day of steps is a contribution of its own, so a score moves by the difference a
+// single contribution makes instead of being summed up again, and is taken back when the
+// workout is deleted.
+type Scorer struct {
+	store challenges.ChallengeStore
+	log   *slog.Logger
+}
+
+func NewScorer(store challenges.ChallengeStore, log *slog.Logger) *Scorer {
+	return &Scorer{store: store, log: log.With(slog.String("component", "challenges/scorer"))}
+}
+
+func (s *Scorer) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.WorkoutCreated, s.handleWorkout)
+	bus.Subscribe(events.WorkoutUpdated, s.handleWorkout)
+	bus.Subscribe(events.WorkoutDeleted, s.handleWorkoutDeleted)
+	bus.Subscribe(events.MetricsLogged, s.handleMetrics)
+}
+
+func (s *Scorer) handleWorkout(e events.Event) {
+	workout, ok := e.Payload.(models.Workout)
+	if !ok {
+		return
+	}
+	log := s.log.With(slog.Int("user_id", e.UserID), slog.Int("workout_id", workout.ID))
+
+	day := workout.StartedAt.UTC().Format(daterange.Layout)
+	list, err := s.store.GetJoinedChallenges(
+		e.UserID, []string{models.ChallengeDistance, models.ChallengeWorkouts}, day,
+	)
+	if err != nil {
+		log.Error("failed to get challenges", sl.Err(err))
+		return
+	}
+
+	source := workoutSource(workout)
+	var contributed []int
+	for _, c := range list {
+		value := 1.0
+		if c.Metric == models.ChallengeDistance {
+			if workout.Distance <= 0 {
+				continue
+			}
+			value = float64(workout.Distance)
+		}
+		contributed = append(contributed, c.ID)
+		if err := s.store.AddContribution(c.ID, e.UserID, source, value); err != nil {
+			log.Error("failed to add contribution", slog.Int("challenge_id", c.ID), sl.Err(err))
+		}
+	}
+
+	// A changed workout may have left challenges, by its day or by its distance.
+	if e.Type == events.WorkoutUpdated {
+		if err := s.store.RemoveContributions(e.UserID, source, contributed); err != nil {
+			log.Error("failed to remove contributions", sl.Err(err))
+		}
+	}
+}
+
+func (s *Scorer) handleWorkoutDeleted(e events.Event) {
+	workout, ok := e.Payload.(models.Workout)
+	if !ok {
+		return
+	}
+
+	if err := s.store.RemoveContributions(e.UserID, workoutSource(workout), nil); err != nil {
+		s.log.Error(
+			"failed to remove contributions", sl.Err(err), slog.Int("user_id", e.UserID),
+			slog.Int("workout_id", workout.ID),
+		)
+	}
+}
+
+// workoutSource is the source of the contributions of a workout, as the backfills name it too.
+func workoutSource(workout models.Workout) string {
+	return fmt.Sprintf("workout:%d", workout.ID)
+}
+
+func (s *Scorer) handleMetrics(e events.Event) {
+	metrics, ok := e.Payload.([]models.DailyMetric)
+	if !ok {
+		return
+	}
+	log := s.log.With(slog.Int("user_id", e.UserID))
+
+	for _, m := range metrics {
+		if m.Metric != models.MetricSteps {
+			continue
+		}
+		list, err := s.store.GetJoinedChallenges(e.UserID, []string{models.ChallengeSteps}, m.Date)
+		if err != nil {
+			log.Error("failed to get challenges", sl.Err(err))
+			return
+		}
+		for _, c := range list {
+			if err := s.store.AddContribution(c.ID, e.UserID, "steps:"+m.Date, m.Value); err != nil {
+				log.Error("failed to add contribution", slog.Int("challenge_id", c.ID), sl.Err(err))
+			}
+		}
+	}
+}
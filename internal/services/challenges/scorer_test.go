@@ -0,0 +1,155 @@
+package challenges
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeStore holds the challenges one user joined and their contributions by source, which the
+// Postgres store sums up into the score.
+type fakeStore struct {
+	challenges.ChallengeStore
+	joined        []models.Challenge
+	contributions map[int]map[string]float64
+}
+
+func newFakeStore(joined ...models.Challenge) *fakeStore {
+	s := &fakeStore{joined: joined, contributions: map[int]map[string]float64{}}
+	for _, c := range joined {
+		s.contributions[c.ID] = map[string]float64{}
+	}
+	return s
+}
+
+func (s *fakeStore) GetJoinedChallenges(_ int, metrics []string, day string) ([]models.Challenge, error) {
+	var list []models.Challenge
+	for _, c := range s.joined {
+		if slices.Contains(metrics, c.Metric) && c.StartsOn <= day && day <= c.EndsOn {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeStore) AddContribution(challengeID int, _ int, source string, value float64) error {
+	s.contributions[challengeID][source] = value
+	return nil
+}
+
+func (s *fakeStore) RemoveContributions(_ int, source string, keep []int) error {
+	for id, c := range s.contributions {
+		if !slices.Contains(keep, id) {
+			delete(c, source)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) score(challengeID int) float64 {
+	var sum float64
+	for _, v := range s.contributions[challengeID] {
+		sum += v
+	}
+	return sum
+}
+
+func newTestScorer(store *fakeStore) *Scorer {
+	return NewScorer(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestScorerWorkout(t *testing.T) {
+	distance := models.Challenge{ID: 1, Metric: models.ChallengeDistance, StartsOn: "2024-09-01", EndsOn: "2024-09-30"}
+	workouts := models.Challenge{ID: 2, Metric: models.ChallengeWorkouts, StartsOn: "2024-09-01", EndsOn: "2024-09-30"}
+	store := newFakeStore(distance, workouts)
+	s := newTestScorer(store)
+	workout := models.Workout{ID: 10, StartedAt: time.Date(2024, 9, 10, 7, 0, 0, 0, time.UTC), Distance: 5000}
+
+	steps := []struct {
+		name         string
+		event        string
+		distance     int
+		startedAt    time.Time
+		wantDistance float64
+		wantWorkouts float64
+	}{
+		{name: "created", event: events.WorkoutCreated, distance: 5000, wantDistance: 5000, wantWorkouts: 1},
+		{name: "created again", event: events.WorkoutCreated, distance: 5000, wantDistance: 5000, wantWorkouts: 1},
+		{name: "longer", event: events.WorkoutUpdated, distance: 8000, wantDistance: 8000, wantWorkouts: 1},
+		{name: "no distance", event: events.WorkoutUpdated, distance: 0, wantDistance: 0, wantWorkouts: 1},
+		{name: "distance again", event: events.WorkoutUpdated, distance: 3000, wantDistance: 3000, wantWorkouts: 1},
+		{
+			name:      "moved after the challenges",
+			event:     events.WorkoutUpdated,
+			distance:  3000,
+			startedAt: time.Date(2024, 10, 1, 7, 0, 0, 0, time.UTC),
+		},
+		{name: "moved back", event: events.WorkoutUpdated, distance: 3000, wantDistance: 3000, wantWorkouts: 1},
+		{name: "deleted", event: events.WorkoutDeleted, distance: 3000},
+	}
+
+	// The steps run in order, each one on the state the previous one left.
+	for _, step := range steps {
+		w := workout
+		w.Distance = step.distance
+		if !step.startedAt.IsZero() {
+			w.StartedAt = step.startedAt
+		}
+		e := events.Event{Type: step.event, UserID: 7, Payload: w}
+		if step.event == events.WorkoutDeleted {
+			s.handleWorkoutDeleted(e)
+		} else {
+			s.handleWorkout(e)
+		}
+
+		if got := store.score(distance.ID); got != step.wantDistance {
+			t.Errorf("%s: distance score = %v, want %v", step.name, got, step.wantDistance)
+		}
+		if got := store.score(workouts.ID); got != step.wantWorkouts {
+			t.Errorf("%s: workouts score = %v, want %v", step.name, got, step.wantWorkouts)
+		}
+	}
+}
+
+func TestScorerWorkoutDayInUTC(t *testing.T) {
+	c := models.Challenge{ID: 1, Metric: models.ChallengeWorkouts, StartsOn: "2024-09-01", EndsOn: "2024-09-30"}
+	store := newFakeStore(c)
+
+	// 01:00 on 1 October at UTC+3 is still 30 September in UTC.
+	zone := time.FixedZone("EEST", 3*60*60)
+	workout := models.Workout{ID: 10, StartedAt: time.Date(2024, 10, 1, 1, 0, 0, 0, zone)}
+	newTestScorer(store).handleWorkout(events.Event{Type: events.WorkoutCreated, UserID: 7, Payload: workout})
+
+	if got := store.score(c.ID); got != 1 {
+		t.Errorf("score = %v, want 1", got)
+	}
+}
+
+func TestScorerSteps(t *testing.T) {
+	c := models.Challenge{ID: 1, Metric: models.ChallengeSteps, StartsOn: "2024-09-01", EndsOn: "2024-09-30"}
+	store := newFakeStore(c)
+	s := newTestScorer(store)
+
+	logged := [][]models.DailyMetric{
+		{{Date: "2024-09-10", Metric: models.MetricSteps, Value: 6000}},
+		{
+			{Date: "2024-09-10", Metric: models.MetricSteps, Value: 9000},
+			{Date: "2024-09-11", Metric: models.MetricSteps, Value: 4000},
+			{Date: "2024-09-11", Metric: models.MetricWater, Value: 1500},
+		},
+		{{Date: "2024-10-01", Metric: models.MetricSteps, Value: 12000}},
+	}
+	for _, metrics := range logged {
+		s.handleMetrics(events.Event{Type: events.MetricsLogged, UserID: 7, Payload: metrics})
+	}
+
+	// The second sync of 10 September replaces the first, 1 October is after the challenge.
+	if got := store.score(c.ID); got != 13000 {
+		t.Errorf("score = %v, want 13000", got)
+	}
+}
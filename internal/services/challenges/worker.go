@@ -0,0 +1,51 @@
+package challenges
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/challenges"
+	"log/slog"
+	"time"
+)
+
+const pollInterval = time.Hour
+
+// Worker records the standings of every running challenge as they were at the end of each UTC day.
+// Snapshots are only written once per challenge and day, so polling every hour is safe.
+type Worker struct {
+	store challenges.ChallengeStore
+	log   *slog.Logger
+}
+
+func NewWorker(store challenges.ChallengeStore, log *slog.Logger) *Worker {
+	return &Worker{store: store, log: log.With(slog.String("component", "challenges/worker"))}
+}
+
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wk.snapshot()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (wk *Worker) snapshot() {
+	day := time.Now().UTC().AddDate(0, 0, -1).Format(daterange.Layout)
+
+	n, err := wk.store.SaveSnapshots(day)
+	if err != nil {
+		wk.log.Error("failed to save snapshots", slog.String("day", day), sl.Err(err))
+		return
+	}
+	if n > 0 {
+		wk.log.Info("snapshots saved", slog.String("day", day), slog.Int64("standings", n))
+	}
+}
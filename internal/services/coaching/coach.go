@@ -0,0 +1,550 @@
+package coaching
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultSummaryDays = 7
+	maxSummaryDays     = 90
+	maxDiaryDays       = 31
+)
+
+// HandleInviteClient asks a user, found by email, to become a client with the given scopes.
+func (h *Handler) HandleInviteClient(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleInviteClient"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.InviteClientPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	client, err := h.userStore.GetUserByEmail(payload.Email)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if client == nil || !client.IsActive {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	if client.ID == user.ID {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "cannot coach yourself"})
+		return
+	}
+
+	c, err := h.store.Invite(user.ID, client.ID, payload.Scopes)
+	if err != nil {
+		if errors.Is(err, coaching.CoachingExists) {
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to invite client", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	c.Username = client.Username
+
+	log.Info("client invited", slog.Int("client_id", client.ID))
+	resp.JSON(w, r, http.StatusCreated, c)
+}
+
+func (h *Handler) HandleGetInvitations(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetInvitations"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetInvitations(user.ID)
+	if err != nil {
+		log.Error("failed to get invitations", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleGetClients is the coach dashboard: the active clients with their adherence over the last ?days= days.
+func (h *Handler) HandleGetClients(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetClients"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	days := defaultSummaryDays
+	if str := r.URL.Query().Get("days"); str != "" {
+		d, err := strconv.Atoi(str)
+		if err != nil || d <= 0 || d > maxSummaryDays {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid days"})
+			return
+		}
+		days = d
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	list, err := h.store.GetClients(user.ID, to.AddDate(0, 0, -days), to)
+	if err != nil {
+		log.Error("failed to get clients", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	for i := range list {
+		c := &list[i]
+		c.Days = days
+		if c.AssignmentsDue != nil && c.AssignmentsCompleted != nil && *c.AssignmentsDue > 0 {
+			adherence := math.Round(float64(*c.AssignmentsCompleted)/float64(*c.AssignmentsDue)*10000) / 100
+			c.Adherence = &adherence
+		}
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleEndClient stops coaching a client or withdraws a pending invitation.
+func (h *Handler) HandleEndClient(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleEndClient"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid client id"})
+		return
+	}
+
+	if err := h.store.End(user.ID, clientID); err != nil {
+		if errors.Is(err, coaching.CoachingNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to end coaching", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("coaching ended by coach", slog.Int("client_id", clientID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleGetClientWorkouts(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetClientWorkouts"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, models.CoachScopeWorkouts)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.workoutStore.GetWorkouts(clientID, limit)
+	if err != nil {
+		log.Error("failed to get workouts", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleGetClientDiary returns the diary entries of the client between ?from= and ?to=, today by default.
+func (h *Handler) HandleGetClientDiary(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetClientDiary"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, models.CoachScopeDiary)
+	if !ok {
+		return
+	}
+
+	period, err := daterange.FromQuery(r.URL.Query(), 1, maxDiaryDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.diaryStore.GetEntries(clientID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get diary entries", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, entries)
+}
+
+func (h *Handler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleCreateTemplate"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateTemplatePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	t := models.WorkoutTemplate{CoachID: user.ID, Name: payload.Name, Notes: payload.Notes}
+	for _, p := range payload.Sets {
+		t.Sets = append(
+			t.Sets, models.WorkoutTemplateSet{
+				ExerciseID: p.ExerciseID,
+				Reps:       p.Reps,
+				Weight:     p.Weight,
+				Duration:   p.Duration,
+				Distance:   p.Distance,
+			},
+		)
+	}
+
+	if err := h.store.CreateTemplate(&t); err != nil {
+		if errors.Is(err, coaching.ExerciseNotFound) {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to create template", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("template created", slog.Int("template_id", t.ID))
+	resp.JSON(w, r, http.StatusCreated, t)
+}
+
+func (h *Handler) HandleGetTemplates(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetTemplates"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetTemplates(user.ID)
+	if err != nil {
+		log.Error("failed to get templates", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandlePublishTemplate adds a template of the coach to the program catalog. The route requires
+// the programs:publish permission.
+func (h *Handler) HandlePublishTemplate(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandlePublishTemplate"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid template id"})
+		return
+	}
+
+	if err := h.store.PublishTemplate(user.ID, id); err != nil {
+		if errors.Is(err, coaching.TemplateNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to publish template", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("template published", slog.Int("template_id", id))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleAssignTemplate gives one of the coach's templates to a client who consented to assign_programs.
+func (h *Handler) HandleAssignTemplate(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleAssignTemplate"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, models.CoachScopePrograms)
+	if !ok {
+		return
+	}
+
+	var payload models.AssignTemplatePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	t, err := h.store.GetTemplate(user.ID, payload.TemplateID)
+	if err != nil {
+		if errors.Is(err, coaching.TemplateNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get template", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	a := models.Assignment{TemplateID: t.ID, CoachID: user.ID, ClientID: clientID, Note: payload.Note, Template: t}
+	if payload.DueOn != "" {
+		a.DueOn = &payload.DueOn
+	}
+	if err := h.store.CreateAssignment(&a); err != nil {
+		log.Error("failed to create assignment", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("template assigned", slog.Int("client_id", clientID), slog.Int("assignment_id", a.ID))
+	resp.JSON(w, r, http.StatusCreated, a)
+}
+
+func (h *Handler) HandleGetClientAssignments(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetClientAssignments"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, models.CoachScopePrograms)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetAssignments(clientID, user.ID, limit)
+	if err != nil {
+		log.Error("failed to get assignments", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleCreateNote leaves a note to a client, whatever the scopes granted.
+func (h *Handler) HandleCreateNote(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleCreateNote"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, "")
+	if !ok {
+		return
+	}
+
+	var payload models.CreateCoachNotePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	note := models.CoachNote{CoachID: user.ID, ClientID: clientID, Body: payload.Body}
+	if err := h.store.CreateNote(&note); err != nil {
+		log.Error("failed to create note", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusCreated, note)
+}
+
+func (h *Handler) HandleGetNotes(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetNotes"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	clientID, ok := h.client(w, r, log, user.ID, "")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetNotes(user.ID, clientID, limit)
+	if err != nil {
+		log.Error("failed to get notes", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// client reads the {id} URL parameter of an active client of the coach who granted scope, if any.
+// The consent is read from the store on every call so that a revocation applies at once.
+func (h *Handler) client(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, coachID int, scope string,
+) (int, bool) {
+	clientID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid client id"})
+		return 0, false
+	}
+
+	scopes, err := h.store.GetScopes(coachID, clientID)
+	if err != nil {
+		if errors.Is(err, coaching.CoachingNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "client not found"})
+			return 0, false
+		}
+		log.Error("failed to get consent", sl.Err(err))
+		resp.Internal(w, r)
+		return 0, false
+	}
+	if scope != "" && !slices.Contains(scopes, scope) {
+		resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "client did not consent to " + scope})
+		return 0, false
+	}
+	return clientID, true
+}
@@ -0,0 +1,467 @@
+package coaching
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type Handler struct {
+	store        coaching.CoachingStore
+	userStore    users.UserStore
+	workoutStore workouts.WorkoutStore
+	diaryStore   diary.DiaryStore
+	recorder     *audit.Recorder
+	log          *slog.Logger
+	cfg          config.Config
+}
+
+func NewHandler(
+	store coaching.CoachingStore, userStore users.UserStore, workoutStore workouts.WorkoutStore,
+	diaryStore diary.DiaryStore, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{
+		store: store, userStore: userStore, workoutStore: workoutStore, diaryStore: diaryStore, recorder: recorder,
+		log: log, cfg: config.Envs,
+	}
+}
+
+// RequireCoach only lets users with the coach role through.
+func (h *Handler) RequireCoach(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			user := mwAuth.User(r.Context())
+			if _, err := h.store.GetCoach(user.ID); err != nil {
+				if errors.Is(err, coaching.CoachNotFound) {
+					resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "coach role required"})
+					return
+				}
+				h.log.Error(
+					"failed to get coach", sl.Err(err),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Int("user_id", user.ID),
+				)
+				resp.Internal(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+// HandleGrantCoach gives the coach role to a user. The route requires the users:write permission.
+func (h *Handler) HandleGrantCoach(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGrantCoach"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, ok := h.userParam(w, r, log)
+	if !ok {
+		return
+	}
+
+	var payload models.GrantCoachPayload
+	err := render.DecodeJSON(r.Body, &payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if err := h.store.SaveCoach(id, payload.Bio); err != nil {
+		log.Error("failed to save coach", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditCoachGranted, user.ID, id, nil)
+	log.Info("coach role granted", slog.Int("coach_id", id))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleRevokeCoach takes the coach role away, which ends the links with the coach's clients.
+func (h *Handler) HandleRevokeCoach(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleRevokeCoach"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.store.DeleteCoach(id); err != nil {
+		if errors.Is(err, coaching.CoachNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete coach", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditCoachRevoked, user.ID, id, nil)
+	log.Info("coach role revoked", slog.Int("coach_id", id))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetCoaches lists the coaches of the user and the pending invitations.
+func (h *Handler) HandleGetCoaches(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetCoaches"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetCoaches(user.ID)
+	if err != nil {
+		log.Error("failed to get coaches", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleAcceptCoach accepts the invitation of a coach, with fewer scopes than requested if the user wishes.
+func (h *Handler) HandleAcceptCoach(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleAcceptCoach"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	coachID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid coach id"})
+		return
+	}
+
+	var payload models.ConsentPayload
+	err = render.DecodeJSON(r.Body, &payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if err := h.store.Accept(coachID, user.ID, payload.Scopes); err != nil {
+		if errors.Is(err, coaching.CoachingNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to accept coach", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("coach accepted", slog.Int("coach_id", coachID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleUpdateConsent replaces the scopes granted to a coach. Removed scopes apply to the next request of the coach.
+func (h *Handler) HandleUpdateConsent(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleUpdateConsent"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	coachID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid coach id"})
+		return
+	}
+
+	var payload models.ConsentPayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	scopes := payload.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	if err := h.store.SetScopes(coachID, user.ID, scopes); err != nil {
+		if errors.Is(err, coaching.CoachingNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to update consent", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("consent updated", slog.Int("coach_id", coachID), slog.Any("scopes", scopes))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleEndCoach declines the invitation of a coach or revokes the consent given to them.
+func (h *Handler) HandleEndCoach(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleEndCoach"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	coachID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid coach id"})
+		return
+	}
+
+	if err := h.store.End(coachID, user.ID); err != nil {
+		if errors.Is(err, coaching.CoachingNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to end coaching", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("coaching ended by client", slog.Int("coach_id", coachID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetCoachNotes lists the notes a coach left for the user.
+func (h *Handler) HandleGetCoachNotes(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetCoachNotes"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	coachID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid coach id"})
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetNotes(coachID, user.ID, limit)
+	if err != nil {
+		log.Error("failed to get notes", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleGetAssignments lists the workouts the coaches of the user assigned, latest first.
+func (h *Handler) HandleGetAssignments(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetAssignments"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetAssignments(user.ID, 0, limit)
+	if err != nil {
+		log.Error("failed to get assignments", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleCompleteAssignment marks an assignment as done by the given logged workout.
+func (h *Handler) HandleCompleteAssignment(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleCompleteAssignment"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid assignment id"})
+		return
+	}
+
+	var payload models.CompleteAssignmentPayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if err := h.store.CompleteAssignment(user.ID, id, payload.WorkoutID); err != nil {
+		if errors.Is(err, coaching.AssignmentNotFound) || errors.Is(err, coaching.WorkoutNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to complete assignment", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("assignment completed", slog.Int("assignment_id", id), slog.Int("workout_id", payload.WorkoutID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetPrograms lists the published templates, open to every user.
+func (h *Handler) HandleGetPrograms(w http.ResponseWriter, r *http.Request) {
+	const op = "coaching.HandleGetPrograms"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetPrograms(limit)
+	if err != nil {
+		log.Error("failed to get programs", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// userParam reads the {id} URL parameter of an existing user.
+func (h *Handler) userParam(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return 0, false
+	}
+
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return 0, false
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return 0, false
+	}
+	return id, true
+}
+
+// queryLimit reads the ?limit= query parameter.
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := defaultListLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return 0, false
+		}
+		limit = min(l, maxListLimit)
+	}
+	return limit, true
+}
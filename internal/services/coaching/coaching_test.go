@@ -0,0 +1,283 @@
+package coaching
+
+import (
+	"github.com/go-chi/chi/v5"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	audit2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-secret"
+	coachID    = 1
+	clientID   = 7
+	strangerID = 8
+)
+
+func TestMain(m *testing.M) {
+	config.Envs.JwtCfg.Secret = testSecret
+	os.Exit(m.Run())
+}
+
+// link is the coaching between coachID and clientID, as the Postgres store keeps it.
+type link struct {
+	status string
+	scopes []string
+}
+
+// fakeStore knows one coach and at most one link with the client.
+type fakeStore struct {
+	coaching.CoachingStore
+	link  *link
+	notes []models.CoachNote
+}
+
+func (s *fakeStore) GetCoach(userID int) (*models.Coach, error) {
+	if userID != coachID {
+		return nil, coaching.CoachNotFound
+	}
+	return &models.Coach{UserID: userID}, nil
+}
+
+func (s *fakeStore) Invite(coach int, client int, scopes []string) (*models.Coaching, error) {
+	if s.find(coach, client, models.CoachingPending, models.CoachingActive) != nil {
+		return nil, coaching.CoachingExists
+	}
+	s.link = &link{status: models.CoachingPending, scopes: scopes}
+	return &models.Coaching{CoachID: coach, ClientID: client, Status: models.CoachingPending, Scopes: scopes}, nil
+}
+
+// find returns the link of coach and client in one of statuses.
+func (s *fakeStore) find(coach int, client int, statuses ...string) *link {
+	if s.link == nil || coach != coachID || client != clientID {
+		return nil
+	}
+	for _, status := range statuses {
+		if s.link.status == status {
+			return s.link
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) Accept(coach int, client int, scopes []string) error {
+	l := s.find(coach, client, models.CoachingPending)
+	if l == nil {
+		return coaching.CoachingNotFound
+	}
+	l.status = models.CoachingActive
+	if scopes != nil {
+		l.scopes = scopes
+	}
+	return nil
+}
+
+func (s *fakeStore) SetScopes(coach int, client int, scopes []string) error {
+	l := s.find(coach, client, models.CoachingActive)
+	if l == nil {
+		return coaching.CoachingNotFound
+	}
+	l.scopes = scopes
+	return nil
+}
+
+func (s *fakeStore) End(coach int, client int) error {
+	l := s.find(coach, client, models.CoachingPending, models.CoachingActive)
+	if l == nil {
+		return coaching.CoachingNotFound
+	}
+	if l.status == models.CoachingPending {
+		l.status = models.CoachingDeclined
+	} else {
+		l.status = models.CoachingRevoked
+	}
+	return nil
+}
+
+func (s *fakeStore) GetScopes(coach int, client int) ([]string, error) {
+	l := s.find(coach, client, models.CoachingActive)
+	if l == nil {
+		return nil, coaching.CoachingNotFound
+	}
+	return l.scopes, nil
+}
+
+func (s *fakeStore) CreateNote(note *models.CoachNote) error {
+	s.notes = append(s.notes, *note)
+	return nil
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id, IsActive: true}, nil
+}
+
+func (fakeUsers) GetUserByEmail(email string) (*models.User, error) {
+	if email != "client@example.com" {
+		return nil, nil
+	}
+	return &models.User{ID: clientID, Username: "client", IsActive: true}, nil
+}
+
+type fakeWorkouts struct{ workouts.WorkoutStore }
+
+func (fakeWorkouts) GetWorkouts(int, int) ([]models.Workout, error) {
+	return []models.Workout{}, nil
+}
+
+type fakeDiary struct{ diary.DiaryStore }
+
+func (fakeDiary) GetEntries(int, time.Time, time.Time) ([]models.DiaryEntry, error) {
+	return []models.DiaryEntry{}, nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) Revoked(int64) bool { return false }
+
+func (fakeSessions) Touch(int64) {}
+
+type fakeAudit struct{ audit2.AuditStore }
+
+func (fakeAudit) Append(*models.AuditEvent) error { return nil }
+
+func newTestRouter(store *fakeStore) http.Handler {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewHandler(store, fakeUsers{}, fakeWorkouts{}, fakeDiary{}, audit.NewRecorder(fakeAudit{}, log), log)
+
+	r := chi.NewRouter()
+	r.Use(mwAuth.New(fakeUsers{}, fakeSessions{}, log))
+	r.Post("/api/me/coaches/{id}", h.HandleAcceptCoach)
+	r.Put("/api/me/coaches/{id}", h.HandleUpdateConsent)
+	r.Delete("/api/me/coaches/{id}", h.HandleEndCoach)
+	r.Group(
+		func(r chi.Router) {
+			r.Use(h.RequireCoach)
+
+			r.Post("/api/coach/invitations", h.HandleInviteClient)
+			r.Get("/api/coach/clients/{id}/workouts", h.HandleGetClientWorkouts)
+			r.Get("/api/coach/clients/{id}/diary", h.HandleGetClientDiary)
+			r.Get("/api/coach/clients/{id}/assignments", h.HandleGetClientAssignments)
+			r.Post("/api/coach/clients/{id}/notes", h.HandleCreateNote)
+		},
+	)
+	return r
+}
+
+func send(t *testing.T, h http.Handler, userID int, method string, path string, body string) int {
+	t.Helper()
+	token, err := jwt.NewToken(models.User{ID: userID}, 1, nil, time.Minute, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+// TestConsent follows a coaching from the invitation to its end. Every step checks what the coach
+// can read of the client at that point.
+func TestConsent(t *testing.T) {
+	store := &fakeStore{}
+	h := newTestRouter(store)
+
+	steps := []struct {
+		name         string
+		userID       int
+		method       string
+		path         string
+		body         string
+		wantStatus   int
+		wantWorkouts int
+		wantDiary    int
+	}{
+		{
+			name: "invited", userID: coachID, method: http.MethodPost, path: "/api/coach/invitations",
+			body:       `{"email":"client@example.com","scopes":["view_workouts","view_diary"]}`,
+			wantStatus: http.StatusCreated, wantWorkouts: http.StatusNotFound, wantDiary: http.StatusNotFound,
+		},
+		{
+			name: "accepted with fewer scopes", userID: clientID, method: http.MethodPost, path: "/api/me/coaches/1",
+			body:       `{"scopes":["view_workouts"]}`,
+			wantStatus: http.StatusOK, wantWorkouts: http.StatusOK, wantDiary: http.StatusForbidden,
+		},
+		{
+			name: "diary granted", userID: clientID, method: http.MethodPut, path: "/api/me/coaches/1",
+			body:       `{"scopes":["view_workouts","view_diary"]}`,
+			wantStatus: http.StatusOK, wantWorkouts: http.StatusOK, wantDiary: http.StatusOK,
+		},
+		{
+			name: "every scope revoked", userID: clientID, method: http.MethodPut, path: "/api/me/coaches/1",
+			body:       `{"scopes":[]}`,
+			wantStatus: http.StatusOK, wantWorkouts: http.StatusForbidden, wantDiary: http.StatusForbidden,
+		},
+		{
+			name: "ended by the client", userID: clientID, method: http.MethodDelete, path: "/api/me/coaches/1",
+			wantStatus: http.StatusOK, wantWorkouts: http.StatusNotFound, wantDiary: http.StatusNotFound,
+		},
+		{
+			name: "accepted after the end", userID: clientID, method: http.MethodPost, path: "/api/me/coaches/1",
+			wantStatus: http.StatusNotFound, wantWorkouts: http.StatusNotFound, wantDiary: http.StatusNotFound,
+		},
+	}
+
+	// The steps run in order, each one on the link the previous one left.
+	for _, step := range steps {
+		if got := send(t, h, step.userID, step.method, step.path, step.body); got != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", step.name, got, step.wantStatus)
+		}
+		if got := send(t, h, coachID, http.MethodGet, "/api/coach/clients/7/workouts", ""); got != step.wantWorkouts {
+			t.Errorf("%s: workouts status = %d, want %d", step.name, got, step.wantWorkouts)
+		}
+		if got := send(t, h, coachID, http.MethodGet, "/api/coach/clients/7/diary", ""); got != step.wantDiary {
+			t.Errorf("%s: diary status = %d, want %d", step.name, got, step.wantDiary)
+		}
+	}
+}
+
+func TestNotesNeedNoScope(t *testing.T) {
+	store := &fakeStore{link: &link{status: models.CoachingActive, scopes: []string{}}}
+	h := newTestRouter(store)
+
+	body := `{"body":"Rest on Sunday"}`
+	if got := send(t, h, coachID, http.MethodPost, "/api/coach/clients/7/notes", body); got != http.StatusCreated {
+		t.Fatalf("note status = %d, want 201", got)
+	}
+	if got := send(t, h, coachID, http.MethodGet, "/api/coach/clients/7/assignments", ""); got != http.StatusForbidden {
+		t.Errorf("assignments status = %d, want 403 without assign_programs", got)
+	}
+	if len(store.notes) != 1 || store.notes[0].ClientID != clientID {
+		t.Errorf("notes = %+v, want one for the client", store.notes)
+	}
+}
+
+func TestCoachRoutesRequireCoach(t *testing.T) {
+	store := &fakeStore{link: &link{status: models.CoachingActive, scopes: []string{models.CoachScopeWorkouts}}}
+	h := newTestRouter(store)
+
+	if got := send(t, h, strangerID, http.MethodGet, "/api/coach/clients/7/workouts", ""); got != http.StatusForbidden {
+		t.Errorf("status for a user without the coach role = %d, want 403", got)
+	}
+	body := `{"email":"client@example.com","scopes":["view_workouts"]}`
+	if got := send(t, h, coachID, http.MethodPost, "/api/coach/invitations", body); got != http.StatusConflict {
+		t.Errorf("second invitation status = %d, want 409", got)
+	}
+}
@@ -0,0 +1,219 @@
+package devicesync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/workouts"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/devicesync"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultPageSize = 200
+	maxPageSize     = 1000
+)
+
+// Handler lets devices that work offline exchange their changes with the server. A device pushes
+// the changes it made since it last synced, then pulls the changes made elsewhere after the cursor
+// of its last pull, starting from 0.
+type Handler struct {
+	store   devicesync.SyncStore
+	tracker *workouts.RecordTracker
+	bus     *events.Bus
+	log     *slog.Logger
+	cfg     config.Config
+}
+
+func NewHandler(
+	store devicesync.SyncStore, tracker *workouts.RecordTracker, bus *events.Bus, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, tracker: tracker, bus: bus, log: log, cfg: config.Envs}
+}
+
+// HandlePush applies a batch of changes in one transaction and reports the decision taken on each.
+// When the server's version wins, it is part of the decision so the device can take it over.
+func (h *Handler) HandlePush(w http.ResponseWriter, r *http.Request) {
+	const op = "devicesync.HandlePush"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.SyncPushPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	for i := range payload.Changes {
+		c := &payload.Changes[i]
+		if c.Op == models.SyncDelete {
+			continue
+		}
+		if c.Record, err = decodeRecord(c.Entity, c.Data); err == nil {
+			err = validate.Struct(c.Record)
+		}
+		if err != nil {
+			log.Warn("invalid change", slog.Int("change", i), sl.Err(err))
+			resp.JSON(
+				w, r, http.StatusUnprocessableEntity,
+				map[string]string{"error": fmt.Sprintf("invalid data of change %d", i)},
+			)
+			return
+		}
+	}
+
+	res, err := h.store.Push(user.ID, payload.DeviceID, payload.Changes)
+	if err != nil {
+		log.Error("failed to push changes", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.publish(user.ID, res, log)
+
+	log.Info("changes pushed", slog.String("device_id", payload.DeviceID), slog.Int("changes", len(res.Results)))
+	resp.JSON(w, r, http.StatusOK, res)
+}
+
+// publish announces what the push changed. Deleted records are published as they were, so that
+// the subscribers can take back what they derived from them.
+func (h *Handler) publish(userID int, res *models.SyncPushResult, log *slog.Logger) {
+	for i := range res.Created.Workouts {
+		workout := &res.Created.Workouts[i]
+		h.bus.Publish(events.WorkoutCreated, userID, *workout)
+		if _, err := h.tracker.Track(workout); err != nil {
+			log.Error("failed to update personal records", sl.Err(err), slog.Int("workout_id", workout.ID))
+		}
+	}
+	for i := range res.Updated.Workouts {
+		workout := &res.Updated.Workouts[i]
+		h.bus.Publish(events.WorkoutUpdated, userID, *workout)
+		if _, err := h.tracker.Track(workout); err != nil {
+			log.Error("failed to update personal records", sl.Err(err), slog.Int("workout_id", workout.ID))
+		}
+	}
+	for _, workout := range res.Deleted.Workouts {
+		h.bus.Publish(events.WorkoutDeleted, userID, workout)
+	}
+
+	for _, entry := range res.Created.DiaryEntries {
+		h.bus.Publish(events.DiaryLogged, userID, entry)
+	}
+	for _, entry := range res.Updated.DiaryEntries {
+		h.bus.Publish(events.DiaryUpdated, userID, entry)
+	}
+	for _, entry := range res.Deleted.DiaryEntries {
+		h.bus.Publish(events.DiaryDeleted, userID, entry)
+	}
+
+	for _, entry := range res.Created.WeightEntries {
+		h.bus.Publish(events.WeightLogged, userID, entry)
+	}
+	for _, entry := range res.Updated.WeightEntries {
+		h.bus.Publish(events.WeightUpdated, userID, entry)
+	}
+	for _, entry := range res.Deleted.WeightEntries {
+		h.bus.Publish(events.WeightDeleted, userID, entry)
+	}
+}
+
+// HandlePull returns the current state of the records changed after ?cursor=, oldest change first.
+// The cursor of the page is passed to the next pull; has_more tells that another page follows.
+func (h *Handler) HandlePull(w http.ResponseWriter, r *http.Request) {
+	const op = "devicesync.HandlePull"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var cursor int64
+	if str := r.URL.Query().Get("cursor"); str != "" {
+		c, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || c < 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+			return
+		}
+		cursor = c
+	}
+
+	limit := defaultPageSize
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxPageSize)
+	}
+
+	page, err := h.store.Pull(user.ID, cursor, limit)
+	if err != nil {
+		log.Error("failed to pull changes", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, page)
+}
+
+// decodeRecord decodes the data of an upsert into the type of its entity.
+func decodeRecord(entity string, data json.RawMessage) (any, error) {
+	if len(data) == 0 {
+		return nil, errors.New("data is required")
+	}
+
+	var record any
+	switch entity {
+	case models.SyncWorkout:
+		record = &models.SyncWorkoutData{}
+	case models.SyncWorkoutSet:
+		record = &models.SyncWorkoutSetData{}
+	case models.SyncDiaryEntry:
+		record = &models.SyncDiaryEntryData{}
+	case models.SyncWeightEntry:
+		record = &models.SyncWeightEntryData{}
+	default:
+		return nil, fmt.Errorf("unknown entity %s", entity)
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,179 @@
+package devicesync
+
+import (
+	"fmt"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/devicesync"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func TestMain(m *testing.M) {
+	config.Envs.JwtCfg.Secret = testSecret
+	os.Exit(m.Run())
+}
+
+// fakeStore applies every change and records the pushes and pulls it was asked for.
+type fakeStore struct {
+	devicesync.SyncStore
+	pushed  [][]models.SyncChange
+	cursors []int64
+	limits  []int
+}
+
+func (s *fakeStore) Push(_ int, _ string, changes []models.SyncChange) (*models.SyncPushResult, error) {
+	s.pushed = append(s.pushed, changes)
+	res := &models.SyncPushResult{}
+	for _, c := range changes {
+		res.Results = append(res.Results, models.SyncResult{Entity: c.Entity, ID: c.ID, Decision: models.SyncApplied})
+	}
+	return res, nil
+}
+
+func (s *fakeStore) Pull(_ int, cursor int64, limit int) (*models.SyncPage, error) {
+	s.cursors = append(s.cursors, cursor)
+	s.limits = append(s.limits, limit)
+	return &models.SyncPage{Changes: []models.SyncRecord{}, Cursor: cursor}, nil
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) Revoked(int64) bool { return false }
+
+func (fakeSessions) Touch(int64) {}
+
+func send(t *testing.T, h http.HandlerFunc, method string, target string, body string) int {
+	t.Helper()
+	token, err := jwt.NewToken(models.User{ID: 7}, 1, nil, time.Minute, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mwAuth.New(fakeUsers{}, fakeSessions{}, log)(h).ServeHTTP(w, r)
+	return w.Code
+}
+
+// pushBody is a push of one weight entry change; data is left out when empty.
+func pushBody(id string, op string, data string) string {
+	change := fmt.Sprintf(`{"entity":"weight_entry","id":%q,"op":%q,"updated_at":"2024-10-03T12:00:00Z"`, id, op)
+	if data != "" {
+		change += `,"data":` + data
+	}
+	return `{"device_id":"phone","changes":[` + change + `}]}`
+}
+
+func TestPushValidatesData(t *testing.T) {
+	const id = "5f0c6a4e-7d1b-4b8e-9a43-0e6f1c2d3b4a"
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "upsert",
+			body:       pushBody(id, models.SyncUpsert, `{"measured_at":"2024-10-03T07:00:00Z","weight":80.5}`),
+			wantStatus: http.StatusOK,
+		},
+		{name: "delete without data", body: pushBody(id, models.SyncDelete, ""), wantStatus: http.StatusOK},
+		{
+			name:       "upsert without data",
+			body:       pushBody(id, models.SyncUpsert, ""),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "invalid data",
+			body:       pushBody(id, models.SyncUpsert, `{"measured_at":"2024-10-03T07:00:00Z","weight":8}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "data of the wrong type",
+			body:       pushBody(id, models.SyncUpsert, `{"measured_at":"2024-10-03T07:00:00Z","weight":"heavy"}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "id is not a uuid",
+			body:       pushBody("42", models.SyncDelete, ""),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := &fakeStore{}
+				h := NewHandler(store, nil, events.New(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+				if got := send(t, h.HandlePush, http.MethodPost, "/api/sync/push", tt.body); got != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", got, tt.wantStatus)
+				}
+				if pushed := len(store.pushed) > 0; pushed != (tt.wantStatus == http.StatusOK) {
+					t.Errorf("pushed = %v for status %d", pushed, tt.wantStatus)
+				}
+			},
+		)
+	}
+}
+
+func TestPullCursor(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCursor int64
+		wantLimit  int
+	}{
+		{name: "first pull", query: "", wantStatus: http.StatusOK, wantCursor: 0, wantLimit: defaultPageSize},
+		{name: "next pull", query: "?cursor=42&limit=10", wantStatus: http.StatusOK, wantCursor: 42, wantLimit: 10},
+		{name: "limit capped", query: "?limit=5000", wantStatus: http.StatusOK, wantLimit: maxPageSize},
+		{name: "negative cursor", query: "?cursor=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid cursor", query: "?cursor=abc", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := &fakeStore{}
+				h := NewHandler(store, nil, events.New(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+				if got := send(t, h.HandlePull, http.MethodGet, "/api/sync/pull"+tt.query, ""); got != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", got, tt.wantStatus)
+				}
+				if tt.wantStatus != http.StatusOK {
+					if len(store.cursors) != 0 {
+						t.Error("store was asked for changes")
+					}
+					return
+				}
+				if store.cursors[0] != tt.wantCursor || store.limits[0] != tt.wantLimit {
+					t.Errorf(
+						"pulled from %d by %d, want from %d by %d",
+						store.cursors[0], store.limits[0], tt.wantCursor, tt.wantLimit,
+					)
+				}
+			},
+		)
+	}
+}
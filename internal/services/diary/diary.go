@@ -0,0 +1,219 @@
+package diary
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const maxDiaryDays = 31
+
+type Handler struct {
+	store       diary.DiaryStore
+	foodStore   foods.FoodStore
+	recipeStore recipes.RecipeStore
+	bus         *events.Bus
+	log         *slog.Logger
+	cfg         config.Config
+}
+
+func NewHandler(
+	store diary.DiaryStore, foodStore foods.FoodStore, recipeStore recipes.RecipeStore, bus *events.Bus,
+	log *slog.Logger,
+) *Handler {
+	return &Handler{
+		store: store, foodStore: foodStore, recipeStore: recipeStore, bus: bus, log: log, cfg: config.Envs,
+	}
+}
+
+type totals struct {
+	Kcal    float64 `json:"kcal"`
+	Protein float64 `json:"protein"`
+	Carbs   float64 `json:"carbs"`
+	Fat     float64 `json:"fat"`
+}
+
+func (h *Handler) HandleCreateEntry(w http.ResponseWriter, r *http.Request) {
+	const op = "diary.HandleCreateEntry"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateDiaryEntryPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if payload.FoodID != nil {
+		food, err := h.foodStore.GetFoodByID(user.ID, *payload.FoodID)
+		if err != nil {
+			if errors.Is(err, foods.FoodNotFound) {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Error("failed to get food", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		fillFromFood(&payload, food)
+	}
+
+	if payload.RecipeID != nil {
+		recipe, err := h.recipeStore.GetRecipe(user.ID, *payload.RecipeID)
+		if err != nil {
+			if errors.Is(err, recipes.RecipeNotFound) {
+				resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Error("failed to get recipe", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+
+		ids := make([]int, len(recipe.Ingredients))
+		for i, ing := range recipe.Ingredients {
+			ids[i] = ing.FoodID
+		}
+		found, err := h.foodStore.GetFoodsByIDs(user.ID, ids)
+		if err != nil {
+			log.Error("failed to get foods", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		fillFromRecipe(&payload, recipe, found)
+	}
+
+	entry, err := h.store.CreateEntry(user.ID, payload)
+	if err != nil {
+		log.Error("failed to save diary entry", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	h.bus.Publish(events.DiaryLogged, user.ID, *entry)
+
+	resp.JSON(w, r, http.StatusCreated, entry)
+}
+
+func (h *Handler) HandleGetEntries(w http.ResponseWriter, r *http.Request) {
+	const op = "diary.HandleGetEntries"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), 1, maxDiaryDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.store.GetEntries(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get diary entries", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	var t totals
+	for _, e := range entries {
+		t.Kcal += e.Kcal
+		t.Protein += e.Protein
+		t.Carbs += e.Carbs
+		t.Fat += e.Fat
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]any{"entries": entries, "totals": t})
+}
+
+func (h *Handler) HandleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	const op = "diary.HandleDeleteEntry"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid diary entry id"})
+		return
+	}
+
+	if err := h.store.DeleteEntry(user.ID, id); err != nil {
+		if errors.Is(err, diary.EntryNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete diary entry", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// fillFromFood replaces the manual name and macros by the values of the eaten amount of food.
+func fillFromFood(p *models.CreateDiaryEntryPayload, food *models.Food) {
+	n := nutrition.Round(nutrition.Scale(food.Nutrients, *p.Grams))
+	p.Name = food.Name
+	if food.Brand != "" {
+		p.Name = food.Brand + " " + food.Name
+	}
+	p.Kcal = n.Kcal
+	p.Protein = n.Protein
+	p.Carbs = n.Carbs
+	p.Fat = n.Fat
+}
+
+// fillFromRecipe replaces the manual name and macros by the values of the eaten servings of a recipe.
+func fillFromRecipe(p *models.CreateDiaryEntryPayload, recipe *models.Recipe, foodsByID map[int]models.Food) {
+	n := nutrition.Round(nutrition.Scale(nutrition.PerServing(recipe, foodsByID), 100**p.Servings))
+	p.Name = recipe.Name
+	p.Kcal = n.Kcal
+	p.Protein = n.Protein
+	p.Carbs = n.Carbs
+	p.Fat = n.Fat
+}
@@ -0,0 +1,133 @@
+package energy
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/energy"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"log/slog"
+	"net/http"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultEnergyDays = 7
+	maxEnergyDays     = 366
+)
+
+type Handler struct {
+	workoutStore workouts.WorkoutStore
+	diaryStore   diary.DiaryStore
+	log          *slog.Logger
+	cfg          config.Config
+}
+
+func NewHandler(workoutStore workouts.WorkoutStore, diaryStore diary.DiaryStore, log *slog.Logger) *Handler {
+	return &Handler{workoutStore: workoutStore, diaryStore: diaryStore, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleGetEnergy(w http.ResponseWriter, r *http.Request) {
+	const op = "energy.HandleGetEnergy"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultEnergyDays, maxEnergyDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	exercises, err := h.workoutStore.GetExercises()
+	if err != nil {
+		log.Error("failed to get exercises", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	list, err := h.workoutStore.GetWorkoutsBetween(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get workouts", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	entries, err := h.diaryStore.GetEntries(user.ID, period.From, period.To)
+	if err != nil {
+		log.Error("failed to get diary entries", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, DailyBalance(*user, period, exercises, list, entries))
+}
+
+// WorkoutKcal estimates the energy a user burned in a workout.
+func WorkoutKcal(user models.User, exercises map[int]models.Exercise, w models.Workout) float64 {
+	activities := make([]energy.Activity, 0, len(w.Sets))
+	for _, set := range w.Sets {
+		ex := exercises[set.ExerciseID]
+		a := energy.Activity{MET: ex.MET, Duration: set.Duration, Distance: set.Distance}
+		if ex.METProfile != nil {
+			a.Profile = *ex.METProfile
+		}
+		activities = append(activities, a)
+	}
+	return energy.WorkoutKcal(float64(user.Weight), w.Duration, activities)
+}
+
+// DailyBalance builds the energy series of every day in the period.
+func DailyBalance(
+	user models.User, period daterange.Range, exercises []models.Exercise, list []models.Workout,
+	entries []models.DiaryEntry,
+) []models.EnergyDay {
+	byID := make(map[int]models.Exercise, len(exercises))
+	for _, ex := range exercises {
+		byID[ex.ID] = ex
+	}
+
+	bmr := energy.Round(energy.BMR(user.IsMale, user.Age, user.Height, float64(user.Weight)))
+
+	days := make([]models.EnergyDay, 0, period.Len())
+	index := make(map[string]int, period.Len())
+	for _, d := range period.Days() {
+		key := d.Format(daterange.Layout)
+		index[key] = len(days)
+		days = append(days, models.EnergyDay{Date: key, BMR: bmr, PerWorkout: []models.WorkoutEnergy{}})
+	}
+
+	for _, e := range entries {
+		if i, ok := index[period.Key(e.EatenAt)]; ok {
+			days[i].Intake += e.Kcal
+		}
+	}
+
+	for _, w := range list {
+		i, ok := index[period.Key(w.StartedAt)]
+		if !ok {
+			continue
+		}
+		kcal := WorkoutKcal(user, byID, w)
+		days[i].Workouts += kcal
+		days[i].PerWorkout = append(days[i].PerWorkout, models.WorkoutEnergy{WorkoutID: w.ID, Kcal: kcal})
+	}
+
+	for i := range days {
+		days[i].Intake = energy.Round(days[i].Intake)
+		days[i].Workouts = energy.Round(days[i].Workouts)
+		days[i].Expenditure = energy.Round(bmr*energy.SedentaryFactor + days[i].Workouts)
+		days[i].Balance = energy.Round(days[i].Intake - days[i].Expenditure)
+	}
+
+	return days
+}
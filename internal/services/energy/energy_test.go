@@ -0,0 +1,40 @@
+package energy
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDailyBalance(t *testing.T) {
+	user := models.User{IsMale: true, Age: 30, Height: 180, Weight: 80}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	period := daterange.Range{From: day, To: day.AddDate(0, 0, 2)}
+	exercises := []models.Exercise{{ID: 1, MET: 5}}
+	list := []models.Workout{
+		{ID: 10, StartedAt: day.Add(18 * time.Hour), Duration: 3600, Sets: []models.WorkoutSet{{ExerciseID: 1}}},
+		{ID: 11, StartedAt: day.AddDate(0, 0, 2), Duration: 3600, Sets: []models.WorkoutSet{{ExerciseID: 1}}},
+	}
+	entries := []models.DiaryEntry{
+		{EatenAt: day.Add(8 * time.Hour), Kcal: 1200.4},
+		{EatenAt: day.Add(20 * time.Hour), Kcal: 1299.6},
+		{EatenAt: day.AddDate(0, 0, 1), Kcal: 1800},
+	}
+
+	// BMR 1780 * 1.2 is the sedentary expenditure; the workout adds 4 net METs * 80 kg * 1 h.
+	want := []models.EnergyDay{
+		{
+			Date: "2024-05-01", Intake: 2500, BMR: 1780, Workouts: 320, Expenditure: 2456, Balance: 44,
+			PerWorkout: []models.WorkoutEnergy{{WorkoutID: 10, Kcal: 320}},
+		},
+		{
+			Date: "2024-05-02", Intake: 1800, BMR: 1780, Expenditure: 2136, Balance: -336,
+			PerWorkout: []models.WorkoutEnergy{},
+		},
+	}
+	if got := DailyBalance(user, period, exercises, list, entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("DailyBalance() = %+v, want %+v", got, want)
+	}
+}
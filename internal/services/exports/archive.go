@@ -0,0 +1,672 @@
+package exports
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/mealplans"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/metrics"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/preferences"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// auditPage is how many audit events are read per query.
+	auditPage = 1000
+	// activityPage is how many activities are read per query.
+	activityPage = 500
+)
+
+// allTime bounds the date range queries used to collect every record of a user.
+var (
+	allTimeFrom = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	allTimeTo   = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// section is one dataset of the archive. It is written as <name>.json when data is set
+// and as <name>.csv when header is set.
+type section struct {
+	name   string
+	data   any
+	header []string
+	rows   [][]string
+}
+
+// profile lists the user fields that are exported. Secrets such as the password hash and
+// the activation code are deliberately left out.
+type profile struct {
+	ID         int       `json:"id"`
+	Email      string    `json:"email"`
+	Username   string    `json:"username"`
+	CreatedAt  time.Time `json:"created_at"`
+	IsActive   bool      `json:"is_active"`
+	IsMale     bool      `json:"is_male"`
+	Age        int       `json:"age"`
+	Height     int       `json:"height"`
+	Weight     int       `json:"weight"`
+	Goal       string    `json:"goal"`
+	WeightGoal int       `json:"weight_goal"`
+}
+
+// Collector gathers the personal data of a user from every store.
+type Collector struct {
+	userStore     users.UserStore
+	prefsStore    preferences.PreferencesStore
+	workoutStore  workouts.WorkoutStore
+	diaryStore    diary.DiaryStore
+	recipeStore   recipes.RecipeStore
+	mealPlanStore mealplans.MealPlanStore
+	metricStore   metrics.MetricStore
+	bodyStore     body.BodyStore
+	goalStore     goals.GoalStore
+	socialStore   social.SocialStore
+	coachingStore coaching.CoachingStore
+	sessionStore  sessions.SessionStore
+	tokenStore    tokens.TokenStore
+	auditStore    audit.AuditStore
+}
+
+func NewCollector(
+	userStore users.UserStore, prefsStore preferences.PreferencesStore, workoutStore workouts.WorkoutStore,
+	diaryStore diary.DiaryStore, recipeStore recipes.RecipeStore, mealPlanStore mealplans.MealPlanStore,
+	metricStore metrics.MetricStore, bodyStore body.BodyStore, goalStore goals.GoalStore,
+	socialStore social.SocialStore, coachingStore coaching.CoachingStore, sessionStore sessions.SessionStore,
+	tokenStore tokens.TokenStore, auditStore audit.AuditStore,
+) *Collector {
+	return &Collector{
+		userStore: userStore, prefsStore: prefsStore, workoutStore: workoutStore, diaryStore: diaryStore,
+		recipeStore: recipeStore, mealPlanStore: mealPlanStore, metricStore: metricStore, bodyStore: bodyStore,
+		goalStore: goalStore, socialStore: socialStore, coachingStore: coachingStore, sessionStore: sessionStore,
+		tokenStore: tokenStore, auditStore: auditStore,
+	}
+}
+
+// collect reads everything the user entered or that was recorded about them. Left out are what is
+// derived from the exported records (achievements, challenge scores, the device sync log), other
+// users' data (kudos and comments on the user's activities, followers' profiles) and credentials
+// (password hash, token hashes, OAuth grants and webhook secrets).
+func (c *Collector) collect(userID int) ([]section, error) {
+	const op = "exports.Collector.collect"
+
+	u, err := c.userStore.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	prefs, err := c.prefsStore.GetPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	list, err := c.workoutStore.GetWorkoutsBetween(userID, allTimeFrom, allTimeTo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	records, err := c.workoutStore.GetRecords(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	entries, err := c.diaryStore.GetEntries(userID, allTimeFrom, allTimeTo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	recipeList, err := c.recipeStore.GetRecipes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	plans, err := c.mealPlanStore.GetPlans(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	dailyMetrics, err := c.metricStore.GetHistory(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	metricGoals, err := c.metricStore.GetGoals(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	weights, err := c.bodyStore.GetWeightEntries(userID, allTimeFrom, allTimeTo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	measurements, err := c.bodyStore.GetMeasurements(userID, allTimeFrom, allTimeTo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	photos, err := c.bodyStore.GetPhotos(userID, allTimeFrom, allTimeTo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	goalList, err := c.goals(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	settings, err := c.socialStore.GetSettings(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	follows, err := c.follows(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	activities, err := c.activities(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	comments, err := c.socialStore.GetUserComments(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	notes, err := c.coachingStore.GetUserNotes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sessionList, err := c.sessionStore.GetSessionHistory(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	tokenList, err := c.tokenStore.GetTokens(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	auditEvents, err := c.auditEvents(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return []section{
+		profileSection(u),
+		{name: "preferences", data: prefs},
+		workoutsSection(list),
+		setsSection(list),
+		recordsSection(records),
+		diarySection(entries),
+		// Recipes and meal plans nest their ingredients and meals, so they are JSON only.
+		{name: "recipes", data: recipeList},
+		{name: "meal_plans", data: plans},
+		metricsSection(dailyMetrics),
+		metricGoalsSection(metricGoals),
+		weightSection(weights),
+		measurementsSection(measurements),
+		photosSection(photos),
+		goalsSection(goalList),
+		{name: "social_settings", data: settings},
+		followsSection(follows),
+		activitiesSection(activities),
+		commentsSection(comments),
+		coachNotesSection(notes),
+		sessionsSection(sessionList),
+		tokensSection(tokenList),
+		auditSection(auditEvents),
+	}, nil
+}
+
+// goals returns the goals of the user with the milestones they reached.
+func (c *Collector) goals(userID int) ([]models.Goal, error) {
+	list, err := c.goalStore.GetGoals(userID, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Milestones, err = c.goalStore.GetMilestones(list[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// follows returns both directions of the follow relations of the user, requests included.
+func (c *Collector) follows(userID int) ([]models.Follow, error) {
+	list, err := c.socialStore.GetFollowing(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range []string{models.FollowAccepted, models.FollowPending} {
+		followers, err := c.socialStore.GetFollowers(userID, status)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, followers...)
+	}
+	return list, nil
+}
+
+// activities returns every activity of the user whatever its visibility, newest first.
+func (c *Collector) activities(userID int) ([]models.Activity, error) {
+	visibilities := []string{models.VisibilityPublic, models.VisibilityFollowers, models.VisibilityPrivate}
+	list := []models.Activity{}
+	var after *cursor.Cursor
+	for {
+		page, err := c.socialStore.GetUserActivities(userID, userID, visibilities, after, activityPage)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, page...)
+		if len(page) < activityPage {
+			return list, nil
+		}
+		last := page[len(page)-1]
+		after = &cursor.Cursor{At: last.OccurredAt, ID: last.ID}
+	}
+}
+
+// auditEvents returns every audit event about the user, newest first.
+func (c *Collector) auditEvents(userID int) ([]models.AuditEvent, error) {
+	filter := models.AuditFilter{SubjectID: &userID}
+	list := []models.AuditEvent{}
+	for {
+		page, err := c.auditStore.GetEvents(filter, auditPage)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, page...)
+		if len(page) < auditPage {
+			return list, nil
+		}
+		filter.BeforeID = page[len(page)-1].ID
+	}
+}
+
+func profileSection(u *models.User) section {
+	p := profile{
+		ID:         u.ID,
+		Email:      u.Email,
+		Username:   u.Username,
+		CreatedAt:  u.CreatedAt,
+		IsActive:   u.IsActive,
+		IsMale:     u.IsMale,
+		Age:        u.Age,
+		Height:     u.Height,
+		Weight:     u.Weight,
+		Goal:       u.Goal,
+		WeightGoal: u.WeightGoal,
+	}
+	return section{
+		name: "profile",
+		data: p,
+		header: []string{
+			"id", "email", "username", "created_at", "is_active", "is_male", "age", "height", "weight", "goal",
+			"weight_goal",
+		},
+		rows: [][]string{
+			{
+				itoa(p.ID), p.Email, p.Username, timestamp(p.CreatedAt), strconv.FormatBool(p.IsActive),
+				strconv.FormatBool(p.IsMale), itoa(p.Age), itoa(p.Height), itoa(p.Weight), p.Goal, itoa(p.WeightGoal),
+			},
+		},
+	}
+}
+
+func workoutsSection(list []models.Workout) section {
+	s := section{
+		name: "workouts",
+		data: list,
+		header: []string{
+			"id", "name", "started_at", "duration", "notes", "source", "distance", "elevation_gain",
+			"avg_heart_rate", "max_heart_rate", "avg_cadence",
+		},
+	}
+	for _, w := range list {
+		s.rows = append(
+			s.rows, []string{
+				itoa(w.ID), w.Name, timestamp(w.StartedAt), itoa(w.Duration), w.Notes, w.Source, itoa(w.Distance),
+				itoa(w.ElevationGain), optInt(w.AvgHeartRate), optInt(w.MaxHeartRate), optInt(w.AvgCadence),
+			},
+		)
+	}
+	return s
+}
+
+// setsSection is CSV only, the JSON file of workouts already nests the sets.
+func setsSection(list []models.Workout) section {
+	s := section{
+		name:   "workout_sets",
+		header: []string{"workout_id", "exercise_id", "position", "reps", "weight", "rpe", "duration", "distance"},
+	}
+	for _, w := range list {
+		for _, set := range w.Sets {
+			s.rows = append(
+				s.rows, []string{
+					itoa(set.WorkoutID), itoa(set.ExerciseID), itoa(set.Position), itoa(set.Reps), ftoa(set.Weight),
+					optFloat(set.RPE), itoa(set.Duration), itoa(set.Distance),
+				},
+			)
+		}
+	}
+	return s
+}
+
+func recordsSection(records []models.PersonalRecord) section {
+	s := section{
+		name:   "personal_records",
+		data:   records,
+		header: []string{"exercise_id", "kind", "value", "workout_id", "achieved_at"},
+	}
+	for _, r := range records {
+		s.rows = append(
+			s.rows, []string{itoa(r.ExerciseID), r.Kind, ftoa(r.Value), optInt(r.WorkoutID), timestamp(r.AchievedAt)},
+		)
+	}
+	return s
+}
+
+func diarySection(entries []models.DiaryEntry) section {
+	s := section{
+		name:   "diary",
+		data:   entries,
+		header: []string{"id", "eaten_at", "meal", "name", "kcal", "protein", "carbs", "fat"},
+	}
+	for _, e := range entries {
+		s.rows = append(
+			s.rows, []string{
+				itoa(e.ID), timestamp(e.EatenAt), e.Meal, e.Name, ftoa(e.Kcal), ftoa(e.Protein), ftoa(e.Carbs),
+				ftoa(e.Fat),
+			},
+		)
+	}
+	return s
+}
+
+func weightSection(entries []models.WeightEntry) section {
+	s := section{
+		name:   "weight_log",
+		data:   entries,
+		header: []string{"id", "measured_at", "weight", "note"},
+	}
+	for _, e := range entries {
+		s.rows = append(s.rows, []string{itoa(e.ID), timestamp(e.MeasuredAt), ftoa(e.Weight), e.Note})
+	}
+	return s
+}
+
+func measurementsSection(list []models.BodyMeasurement) section {
+	s := section{
+		name: "body_measurements",
+		data: list,
+		header: []string{
+			"id", "measured_at", "waist", "hips", "chest", "arm", "thigh", "body_fat", "body_fat_method",
+		},
+	}
+	for _, m := range list {
+		method := ""
+		if m.BodyFatMethod != nil {
+			method = *m.BodyFatMethod
+		}
+		s.rows = append(
+			s.rows, []string{
+				itoa(m.ID), timestamp(m.MeasuredAt), optFloat(m.Waist), optFloat(m.Hips), optFloat(m.Chest),
+				optFloat(m.Arm), optFloat(m.Thigh), optFloat(m.BodyFat), method,
+			},
+		)
+	}
+	return s
+}
+
+func metricsSection(list []models.DailyMetric) section {
+	s := section{
+		name:   "daily_metrics",
+		data:   list,
+		header: []string{"date", "metric", "value", "source", "updated_at"},
+	}
+	for _, m := range list {
+		s.rows = append(s.rows, []string{m.Date, m.Metric, ftoa(m.Value), m.Source, timestamp(m.UpdatedAt)})
+	}
+	return s
+}
+
+func metricGoalsSection(list []models.MetricGoal) section {
+	s := section{
+		name:   "metric_goals",
+		data:   list,
+		header: []string{"metric", "target"},
+	}
+	for _, g := range list {
+		s.rows = append(s.rows, []string{g.Metric, ftoa(g.Target)})
+	}
+	return s
+}
+
+// photosSection lists the photo metadata only; the images themselves stay in the blob storage.
+func photosSection(list []models.ProgressPhoto) section {
+	s := section{
+		name:   "progress_photos",
+		data:   list,
+		header: []string{"id", "taken_at", "pose", "content_type", "width", "height", "size"},
+	}
+	for _, p := range list {
+		s.rows = append(
+			s.rows, []string{
+				itoa(p.ID), timestamp(p.TakenAt), p.Pose, p.ContentType, itoa(p.Width), itoa(p.Height), itoa(p.Size),
+			},
+		)
+	}
+	return s
+}
+
+func goalsSection(list []models.Goal) section {
+	s := section{
+		name: "goals",
+		data: list,
+		header: []string{
+			"id", "kind", "target", "start_value", "starts_on", "ends_on", "status", "progress", "created_at",
+			"completed_at",
+		},
+	}
+	for _, g := range list {
+		s.rows = append(
+			s.rows, []string{
+				itoa(g.ID), g.Kind, ftoa(g.Target), optFloat(g.StartValue), g.StartsOn, g.EndsOn, g.Status,
+				ftoa(g.Progress), timestamp(g.CreatedAt), optTimestamp(g.CompletedAt),
+			},
+		)
+	}
+	return s
+}
+
+func followsSection(list []models.Follow) section {
+	s := section{
+		name:   "follows",
+		data:   list,
+		header: []string{"follower_id", "followee_id", "username", "status", "created_at", "accepted_at"},
+	}
+	for _, f := range list {
+		s.rows = append(
+			s.rows, []string{
+				itoa(f.FollowerID), itoa(f.FolloweeID), f.Username, f.Status, timestamp(f.CreatedAt),
+				optTimestamp(f.AcceptedAt),
+			},
+		)
+	}
+	return s
+}
+
+func activitiesSection(list []models.Activity) section {
+	s := section{
+		name: "activities",
+		data: list,
+		header: []string{
+			"id", "kind", "visibility", "occurred_at", "kudos_count", "comments_count", "payload",
+		},
+	}
+	for _, a := range list {
+		s.rows = append(
+			s.rows, []string{
+				strconv.FormatInt(a.ID, 10), a.Kind, a.Visibility, timestamp(a.OccurredAt), itoa(a.KudosCount),
+				itoa(a.CommentsCount), string(a.Payload),
+			},
+		)
+	}
+	return s
+}
+
+func commentsSection(list []models.Comment) section {
+	s := section{
+		name:   "comments",
+		data:   list,
+		header: []string{"id", "activity_id", "body", "created_at"},
+	}
+	for _, c := range list {
+		s.rows = append(
+			s.rows, []string{
+				strconv.FormatInt(c.ID, 10), strconv.FormatInt(c.ActivityID, 10), c.Body, timestamp(c.CreatedAt),
+			},
+		)
+	}
+	return s
+}
+
+// coachNotesSection holds the notes the user wrote as a coach and those coaches left for them.
+func coachNotesSection(list []models.CoachNote) section {
+	s := section{
+		name:   "coach_notes",
+		data:   list,
+		header: []string{"id", "coach_id", "client_id", "body", "created_at"},
+	}
+	for _, n := range list {
+		s.rows = append(
+			s.rows, []string{itoa(n.ID), itoa(n.CoachID), itoa(n.ClientID), n.Body, timestamp(n.CreatedAt)},
+		)
+	}
+	return s
+}
+
+// sessionsSection is CSV only, the JSON form of a session hides when it was revoked.
+func sessionsSection(list []models.Session) section {
+	s := section{
+		name: "sessions",
+		header: []string{
+			"id", "device_name", "user_agent", "ip", "created_at", "last_active_at", "expires_at", "revoked_at",
+		},
+	}
+	for _, session := range list {
+		s.rows = append(
+			s.rows, []string{
+				strconv.FormatInt(session.ID, 10), session.DeviceName, session.UserAgent, session.IP,
+				timestamp(session.CreatedAt), timestamp(session.LastActiveAt), timestamp(session.ExpiresAt),
+				optTimestamp(session.RevokedAt),
+			},
+		)
+	}
+	return s
+}
+
+// tokensSection lists the personal access tokens without their secret, which is never stored.
+func tokensSection(list []models.PersonalToken) section {
+	s := section{
+		name:   "personal_tokens",
+		data:   list,
+		header: []string{"id", "name", "hint", "scopes", "expires_at", "last_used_at", "created_at"},
+	}
+	for _, t := range list {
+		s.rows = append(
+			s.rows, []string{
+				itoa(t.ID), t.Name, t.Hint, strings.Join(t.Scopes, " "), optTimestamp(t.ExpiresAt),
+				optTimestamp(t.LastUsedAt), timestamp(t.CreatedAt),
+			},
+		)
+	}
+	return s
+}
+
+// auditSection lists the security-relevant events about the user, such as logins and password
+// changes, with the request they came from.
+func auditSection(list []models.AuditEvent) section {
+	s := section{
+		name:   "audit_events",
+		data:   list,
+		header: []string{"id", "occurred_at", "action", "actor_id", "ip", "user_agent", "details"},
+	}
+	for _, e := range list {
+		s.rows = append(
+			s.rows, []string{
+				strconv.FormatInt(e.ID, 10), timestamp(e.OccurredAt), e.Action, optInt(e.ActorID), e.IP, e.UserAgent,
+				string(e.Details),
+			},
+		)
+	}
+	return s
+}
+
+func writeArchive(w io.Writer, sections []section) error {
+	zw := zip.NewWriter(w)
+
+	for _, s := range sections {
+		if s.data != nil {
+			f, err := zw.Create(s.name + ".json")
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(s.data); err != nil {
+				return err
+			}
+		}
+
+		if s.header != nil {
+			f, err := zw.Create(s.name + ".csv")
+			if err != nil {
+				return err
+			}
+			cw := csv.NewWriter(f)
+			if err := cw.Write(s.header); err != nil {
+				return err
+			}
+			if err := cw.WriteAll(s.rows); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+func itoa(v int) string {
+	return strconv.Itoa(v)
+}
+
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func optInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return itoa(*v)
+}
+
+func optFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return ftoa(*v)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func optTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return timestamp(*t)
+}
@@ -0,0 +1,288 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/coaching"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/mealplans"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/metrics"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/preferences"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fakes embed the store interfaces and implement only what the collector reads;
+// anything else panics on the nil interface.
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id, Email: "ann@example.com", Username: "ann", Password: []byte("password-hash")}, nil
+}
+
+type fakePrefs struct{ preferences.PreferencesStore }
+
+func (fakePrefs) GetPreferences(int) (*models.Preferences, error) {
+	return &models.Preferences{}, nil
+}
+
+type fakeWorkouts struct{ workouts.WorkoutStore }
+
+func (fakeWorkouts) GetWorkoutsBetween(int, time.Time, time.Time) ([]models.Workout, error) {
+	return nil, nil
+}
+
+func (fakeWorkouts) GetRecords(int) ([]models.PersonalRecord, error) {
+	return nil, nil
+}
+
+type fakeDiary struct{ diary.DiaryStore }
+
+func (fakeDiary) GetEntries(int, time.Time, time.Time) ([]models.DiaryEntry, error) {
+	return nil, nil
+}
+
+type fakeRecipes struct{ recipes.RecipeStore }
+
+func (fakeRecipes) GetRecipes(int) ([]models.Recipe, error) {
+	return nil, nil
+}
+
+type fakeMealPlans struct{ mealplans.MealPlanStore }
+
+func (fakeMealPlans) GetPlans(int) ([]models.MealPlan, error) {
+	return nil, nil
+}
+
+type fakeMetrics struct{ metrics.MetricStore }
+
+func (fakeMetrics) GetHistory(int) ([]models.DailyMetric, error) {
+	return nil, nil
+}
+
+func (fakeMetrics) GetGoals(int) ([]models.MetricGoal, error) {
+	return nil, nil
+}
+
+type fakeBody struct{ body.BodyStore }
+
+func (fakeBody) GetWeightEntries(int, time.Time, time.Time) ([]models.WeightEntry, error) {
+	return nil, nil
+}
+
+func (fakeBody) GetMeasurements(int, time.Time, time.Time) ([]models.BodyMeasurement, error) {
+	return nil, nil
+}
+
+func (fakeBody) GetPhotos(int, time.Time, time.Time) ([]models.ProgressPhoto, error) {
+	return nil, nil
+}
+
+type fakeGoals struct{ goals.GoalStore }
+
+func (fakeGoals) GetGoals(int, string) ([]models.Goal, error) {
+	return []models.Goal{{ID: 3, Kind: "weight"}}, nil
+}
+
+func (fakeGoals) GetMilestones(goalID int) ([]models.GoalMilestone, error) {
+	return []models.GoalMilestone{{GoalID: goalID, Percent: 50}}, nil
+}
+
+// fakeSocial holds n activities with descending ids and serves them in pages like the store does.
+type fakeSocial struct {
+	social.SocialStore
+	n     int
+	pages int
+}
+
+func (*fakeSocial) GetSettings(int) (*models.SocialSettings, error) {
+	return &models.SocialSettings{DefaultVisibility: models.VisibilityPublic}, nil
+}
+
+func (*fakeSocial) GetFollowers(userID int, status string) ([]models.Follow, error) {
+	return []models.Follow{{FollowerID: 2, FolloweeID: userID, Status: status}}, nil
+}
+
+func (*fakeSocial) GetFollowing(userID int) ([]models.Follow, error) {
+	return []models.Follow{{FollowerID: userID, FolloweeID: 2, Status: models.FollowAccepted}}, nil
+}
+
+func (f *fakeSocial) GetUserActivities(
+	viewerID int, userID int, visibilities []string, after *cursor.Cursor, limit int,
+) ([]models.Activity, error) {
+	f.pages++
+	next := int64(f.n)
+	if after != nil {
+		next = after.ID - 1
+	}
+	page := []models.Activity{}
+	for ; next > 0 && len(page) < limit; next-- {
+		page = append(page, models.Activity{ID: next, UserID: userID, Visibility: visibilities[int(next)%3]})
+	}
+	return page, nil
+}
+
+func (*fakeSocial) GetUserComments(int) ([]models.Comment, error) {
+	return nil, nil
+}
+
+type fakeCoaching struct{ coaching.CoachingStore }
+
+func (fakeCoaching) GetUserNotes(int) ([]models.CoachNote, error) {
+	return nil, nil
+}
+
+type fakeSessions struct{ sessions.SessionStore }
+
+func (fakeSessions) GetSessionHistory(int) ([]models.Session, error) {
+	revokedAt := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	return []models.Session{{ID: 9, RevokedAt: &revokedAt}}, nil
+}
+
+type fakeTokens struct{ tokens.TokenStore }
+
+func (fakeTokens) GetTokens(int) ([]models.PersonalToken, error) {
+	return []models.PersonalToken{{ID: 4, Name: "script", Hint: "afp_abcd", Scopes: []string{"profile"}}}, nil
+}
+
+type fakeAudit struct{ audit.AuditStore }
+
+func (fakeAudit) GetEvents(models.AuditFilter, int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+
+func newTestCollector(s *fakeSocial) *Collector {
+	return NewCollector(
+		fakeUsers{}, fakePrefs{}, fakeWorkouts{}, fakeDiary{}, fakeRecipes{}, fakeMealPlans{}, fakeMetrics{},
+		fakeBody{}, fakeGoals{}, s, fakeCoaching{}, fakeSessions{}, fakeTokens{}, fakeAudit{},
+	)
+}
+
+func readArchive(t *testing.T, userID int, c *Collector) map[string]string {
+	t.Helper()
+	sections, err := c.collect(userID)
+	if err != nil {
+		t.Fatalf("collect() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, sections); err != nil {
+		t.Fatalf("writeArchive() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestArchiveFiles(t *testing.T) {
+	files := readArchive(t, 1, newTestCollector(&fakeSocial{n: 2}))
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{
+		"activities.csv", "activities.json", "audit_events.csv", "audit_events.json", "body_measurements.csv",
+		"body_measurements.json", "coach_notes.csv", "coach_notes.json", "comments.csv", "comments.json",
+		"daily_metrics.csv", "daily_metrics.json", "diary.csv", "diary.json", "follows.csv", "follows.json",
+		"goals.csv", "goals.json", "meal_plans.json", "metric_goals.csv", "metric_goals.json",
+		"personal_records.csv", "personal_records.json", "personal_tokens.csv", "personal_tokens.json",
+		"preferences.json", "profile.csv", "profile.json", "progress_photos.csv", "progress_photos.json",
+		"recipes.json", "sessions.csv", "social_settings.json", "weight_log.csv", "weight_log.json",
+		"workout_sets.csv", "workouts.csv", "workouts.json",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("archive files = %v, want %v", names, want)
+	}
+
+	for name, data := range files {
+		if strings.Contains(data, "password-hash") {
+			t.Errorf("%s contains the password hash: %s", name, data)
+		}
+	}
+	if !strings.Contains(files["personal_tokens.csv"], "afp_abcd") {
+		t.Errorf("personal_tokens.csv misses the token hint: %s", files["personal_tokens.csv"])
+	}
+	if !strings.Contains(files["sessions.csv"], "2024-05-02T00:00:00Z") {
+		t.Errorf("sessions.csv misses revoked_at: %s", files["sessions.csv"])
+	}
+	if got := strings.Count(files["follows.csv"], "\n"); got != 4 {
+		t.Errorf("follows.csv has %d lines, want a header, the followee and both kinds of followers", got)
+	}
+
+	var list []models.Goal
+	if err := json.Unmarshal([]byte(files["goals.json"]), &list); err != nil {
+		t.Fatalf("goals.json: %v", err)
+	}
+	if len(list) != 1 || len(list[0].Milestones) != 1 {
+		t.Errorf("goals.json = %+v, want the goal with its milestone", list)
+	}
+}
+
+func TestArchiveActivitiesPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		wantPages int
+	}{
+		{name: "empty", n: 0, wantPages: 1},
+		{name: "partial page", n: 3, wantPages: 1},
+		{name: "full page", n: activityPage, wantPages: 2},
+		{name: "several pages", n: 2*activityPage + 1, wantPages: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				s := &fakeSocial{n: tt.n}
+				list, err := newTestCollector(s).activities(1)
+				if err != nil {
+					t.Fatalf("activities() error = %v", err)
+				}
+				if len(list) != tt.n {
+					t.Errorf("activities() returned %d activities, want %d", len(list), tt.n)
+				}
+				for i, a := range list {
+					if a.ID != int64(tt.n-i) {
+						t.Fatalf("activities()[%d].ID = %d, want %d", i, a.ID, tt.n-i)
+					}
+				}
+				if s.pages != tt.wantPages {
+					t.Errorf("activities() read %d pages, want %d", s.pages, tt.wantPages)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,143 @@
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/exports"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+type Handler struct {
+	store    exports.ExportStore
+	worker   *Worker
+	storage  blob.Storage
+	recorder *audit.Recorder
+	log      *slog.Logger
+	cfg      config.Config
+}
+
+func NewHandler(
+	store exports.ExportStore, worker *Worker, storage blob.Storage, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, worker: worker, storage: storage, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleCreateExport(w http.ResponseWriter, r *http.Request) {
+	const op = "exports.HandleCreateExport"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetExports(user.ID)
+	if err != nil {
+		log.Error("failed to get exports", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	for _, e := range list {
+		if e.Status == models.ExportPending || e.Status == models.ExportProcessing {
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": "an export is already in progress"})
+			return
+		}
+	}
+
+	e, err := h.store.CreateExport(user.ID)
+	if err != nil {
+		log.Error("failed to save export request", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	h.worker.Notify()
+
+	h.recorder.Record(r, models.AuditExportRequested, user.ID, user.ID, map[string]int{"export_id": e.ID})
+	log.Info("export requested", slog.Int("export_id", e.ID))
+	resp.JSON(w, r, http.StatusAccepted, e)
+}
+
+func (h *Handler) HandleGetExports(w http.ResponseWriter, r *http.Request) {
+	const op = "exports.HandleGetExports"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetExports(user.ID)
+	if err != nil {
+		log.Error("failed to get exports", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleDownload serves the archive behind an emailed link. The token in the link is the only
+// credential, so the route is public and the link stops working once it expires.
+func (h *Handler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	const op = "exports.HandleDownload"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	e, err := h.store.GetExportByToken(tokenhash.Sum(chi.URLParam(r, "token")))
+	if err != nil {
+		if errors.Is(err, exports.ExportNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get export", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if e.Status != models.ExportReady || e.ExpiresAt == nil || e.ExpiresAt.Before(time.Now()) {
+		resp.JSON(w, r, http.StatusGone, map[string]string{"error": "export link expired"})
+		return
+	}
+
+	f, err := h.storage.Get(r.Context(), e.BlobKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": exports.ExportNotFound.Error()})
+			return
+		}
+		log.Error("failed to read export", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	defer f.Close()
+
+	// The link may have been forwarded, so whoever downloads is unknown.
+	h.recorder.Record(r, models.AuditExportDownloaded, 0, e.UserID, map[string]int{"export_id": e.ID})
+	log.Info("export downloaded", slog.Int("export_id", e.ID), slog.Int("user_id", e.UserID))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="atom-fit-export-%s.zip"`, e.CreatedAt.Format("2006-01-02")),
+	)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Warn("failed to send export", sl.Err(err))
+	}
+}
@@ -0,0 +1,164 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/email"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/exports"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+	"time"
+)
+
+const (
+	pollInterval = time.Minute
+	// claimLease is how long an export may take to build before another worker takes it over.
+	claimLease = 30 * time.Minute
+)
+
+// Worker builds the requested archives in the background, emails the download link
+// and deletes archives whose link expired.
+type Worker struct {
+	store     exports.ExportStore
+	userStore users.UserStore
+	collector *Collector
+	storage   blob.Storage
+	log       *slog.Logger
+	cfg       config.Export
+	appURL    string
+	wake      chan struct{}
+}
+
+func NewWorker(
+	store exports.ExportStore, userStore users.UserStore, collector *Collector, storage blob.Storage, log *slog.Logger,
+) *Worker {
+	return &Worker{
+		store:     store,
+		userStore: userStore,
+		collector: collector,
+		storage:   storage,
+		log:       log.With(slog.String("component", "exports/worker")),
+		cfg:       config.Envs.Export,
+		appURL:    config.Envs.AppURL,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the worker up without waiting for the next poll.
+func (wk *Worker) Notify() {
+	select {
+	case wk.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wk.expire(ctx)
+		wk.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wk.wake:
+		}
+	}
+}
+
+func (wk *Worker) expire(ctx context.Context) {
+	keys, err := wk.store.ExpireExports(time.Now())
+	if err != nil {
+		wk.log.Error("failed to expire exports", sl.Err(err))
+		return
+	}
+	for _, key := range keys {
+		if err := wk.storage.Delete(ctx, key); err != nil {
+			wk.log.Error("failed to delete expired export", sl.Err(err), slog.String("key", key))
+		}
+	}
+}
+
+func (wk *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		e, err := wk.store.ClaimPending(claimLease)
+		if err != nil {
+			if !errors.Is(err, exports.ExportNotFound) {
+				wk.log.Error("failed to claim export", sl.Err(err))
+			}
+			return
+		}
+
+		log := wk.log.With(slog.Int("export_id", e.ID), slog.Int("user_id", e.UserID))
+		if err := wk.process(ctx, e); err != nil {
+			log.Error("export failed", sl.Err(err))
+			if err := wk.store.FailExport(e.ID, "failed to build the archive"); err != nil {
+				log.Error("failed to mark export as failed", sl.Err(err))
+			}
+			continue
+		}
+		log.Info("export ready")
+	}
+}
+
+func (wk *Worker) process(ctx context.Context, e *models.DataExport) error {
+	const op = "exports.Worker.process"
+
+	sections, err := wk.collector.collect(e.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, sections); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	key := fmt.Sprintf("exports/%d/%d.zip", e.UserID, e.ID)
+	if err := wk.storage.Put(ctx, key, buf.Bytes(), "application/zip"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	expiresAt := time.Now().Add(wk.cfg.TTL)
+	if err := wk.store.CompleteExport(e.ID, tokenhash.Sum(token), key, expiresAt); err != nil {
+		if err := wk.storage.Delete(ctx, key); err != nil {
+			wk.log.Warn("failed to delete blob", slog.String("key", key), sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u, err := wk.userStore.GetUserByID(e.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	link := wk.appURL + "/api/exports/" + token
+	if err := email.SendDataExport(u.Username, u.Email, link, expiresAt); err != nil {
+		wk.log.Error("failed to send export email", sl.Err(err), slog.Int("export_id", e.ID))
+	}
+
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
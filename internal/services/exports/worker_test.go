@@ -0,0 +1,62 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/blob"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/exports"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeExports struct {
+	exports.ExportStore
+	expired []string
+}
+
+func (f *fakeExports) ExpireExports(time.Time) ([]string, error) {
+	keys := f.expired
+	f.expired = nil
+	return keys, nil
+}
+
+type memStorage map[string][]byte
+
+func (m memStorage) Put(_ context.Context, key string, data []byte, _ string) error {
+	m[key] = data
+	return nil
+}
+
+func (m memStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m memStorage) Delete(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestWorkerExpireDeletesArchives(t *testing.T) {
+	storage := memStorage{"exports/1/10.zip": nil, "exports/1/11.zip": nil, "exports/2/12.zip": nil}
+	store := &fakeExports{expired: []string{"exports/1/10.zip", "exports/2/12.zip"}}
+	wk := NewWorker(store, nil, nil, storage, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	wk.expire(context.Background())
+
+	keys := make([]string, 0, len(storage))
+	for key := range storage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := []string{"exports/1/11.zip"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("blobs left after expire = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,223 @@
+package foods
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type Handler struct {
+	store    foods.FoodStore
+	recorder *audit.Recorder
+	log      *slog.Logger
+	cfg      config.Config
+}
+
+func NewHandler(store foods.FoodStore, recorder *audit.Recorder, log *slog.Logger) *Handler {
+	return &Handler{store: store, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleSearchFoods(w http.ResponseWriter, r *http.Request) {
+	const op = "foods.HandleSearchFoods"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "query parameter q is required"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxSearchLimit)
+	}
+
+	list, err := h.store.SearchFoods(user.ID, q, limit)
+	if err != nil {
+		log.Error("failed to search foods", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetFoodByBarcode(w http.ResponseWriter, r *http.Request) {
+	const op = "foods.HandleGetFoodByBarcode"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	barcode, ok := nutrition.NormalizeBarcode(chi.URLParam(r, "ean"))
+	if !ok {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid barcode"})
+		return
+	}
+
+	food, err := h.store.GetFoodByBarcode(user.ID, barcode)
+	if err != nil {
+		if errors.Is(err, foods.FoodNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get food", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, food)
+}
+
+func (h *Handler) HandleGetFood(w http.ResponseWriter, r *http.Request) {
+	const op = "foods.HandleGetFood"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid food id"})
+		return
+	}
+
+	food, err := h.store.GetFoodByID(user.ID, id)
+	if err != nil {
+		if errors.Is(err, foods.FoodNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get food", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, food)
+}
+
+// HandleCreateFood saves a private custom food of the authenticated user.
+func (h *Handler) HandleCreateFood(w http.ResponseWriter, r *http.Request) {
+	const op = "foods.HandleCreateFood"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateFoodPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if payload.Barcode != nil {
+		barcode, ok := nutrition.NormalizeBarcode(*payload.Barcode)
+		if !ok {
+			resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "invalid barcode"})
+			return
+		}
+		payload.Barcode = &barcode
+	}
+	payload.Nutrients = nutrition.Round(payload.Nutrients)
+
+	food, err := h.store.CreateFood(user.ID, payload)
+	if err != nil {
+		log.Error("failed to save food", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusCreated, food)
+}
+
+// HandlePublishFood moves a custom food of any user to the shared database. The route requires
+// the foods:publish permission.
+func (h *Handler) HandlePublishFood(w http.ResponseWriter, r *http.Request) {
+	const op = "foods.HandlePublishFood"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid food id"})
+		return
+	}
+
+	food, err := h.store.PublishFood(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, foods.FoodNotFound):
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, foods.FoodExists):
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Error("failed to publish food", sl.Err(err))
+			resp.Internal(w, r)
+		}
+		return
+	}
+
+	h.recorder.Record(r, models.AuditFoodPublished, user.ID, 0, map[string]any{"food_id": food.ID, "name": food.Name})
+	log.Info("food published", slog.Int("food_id", food.ID))
+	resp.JSON(w, r, http.StatusOK, food)
+}
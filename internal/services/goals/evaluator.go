@@ -0,0 +1,223 @@
+package goals
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/coalesce"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/goal"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/body"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/diary"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/metrics"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"log/slog"
+	"time"
+)
+
+// offTrackNoticeInterval is the minimum time between two off track emails for the same goal.
+const offTrackNoticeInterval = 7 * 24 * time.Hour
+
+// Evaluator recomputes the progress of the active goals from the logged data, records the
+// milestones and publishes the goal events.
+type Evaluator struct {
+	store        goals.GoalStore
+	userStore    users.UserStore
+	workoutStore workouts.WorkoutStore
+	diaryStore   diary.DiaryStore
+	metricStore  metrics.MetricStore
+	bodyStore    body.BodyStore
+	bus          *events.Bus
+	log          *slog.Logger
+	running      coalesce.Group
+}
+
+func NewEvaluator(
+	store goals.GoalStore, userStore users.UserStore, workoutStore workouts.WorkoutStore, diaryStore diary.DiaryStore,
+	metricStore metrics.MetricStore, bodyStore body.BodyStore, bus *events.Bus, log *slog.Logger,
+) *Evaluator {
+	return &Evaluator{
+		store:        store,
+		userStore:    userStore,
+		workoutStore: workoutStore,
+		diaryStore:   diaryStore,
+		metricStore:  metricStore,
+		bodyStore:    bodyStore,
+		bus:          bus,
+		log:          log.With(slog.String("component", "goals/evaluator")),
+	}
+}
+
+// Subscribe re-evaluates the user's goals whenever data feeding them is logged, changed or deleted.
+func (e *Evaluator) Subscribe(bus *events.Bus) {
+	for _, t := range []string{
+		events.WorkoutCreated, events.WorkoutUpdated, events.WorkoutDeleted, events.WeightLogged, events.WeightUpdated,
+		events.WeightDeleted, events.MetricsLogged, events.DiaryLogged, events.DiaryUpdated, events.DiaryDeleted,
+	} {
+		bus.Subscribe(t, func(ev events.Event) { e.Refresh(ev.UserID) })
+	}
+}
+
+// Refresh evaluates the user's goals. Calls arriving while an evaluation of the same user runs,
+// e.g. during an import, are folded into a single evaluation that follows it.
+func (e *Evaluator) Refresh(userID int) {
+	e.running.Do(
+		userID, func() {
+			if _, err := e.EvaluateUser(userID, time.Now()); err != nil {
+				e.log.Error("failed to evaluate goals", sl.Err(err), slog.Int("user_id", userID))
+			}
+		},
+	)
+}
+
+// EvaluateUser evaluates the active goals of the user and returns them updated.
+func (e *Evaluator) EvaluateUser(userID int, now time.Time) ([]models.Goal, error) {
+	const op = "goals.EvaluateUser"
+
+	list, err := e.store.GetGoals(userID, models.GoalStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range list {
+		if err := e.evaluate(&list[i], now); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return list, nil
+}
+
+func (e *Evaluator) evaluate(g *models.Goal, now time.Time) error {
+	data, err := e.data(*g)
+	if err != nil {
+		return err
+	}
+
+	res := goal.Evaluate(*g, data)
+	g.Progress = res.Progress
+	g.Current = &res.Current
+	g.OffTrack = goal.OffTrack(*g, res.Progress, now)
+	g.EvaluatedAt = &now
+
+	for _, percent := range goal.Reached(res.Progress) {
+		added, err := e.store.AddMilestone(g.ID, percent)
+		if err != nil {
+			return err
+		}
+		if added {
+			e.bus.Publish(events.GoalMilestone, g.UserID, models.GoalEvent{Goal: *g, Percent: percent})
+		}
+	}
+
+	if err := e.store.SaveProgress(*g); err != nil {
+		return err
+	}
+
+	switch {
+	case res.Progress >= 100:
+		return e.finish(g, models.GoalStatusCompleted, now)
+	case goal.Ended(*g, now):
+		return e.finish(g, models.GoalStatusMissed, now)
+	case g.OffTrack:
+		claimed, err := e.store.ClaimOffTrackNotice(g.ID, now.Add(-offTrackNoticeInterval))
+		if err != nil {
+			return err
+		}
+		if claimed {
+			e.bus.Publish(
+				events.GoalOffTrack, g.UserID, models.GoalEvent{Goal: *g, Expected: goal.Expected(*g, now)},
+			)
+		}
+	}
+	return nil
+}
+
+func (e *Evaluator) finish(g *models.Goal, status string, now time.Time) error {
+	finished, err := e.store.FinishGoal(g.ID, status)
+	if err != nil {
+		return err
+	}
+	g.Status = status
+	g.OffTrack = false
+	if status == models.GoalStatusCompleted {
+		g.CompletedAt = &now
+	}
+	if finished && status == models.GoalStatusCompleted {
+		e.bus.Publish(events.GoalCompleted, g.UserID, models.GoalEvent{Goal: *g, Percent: 100})
+	}
+	return nil
+}
+
+// data loads what the goal's kind is measured with over the goal's period.
+func (e *Evaluator) data(g models.Goal) (goal.Data, error) {
+	from, to, err := goal.Span(g)
+	if err != nil {
+		return goal.Data{}, err
+	}
+
+	var d goal.Data
+	switch g.Kind {
+	case models.GoalTargetWeight:
+		d.Weight, err = e.CurrentWeight(g.UserID)
+		if err != nil {
+			return goal.Data{}, err
+		}
+
+	case models.GoalWorkoutsPerWeek:
+		list, err := e.workoutStore.GetWorkoutsBetween(g.UserID, from, to)
+		if err != nil {
+			return goal.Data{}, err
+		}
+		for _, w := range list {
+			d.Workouts = append(d.Workouts, w.StartedAt)
+		}
+
+	case models.GoalDailySteps:
+		list, err := e.metricStore.GetMetrics(g.UserID, g.StartsOn, g.EndsOn)
+		if err != nil {
+			return goal.Data{}, err
+		}
+		d.Daily = make(map[string]float64)
+		for _, m := range list {
+			if m.Metric == models.MetricSteps {
+				d.Daily[m.Date] = m.Value
+			}
+		}
+
+	case models.GoalProteinPerDay:
+		list, err := e.diaryStore.GetEntries(g.UserID, from, to)
+		if err != nil {
+			return goal.Data{}, err
+		}
+		d.Daily = make(map[string]float64)
+		for _, entry := range list {
+			d.Daily[entry.EatenAt.UTC().Format(daterange.Layout)] += entry.Protein
+		}
+	}
+	return d, nil
+}
+
+// CurrentWeight is the latest logged weight, falling back to the weight of the profile.
+func (e *Evaluator) CurrentWeight(userID int) (*float64, error) {
+	entry, err := e.bodyStore.GetLatestWeightEntry(userID)
+	if err == nil {
+		return &entry.Weight, nil
+	}
+	if !errors.Is(err, body.WeightEntryNotFound) {
+		return nil, err
+	}
+
+	user, err := e.userStore.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Weight <= 0 {
+		return nil, nil
+	}
+	weight := float64(user.Weight)
+	return &weight, nil
+}
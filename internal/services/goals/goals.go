@@ -0,0 +1,231 @@
+package goals
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/goal"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// maxActiveGoals bounds the goals evaluated on every logged workout, meal or metric.
+const maxActiveGoals = 20
+
+type Handler struct {
+	store     goals.GoalStore
+	evaluator *Evaluator
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(store goals.GoalStore, evaluator *Evaluator, log *slog.Logger) *Handler {
+	return &Handler{store: store, evaluator: evaluator, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleCreateGoal(w http.ResponseWriter, r *http.Request) {
+	const op = "goals.HandleCreateGoal"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateGoalPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	g := models.Goal{
+		UserID: user.ID, Kind: payload.Kind, Target: payload.Target, StartsOn: payload.StartsOn, EndsOn: payload.EndsOn,
+	}
+	if g.StartsOn == "" {
+		today, err := daterange.Today(r.URL.Query())
+		if err != nil {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		g.StartsOn = today.Format(daterange.Layout)
+	}
+	if g.Kind == models.GoalTargetWeight {
+		g.StartValue, err = h.evaluator.CurrentWeight(user.ID)
+		if err != nil {
+			log.Error("failed to get current weight", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+	}
+
+	if err := goal.Validate(g); err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	active, err := h.store.GetGoals(user.ID, models.GoalStatusActive)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if len(active) >= maxActiveGoals {
+		resp.JSON(w, r, http.StatusConflict, map[string]string{"error": "too many active goals"})
+		return
+	}
+
+	if err := h.store.CreateGoal(&g); err != nil {
+		log.Error("failed to create goal", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	// Data logged before the goal was set may already count towards it.
+	if err := h.evaluator.evaluate(&g, time.Now()); err != nil {
+		log.Error("failed to evaluate goal", sl.Err(err))
+	}
+
+	log.Info("goal created", slog.Int("goal_id", g.ID), slog.String("kind", g.Kind))
+	resp.JSON(w, r, http.StatusCreated, g)
+}
+
+// HandleGetGoals lists the goals, optionally filtered by ?status=, with the active ones evaluated anew.
+func (h *Handler) HandleGetGoals(w http.ResponseWriter, r *http.Request) {
+	const op = "goals.HandleGetGoals"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.GoalStatusActive, models.GoalStatusCompleted, models.GoalStatusMissed, models.GoalStatusAbandoned:
+	default:
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+		return
+	}
+
+	if status == "" || status == models.GoalStatusActive {
+		if _, err := h.evaluator.EvaluateUser(user.ID, time.Now()); err != nil {
+			log.Error("failed to evaluate goals", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+	}
+
+	list, err := h.store.GetGoals(user.ID, status)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetGoal(w http.ResponseWriter, r *http.Request) {
+	const op = "goals.HandleGetGoal"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid goal id"})
+		return
+	}
+
+	g, err := h.store.GetGoal(user.ID, id)
+	if err != nil {
+		if errors.Is(err, goals.GoalNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get goal", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if g.Status == models.GoalStatusActive {
+		if err := h.evaluator.evaluate(g, time.Now()); err != nil {
+			log.Error("failed to evaluate goal", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+	}
+
+	g.Milestones, err = h.store.GetMilestones(g.ID)
+	if err != nil {
+		log.Error("failed to get milestones", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, g)
+}
+
+// HandleAbandonGoal stops tracking an active goal. It stays in the history as abandoned.
+func (h *Handler) HandleAbandonGoal(w http.ResponseWriter, r *http.Request) {
+	const op = "goals.HandleAbandonGoal"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid goal id"})
+		return
+	}
+
+	if err := h.store.AbandonGoal(user.ID, id); err != nil {
+		if errors.Is(err, goals.GoalNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to abandon goal", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
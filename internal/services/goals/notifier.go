@@ -0,0 +1,57 @@
+package goals
+
+import (
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/email"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/goal"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+)
+
+// Notifier emails users about the milestones of their goals and the goals falling behind.
+type Notifier struct {
+	userStore users.UserStore
+	log       *slog.Logger
+}
+
+func NewNotifier(userStore users.UserStore, log *slog.Logger) *Notifier {
+	return &Notifier{userStore: userStore, log: log.With(slog.String("component", "goals/notifier"))}
+}
+
+func (n *Notifier) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.GoalMilestone, n.handle)
+	bus.Subscribe(events.GoalOffTrack, n.handle)
+}
+
+func (n *Notifier) handle(e events.Event) {
+	payload, ok := e.Payload.(models.GoalEvent)
+	if !ok {
+		return
+	}
+	log := n.log.With(
+		slog.String("event", e.Type), slog.Int("user_id", e.UserID), slog.Int("goal_id", payload.Goal.ID),
+	)
+
+	user, err := n.userStore.GetUserByID(e.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return
+	}
+
+	title := goal.Title(payload.Goal)
+	switch e.Type {
+	case events.GoalMilestone:
+		err = email.SendGoalMilestone(user.Username, user.Email, title, payload.Percent)
+	case events.GoalOffTrack:
+		err = email.SendGoalOffTrack(
+			user.Username, user.Email, title, payload.Goal.Progress, payload.Expected, payload.Goal.EndsOn,
+		)
+	}
+	if err != nil {
+		log.Error("failed to send goal email", sl.Err(err))
+		return
+	}
+	log.Info("goal email sent")
+}
@@ -0,0 +1,62 @@
+package goals
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/goals"
+	"log/slog"
+	"time"
+)
+
+const (
+	pollInterval = time.Hour
+	batchSize    = 100
+)
+
+// Worker evaluates the goals nobody logged data for today, so that goals falling behind
+// and goals whose period ended are noticed without any activity of the user.
+type Worker struct {
+	store     goals.GoalStore
+	evaluator *Evaluator
+	log       *slog.Logger
+}
+
+func NewWorker(store goals.GoalStore, evaluator *Evaluator, log *slog.Logger) *Worker {
+	return &Worker{store: store, evaluator: evaluator, log: log.With(slog.String("component", "goals/worker"))}
+}
+
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wk.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (wk *Worker) drain(ctx context.Context) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	lastID := 0
+	for ctx.Err() == nil {
+		ids, err := wk.store.GetUsersToEvaluate(today, lastID, batchSize)
+		if err != nil {
+			wk.log.Error("failed to get users to evaluate", sl.Err(err))
+			return
+		}
+		for _, id := range ids {
+			wk.evaluator.Refresh(id)
+			lastID = id
+		}
+		if len(ids) < batchSize {
+			return
+		}
+	}
+}
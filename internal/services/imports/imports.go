@@ -0,0 +1,107 @@
+package imports
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tracks"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/imports"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const maxImportSize = 32 << 20
+
+type Handler struct {
+	store  imports.ImportStore
+	worker *Worker
+	log    *slog.Logger
+	cfg    config.Config
+}
+
+func NewHandler(store imports.ImportStore, worker *Worker, log *slog.Logger) *Handler {
+	return &Handler{store: store, worker: worker, log: log, cfg: config.Envs}
+}
+
+// HandleCreateImport accepts a multipart upload with a GPX, TCX or FIT file in the "file" field.
+// The file is parsed in the background, the returned import can be polled for its status.
+func (h *Handler) HandleCreateImport(w http.ResponseWriter, r *http.Request) {
+	const op = "imports.HandleCreateImport"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		log.Warn("failed to read uploaded file", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "field file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Warn("failed to read uploaded file", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to read file"})
+		return
+	}
+
+	format, err := tracks.DetectFormat(header.Filename, data)
+	if err != nil {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	imp, err := h.store.CreateImport(user.ID, header.Filename, format, data)
+	if err != nil {
+		log.Error("failed to save import", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	h.worker.Notify()
+
+	log.Info("import queued", slog.Int("import_id", imp.ID), slog.String("format", format))
+	resp.JSON(w, r, http.StatusAccepted, imp)
+}
+
+func (h *Handler) HandleGetImport(w http.ResponseWriter, r *http.Request) {
+	const op = "imports.HandleGetImport"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid import id"})
+		return
+	}
+
+	imp, err := h.store.GetImport(user.ID, id)
+	if err != nil {
+		if errors.Is(err, imports.ImportNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get import", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, imp)
+}
@@ -0,0 +1,157 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tracks"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/imports"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"log/slog"
+	"time"
+)
+
+const (
+	pollInterval = 30 * time.Second
+	// claimLease is how long an import may take to process before another worker takes it over.
+	claimLease = 10 * time.Minute
+	// dedupeTolerance is how far apart two start times may be to count as the same session.
+	dedupeTolerance = 2 * time.Minute
+)
+
+var sportExercises = map[string]string{
+	tracks.SportRunning: "Running",
+	tracks.SportCycling: "Cycling",
+	tracks.SportWalking: "Walking",
+}
+
+// Worker parses uploaded track files in the background and turns them into workouts.
+type Worker struct {
+	store        imports.ImportStore
+	workoutStore workouts.WorkoutStore
+	bus          *events.Bus
+	log          *slog.Logger
+	wake         chan struct{}
+}
+
+func NewWorker(
+	store imports.ImportStore, workoutStore workouts.WorkoutStore, bus *events.Bus, log *slog.Logger,
+) *Worker {
+	return &Worker{
+		store:        store,
+		workoutStore: workoutStore,
+		bus:          bus,
+		log:          log.With(slog.String("component", "imports/worker")),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the worker up without waiting for the next poll.
+func (wk *Worker) Notify() {
+	select {
+	case wk.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wk.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wk.wake:
+		}
+	}
+}
+
+func (wk *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		imp, data, err := wk.store.ClaimPending(claimLease)
+		if err != nil {
+			if !errors.Is(err, imports.ImportNotFound) {
+				wk.log.Error("failed to claim import", sl.Err(err))
+			}
+			return
+		}
+
+		log := wk.log.With(slog.Int("import_id", imp.ID), slog.Int("user_id", imp.UserID))
+
+		status, workoutID, err := wk.process(imp, data)
+		errMsg := ""
+		if err != nil {
+			log.Warn("import failed", sl.Err(err))
+			status, errMsg = models.ImportFailed, err.Error()
+		}
+		if err := wk.store.FinishImport(imp.ID, status, workoutID, errMsg); err != nil {
+			log.Error("failed to finish import", sl.Err(err))
+			continue
+		}
+		log.Info("import finished", slog.String("status", status))
+	}
+}
+
+func (wk *Worker) process(imp *models.Import, data []byte) (string, *int, error) {
+	const op = "imports.Worker.process"
+
+	track, err := tracks.Parse(imp.Format, bytes.NewReader(data))
+	if err != nil {
+		return "", nil, err
+	}
+	summary, err := tracks.Summarize(track)
+	if err != nil {
+		return "", nil, err
+	}
+
+	existing, err := wk.workoutStore.FindWorkoutByStart(imp.UserID, summary.StartedAt, dedupeTolerance)
+	if err == nil {
+		return models.ImportDuplicate, &existing.ID, nil
+	}
+	if !errors.Is(err, workouts.WorkoutNotFound) {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exercise, err := wk.workoutStore.GetExerciseByName(sportExercises[summary.Sport])
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w := models.Workout{
+		UserID:        imp.UserID,
+		Name:          exercise.Name,
+		StartedAt:     summary.StartedAt,
+		Duration:      summary.Duration,
+		Notes:         "Imported from " + imp.Filename,
+		Source:        imp.Format,
+		Distance:      summary.Distance,
+		ElevationGain: summary.ElevationGain,
+		AvgHeartRate:  positive(summary.AvgHeartRate),
+		MaxHeartRate:  positive(summary.MaxHeartRate),
+		AvgCadence:    positive(summary.AvgCadence),
+		Sets: []models.WorkoutSet{
+			{ExerciseID: exercise.ID, Duration: summary.Duration, Distance: summary.Distance},
+		},
+	}
+	if err := wk.workoutStore.SaveWorkout(&w); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	wk.bus.Publish(events.WorkoutCreated, w.UserID, w)
+
+	return models.ImportDone, &w.ID, nil
+}
+
+func positive(v int) *int {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
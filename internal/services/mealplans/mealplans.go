@@ -0,0 +1,327 @@
+package mealplans
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/mealplan"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/mealplans"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/preferences"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// candidateFoods is the size of the food sample the planner chooses from.
+const candidateFoods = 300
+
+type Handler struct {
+	store       mealplans.MealPlanStore
+	foodStore   foods.FoodStore
+	recipeStore recipes.RecipeStore
+	prefsStore  preferences.PreferencesStore
+	log         *slog.Logger
+	cfg         config.Config
+}
+
+func NewHandler(
+	store mealplans.MealPlanStore, foodStore foods.FoodStore, recipeStore recipes.RecipeStore,
+	prefsStore preferences.PreferencesStore, log *slog.Logger,
+) *Handler {
+	return &Handler{
+		store: store, foodStore: foodStore, recipeStore: recipeStore, prefsStore: prefsStore, log: log,
+		cfg: config.Envs,
+	}
+}
+
+func (h *Handler) HandleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	const op = "mealplans.HandleCreatePlan"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateMealPlanPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	plan := models.MealPlan{UserID: user.ID, Seed: time.Now().UnixNano()}
+	if payload.Seed != nil {
+		plan.Seed = *payload.Seed
+	}
+	tolerance := mealplan.DefaultTolerance
+	if payload.Tolerance != nil {
+		tolerance = *payload.Tolerance
+	}
+	plan.Targets = mealplan.TargetsFor(*user, tolerance)
+
+	list, err := h.candidates(user.ID, plan.Seed)
+	if err != nil {
+		log.Error("failed to get candidates", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	plan.Days, err = mealplan.Generate(plan.Targets, list, payload.Days, plan.Seed)
+	if err != nil {
+		if errors.Is(err, mealplan.ErrNoCandidates) {
+			resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to generate meal plan", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.CreatePlan(&plan); err != nil {
+		log.Error("failed to save meal plan", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("meal plan generated", slog.Int("meal_plan_id", plan.ID), slog.Int64("seed", plan.Seed))
+	resp.JSON(w, r, http.StatusCreated, plan)
+}
+
+func (h *Handler) HandleGetPlan(w http.ResponseWriter, r *http.Request) {
+	const op = "mealplans.HandleGetPlan"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid meal plan id"})
+		return
+	}
+
+	plan, err := h.store.GetPlan(user.ID, id)
+	if err != nil {
+		if errors.Is(err, mealplans.MealPlanNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get meal plan", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, plan)
+}
+
+// HandleSwapItem replaces a single item of a day and rebalances the other portions of that day.
+func (h *Handler) HandleSwapItem(w http.ResponseWriter, r *http.Request) {
+	const op = "mealplans.HandleSwapItem"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid meal plan id"})
+		return
+	}
+
+	var payload models.SwapMealPlanItemPayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	plan, err := h.store.GetPlan(user.ID, id)
+	if err != nil {
+		if errors.Is(err, mealplans.MealPlanNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get meal plan", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if payload.Day > len(plan.Days) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": mealplan.ErrItemNotFound.Error()})
+		return
+	}
+
+	list, err := h.candidates(user.ID, plan.Seed)
+	if err != nil {
+		log.Error("failed to get candidates", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	var replacement *mealplan.Candidate
+	if payload.FoodID != nil || payload.RecipeID != nil {
+		i := slices.IndexFunc(
+			list, func(c mealplan.Candidate) bool {
+				if payload.FoodID != nil {
+					return c.FoodID != nil && *c.FoodID == *payload.FoodID
+				}
+				return c.RecipeID != nil && *c.RecipeID == *payload.RecipeID
+			},
+		)
+		if i < 0 && payload.FoodID != nil {
+			c, err := h.foodCandidate(user.ID, *payload.FoodID)
+			if err != nil {
+				if errors.Is(err, foods.FoodNotFound) {
+					resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+					return
+				}
+				log.Error("failed to get food", sl.Err(err))
+				resp.Internal(w, r)
+				return
+			}
+			replacement = c
+		} else if i < 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": recipes.RecipeNotFound.Error()})
+			return
+		} else {
+			replacement = &list[i]
+		}
+	}
+
+	day, err := mealplan.Swap(
+		plan.Targets, plan.Days[payload.Day-1], payload.Meal, payload.Item, replacement, list, plan.Seed,
+	)
+	if err != nil {
+		if errors.Is(err, mealplan.ErrItemNotFound) || errors.Is(err, mealplan.ErrNoCandidates) {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to swap item", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	plan.Days[payload.Day-1] = day
+
+	if err := h.store.UpdatePlanDays(plan); err != nil {
+		log.Error("failed to save meal plan", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, plan)
+}
+
+// candidates returns the foods and recipes fitting the user's diet and allergens,
+// in an order that only depends on the seed and the database content.
+func (h *Handler) candidates(userID int, seed int64) ([]mealplan.Candidate, error) {
+	prefs, err := h.prefsStore.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := h.foodStore.GetPlanCandidates(userID, *prefs, seed, candidateFoods)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]mealplan.Candidate, 0, len(list))
+	for _, f := range list {
+		res = append(res, mealplan.Candidate{FoodID: &f.ID, Name: f.Name, Unit: f.Nutrients})
+	}
+
+	recipeList, err := h.recipeStore.GetRecipes(userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, rec := range recipeList {
+		for _, ing := range rec.Ingredients {
+			ids = append(ids, ing.FoodID)
+		}
+	}
+	found, err := h.foodStore.GetFoodsByIDs(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rec := range recipeList {
+		if !recipeSuits(*prefs, rec, found) {
+			continue
+		}
+		perServing := nutrition.PerServing(&rec, found)
+		if perServing.Kcal <= 0 {
+			continue
+		}
+		res = append(res, mealplan.Candidate{RecipeID: &rec.ID, Name: rec.Name, Unit: perServing})
+	}
+	return res, nil
+}
+
+// foodCandidate lets users swap in any visible food, even one outside the sampled candidates.
+func (h *Handler) foodCandidate(userID int, id int) (*mealplan.Candidate, error) {
+	f, err := h.foodStore.GetFoodByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &mealplan.Candidate{FoodID: &f.ID, Name: f.Name, Unit: f.Nutrients}, nil
+}
+
+// recipeSuits checks every ingredient: one unsuitable or unknown food rules the recipe out.
+func recipeSuits(prefs models.Preferences, rec models.Recipe, found map[int]models.Food) bool {
+	for _, ing := range rec.Ingredients {
+		f, ok := found[ing.FoodID]
+		if !ok || !nutrition.Suits(prefs, f.Diets, f.Allergens) {
+			return false
+		}
+	}
+	return true
+}
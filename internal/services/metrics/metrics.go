@@ -0,0 +1,405 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/daterange"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/habits"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/metrics"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultMetricDays  = 7
+	maxMetricDays      = 366
+	defaultSummaryDays = 28
+)
+
+type Handler struct {
+	store metrics.MetricStore
+	bus   *events.Bus
+	log   *slog.Logger
+	cfg   config.Config
+}
+
+func NewHandler(store metrics.MetricStore, bus *events.Bus, log *slog.Logger) *Handler {
+	return &Handler{store: store, bus: bus, log: log, cfg: config.Envs}
+}
+
+// HandleSaveDay sets metrics of a single day. Sending the same values again changes nothing.
+func (h *Handler) HandleSaveDay(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleSaveDay"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	date := chi.URLParam(r, "date")
+	if _, err := time.Parse(daterange.Layout, date); err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid date"})
+		return
+	}
+
+	var payload models.SaveDayMetricsPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	source := payload.Source
+	if source == "" {
+		source = models.MetricSourceManual
+	}
+
+	entries := make([]models.MetricEntryPayload, 0, len(payload.Values))
+	for _, metric := range habits.Metrics {
+		if v, ok := payload.Values[metric]; ok {
+			entries = append(entries, models.MetricEntryPayload{Date: date, Metric: metric, Value: v})
+		}
+	}
+
+	changed, ok := h.save(w, r, log, user.ID, source, entries)
+	if !ok {
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]int{"changed": len(changed)})
+}
+
+// HandleSync applies a batch of entries from a wearable in one transaction.
+func (h *Handler) HandleSync(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleSync"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.SyncMetricsPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	// keep the last entry of every day and metric
+	type key struct{ date, metric string }
+	last := make(map[key]int, len(payload.Entries))
+	for i, e := range payload.Entries {
+		last[key{e.Date, e.Metric}] = i
+	}
+	entries := make([]models.MetricEntryPayload, 0, len(last))
+	for i, e := range payload.Entries {
+		if last[key{e.Date, e.Metric}] == i {
+			entries = append(entries, e)
+		}
+	}
+
+	changed, ok := h.save(w, r, log, user.ID, payload.Source, entries)
+	if !ok {
+		return
+	}
+
+	log.Info("metrics synced", slog.Int("received", len(payload.Entries)), slog.Int("changed", len(changed)))
+	resp.JSON(
+		w, r, http.StatusOK, map[string]int{
+			"received":  len(payload.Entries),
+			"applied":   len(entries),
+			"changed":   len(changed),
+			"unchanged": len(entries) - len(changed),
+		},
+	)
+}
+
+// save validates the values, stores them and publishes the changed ones. It writes the error
+// response itself and reports whether the caller can go on.
+func (h *Handler) save(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, userID int, source string,
+	entries []models.MetricEntryPayload,
+) ([]models.DailyMetric, bool) {
+	for _, e := range entries {
+		if err := habits.Validate(e.Metric, e.Value); err != nil {
+			resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return nil, false
+		}
+	}
+
+	changed, err := h.store.SaveMetrics(userID, source, entries)
+	if err != nil {
+		log.Error("failed to save metrics", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+
+	if len(changed) > 0 {
+		h.bus.Publish(events.MetricsLogged, userID, changed)
+	}
+	return changed, true
+}
+
+func (h *Handler) HandleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleGetMetrics"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultMetricDays, maxMetricDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	list, err := h.store.GetMetrics(user.ID, period.Key(period.From), period.Key(period.To.AddDate(0, 0, -1)))
+	if err != nil {
+		log.Error("failed to get metrics", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleGetSummary aggregates the metrics per week or month (?period=week|month).
+func (h *Handler) HandleGetSummary(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleGetSummary"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	periodName := r.URL.Query().Get("period")
+	if periodName == "" {
+		periodName = habits.PeriodWeek
+	}
+	if periodName != habits.PeriodWeek && periodName != habits.PeriodMonth {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": habits.ErrInvalidPeriod.Error()})
+		return
+	}
+
+	period, err := daterange.FromQuery(r.URL.Query(), defaultSummaryDays, maxMetricDays)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	list, err := h.store.GetMetrics(user.ID, period.Key(period.From), period.Key(period.To.AddDate(0, 0, -1)))
+	if err != nil {
+		log.Error("failed to get metrics", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	own, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	summary, err := habits.Summarize(periodName, list, habits.Goals(own))
+	if err != nil {
+		log.Error("failed to summarize metrics", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, summary)
+}
+
+func (h *Handler) HandleGetStreaks(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleGetStreaks"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	today, err := daterange.Today(r.URL.Query())
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	own, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	history, err := h.store.GetHistory(user.ID)
+	if err != nil {
+		log.Error("failed to get metrics", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	values := make(map[string]map[string]float64)
+	for _, m := range history {
+		if values[m.Metric] == nil {
+			values[m.Metric] = make(map[string]float64)
+		}
+		values[m.Metric][m.Date] = m.Value
+	}
+
+	streaks := []models.MetricStreak{}
+	for _, g := range habits.Goals(own) {
+		streaks = append(streaks, habits.Streak(g.Metric, values[g.Metric], g.Target, today))
+	}
+
+	resp.JSON(w, r, http.StatusOK, streaks)
+}
+
+func (h *Handler) HandleGetGoals(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleGetGoals"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	own, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, habits.Goals(own))
+}
+
+func (h *Handler) HandleSaveGoals(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleSaveGoals"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.SaveMetricGoalsPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	for metric, target := range payload.Goals {
+		if err := habits.Validate(metric, target); err != nil {
+			resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.store.SaveGoals(user.ID, payload.Goals); err != nil {
+		log.Error("failed to save goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	own, err := h.store.GetGoals(user.ID)
+	if err != nil {
+		log.Error("failed to get goals", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, habits.Goals(own))
+}
+
+// HandleDeleteGoal removes the user's own goal, falling back to the default one if any.
+func (h *Handler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	const op = "metrics.HandleDeleteGoal"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	if err := h.store.DeleteGoal(user.ID, chi.URLParam(r, "metric")); err != nil {
+		if errors.Is(err, metrics.GoalNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete goal", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
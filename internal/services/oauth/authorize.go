@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// HandleGetAuthorize checks an authorization request and returns what the consent screen shows.
+// The app redirects the user to the consent screen with the parameters of the authorization-code
+// flow, which the screen passes on here.
+func (h *Handler) HandleGetAuthorize(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleGetAuthorize"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	q := r.URL.Query()
+	req := models.AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+	if err := validator.New().Struct(req); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	client, scopes, ok := h.checkRequest(w, r, log, req)
+	if !ok {
+		return
+	}
+
+	granted, err := h.store.GetConsent(user.ID, client.AppID)
+	if err != nil {
+		log.Error("failed to get consent", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	consent := models.OAuthConsent{AppName: client.AppName, Scopes: scopes, Granted: granted != nil}
+	for _, s := range scopes {
+		if !slices.Contains(granted, s) {
+			consent.Granted = false
+		}
+	}
+
+	resp.JSON(w, r, http.StatusOK, consent)
+}
+
+// HandleAuthorize records the answer of the user and returns where to redirect the user to: the
+// redirect URI of the app with either an authorization code or the access_denied error.
+func (h *Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleAuthorize"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.AuthorizePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	client, scopes, ok := h.checkRequest(w, r, log, payload.AuthorizeRequest)
+	if !ok {
+		return
+	}
+
+	if !payload.Approve {
+		log.Info("authorization denied", slog.String("client_id", client.ClientID))
+		resp.JSON(
+			w, r, http.StatusOK, map[string]string{
+				"redirect_uri": redirectURI(payload.RedirectURI, "error", "access_denied", payload.State),
+			},
+		)
+		return
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		log.Error("failed to generate code", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.SaveConsent(user.ID, client.AppID, scopes); err != nil {
+		log.Error("failed to save consent", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	err = h.store.CreateCode(
+		models.OAuthCode{
+			CodeHash:      tokenhash.Sum(code),
+			ClientID:      client.ID,
+			UserID:        user.ID,
+			RedirectURI:   payload.RedirectURI,
+			Scopes:        scopes,
+			CodeChallenge: payload.CodeChallenge,
+			ExpiresAt:     time.Now().Add(codeTTL),
+		},
+	)
+	if err != nil {
+		log.Error("failed to create code", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(
+		r, models.AuditAppAuthorized, user.ID, user.ID,
+		map[string]any{"app_id": client.AppID, "client_id": client.ClientID, "scopes": scopes},
+	)
+	log.Info("authorization granted", slog.String("client_id", client.ClientID), slog.Any("scopes", scopes))
+	resp.JSON(
+		w, r, http.StatusOK, map[string]string{
+			"redirect_uri": redirectURI(payload.RedirectURI, "code", code, payload.State),
+		},
+	)
+}
+
+// checkRequest resolves the client of the request and the scopes asked for. The redirect URI must
+// be one of the registered ones.
+func (h *Handler) checkRequest(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, req models.AuthorizeRequest,
+) (*models.OAuthClient, []string, bool) {
+	client, err := h.store.GetClient(req.ClientID)
+	if err != nil {
+		if errors.Is(err, oauth.ClientNotFound) {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "unknown client"})
+			return nil, nil, false
+		}
+		log.Error("failed to get client", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, nil, false
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "redirect_uri is not registered"})
+		return nil, nil, false
+	}
+
+	scopes, err := parseScopes(req.Scope)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, nil, false
+	}
+	return client, scopes, true
+}
+
+// redirectURI adds the result and the state of the app to the redirect URI.
+func redirectURI(base string, key string, value string, state string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	q.Set(key, value)
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,157 @@
+package oauth
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// HandleCreateClient registers OAuth2 credentials for a partner app. The secret of confidential
+// clients is only part of this response.
+func (h *Handler) HandleCreateClient(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleCreateClient"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	appID, err := strconv.Atoi(chi.URLParam(r, "app"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return
+	}
+
+	var payload models.CreateOAuthClientPayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	client := models.OAuthClient{AppID: appID, RedirectURIs: payload.RedirectURIs}
+	if client.ClientID, err = randomToken(18); err != nil {
+		log.Error("failed to generate client id", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	var secret string
+	if payload.Confidential {
+		if secret, err = randomToken(32); err != nil {
+			log.Error("failed to generate secret", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		hash := tokenhash.Sum(secret)
+		client.SecretHash = &hash
+	}
+
+	if err := h.store.CreateClient(&client); err != nil {
+		if errors.Is(err, oauth.AppNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": oauth.AppNotFound.Error()})
+			return
+		}
+		log.Error("failed to create client", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditClientCreated, user.ID, 0, client)
+	log.Info("oauth client created", slog.Int("app_id", appID), slog.String("client_id", client.ClientID))
+
+	res := map[string]any{"client": client}
+	if secret != "" {
+		res["client_secret"] = secret
+	}
+	resp.JSON(w, r, http.StatusCreated, res)
+}
+
+func (h *Handler) HandleGetClients(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleGetClients"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	appID, err := strconv.Atoi(chi.URLParam(r, "app"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return
+	}
+
+	list, err := h.store.GetClients(appID)
+	if err != nil {
+		log.Error("failed to get clients", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleDeleteClient removes the credentials. The tokens issued to them stop working at once.
+func (h *Handler) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleDeleteClient"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	appID, err := strconv.Atoi(chi.URLParam(r, "app"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return
+	}
+	clientID := chi.URLParam(r, "client")
+
+	if err := h.store.DeleteClient(appID, clientID); err != nil {
+		if errors.Is(err, oauth.ClientNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": oauth.ClientNotFound.Error()})
+			return
+		}
+		log.Error("failed to delete client", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(
+		r, models.AuditClientDeleted, user.ID, 0, map[string]any{"app_id": appID, "client_id": clientID},
+	)
+	log.Info("oauth client deleted", slog.Int("app_id", appID), slog.String("client_id", clientID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
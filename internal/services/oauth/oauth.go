@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// codeTTL is how long an authorization code can be exchanged for tokens.
+const codeTTL = 10 * time.Minute
+
+var errInvalidScope = errors.New("invalid scope")
+
+// Handler makes atom-fit an OAuth2 provider for the partner apps: admins register clients for an
+// app, users consent to the scopes an app asks for, and apps exchange the authorization code,
+// protected by PKCE, for tokens. Consenting connects the app to the user, disconnecting it
+// through /api/me/apps revokes its tokens.
+type Handler struct {
+	store     oauth.OAuthStore
+	userStore users.UserStore
+	recorder  *audit.Recorder
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(
+	store oauth.OAuthStore, userStore users.UserStore, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, userStore: userStore, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+// oauthError answers in the format of RFC 6749, which the client libraries of the apps expect.
+func oauthError(w http.ResponseWriter, r *http.Request, status int, code string, description string) {
+	resp.JSON(w, r, status, map[string]string{"error": code, "error_description": description})
+}
+
+// parseScopes reads a space separated scope parameter, rejecting unknown scopes.
+func parseScopes(scope string) ([]string, error) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(models.OAuthScopes, s) {
+			return nil, errInvalidScope
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errInvalidScope
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
+
+// verifyPKCE checks the code verifier against an S256 challenge.
+func verifyPKCE(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:]) == challenge
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
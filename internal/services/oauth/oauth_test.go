@@ -0,0 +1,351 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID    = "client-1"
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// fakeStore holds one public client and the codes and tokens issued to it. UseCode follows the
+// Postgres store: a code works once, until it expires, and presenting it again revokes its tokens.
+type fakeStore struct {
+	oauth.OAuthStore
+	client *models.OAuthClient
+	codes  map[string]*models.OAuthCode
+	tokens []*models.OAuthToken
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		client: &models.OAuthClient{ID: 1, AppID: 1, ClientID: testClientID, RedirectURIs: []string{testRedirectURI}},
+		codes:  map[string]*models.OAuthCode{},
+	}
+}
+
+func (s *fakeStore) GetClient(clientID string) (*models.OAuthClient, error) {
+	if clientID != s.client.ClientID {
+		return nil, oauth.ClientNotFound
+	}
+	return s.client, nil
+}
+
+func (s *fakeStore) UseCode(codeHash string) (*models.OAuthCode, error) {
+	c, ok := s.codes[codeHash]
+	if !ok {
+		return nil, oauth.CodeNotFound
+	}
+	if c.UsedAt != nil {
+		now := time.Now()
+		for _, t := range s.tokens {
+			if t.CodeHash != nil && *t.CodeHash == codeHash && t.RevokedAt == nil {
+				t.RevokedAt = &now
+			}
+		}
+		return nil, oauth.CodeUsed
+	}
+	if !c.ExpiresAt.After(time.Now()) {
+		return nil, oauth.CodeNotFound
+	}
+	now := time.Now()
+	c.UsedAt = &now
+	used := *c
+	return &used, nil
+}
+
+func (s *fakeStore) CreateToken(t *models.OAuthToken) error {
+	t.ID = int64(len(s.tokens) + 1)
+	t.CreatedAt = time.Now()
+	s.tokens = append(s.tokens, t)
+	return nil
+}
+
+func (s *fakeStore) GetTokenByRefresh(refreshHash string) (*models.OAuthToken, error) {
+	for _, t := range s.tokens {
+		if t.RefreshHash == refreshHash {
+			return t, nil
+		}
+	}
+	return nil, oauth.TokenNotFound
+}
+
+func (s *fakeStore) RotateToken(oldID int64, next *models.OAuthToken) error {
+	now := time.Now()
+	s.tokens[oldID-1].RevokedAt = &now
+	return s.CreateToken(next)
+}
+
+// addCode stores a code for the user with the S256 challenge of testVerifier.
+func (s *fakeStore) addCode(code string, scopes []string, expiresAt time.Time) {
+	sum := sha256.Sum256([]byte(testVerifier))
+	s.codes[tokenhash.Sum(code)] = &models.OAuthCode{
+		CodeHash:      tokenhash.Sum(code),
+		ClientID:      s.client.ID,
+		UserID:        7,
+		RedirectURI:   testRedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+		ExpiresAt:     expiresAt,
+	}
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id, Username: "ann"}, nil
+}
+
+func newTestHandler(store *fakeStore) *Handler {
+	cfg := config.Config{
+		JwtCfg: config.JWTConfig{Secret: "test-secret"},
+		OAuth:  config.OAuth{AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour},
+	}
+	return &Handler{
+		store: store, userStore: fakeUsers{}, log: slog.New(slog.NewTextHandler(io.Discard, nil)), cfg: cfg,
+	}
+}
+
+func postToken(h *Handler, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.HandleToken(w, r)
+	return w
+}
+
+func codeForm(code string) url.Values {
+	return url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {testClientID},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+}
+
+func oauthErrorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return body["error"]
+}
+
+func TestExchangeCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		form       func(url.Values)
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "valid",
+			expiresIn:  codeTTL,
+			form:       func(url.Values) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "pkce mismatch",
+			expiresIn:  codeTTL,
+			form:       func(f url.Values) { f.Set("code_verifier", strings.Repeat("a", 43)) },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "missing verifier",
+			expiresIn:  codeTTL,
+			form:       func(f url.Values) { f.Set("code_verifier", "") },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "redirect uri mismatch",
+			expiresIn:  codeTTL,
+			form:       func(f url.Values) { f.Set("redirect_uri", "https://evil.example.com/callback") },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "expired code",
+			expiresIn:  -time.Second,
+			form:       func(url.Values) {},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "unknown code",
+			expiresIn:  codeTTL,
+			form:       func(f url.Values) { f.Set("code", "forged") },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "unknown client",
+			expiresIn:  codeTTL,
+			form:       func(f url.Values) { f.Set("client_id", "client-2") },
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := newFakeStore()
+				store.addCode("code-1", []string{models.ScopeProfile}, time.Now().Add(tt.expiresIn))
+				form := codeForm("code-1")
+				tt.form(form)
+
+				w := postToken(newTestHandler(store), form)
+
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+				}
+				if tt.wantError == "" {
+					if len(store.tokens) != 1 {
+						t.Errorf("issued %d grants, want 1", len(store.tokens))
+					}
+					return
+				}
+				if got := oauthErrorCode(t, w); got != tt.wantError {
+					t.Errorf("error = %q, want %q", got, tt.wantError)
+				}
+				if len(store.tokens) != 0 {
+					t.Errorf("issued %d grants, want none", len(store.tokens))
+				}
+			},
+		)
+	}
+}
+
+func TestExchangeCodeReuseRevokesTokens(t *testing.T) {
+	store := newFakeStore()
+	store.addCode("code-1", []string{models.ScopeProfile}, time.Now().Add(codeTTL))
+	h := newTestHandler(store)
+
+	if w := postToken(h, codeForm("code-1")); w.Code != http.StatusOK {
+		t.Fatalf("first exchange = %d, want 200: %s", w.Code, w.Body)
+	}
+	w := postToken(h, codeForm("code-1"))
+
+	if w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_grant" {
+		t.Errorf("second exchange = %d, want 400 invalid_grant", w.Code)
+	}
+	if len(store.tokens) != 1 || store.tokens[0].RevokedAt == nil {
+		t.Errorf("grant of the reused code was not revoked: %+v", store.tokens)
+	}
+}
+
+func TestRefreshScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		scope      string
+		wantStatus int
+		wantScope  string
+	}{
+		{name: "same scopes", scope: "", wantStatus: http.StatusOK, wantScope: "profile workouts:read"},
+		{name: "narrower", scope: "profile", wantStatus: http.StatusOK, wantScope: "profile"},
+		{name: "beyond the granted ones", scope: "profile weight:write", wantStatus: http.StatusBadRequest},
+		{name: "unregistered scope", scope: "admin", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := newFakeStore()
+				store.CreateToken(
+					&models.OAuthToken{
+						ClientID: store.client.ID, UserID: 7, Scopes: []string{"profile", "workouts:read"},
+						RefreshHash: tokenhash.Sum("refresh-1"), ExpiresAt: time.Now().Add(time.Hour),
+					},
+				)
+				form := url.Values{
+					"grant_type": {"refresh_token"}, "client_id": {testClientID}, "refresh_token": {"refresh-1"},
+				}
+				if tt.scope != "" {
+					form.Set("scope", tt.scope)
+				}
+
+				w := postToken(newTestHandler(store), form)
+
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+				}
+				if tt.wantStatus != http.StatusOK {
+					if got := oauthErrorCode(t, w); got != "invalid_scope" {
+						t.Errorf("error = %q, want invalid_scope", got)
+					}
+					if store.tokens[0].RevokedAt != nil {
+						t.Error("refused refresh revoked the grant")
+					}
+					return
+				}
+				var res models.TokenResponse
+				if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+					t.Fatal(err)
+				}
+				if res.Scope != tt.wantScope {
+					t.Errorf("scope = %q, want %q", res.Scope, tt.wantScope)
+				}
+			},
+		)
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	valid := models.AuthorizeRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "profile"}
+	tests := []struct {
+		name   string
+		modify func(*models.AuthorizeRequest)
+		wantOK bool
+	}{
+		{name: "valid", modify: func(*models.AuthorizeRequest) {}, wantOK: true},
+		{name: "unknown client", modify: func(req *models.AuthorizeRequest) { req.ClientID = "client-2" }},
+		{
+			name:   "unregistered redirect uri",
+			modify: func(req *models.AuthorizeRequest) { req.RedirectURI = testRedirectURI + "/other" },
+		},
+		{name: "unregistered scope", modify: func(req *models.AuthorizeRequest) { req.Scope = "profile admin" }},
+		{name: "no scope", modify: func(req *models.AuthorizeRequest) { req.Scope = " " }},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := valid
+				tt.modify(&req)
+				h := newTestHandler(newFakeStore())
+				w := httptest.NewRecorder()
+
+				_, _, ok := h.checkRequest(w, httptest.NewRequest(http.MethodGet, "/", nil), h.log, req)
+
+				if ok != tt.wantOK {
+					t.Fatalf("checkRequest() ok = %v, want %v", ok, tt.wantOK)
+				}
+				if !ok && w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", w.Code)
+				}
+			},
+		)
+	}
+}
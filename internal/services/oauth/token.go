@@ -0,0 +1,349 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/oauth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// HandleToken is the token endpoint. It exchanges authorization codes and refresh tokens, the
+// refresh token being replaced on every use.
+func (h *Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleToken"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	client, ok := h.authenticateClient(w, r, log)
+	if !ok {
+		return
+	}
+	log = log.With(slog.String("client_id", client.ClientID))
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		h.exchangeCode(w, r, log, client)
+	case "refresh_token":
+		h.refresh(w, r, log, client)
+	default:
+		oauthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "grant_type is not supported")
+	}
+}
+
+func (h *Handler) exchangeCode(w http.ResponseWriter, r *http.Request, log *slog.Logger, client *models.OAuthClient) {
+	codeHash := tokenhash.Sum(r.PostForm.Get("code"))
+	code, err := h.store.UseCode(codeHash)
+	if err != nil {
+		if errors.Is(err, oauth.CodeUsed) {
+			log.Warn("authorization code reused, tokens revoked")
+		}
+		if errors.Is(err, oauth.CodeNotFound) || errors.Is(err, oauth.CodeUsed) {
+			oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired code")
+			return
+		}
+		log.Error("failed to use code", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+		oauthError(w, r, http.StatusBadRequest, "invalid_grant", "code was issued to another client or redirect_uri")
+		return
+	}
+	if !verifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		oauthError(w, r, http.StatusBadRequest, "invalid_grant", "code_verifier does not match")
+		return
+	}
+
+	h.issue(w, r, log, client, code.UserID, code.Scopes, &codeHash, 0)
+}
+
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request, log *slog.Logger, client *models.OAuthClient) {
+	t, err := h.store.GetTokenByRefresh(tokenhash.Sum(r.PostForm.Get("refresh_token")))
+	if err != nil && !errors.Is(err, oauth.TokenNotFound) {
+		log.Error("failed to get token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if err != nil || t.ClientID != client.ID || t.RevokedAt != nil || time.Now().After(t.ExpiresAt) {
+		oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired refresh token")
+		return
+	}
+
+	scopes := []string(t.Scopes)
+	if scope := r.PostForm.Get("scope"); scope != "" {
+		if scopes, err = parseScopes(scope); err != nil {
+			oauthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		}
+		for _, s := range scopes {
+			if !slices.Contains(t.Scopes, s) {
+				oauthError(w, r, http.StatusBadRequest, "invalid_scope", "scope exceeds the granted ones")
+				return
+			}
+		}
+	}
+
+	h.issue(w, r, log, client, t.UserID, scopes, t.CodeHash, t.ID)
+}
+
+// issue creates a grant with a new access and refresh token. A grant replacing oldID is issued
+// in place of it.
+func (h *Handler) issue(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, client *models.OAuthClient, userID int,
+	scopes []string, codeHash *string, oldID int64,
+) {
+	user, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			oauthError(w, r, http.StatusBadRequest, "invalid_grant", "user not found")
+			return
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		log.Error("failed to generate refresh token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	t := models.OAuthToken{
+		ClientID:    client.ID,
+		UserID:      userID,
+		Scopes:      scopes,
+		RefreshHash: tokenhash.Sum(refreshToken),
+		CodeHash:    codeHash,
+		ExpiresAt:   time.Now().Add(h.cfg.OAuth.RefreshTTL),
+	}
+	if oldID == 0 {
+		err = h.store.CreateToken(&t)
+	} else {
+		err = h.store.RotateToken(oldID, &t)
+	}
+	if err != nil {
+		if errors.Is(err, oauth.TokenNotFound) {
+			oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired refresh token")
+			return
+		}
+		log.Error("failed to create token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	accessToken, err := jwt.NewClientToken(
+		*user, client.ClientID, t.ID, scopes, h.cfg.OAuth.AccessTTL, h.cfg.JwtCfg.Secret,
+	)
+	if err != nil {
+		log.Error("failed to sign access token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("tokens issued", slog.Int("target_id", userID), slog.Int64("grant_id", t.ID))
+	resp.JSON(
+		w, r, http.StatusOK, models.TokenResponse{
+			AccessToken:  accessToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int(h.cfg.OAuth.AccessTTL.Seconds()),
+			RefreshToken: refreshToken,
+			Scope:        strings.Join(scopes, " "),
+		},
+	)
+}
+
+// HandleIntrospect tells a client whether one of its tokens is active (RFC 7662). The tokens of
+// other clients are reported inactive.
+func (h *Handler) HandleIntrospect(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleIntrospect"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	client, ok := h.authenticateClient(w, r, log)
+	if !ok {
+		return
+	}
+
+	t, claims, err := h.findToken(client, r.PostForm.Get("token"))
+	if err != nil {
+		if errors.Is(err, oauth.TokenNotFound) {
+			resp.JSON(w, r, http.StatusOK, models.Introspection{})
+			return
+		}
+		log.Error("failed to find token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	active, err := h.store.IsActive(t.ID)
+	if err != nil {
+		log.Error("failed to check token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if !active || claims == nil && time.Now().After(t.ExpiresAt) {
+		resp.JSON(w, r, http.StatusOK, models.Introspection{})
+		return
+	}
+
+	user, err := h.userStore.GetUserByID(t.UserID)
+	if err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusOK, models.Introspection{})
+			return
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	res := models.Introspection{
+		Active:    true,
+		ClientID:  client.ClientID,
+		Username:  user.Username,
+		Sub:       strconv.Itoa(user.ID),
+		TokenType: "refresh_token",
+		Scope:     strings.Join(t.Scopes, " "),
+		Exp:       t.ExpiresAt.Unix(),
+		Iat:       t.CreatedAt.Unix(),
+	}
+	if claims != nil {
+		res.TokenType = "access_token"
+		res.Scope = strings.Join(claims.Scopes, " ")
+		res.Exp = claims.ExpiresAt.Unix()
+		res.Iat = claims.IssuedAt.Unix()
+	}
+	resp.JSON(w, r, http.StatusOK, res)
+}
+
+// HandleRevoke revokes the grant of an access or a refresh token of the client (RFC 7009).
+// Unknown tokens are not an error.
+func (h *Handler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	const op = "oauth.HandleRevoke"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	client, ok := h.authenticateClient(w, r, log)
+	if !ok {
+		return
+	}
+
+	t, _, err := h.findToken(client, r.PostForm.Get("token"))
+	if err != nil {
+		if errors.Is(err, oauth.TokenNotFound) {
+			resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+			return
+		}
+		log.Error("failed to find token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.RevokeToken(t.ID); err != nil {
+		log.Error("failed to revoke token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("token revoked", slog.String("client_id", client.ClientID), slog.Int64("grant_id", t.ID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// findToken resolves the grant of an access token, returned with its claims, or of a refresh
+// token. Tokens of other clients are reported as TokenNotFound.
+func (h *Handler) findToken(client *models.OAuthClient, token string) (*models.OAuthToken, *jwt.Claims, error) {
+	if token == "" {
+		return nil, nil, oauth.TokenNotFound
+	}
+
+	claims, err := jwt.ParseToken(token, h.cfg.JwtCfg.Secret)
+	if err == nil {
+		if claims.ClientID != client.ClientID {
+			return nil, nil, oauth.TokenNotFound
+		}
+		t, err := h.store.GetToken(claims.GrantID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, claims, nil
+	}
+
+	t, err := h.store.GetTokenByRefresh(tokenhash.Sum(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.ClientID != client.ID {
+		return nil, nil, oauth.TokenNotFound
+	}
+	return t, nil, nil
+}
+
+// authenticateClient reads the form and resolves the client from HTTP Basic authentication or
+// the client_id and client_secret parameters. Public clients only give their id.
+func (h *Handler) authenticateClient(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger,
+) (*models.OAuthClient, bool) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, r, http.StatusBadRequest, "invalid_request", "failed to parse form")
+		return nil, false
+	}
+
+	clientID, secret, basic := r.BasicAuth()
+	if !basic {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	fail := func() (*models.OAuthClient, bool) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="atom-fit"`)
+		}
+		oauthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return nil, false
+	}
+	if clientID == "" {
+		return fail()
+	}
+
+	client, err := h.store.GetClient(clientID)
+	if err != nil {
+		if errors.Is(err, oauth.ClientNotFound) {
+			return fail()
+		}
+		log.Error("failed to get client", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+	if client.Confidential &&
+		subtle.ConstantTimeCompare([]byte(tokenhash.Sum(secret)), []byte(*client.SecretHash)) != 1 {
+		return fail()
+	}
+	return client, true
+}
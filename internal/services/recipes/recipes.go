@@ -0,0 +1,383 @@
+package recipes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/foods"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/recipes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+type Handler struct {
+	store     recipes.RecipeStore
+	foodStore foods.FoodStore
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(store recipes.RecipeStore, foodStore foods.FoodStore, log *slog.Logger) *Handler {
+	return &Handler{store: store, foodStore: foodStore, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleCreateRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleCreateRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateRecipePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	found, err := h.ingredientFoods(user.ID, payload.Ingredients)
+	if err != nil {
+		if errors.Is(err, foods.FoodNotFound) {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get foods", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	recipe, err := h.store.CreateRecipe(user.ID, payload)
+	if err != nil {
+		log.Error("failed to save recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	recipe.Nutrition = nutrition.Recipe(recipe, found)
+
+	resp.JSON(w, r, http.StatusCreated, recipe)
+}
+
+func (h *Handler) HandleGetRecipes(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleGetRecipes"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetRecipes(user.ID)
+	if err != nil {
+		log.Error("failed to get recipes", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if err := h.withNutrition(user.ID, list); err != nil {
+		log.Error("failed to compute nutrition", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleGetRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid recipe id"})
+		return
+	}
+
+	recipe, err := h.store.GetRecipe(user.ID, id)
+	if err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	list := []models.Recipe{*recipe}
+	if err := h.withNutrition(user.ID, list); err != nil {
+		log.Error("failed to compute nutrition", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list[0])
+}
+
+func (h *Handler) HandleUpdateRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleUpdateRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid recipe id"})
+		return
+	}
+
+	var payload models.CreateRecipePayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	found, err := h.ingredientFoods(user.ID, payload.Ingredients)
+	if err != nil {
+		if errors.Is(err, foods.FoodNotFound) {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get foods", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	recipe, err := h.store.UpdateRecipe(user.ID, id, payload)
+	if err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to update recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	recipe.Nutrition = nutrition.Recipe(recipe, found)
+
+	resp.JSON(w, r, http.StatusOK, recipe)
+}
+
+func (h *Handler) HandleDeleteRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleDeleteRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid recipe id"})
+		return
+	}
+
+	if err := h.store.DeleteRecipe(user.ID, id); err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleShareRecipe creates a new share link, replacing any previous one.
+func (h *Handler) HandleShareRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleShareRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid recipe id"})
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("failed to generate share token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	if err := h.store.SetShareToken(user.ID, id, &token); err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to share recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(
+		w, r, http.StatusOK, map[string]string{
+			"share_token": token,
+			"url":         h.cfg.AppURL + "/api/shared/recipes/" + token,
+		},
+	)
+}
+
+func (h *Handler) HandleUnshareRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleUnshareRecipe"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid recipe id"})
+		return
+	}
+
+	if err := h.store.SetShareToken(user.ID, id, nil); err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to unshare recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetSharedRecipe is public: the share token in the link is what grants read access.
+func (h *Handler) HandleGetSharedRecipe(w http.ResponseWriter, r *http.Request) {
+	const op = "recipes.HandleGetSharedRecipe"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	recipe, err := h.store.GetSharedRecipe(chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, recipes.RecipeNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to get shared recipe", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	list := []models.Recipe{*recipe}
+	if err := h.withNutrition(recipe.OwnerID, list); err != nil {
+		log.Error("failed to compute nutrition", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list[0])
+}
+
+// ingredientFoods loads the foods of the ingredients, failing when one is not visible to the user.
+func (h *Handler) ingredientFoods(userID int, ingredients []models.RecipeIngredientPayload) (
+	map[int]models.Food, error,
+) {
+	ids := make([]int, len(ingredients))
+	for i, ing := range ingredients {
+		ids[i] = ing.FoodID
+	}
+
+	found, err := h.foodStore.GetFoodsByIDs(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			return nil, foods.FoodNotFound
+		}
+	}
+	return found, nil
+}
+
+func (h *Handler) withNutrition(userID int, list []models.Recipe) error {
+	var ids []int
+	for _, rec := range list {
+		for _, ing := range rec.Ingredients {
+			ids = append(ids, ing.FoodID)
+		}
+	}
+
+	found, err := h.foodStore.GetFoodsByIDs(userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].Nutrition = nutrition.Recipe(&list[i], found)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package sessions
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	refreshInterval = 15 * time.Second
+	// refreshOverlap reloads the revocations of a transaction that committed after a later one.
+	refreshOverlap = time.Minute
+)
+
+// Cache is the revocation list the auth middleware checks, kept in memory so that a request costs
+// no query. Revocations made by this instance apply at once, the ones of other instances after
+// the next refresh. Sessions leave the list when their tokens expire.
+//
+// It also buffers the last activity of the sessions, which is written every refreshInterval.
+type Cache struct {
+	store sessions.SessionStore
+	log   *slog.Logger
+
+	mu      sync.RWMutex
+	revoked map[int64]time.Time
+	since   time.Time
+
+	seenMu sync.Mutex
+	seen   map[int64]time.Time
+}
+
+func NewCache(store sessions.SessionStore, log *slog.Logger) *Cache {
+	return &Cache{
+		store:   store,
+		log:     log.With(slog.String("component", "sessions/cache")),
+		revoked: make(map[int64]time.Time),
+		seen:    make(map[int64]time.Time),
+	}
+}
+
+// Load reads the revocation list. It is called once before serving, Run keeps the list fresh.
+func (c *Cache) Load() error {
+	return c.refresh()
+}
+
+func (c *Cache) Run(ctx context.Context) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.flush()
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.refresh(); err != nil {
+			c.log.Error("failed to refresh revoked sessions", sl.Err(err))
+		}
+		c.flush()
+	}
+}
+
+// Revoked reports whether the session was revoked.
+func (c *Cache) Revoked(id int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.revoked[id]
+	return ok
+}
+
+// Revoke adds sessions revoked by this instance to the list.
+func (c *Cache) Revoke(list ...models.RevokedSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range list {
+		c.revoked[s.ID] = s.ExpiresAt
+	}
+}
+
+// Touch records a request made in the session.
+func (c *Cache) Touch(id int64) {
+	c.seenMu.Lock()
+	defer c.seenMu.Unlock()
+
+	c.seen[id] = time.Now()
+}
+
+// LastActive returns the activity of the session not written yet.
+func (c *Cache) LastActive(id int64) (time.Time, bool) {
+	c.seenMu.Lock()
+	defer c.seenMu.Unlock()
+
+	t, ok := c.seen[id]
+	return t, ok
+}
+
+func (c *Cache) refresh() error {
+	c.mu.RLock()
+	since := c.since
+	c.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-refreshOverlap)
+	}
+
+	list, err := c.store.GetRevoked(since)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range c.revoked {
+		if now.After(expiresAt) {
+			delete(c.revoked, id)
+		}
+	}
+	for _, s := range list {
+		c.revoked[s.ID] = s.ExpiresAt
+		if s.RevokedAt.After(c.since) {
+			c.since = s.RevokedAt
+		}
+	}
+	return nil
+}
+
+func (c *Cache) flush() {
+	c.seenMu.Lock()
+	seen := c.seen
+	c.seen = make(map[int64]time.Time)
+	c.seenMu.Unlock()
+
+	if len(seen) == 0 {
+		return
+	}
+	if err := c.store.TouchSessions(seen); err != nil {
+		c.log.Error("failed to write session activity", sl.Err(err), slog.Int("sessions", len(seen)))
+	}
+}
@@ -0,0 +1,125 @@
+package sessions
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeStore answers GetRevoked like the Postgres store, from revocations the tests add, and
+// records what the cache asked for and wrote.
+type fakeStore struct {
+	sessions.SessionStore
+	revoked []models.RevokedSession
+	asked   []time.Time
+	touched []map[int64]time.Time
+}
+
+func (s *fakeStore) GetRevoked(since time.Time) ([]models.RevokedSession, error) {
+	s.asked = append(s.asked, since)
+	var list []models.RevokedSession
+	for _, r := range s.revoked {
+		if !r.RevokedAt.Before(since) && r.ExpiresAt.After(time.Now()) {
+			list = append(list, r)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeStore) TouchSessions(seen map[int64]time.Time) error {
+	s.touched = append(s.touched, seen)
+	return nil
+}
+
+func newTestCache(store *fakeStore) *Cache {
+	return NewCache(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestCacheRefreshOverlap(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	store := &fakeStore{revoked: []models.RevokedSession{{ID: 1, RevokedAt: now.Add(-time.Hour), ExpiresAt: expires}}}
+	c := newTestCache(store)
+
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !store.asked[0].IsZero() {
+		t.Errorf("first load asked since %v, want the whole list", store.asked[0])
+	}
+
+	// Session 2 was revoked by a transaction that committed after the one revoking session 3,
+	// although its revoked_at is earlier; a refresh reading from the last revoked_at would miss it.
+	store.revoked = append(store.revoked, models.RevokedSession{ID: 3, RevokedAt: now, ExpiresAt: expires})
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	store.revoked = append(
+		store.revoked, models.RevokedSession{ID: 2, RevokedAt: now.Add(-30 * time.Second), ExpiresAt: expires},
+	)
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := now.Add(-refreshOverlap); !store.asked[2].Equal(want) {
+		t.Errorf("refresh asked since %v, want the last revocation minus the overlap %v", store.asked[2], want)
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if !c.Revoked(id) {
+			t.Errorf("session %d is not revoked", id)
+		}
+	}
+	if c.Revoked(4) {
+		t.Error("session 4 is revoked")
+	}
+}
+
+func TestCacheDropsExpiredSessions(t *testing.T) {
+	store := &fakeStore{}
+	c := newTestCache(store)
+	c.Revoke(
+		models.RevokedSession{ID: 1, ExpiresAt: time.Now().Add(-time.Second)},
+		models.RevokedSession{ID: 2, ExpiresAt: time.Now().Add(time.Hour)},
+	)
+
+	// Revocations made by this instance apply before any refresh.
+	if !c.Revoked(1) || !c.Revoked(2) {
+		t.Fatal("revocations of this instance do not apply at once")
+	}
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Revoked(1) {
+		t.Error("session whose tokens expired is still listed")
+	}
+	if !c.Revoked(2) {
+		t.Error("session 2 is no longer revoked")
+	}
+}
+
+func TestCacheFlushesActivityOnStop(t *testing.T) {
+	store := &fakeStore{}
+	c := newTestCache(store)
+	c.Touch(5)
+	c.Touch(6)
+	seen6, _ := c.LastActive(6)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx)
+
+	if len(store.touched) != 1 {
+		t.Fatalf("wrote activity %d times, want 1", len(store.touched))
+	}
+	if got := store.touched[0]; len(got) != 2 || !got[6].Equal(seen6) {
+		t.Errorf("wrote %v, want sessions 5 and 6", got)
+	}
+	if _, ok := c.LastActive(6); ok {
+		t.Error("activity is still buffered after the flush")
+	}
+}
@@ -0,0 +1,116 @@
+package sessions
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// othersParam as session id revokes all the sessions but the one of the request.
+const othersParam = "others"
+
+// Handler lists the devices the user is logged in on and logs them out.
+type Handler struct {
+	store    sessions.SessionStore
+	cache    *Cache
+	recorder *audit.Recorder
+	log      *slog.Logger
+	cfg      config.Config
+}
+
+func NewHandler(store sessions.SessionStore, cache *Cache, recorder *audit.Recorder, log *slog.Logger) *Handler {
+	return &Handler{store: store, cache: cache, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+// HandleGetSessions returns the active sessions of the user, marking the one of the request.
+func (h *Handler) HandleGetSessions(w http.ResponseWriter, r *http.Request) {
+	const op = "sessions.HandleGetSessions"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetSessions(user.ID)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	current := mwAuth.Session(r.Context())
+	for i := range list {
+		list[i].Current = list[i].ID == current
+		if t, ok := h.cache.LastActive(list[i].ID); ok && t.After(list[i].LastActiveAt) {
+			list[i].LastActiveAt = t
+		}
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleDeleteSession logs a device out, or with "others" as id all the devices but the one of
+// the request. The tokens of a revoked session are refused from then on.
+func (h *Handler) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	const op = "sessions.HandleDeleteSession"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var revoked []models.RevokedSession
+	if param := chi.URLParam(r, "id"); param == othersParam {
+		list, err := h.store.RevokeOtherSessions(user.ID, mwAuth.Session(r.Context()))
+		if err != nil {
+			log.Error("failed to revoke sessions", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		revoked = list
+	} else {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid session id"})
+			return
+		}
+		s, err := h.store.RevokeSession(user.ID, id)
+		if err != nil {
+			if errors.Is(err, sessions.SessionNotFound) {
+				resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": sessions.SessionNotFound.Error()})
+				return
+			}
+			log.Error("failed to revoke session", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+		revoked = append(revoked, *s)
+	}
+
+	h.cache.Revoke(revoked...)
+
+	ids := make([]int64, len(revoked))
+	for i, s := range revoked {
+		ids[i] = s.ID
+	}
+	if len(ids) > 0 {
+		h.recorder.Record(r, models.AuditSessionRevoked, user.ID, user.ID, map[string][]int64{"session_ids": ids})
+	}
+	log.Info("sessions revoked", slog.Any("session_ids", ids))
+	resp.JSON(w, r, http.StatusOK, map[string]int{"revoked": len(ids)})
+}
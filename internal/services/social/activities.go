@@ -0,0 +1,446 @@
+package social
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// HandleGetFeed returns the activities of the user and of the followed users, newest first.
+// The next page is requested with ?cursor= set to the returned next_cursor.
+func (h *Handler) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetFeed"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	after, limit, ok := page(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetFeed(user.ID, after, limit+1)
+	if err != nil {
+		log.Error("failed to get feed", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, activityPage(list, limit))
+}
+
+// HandleGetUserActivities returns the activities of a user the reading user is allowed to see.
+func (h *Handler) HandleGetUserActivities(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetUserActivities"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	after, limit, ok := page(w, r)
+	if !ok {
+		return
+	}
+
+	visible, err := h.visibilities(user.ID, id)
+	if err != nil {
+		log.Error("failed to get visibilities", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if len(visible) == 0 {
+		resp.JSON(w, r, http.StatusForbidden, map[string]string{"error": "profile is private"})
+		return
+	}
+
+	list, err := h.store.GetUserActivities(user.ID, id, visible, after, limit+1)
+	if err != nil {
+		log.Error("failed to get activities", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, activityPage(list, limit))
+}
+
+func (h *Handler) HandleGetActivity(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetActivity"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	activity, ok := h.visibleActivity(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, activity)
+}
+
+// HandleUpdateActivity changes who sees an activity of the user.
+func (h *Handler) HandleUpdateActivity(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleUpdateActivity"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid activity id"})
+		return
+	}
+
+	var payload models.UpdateActivityPayload
+
+	err = render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if err := h.store.SetVisibility(user.ID, id, payload.Visibility); err != nil {
+		if errors.Is(err, social.ActivityNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to update visibility", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	activity, err := h.store.GetActivity(user.ID, id)
+	if err != nil {
+		log.Error("failed to get activity", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, activity)
+}
+
+func (h *Handler) HandleAddKudos(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleAddKudos"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	activity, ok := h.visibleActivity(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+	if activity.UserID == user.ID {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "cannot give kudos to your own activity"})
+		return
+	}
+
+	if err := h.store.AddKudos(activity.ID, user.ID); err != nil {
+		log.Error("failed to add kudos", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleRemoveKudos(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleRemoveKudos"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid activity id"})
+		return
+	}
+
+	if err := h.store.RemoveKudos(id, user.ID); err != nil {
+		log.Error("failed to remove kudos", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleGetComments returns the comments oldest first. The next page is requested with ?after=<last comment id>.
+func (h *Handler) HandleGetComments(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetComments"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	activity, ok := h.visibleActivity(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+
+	var afterID int64
+	if str := r.URL.Query().Get("after"); str != "" {
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || n < 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid after"})
+			return
+		}
+		afterID = n
+	}
+	_, limit, ok := page(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetComments(activity.ID, afterID, limit)
+	if err != nil {
+		log.Error("failed to get comments", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleCreateComment(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleCreateComment"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	activity, ok := h.visibleActivity(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+
+	var payload models.CreateCommentPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	comment := models.Comment{
+		ActivityID: activity.ID, UserID: user.ID, Username: user.Username, Body: payload.Body,
+	}
+	if err := h.store.CreateComment(&comment); err != nil {
+		log.Error("failed to create comment", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusCreated, comment)
+}
+
+// HandleDeleteComment lets the author of a comment or of the commented activity delete it.
+func (h *Handler) HandleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleDeleteComment"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid comment id"})
+		return
+	}
+
+	if err := h.store.DeleteComment(id, user.ID); err != nil {
+		if errors.Is(err, social.CommentNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete comment", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// visibleActivity loads the activity of the URL and answers 404 when the user may not see it,
+// so that hidden activities cannot be told apart from missing ones.
+func (h *Handler) visibleActivity(
+	w http.ResponseWriter, r *http.Request, log *slog.Logger, userID int,
+) (*models.Activity, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid activity id"})
+		return nil, false
+	}
+
+	activity, err := h.store.GetActivity(userID, id)
+	if err != nil {
+		if errors.Is(err, social.ActivityNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return nil, false
+		}
+		log.Error("failed to get activity", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+
+	visible, err := h.visibilities(userID, activity.UserID)
+	if err != nil {
+		log.Error("failed to get visibilities", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+	if !slices.Contains(visible, activity.Visibility) {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": social.ActivityNotFound.Error()})
+		return nil, false
+	}
+	return activity, true
+}
+
+// visibilities returns the visibilities of the owner's activities the viewer may see. Accepted
+// followers see public and followers activities, other users only the public activities of
+// public profiles, and blocked users nothing.
+func (h *Handler) visibilities(viewerID int, ownerID int) ([]string, error) {
+	if viewerID == ownerID {
+		return []string{models.VisibilityPublic, models.VisibilityFollowers, models.VisibilityPrivate}, nil
+	}
+
+	blocked, err := h.store.IsBlocked(viewerID, ownerID)
+	if err != nil || blocked {
+		return nil, err
+	}
+
+	follow, err := h.store.GetFollow(viewerID, ownerID)
+	if err != nil && !errors.Is(err, social.FollowNotFound) {
+		return nil, err
+	}
+	if follow != nil && follow.Status == models.FollowAccepted {
+		return []string{models.VisibilityPublic, models.VisibilityFollowers}, nil
+	}
+
+	settings, err := h.store.GetSettings(ownerID)
+	if err != nil || settings.Private {
+		return nil, err
+	}
+	return []string{models.VisibilityPublic}, nil
+}
+
+// page reads the ?cursor= and ?limit= query parameters.
+func page(w http.ResponseWriter, r *http.Request) (*cursor.Cursor, int, bool) {
+	after, err := cursor.Decode(r.URL.Query().Get("cursor"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, 0, false
+	}
+
+	limit := defaultPageLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return nil, 0, false
+		}
+		limit = min(l, maxPageLimit)
+	}
+	return after, limit, true
+}
+
+// activityPage cuts list, loaded with one item more than limit, and points the cursor at the last item kept.
+func activityPage(list []models.Activity, limit int) models.ActivityPage {
+	if len(list) <= limit {
+		return models.ActivityPage{Items: list}
+	}
+	list = list[:limit]
+	last := list[limit-1]
+	return models.ActivityPage{Items: list, NextCursor: cursor.Encode(cursor.Cursor{At: last.OccurredAt, ID: last.ID})}
+}
@@ -0,0 +1,164 @@
+package social
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"log/slog"
+	"strconv"
+)
+
+// Publisher turns workouts, personal records and achievements into activities shown in the feeds.
+// The activities of a workout follow it when it changes or is deleted.
+type Publisher struct {
+	store        social.SocialStore
+	workoutStore workouts.WorkoutStore
+	log          *slog.Logger
+}
+
+func NewPublisher(store social.SocialStore, workoutStore workouts.WorkoutStore, log *slog.Logger) *Publisher {
+	return &Publisher{
+		store: store, workoutStore: workoutStore, log: log.With(slog.String("component", "social/publisher")),
+	}
+}
+
+func (p *Publisher) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.WorkoutCreated, p.handle)
+	bus.Subscribe(events.WorkoutUpdated, p.handleWorkoutUpdated)
+	bus.Subscribe(events.WorkoutDeleted, p.handleWorkoutDeleted)
+	bus.Subscribe(events.RecordBroken, p.handle)
+	bus.Subscribe(events.AchievementUnlocked, p.handle)
+}
+
+type workoutPayload struct {
+	WorkoutID int    `json:"workout_id"`
+	Name      string `json:"name"`
+	Duration  int    `json:"duration"`
+	Distance  int    `json:"distance"`
+	Sets      int    `json:"sets"`
+}
+
+type recordPayload struct {
+	WorkoutID *int          `json:"workout_id,omitempty"`
+	Records   []recordEntry `json:"records"`
+}
+
+type recordEntry struct {
+	ExerciseID int     `json:"exercise_id"`
+	Exercise   string  `json:"exercise"`
+	Kind       string  `json:"kind"`
+	Value      float64 `json:"value"`
+}
+
+type achievementPayload struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (p *Publisher) handle(e events.Event) {
+	log := p.log.With(slog.String("event", e.Type), slog.Int("user_id", e.UserID))
+
+	activity, err := p.activity(e)
+	if err != nil {
+		log.Error("failed to build activity", sl.Err(err))
+		return
+	}
+	if activity == nil {
+		return
+	}
+
+	settings, err := p.store.GetSettings(e.UserID)
+	if err != nil {
+		log.Error("failed to get social settings", sl.Err(err))
+		return
+	}
+	activity.UserID = e.UserID
+	activity.Visibility = settings.DefaultVisibility
+
+	if err := p.store.SaveActivity(activity); err != nil {
+		log.Error("failed to save activity", sl.Err(err))
+	}
+}
+
+func (p *Publisher) handleWorkoutUpdated(e events.Event) {
+	log := p.log.With(slog.String("event", e.Type), slog.Int("user_id", e.UserID))
+
+	activity, err := p.activity(e)
+	if err != nil {
+		log.Error("failed to build activity", sl.Err(err))
+		return
+	}
+	if activity == nil {
+		return
+	}
+	activity.UserID = e.UserID
+
+	err = p.store.UpdateActivity(activity)
+	if err != nil && !errors.Is(err, social.ActivityNotFound) {
+		log.Error("failed to update activity", sl.Err(err))
+	}
+}
+
+// handleWorkoutDeleted deletes the activity of the workout and the one of the records set in it.
+func (p *Publisher) handleWorkoutDeleted(e events.Event) {
+	workout, ok := e.Payload.(models.Workout)
+	if !ok {
+		return
+	}
+
+	err := p.store.DeleteActivities(
+		e.UserID, strconv.Itoa(workout.ID), []string{models.ActivityWorkout, models.ActivityRecord},
+	)
+	if err != nil {
+		p.log.Error(
+			"failed to delete activities", sl.Err(err), slog.Int("user_id", e.UserID),
+			slog.Int("workout_id", workout.ID),
+		)
+	}
+}
+
+// activity builds the activity of the event. The records of one workout share an activity.
+func (p *Publisher) activity(e events.Event) (*models.Activity, error) {
+	var a models.Activity
+	var payload any
+
+	switch v := e.Payload.(type) {
+	case models.Workout:
+		a.Kind, a.Ref, a.OccurredAt = models.ActivityWorkout, strconv.Itoa(v.ID), v.StartedAt
+		payload = workoutPayload{
+			WorkoutID: v.ID, Name: v.Name, Duration: v.Duration, Distance: v.Distance, Sets: len(v.Sets),
+		}
+	case models.PersonalRecord:
+		exercise, err := p.workoutStore.GetExerciseByID(v.ExerciseID)
+		if err != nil {
+			return nil, err
+		}
+		a.Kind, a.OccurredAt = models.ActivityRecord, v.AchievedAt
+		a.Ref = fmt.Sprintf("exercise:%d:%s", v.ExerciseID, v.Kind)
+		if v.WorkoutID != nil {
+			a.Ref = strconv.Itoa(*v.WorkoutID)
+		}
+		payload = recordPayload{
+			WorkoutID: v.WorkoutID,
+			Records:   []recordEntry{{ExerciseID: v.ExerciseID, Exercise: exercise.Name, Kind: v.Kind, Value: v.Value}},
+		}
+	case models.Achievement:
+		a.Kind, a.Ref, a.OccurredAt = models.ActivityAchievement, v.Code, e.OccurredAt
+		payload = achievementPayload{Code: v.Code, Name: v.Name, Description: v.Description}
+	default:
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	a.Payload = raw
+	return &a, nil
+}
@@ -0,0 +1,384 @@
+package social
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/social"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+type Handler struct {
+	store     social.SocialStore
+	userStore users.UserStore
+	log       *slog.Logger
+	cfg       config.Config
+}
+
+func NewHandler(store social.SocialStore, userStore users.UserStore, log *slog.Logger) *Handler {
+	return &Handler{store: store, userStore: userStore, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleGetSettings(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetSettings"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	settings, err := h.store.GetSettings(user.ID)
+	if err != nil {
+		log.Error("failed to get social settings", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, settings)
+}
+
+// HandleUpdateSettings changes the given settings. Making the profile public accepts the pending follow requests.
+func (h *Handler) HandleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleUpdateSettings"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.UpdateSocialSettingsPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	settings, err := h.store.GetSettings(user.ID)
+	if err != nil {
+		log.Error("failed to get social settings", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	wasPrivate := settings.Private
+	if payload.Private != nil {
+		settings.Private = *payload.Private
+	}
+	if payload.DefaultVisibility != "" {
+		settings.DefaultVisibility = payload.DefaultVisibility
+	}
+
+	if err := h.store.SaveSettings(*settings); err != nil {
+		log.Error("failed to save social settings", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	if wasPrivate && !settings.Private {
+		if err := h.store.AcceptPendingFollows(user.ID); err != nil {
+			log.Error("failed to accept pending follows", sl.Err(err))
+			resp.Internal(w, r)
+			return
+		}
+	}
+
+	resp.JSON(w, r, http.StatusOK, settings)
+}
+
+// HandleFollow follows a user right away, or sends a follow request when the profile is private.
+func (h *Handler) HandleFollow(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleFollow"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, ok := h.otherUser(w, r, log, user.ID)
+	if !ok {
+		return
+	}
+
+	settings, err := h.store.GetSettings(id)
+	if err != nil {
+		log.Error("failed to get social settings", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	status := models.FollowAccepted
+	if settings.Private {
+		status = models.FollowPending
+	}
+
+	if err := h.store.CreateFollow(user.ID, id, status); err != nil {
+		log.Error("failed to follow user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	follow, err := h.store.GetFollow(user.ID, id)
+	if err != nil {
+		log.Error("failed to get follow", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusCreated, follow)
+}
+
+// HandleUnfollow stops following a user or cancels a follow request.
+func (h *Handler) HandleUnfollow(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleUnfollow"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.store.DeleteFollow(user.ID, id); err != nil {
+		if errors.Is(err, social.FollowNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to unfollow user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	h.followers(w, r, "social.HandleGetFollowers", models.FollowAccepted)
+}
+
+func (h *Handler) HandleGetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	h.followers(w, r, "social.HandleGetFollowRequests", models.FollowPending)
+}
+
+func (h *Handler) followers(w http.ResponseWriter, r *http.Request, op string, status string) {
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetFollowers(user.ID, status)
+	if err != nil {
+		log.Error("failed to get followers", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleGetFollowing"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetFollowing(user.ID)
+	if err != nil {
+		log.Error("failed to get following", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleApproveFollowRequest accepts the request of the follower given by id.
+func (h *Handler) HandleApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleApproveFollowRequest"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.store.AcceptFollow(id, user.ID); err != nil {
+		if errors.Is(err, social.FollowNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to accept follow", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleRejectFollowRequest declines a pending request or removes an accepted follower.
+func (h *Handler) HandleRejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleRejectFollowRequest"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.store.DeleteFollow(id, user.ID); err != nil {
+		if errors.Is(err, social.FollowNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to delete follow", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleBlock(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleBlock"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id == user.ID {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.Block(user.ID, id); err != nil {
+		log.Error("failed to block user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleUnblock(w http.ResponseWriter, r *http.Request) {
+	const op = "social.HandleUnblock"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.store.Unblock(user.ID, id); err != nil {
+		log.Error("failed to unblock user", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// otherUser reads the id of the user the request is about and checks that the user exists
+// and that neither blocked the other. Blocked users look like missing ones.
+func (h *Handler) otherUser(w http.ResponseWriter, r *http.Request, log *slog.Logger, userID int) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id == userID {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		return 0, false
+	}
+
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		if errors.Is(err, users.UserNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return 0, false
+		}
+		log.Error("failed to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return 0, false
+	}
+
+	blocked, err := h.store.IsBlocked(userID, id)
+	if err != nil {
+		log.Error("failed to check block", sl.Err(err))
+		resp.Internal(w, r)
+		return 0, false
+	}
+	if blocked {
+		resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return 0, false
+	}
+	return id, true
+}
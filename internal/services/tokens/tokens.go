@@ -0,0 +1,168 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// hintLength is how much of the token, prefix included, is kept to recognize it.
+const hintLength = 10
+
+// Handler manages the personal access tokens of the user. Tokens are sent as
+// "Authorization: Bearer afp_..." and work on the routes behind mwAuth.NewScoped, within their
+// scopes.
+type Handler struct {
+	store    tokens.TokenStore
+	usage    *Usage
+	recorder *audit.Recorder
+	log      *slog.Logger
+	cfg      config.Config
+}
+
+func NewHandler(store tokens.TokenStore, usage *Usage, recorder *audit.Recorder, log *slog.Logger) *Handler {
+	return &Handler{store: store, usage: usage, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+// HandleCreateToken issues a token. The response is the only time the token is shown.
+func (h *Handler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
+	const op = "tokens.HandleCreateToken"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreatePersonalTokenPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	secret := models.PersonalTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	scopes := slices.Clone(payload.Scopes)
+	slices.Sort(scopes)
+	t := models.PersonalToken{
+		UserID:    user.ID,
+		Name:      payload.Name,
+		Hint:      secret[:hintLength],
+		Scopes:    slices.Compact(scopes),
+		ExpiresAt: payload.ExpiresAt,
+	}
+	if err := h.store.CreateToken(&t, secret); err != nil {
+		if errors.Is(err, tokens.TokenExists) {
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": tokens.TokenExists.Error()})
+			return
+		}
+		log.Error("failed to create token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditTokenCreated, user.ID, user.ID, t)
+	log.Info("personal token created", slog.Int("token_id", t.ID), slog.Any("scopes", t.Scopes))
+	resp.JSON(w, r, http.StatusCreated, map[string]any{"token": t, "secret": secret})
+}
+
+func (h *Handler) HandleGetTokens(w http.ResponseWriter, r *http.Request) {
+	const op = "tokens.HandleGetTokens"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetTokens(user.ID)
+	if err != nil {
+		log.Error("failed to get tokens", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	for i := range list {
+		if t, ok := h.usage.LastUsed(list[i].ID); ok && (list[i].LastUsedAt == nil || t.After(*list[i].LastUsedAt)) {
+			list[i].LastUsedAt = &t
+		}
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleDeleteToken revokes the token, requests made with it fail from then on.
+func (h *Handler) HandleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	const op = "tokens.HandleDeleteToken"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid token id"})
+		return
+	}
+
+	if err := h.store.DeleteToken(user.ID, id); err != nil {
+		if errors.Is(err, tokens.TokenNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": tokens.TokenNotFound.Error()})
+			return
+		}
+		log.Error("failed to delete token", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditTokenRevoked, user.ID, user.ID, map[string]int{"token_id": id})
+	log.Info("personal token revoked", slog.Int("token_id", id))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
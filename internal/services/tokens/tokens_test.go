@@ -0,0 +1,126 @@
+package tokens
+
+import (
+	"encoding/json"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	audit2 "github.com/stanislavCasciuc/atom-fit-go/internal/store/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func TestMain(m *testing.M) {
+	config.Envs.JwtCfg.Secret = testSecret
+	os.Exit(m.Run())
+}
+
+// fakeStore keeps what the Postgres store keeps: the token without its secret.
+type fakeStore struct {
+	tokens.TokenStore
+	list    []models.PersonalToken
+	secrets []string
+}
+
+func (s *fakeStore) CreateToken(t *models.PersonalToken, token string) error {
+	t.ID = len(s.list) + 1
+	t.CreatedAt = time.Now()
+	s.list = append(s.list, *t)
+	s.secrets = append(s.secrets, token)
+	return nil
+}
+
+func (s *fakeStore) GetTokens(int) ([]models.PersonalToken, error) {
+	return s.list, nil
+}
+
+type fakeUsers struct{ users.UserStore }
+
+func (fakeUsers) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) Revoked(int64) bool { return false }
+
+func (fakeSessions) Touch(int64) {}
+
+type fakeAudit struct{ audit2.AuditStore }
+
+func (fakeAudit) Append(*models.AuditEvent) error { return nil }
+
+func send(t *testing.T, h http.HandlerFunc, method string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := jwt.NewToken(models.User{ID: 7}, 1, nil, time.Minute, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, "/api/me/tokens", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mwAuth.New(fakeUsers{}, fakeSessions{}, log)(h).ServeHTTP(w, r)
+	return w
+}
+
+func TestSecretShownOnlyAtCreation(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := &fakeStore{}
+	h := NewHandler(store, NewUsage(store, log), audit.NewRecorder(fakeAudit{}, log), log)
+
+	w := send(t, h.HandleCreateToken, http.MethodPost, `{"name":"script","scopes":["workouts:read","profile"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %d, want 201: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Token  models.PersonalToken `json:"token"`
+		Secret string               `json:"secret"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Secret, models.PersonalTokenPrefix) || created.Secret != store.secrets[0] {
+		t.Fatalf("created secret = %q, want the stored afp_ token", created.Secret)
+	}
+	if created.Token.Hint != created.Secret[:hintLength] {
+		t.Errorf("hint = %q, want %q", created.Token.Hint, created.Secret[:hintLength])
+	}
+	if got := strings.Join(created.Token.Scopes, " "); got != "profile workouts:read" {
+		t.Errorf("scopes = %q, want them sorted", got)
+	}
+
+	w = send(t, h.HandleGetTokens, http.MethodGet, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list = %d, want 200: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), created.Secret) || strings.Contains(w.Body.String(), `"secret"`) {
+		t.Errorf("token list shows the secret: %s", w.Body)
+	}
+}
+
+func TestCreateTokenRejectsPastExpiry(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := &fakeStore{}
+	h := NewHandler(store, NewUsage(store, log), audit.NewRecorder(fakeAudit{}, log), log)
+
+	body := `{"name":"script","scopes":["profile"],"expires_at":"2000-01-01T00:00:00Z"}`
+	if w := send(t, h.HandleCreateToken, http.MethodPost, body); w.Code != http.StatusBadRequest {
+		t.Errorf("create = %d, want 400", w.Code)
+	}
+	if len(store.list) != 0 {
+		t.Errorf("stored %d tokens, want none", len(store.list))
+	}
+}
@@ -0,0 +1,76 @@
+package tokens
+
+import (
+	"context"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/tokens"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const flushInterval = 15 * time.Second
+
+// Usage buffers the last use of the personal access tokens, which is written every flushInterval,
+// so that busy scripts do not cause a write per request.
+type Usage struct {
+	store tokens.TokenStore
+	log   *slog.Logger
+
+	mu   sync.Mutex
+	used map[int]time.Time
+}
+
+func NewUsage(store tokens.TokenStore, log *slog.Logger) *Usage {
+	return &Usage{
+		store: store,
+		log:   log.With(slog.String("component", "tokens/usage")),
+		used:  make(map[int]time.Time),
+	}
+}
+
+func (u *Usage) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			u.flush()
+			return
+		case <-ticker.C:
+			u.flush()
+		}
+	}
+}
+
+// Touch records a request made with the token.
+func (u *Usage) Touch(id int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.used[id] = time.Now()
+}
+
+// LastUsed returns the use of the token not written yet.
+func (u *Usage) LastUsed(id int) (time.Time, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	t, ok := u.used[id]
+	return t, ok
+}
+
+func (u *Usage) flush() {
+	u.mu.Lock()
+	used := u.used
+	u.used = make(map[int]time.Time)
+	u.mu.Unlock()
+
+	if len(used) == 0 {
+		return
+	}
+	if err := u.store.TouchTokens(used); err != nil {
+		u.log.Error("failed to write token usage", sl.Err(err), slog.Int("tokens", len(used)))
+	}
+}
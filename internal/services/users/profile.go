@@ -0,0 +1,154 @@
+package users
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+// HandleGetProfile returns the profile of the user. Apps read it with the profile scope.
+func (h *Handler) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	user := mwAuth.User(r.Context())
+
+	resp.JSON(
+		w, r, http.StatusOK, models.Profile{
+			ID:         user.ID,
+			Email:      user.Email,
+			Username:   user.Username,
+			IsMale:     user.IsMale,
+			Age:        user.Age,
+			Height:     user.Height,
+			Weight:     user.Weight,
+			Goal:       user.Goal,
+			WeightGoal: user.WeightGoal,
+			CreatedAt:  user.CreatedAt,
+		},
+	)
+}
+
+// HandleUpdateProfile replaces the profile of the user. The changed fields are recorded in the
+// audit log.
+func (h *Handler) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	const op = "users.HandleUpdateProfile"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.UpdateProfilePayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	updated := *user
+	updated.Username = payload.Username
+	updated.IsMale = payload.IsMale
+	updated.Age = payload.Age
+	updated.Height = payload.Height
+	updated.Weight = payload.Weight
+	updated.Goal = payload.Goal
+	updated.WeightGoal = payload.WeightGoal
+
+	changes := audit.Diff(user, &updated)
+	if len(changes) == 0 {
+		resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+		return
+	}
+
+	if err := h.store.UpdateUser(user.ID, updated); err != nil {
+		log.Error("failed to update profile", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditProfileUpdated, user.ID, user.ID, changes)
+	log.Info("profile updated")
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleChangePassword sets a new password after checking the current one. Tokens issued
+// before stay valid until they expire.
+func (h *Handler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	const op = "users.HandleChangePassword"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.ChangePasswordPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.Password, []byte(payload.CurrentPassword)); err != nil {
+		log.Warn("wrong current password")
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid credentials"})
+		return
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("error to hash password", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	if err := h.store.UpdatePassword(user.ID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditPasswordChanged, user.ID, user.ID, nil)
+	log.Info("password changed")
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
@@ -10,24 +10,40 @@ import (
 	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/jwt"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/services/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/preferences"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/roles"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/sessions"
 	"github.com/stanislavCasciuc/atom-fit-go/internal/store/users"
 	"golang.org/x/crypto/bcrypt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
 	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
 )
 
 type Handler struct {
-	store users.UserStore
-	log   *slog.Logger
-	cfg   config.Config
+	store        users.UserStore
+	prefsStore   preferences.PreferencesStore
+	roleStore    roles.RoleStore
+	sessionStore sessions.SessionStore
+	recorder     *audit.Recorder
+	log          *slog.Logger
+	cfg          config.Config
 }
 
-func NewHandler(store users.UserStore, log *slog.Logger) *Handler {
-	return &Handler{store: store, log: log, cfg: config.Envs}
+func NewHandler(
+	store users.UserStore, prefsStore preferences.PreferencesStore, roleStore roles.RoleStore,
+	sessionStore sessions.SessionStore, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{
+		store: store, prefsStore: prefsStore, roleStore: roleStore, sessionStore: sessionStore, recorder: recorder,
+		log: log, cfg: config.Envs,
+	}
 }
 
 func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
@@ -60,31 +76,63 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.With(slog.String("email", payload.Email))
+	log = log.With(slog.String("email", payload.Email))
 
 	u, err := h.store.GetUserByEmail(payload.Email)
 	if err != nil {
-		if errors.Is(users.UserNotFound, err) {
-			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": users.UserNotFound.Error()})
-			log.Error("users not found")
-			return
-		}
 		resp.Internal(w, r)
 		log.Error("failed to get users", sl.Err(err))
+		return
+	}
+	// An unknown email answers like a wrong password, so logins cannot be used to find accounts.
+	if u == nil {
+		h.recorder.Record(r, models.AuditLoginFailed, 0, 0, map[string]string{"email": payload.Email})
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid credentials"})
+		log.Warn("users not found")
+		return
 	}
 
 	err = bcrypt.CompareHashAndPassword(u.Password, []byte(payload.Password))
 	if err != nil {
+		h.recorder.Record(r, models.AuditLoginFailed, 0, u.ID, map[string]string{"email": payload.Email})
 		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid credentials"})
-		log.Error("invalid credentials", sl.Err(err))
+		log.Warn("invalid credentials", sl.Err(err))
+		return
 	}
 
-	token, err := jwt.NewToken(*u, h.cfg.JwtCfg.Exp, h.cfg.JwtCfg.Secret)
+	perms, err := h.roleStore.GetUserPermissions(u.ID)
+	if err != nil {
+		resp.Internal(w, r)
+		log.Error("failed to get permissions", sl.Err(err))
+		return
+	}
+
+	// Every login is a session of its own, which the user can revoke from another device.
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	session := models.Session{
+		UserID:     u.ID,
+		DeviceName: payload.DeviceName,
+		UserAgent:  r.UserAgent(),
+		IP:         ip,
+		ExpiresAt:  time.Now().Add(h.cfg.JwtCfg.Exp),
+	}
+	if err := h.sessionStore.CreateSession(&session); err != nil {
+		resp.Internal(w, r)
+		log.Error("failed to create session", sl.Err(err))
+		return
+	}
+
+	token, err := jwt.NewToken(*u, session.ID, perms, h.cfg.JwtCfg.Exp, h.cfg.JwtCfg.Secret)
 	if err != nil {
 		resp.Internal(w, r)
 		log.Error("cannot to create token", sl.Err(err))
+		return
 	}
 
+	h.recorder.Record(r, models.AuditLoginSucceeded, u.ID, u.ID, map[string]int64{"session_id": session.ID})
 	resp.JSON(w, r, http.StatusOK, map[string]string{"token": token})
 
 }
@@ -128,6 +176,7 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("error to hash password", sl.Err(err))
 		resp.Internal(w, r)
+		return
 	}
 
 	id, activationCode, err := h.store.CreateUser(payload, passHash)
@@ -143,6 +192,7 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, models.AuditRegistered, id, id, map[string]string{"email": payload.Email})
 	resp.JSON(w, r, http.StatusCreated, map[string]int{"user_id": id})
 
 	err = email.SendVerifyUser(payload.Username, payload.Email, activationCode)
@@ -178,6 +228,8 @@ func (h *Handler) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		log.Error("error to get user", sl.Err(err))
+		resp.Internal(w, r)
+		return
 	}
 	log.With(slog.Int("user_id", user.ID))
 	if *user.ActivationCode != payload.ActivationCode {
@@ -188,12 +240,13 @@ func (h *Handler) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	user.IsActive = true
 
-	if err := h.store.UpdateUser(user.ID, user); err != nil {
+	if err := h.store.UpdateUser(user.ID, *user); err != nil {
 		log.Error("cannot update users data", sl.Err(err))
 		resp.Internal(w, r)
 		return
 	}
 
+	h.recorder.Record(r, models.AuditActivated, user.ID, user.ID, nil)
 	log.Info("user successfully activated")
 	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
 }
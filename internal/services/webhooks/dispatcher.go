@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/webhooks"
+	"log/slog"
+)
+
+// Dispatcher turns the domain events webhooks can subscribe to into deliveries for the worker.
+type Dispatcher struct {
+	store  webhooks.WebhookStore
+	worker *Worker
+	log    *slog.Logger
+}
+
+func NewDispatcher(store webhooks.WebhookStore, worker *Worker, log *slog.Logger) *Dispatcher {
+	return &Dispatcher{store: store, worker: worker, log: log.With(slog.String("component", "webhooks/dispatcher"))}
+}
+
+func (d *Dispatcher) Subscribe(bus *events.Bus) {
+	for _, t := range []string{events.WorkoutCreated, events.WeightLogged, events.GoalCompleted} {
+		bus.Subscribe(t, d.handle)
+	}
+}
+
+func (d *Dispatcher) handle(e events.Event) {
+	log := d.log.With(slog.String("event", e.Type), slog.Int("user_id", e.UserID))
+
+	subscribers, err := d.store.GetSubscribers(e.UserID, e.Type)
+	if err != nil {
+		log.Error("failed to get subscribers", sl.Err(err))
+		return
+	}
+	if len(subscribers) == 0 {
+		return
+	}
+
+	data := e.Payload
+	if ge, ok := e.Payload.(models.GoalEvent); ok {
+		data = ge.Goal
+	}
+	payload, err := json.Marshal(
+		models.WebhookEnvelope{
+			ID: uuid.NewString(), Event: e.Type, OccurredAt: e.OccurredAt, UserID: e.UserID, Data: data,
+		},
+	)
+	if err != nil {
+		log.Error("failed to marshal payload", sl.Err(err))
+		return
+	}
+
+	ids := make([]int, len(subscribers))
+	for i, w := range subscribers {
+		ids[i] = w.ID
+	}
+	if err := d.store.Enqueue(ids, e.Type, payload); err != nil {
+		log.Error("failed to enqueue deliveries", sl.Err(err))
+		return
+	}
+	d.worker.Notify()
+}
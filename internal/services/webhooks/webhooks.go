@@ -0,0 +1,485 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/webhook"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/services/audit"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/webhooks"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// Handler manages partner apps and webhooks. The webhook routes serve two kinds of owners: under
+// /api/me they manage the user's own webhooks, under /api/admin/apps/{app} those of a partner app.
+type Handler struct {
+	store    webhooks.WebhookStore
+	worker   *Worker
+	recorder *audit.Recorder
+	log      *slog.Logger
+	cfg      config.Config
+}
+
+func NewHandler(
+	store webhooks.WebhookStore, worker *Worker, recorder *audit.Recorder, log *slog.Logger,
+) *Handler {
+	return &Handler{store: store, worker: worker, recorder: recorder, log: log, cfg: config.Envs}
+}
+
+func (h *Handler) HandleGetApps(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleGetApps"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetApps()
+	if err != nil {
+		log.Error("failed to get apps", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleCreateApp(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleCreateApp"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreatePartnerAppPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	app, err := h.store.CreateApp(payload.Name)
+	if err != nil {
+		if errors.Is(err, webhooks.AppExists) {
+			resp.JSON(w, r, http.StatusConflict, map[string]string{"error": webhooks.AppExists.Error()})
+			return
+		}
+		log.Error("failed to create app", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	h.recorder.Record(r, models.AuditAppCreated, user.ID, 0, app)
+	log.Info("partner app created", slog.Int("app_id", app.ID))
+	resp.JSON(w, r, http.StatusCreated, app)
+}
+
+func (h *Handler) HandleGetConnectedApps(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleGetConnectedApps"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	list, err := h.store.GetConnectedApps(user.ID)
+	if err != nil {
+		log.Error("failed to get connected apps", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// HandleConnectApp lets the partner app receive the user's events.
+func (h *Handler) HandleConnectApp(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleConnectApp"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	appID, err := strconv.Atoi(chi.URLParam(r, "app"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return
+	}
+
+	if err := h.store.ConnectApp(user.ID, appID); err != nil {
+		if errors.Is(err, webhooks.AppNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": webhooks.AppNotFound.Error()})
+			return
+		}
+		log.Error("failed to connect app", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("app connected", slog.Int("app_id", appID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+func (h *Handler) HandleDisconnectApp(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleDisconnectApp"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	appID, err := strconv.Atoi(chi.URLParam(r, "app"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return
+	}
+
+	if err := h.store.DisconnectApp(user.ID, appID); err != nil {
+		if errors.Is(err, webhooks.AppNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": webhooks.AppNotFound.Error()})
+			return
+		}
+		log.Error("failed to disconnect app", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("app disconnected", slog.Int("app_id", appID))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandleCreateWebhook subscribes a URL to events. The response holds the signing secret, which
+// cannot be read again.
+func (h *Handler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleCreateWebhook"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	owner, ok := h.owner(w, r, log)
+	if !ok {
+		return
+	}
+
+	var payload models.CreateWebhookPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	u, err := url.Parse(payload.URL)
+	if err != nil || !(u.Scheme == "https" || u.Scheme == "http" && h.cfg.Webhooks.AllowHTTP) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "webhook url must use https"})
+		return
+	}
+	if !h.allowedHost(u.Hostname()) {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "webhook url must point to a public host"})
+		return
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	hook := models.Webhook{
+		UserID: owner.UserID, AppID: owner.AppID, URL: payload.URL, Secret: secret, Events: payload.Events,
+	}
+	if err := h.store.CreateWebhook(&hook); err != nil {
+		log.Error("failed to create webhook", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("webhook created", slog.Int("webhook_id", hook.ID))
+	resp.JSON(w, r, http.StatusCreated, map[string]any{"webhook": hook, "secret": secret})
+}
+
+func (h *Handler) HandleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleGetWebhooks"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	owner, ok := h.owner(w, r, log)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetWebhooks(owner)
+	if err != nil {
+		log.Error("failed to get webhooks", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleDeleteWebhook"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	owner, ok := h.owner(w, r, log)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+		return
+	}
+
+	if err := h.store.DeleteWebhook(owner, id); err != nil {
+		if errors.Is(err, webhooks.WebhookNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": webhooks.WebhookNotFound.Error()})
+			return
+		}
+		log.Error("failed to delete webhook", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	log.Info("webhook deleted", slog.Int("webhook_id", id))
+	resp.JSON(w, r, http.StatusOK, map[string]string{"success": "ok"})
+}
+
+// HandlePing sends a ping event to the webhook right away and returns the logged delivery, so
+// that receivers can be tested without producing real events.
+func (h *Handler) HandlePing(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandlePing"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	hook, ok := h.webhook(w, r, log)
+	if !ok {
+		return
+	}
+
+	payload, err := json.Marshal(
+		models.WebhookEnvelope{
+			ID:         uuid.NewString(),
+			Event:      models.WebhookPing,
+			OccurredAt: time.Now(),
+			Data:       map[string]int{"webhook_id": hook.ID},
+		},
+	)
+	if err != nil {
+		log.Error("failed to marshal payload", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	d, err := h.store.CreateDelivery(hook.ID, models.WebhookPing, payload)
+	if err != nil {
+		log.Error("failed to create delivery", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	d, err = h.worker.Ping(r.Context(), models.DueDelivery{WebhookDelivery: *d, URL: hook.URL, Secret: hook.Secret})
+	if err != nil {
+		log.Error("failed to record attempt", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, d)
+}
+
+// HandleGetDeliveries returns the delivery log of the webhook, newest first.
+func (h *Handler) HandleGetDeliveries(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooks.HandleGetDeliveries"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	hook, ok := h.webhook(w, r, log)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxDeliveryLimit)
+	}
+
+	list, err := h.store.GetDeliveries(hook.ID, limit)
+	if err != nil {
+		log.Error("failed to get deliveries", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+// owner is the partner app of the {app} URL parameter, or the user when there is none.
+func (h *Handler) owner(w http.ResponseWriter, r *http.Request, log *slog.Logger) (models.WebhookOwner, bool) {
+	str := chi.URLParam(r, "app")
+	if str == "" {
+		user := mwAuth.User(r.Context())
+		return models.WebhookOwner{UserID: &user.ID}, true
+	}
+
+	appID, err := strconv.Atoi(str)
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid app id"})
+		return models.WebhookOwner{}, false
+	}
+	if _, err := h.store.GetApp(appID); err != nil {
+		if errors.Is(err, webhooks.AppNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": webhooks.AppNotFound.Error()})
+			return models.WebhookOwner{}, false
+		}
+		log.Error("failed to get app", sl.Err(err))
+		resp.Internal(w, r)
+		return models.WebhookOwner{}, false
+	}
+	return models.WebhookOwner{AppID: &appID}, true
+}
+
+// webhook loads the webhook of the {id} URL parameter belonging to the owner.
+func (h *Handler) webhook(w http.ResponseWriter, r *http.Request, log *slog.Logger) (*models.Webhook, bool) {
+	owner, ok := h.owner(w, r, log)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+		return nil, false
+	}
+
+	hook, err := h.store.GetWebhook(owner, id)
+	if err != nil {
+		if errors.Is(err, webhooks.WebhookNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": webhooks.WebhookNotFound.Error()})
+			return nil, false
+		}
+		log.Error("failed to get webhook", sl.Err(err))
+		resp.Internal(w, r)
+		return nil, false
+	}
+	return hook, true
+}
+
+// allowedHost rejects empty hosts and, unless private addresses are allowed, local names and
+// non-public IP literals. Host names are checked again on the resolved address by the worker.
+func (h *Handler) allowedHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if h.cfg.Webhooks.AllowPrivate {
+		return true
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return webhook.PublicAddr(addr)
+	}
+	return true
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
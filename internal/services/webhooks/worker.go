@@ -0,0 +1,206 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/webhook"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/webhooks"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	pollInterval = 10 * time.Second
+	claimBatch   = 20
+	// The first retry waits baseBackoff, every further one twice as long, up to maxBackoff.
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// Finished deliveries stay in the log for deliveryRetention.
+	deliveryRetention = 30 * 24 * time.Hour
+	purgeInterval     = time.Hour
+)
+
+// Worker sends the pending deliveries, retrying failed ones with exponential backoff until
+// cfg.MaxAttempts attempts failed. Any 2xx response counts as delivered; redirects are not followed.
+type Worker struct {
+	store     webhooks.WebhookStore
+	client    *http.Client
+	log       *slog.Logger
+	cfg       config.Webhooks
+	wake      chan struct{}
+	lastPurge time.Time
+}
+
+func NewWorker(store webhooks.WebhookStore, log *slog.Logger) *Worker {
+	cfg := config.Envs.Webhooks
+	dialer := &net.Dialer{Timeout: cfg.DeliveryTimeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = webhook.DialControl
+	}
+	return &Worker{
+		store: store,
+		client: &http.Client{
+			Timeout: cfg.DeliveryTimeout,
+			// No proxy: the dialer has to see the receiver's address to refuse internal ones.
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.DeliveryTimeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		log:  log.With(slog.String("component", "webhooks/worker")),
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the worker up without waiting for the next poll.
+func (wk *Worker) Notify() {
+	select {
+	case wk.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wk.purge()
+		wk.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wk.wake:
+		}
+	}
+}
+
+func (wk *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		// The batch is sent one delivery after another, so a claimed delivery must not be due again
+		// before every attempt of the batch could have timed out.
+		list, err := wk.store.ClaimDue(claimBatch, (claimBatch+1)*wk.cfg.DeliveryTimeout)
+		if err != nil {
+			wk.log.Error("failed to claim deliveries", sl.Err(err))
+			return
+		}
+		for _, d := range list {
+			if _, err := wk.attempt(ctx, d, true); err != nil {
+				wk.log.Error("failed to record attempt", sl.Err(err), slog.Int64("delivery_id", d.ID))
+			}
+		}
+		if len(list) < claimBatch {
+			return
+		}
+	}
+}
+
+func (wk *Worker) purge() {
+	if time.Since(wk.lastPurge) < purgeInterval {
+		return
+	}
+	wk.lastPurge = time.Now()
+
+	n, err := wk.store.PurgeDeliveries(time.Now().Add(-deliveryRetention))
+	if err != nil {
+		wk.log.Error("failed to purge deliveries", sl.Err(err))
+		return
+	}
+	if n > 0 {
+		wk.log.Info("old deliveries purged", slog.Int64("deliveries", n))
+	}
+}
+
+// Ping sends a delivery right away and records the outcome without retrying on failure.
+func (wk *Worker) Ping(ctx context.Context, d models.DueDelivery) (*models.WebhookDelivery, error) {
+	return wk.attempt(ctx, d, false)
+}
+
+// attempt sends the delivery once and records the outcome.
+func (wk *Worker) attempt(ctx context.Context, d models.DueDelivery, retry bool) (*models.WebhookDelivery, error) {
+	log := wk.log.With(slog.Int64("delivery_id", d.ID), slog.Int("webhook_id", d.WebhookID))
+
+	statusCode, err := wk.send(ctx, d)
+	if err == nil {
+		log.Info("delivery sent", slog.Int("status", *statusCode))
+		return wk.store.RecordAttempt(d.ID, statusCode, "", nil)
+	}
+
+	var next *time.Time
+	if attempts := d.Attempts + 1; retry && attempts < wk.cfg.MaxAttempts {
+		t := time.Now().Add(backoff(attempts))
+		next = &t
+	}
+	log.Warn(
+		"delivery attempt failed", sl.Err(err), slog.Int("attempt", d.Attempts+1), slog.Bool("retry", next != nil),
+	)
+	return wk.store.RecordAttempt(d.ID, statusCode, attemptError(statusCode, err), next)
+}
+
+// attemptError is the error shown in the delivery log. Transport errors are reduced to their kind
+// so that the log does not tell the owner how the network behind the worker answered.
+func attemptError(statusCode *int, err error) string {
+	var netErr net.Error
+	switch {
+	case statusCode != nil:
+		return fmt.Sprintf("unexpected status %d", *statusCode)
+	case errors.Is(err, webhook.ErrBlockedAddress):
+		return webhook.ErrBlockedAddress.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "connection failed"
+	}
+}
+
+func (wk *Worker) send(ctx context.Context, d models.DueDelivery) (*int, error) {
+	ctx, cancel := context.WithTimeout(ctx, wk.cfg.DeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "atom-fit-webhooks")
+	req.Header.Set(webhook.EventHeader, d.Event)
+	req.Header.Set(webhook.DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(d.Secret, time.Now(), d.Payload))
+
+	res, err := wk.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return &res.StatusCode, nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
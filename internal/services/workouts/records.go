@@ -0,0 +1,87 @@
+package workouts
+
+import (
+	"fmt"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/training"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+)
+
+// RecordTracker keeps the per-exercise personal records of users up to date.
+type RecordTracker struct {
+	store workouts.WorkoutStore
+	bus   *events.Bus
+}
+
+func NewRecordTracker(store workouts.WorkoutStore, bus *events.Bus) *RecordTracker {
+	return &RecordTracker{store: store, bus: bus}
+}
+
+type recordKey struct {
+	exerciseID int
+	kind       string
+}
+
+// Track compares the workout against the stored records, saves the broken ones
+// and publishes a record.broken event for each of them.
+func (t *RecordTracker) Track(w *models.Workout) ([]models.PersonalRecord, error) {
+	const op = "workouts.RecordTracker.Track"
+
+	current, err := t.store.GetRecords(w.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	best := make(map[recordKey]float64, len(current))
+	for _, r := range current {
+		best[recordKey{r.ExerciseID, r.Kind}] = r.Value
+	}
+
+	var order []int
+	sessions := make(map[int]*training.Session)
+	for _, set := range w.Sets {
+		session, ok := sessions[set.ExerciseID]
+		if !ok {
+			session = &training.Session{}
+			sessions[set.ExerciseID] = session
+			order = append(order, set.ExerciseID)
+		}
+		session.Sets = append(session.Sets, training.Set{Weight: set.Weight, Reps: set.Reps, RPE: set.RPE})
+	}
+
+	broken := []models.PersonalRecord{}
+	for _, exerciseID := range order {
+		rec := training.SessionRecords(*sessions[exerciseID])
+		values := map[string]float64{
+			models.RecordEstimatedOneRepMax: rec.EstimatedOneRepMax,
+			models.RecordMaxWeight:          rec.MaxWeight,
+			models.RecordMaxReps:            float64(rec.MaxReps),
+			models.RecordMaxVolume:          rec.MaxVolume,
+		}
+		for _, kind := range []string{
+			models.RecordEstimatedOneRepMax, models.RecordMaxWeight, models.RecordMaxReps, models.RecordMaxVolume,
+		} {
+			value := values[kind]
+			if value <= 0 || value <= best[recordKey{exerciseID, kind}] {
+				continue
+			}
+
+			workoutID := w.ID
+			record := models.PersonalRecord{
+				UserID:     w.UserID,
+				ExerciseID: exerciseID,
+				Kind:       kind,
+				Value:      value,
+				WorkoutID:  &workoutID,
+				AchievedAt: w.StartedAt,
+			}
+			if err := t.store.SaveRecord(record); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			broken = append(broken, record)
+			t.bus.Publish(events.RecordBroken, w.UserID, record)
+		}
+	}
+
+	return broken, nil
+}
@@ -0,0 +1,234 @@
+package workouts
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	mwAuth "github.com/stanislavCasciuc/atom-fit-go/internal/api/midleware/auth"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/config"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/events"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/logger/sl"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/training"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/store/workouts"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	resp "github.com/stanislavCasciuc/atom-fit-go/internal/api/response"
+)
+
+const (
+	defaultWorkoutsLimit = 20
+	maxWorkoutsLimit     = 100
+	historySessions      = 10
+)
+
+type Handler struct {
+	store   workouts.WorkoutStore
+	tracker *RecordTracker
+	bus     *events.Bus
+	log     *slog.Logger
+	cfg     config.Config
+}
+
+func NewHandler(store workouts.WorkoutStore, bus *events.Bus, log *slog.Logger) *Handler {
+	return &Handler{
+		store:   store,
+		tracker: NewRecordTracker(store, bus),
+		bus:     bus,
+		log:     log,
+		cfg:     config.Envs,
+	}
+}
+
+func (h *Handler) HandleCreateWorkout(w http.ResponseWriter, r *http.Request) {
+	const op = "workouts.HandleCreateWorkout"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	var payload models.CreateWorkoutPayload
+
+	err := render.DecodeJSON(r.Body, &payload)
+	if errors.Is(err, io.EOF) {
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "empty payload"})
+		log.Error("request is empty")
+		return
+	}
+	if err != nil {
+		log.Error("failed to decode payload", sl.Err(err))
+		resp.JSON(w, r, http.StatusUnprocessableEntity, map[string]string{"error": "failed to decode payload"})
+		return
+	}
+
+	if err := validator.New().Struct(payload); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		resp.ValidationError(w, r, validateErr)
+		return
+	}
+
+	workout, err := h.store.CreateWorkout(user.ID, payload)
+	if err != nil {
+		if errors.Is(err, workouts.ExerciseNotFound) {
+			log.Warn("unknown exercise in payload")
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Error("failed to save workout", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	h.bus.Publish(events.WorkoutCreated, user.ID, *workout)
+
+	records, err := h.tracker.Track(workout)
+	if err != nil {
+		log.Error("failed to update personal records", sl.Err(err))
+		records = []models.PersonalRecord{}
+	}
+
+	log.Info("workout saved", slog.Int("workout_id", workout.ID), slog.Int("records", len(records)))
+	resp.JSON(w, r, http.StatusCreated, map[string]any{"workout": workout, "records": records})
+}
+
+func (h *Handler) HandleGetWorkouts(w http.ResponseWriter, r *http.Request) {
+	const op = "workouts.HandleGetWorkouts"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	limit := defaultWorkoutsLimit
+	if str := r.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil || l <= 0 {
+			resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = min(l, maxWorkoutsLimit)
+	}
+
+	list, err := h.store.GetWorkouts(user.ID, limit)
+	if err != nil {
+		log.Error("failed to get workouts", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, list)
+}
+
+func (h *Handler) HandleGetExercises(w http.ResponseWriter, r *http.Request) {
+	const op = "workouts.HandleGetExercises"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	exercises, err := h.store.GetExercises()
+	if err != nil {
+		log.Error("failed to get exercises", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, exercises)
+}
+
+func (h *Handler) HandleGetExerciseHistory(w http.ResponseWriter, r *http.Request) {
+	const op = "workouts.HandleGetExerciseHistory"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	exerciseID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		resp.JSON(w, r, http.StatusBadRequest, map[string]string{"error": "invalid exercise id"})
+		return
+	}
+
+	exercise, err := h.store.GetExerciseByID(exerciseID)
+	if err != nil {
+		if errors.Is(err, workouts.ExerciseNotFound) {
+			resp.JSON(w, r, http.StatusNotFound, map[string]string{"error": workouts.ExerciseNotFound.Error()})
+			return
+		}
+		log.Error("failed to get exercise", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	sessions, err := h.store.GetExerciseHistory(user.ID, exerciseID, historySessions)
+	if err != nil {
+		log.Error("failed to get exercise history", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	allRecords, err := h.store.GetRecords(user.ID)
+	if err != nil {
+		log.Error("failed to get records", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+	records := []models.PersonalRecord{}
+	for _, rec := range allRecords {
+		if rec.ExerciseID == exerciseID {
+			records = append(records, rec)
+		}
+	}
+
+	trainingSessions := make([]training.Session, len(sessions))
+	for i, s := range sessions {
+		for _, set := range s.Sets {
+			trainingSessions[i].Sets = append(
+				trainingSessions[i].Sets, training.Set{Weight: set.Weight, Reps: set.Reps, RPE: set.RPE},
+			)
+		}
+	}
+
+	resp.JSON(
+		w, r, http.StatusOK, map[string]any{
+			"exercise":   exercise,
+			"sessions":   sessions,
+			"records":    records,
+			"suggestion": training.Suggest(trainingSessions, training.DefaultIncrement),
+		},
+	)
+}
+
+func (h *Handler) HandleGetRecords(w http.ResponseWriter, r *http.Request) {
+	const op = "workouts.HandleGetRecords"
+
+	user := mwAuth.User(r.Context())
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Int("user_id", user.ID),
+	)
+
+	records, err := h.store.GetRecords(user.ID)
+	if err != nil {
+		log.Error("failed to get records", sl.Err(err))
+		resp.Internal(w, r)
+		return
+	}
+
+	resp.JSON(w, r, http.StatusOK, records)
+}
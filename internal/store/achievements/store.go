@@ -0,0 +1,97 @@
+package achievements
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type AchievementStore interface {
+	GetDefinitions() ([]models.Achievement, error)
+	GetAwarded(userID int) ([]models.AwardedAchievement, error)
+	Award(userID int, code string) (bool, error)
+	GetStats(userID int) (*models.AchievementStats, error)
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetDefinitions() ([]models.Achievement, error) {
+	const op = "achievements.store.GetDefinitions"
+
+	list := []models.Achievement{}
+	err := s.db.Select(
+		&list, "SELECT code, name, description, rule, threshold, position FROM achievements ORDER BY position, code",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetAwarded(userID int) ([]models.AwardedAchievement, error) {
+	const op = "achievements.store.GetAwarded"
+
+	list := []models.AwardedAchievement{}
+	err := s.db.Select(
+		&list, "SELECT user_id, code, awarded_at FROM user_achievements WHERE user_id = $1 ORDER BY awarded_at", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// Award unlocks the achievement for the user. It reports false when the user already had it,
+// so evaluating the same data twice never awards twice.
+func (s *Store) Award(userID int, code string) (bool, error) {
+	const op = "achievements.store.Award"
+
+	res, err := s.db.Exec(
+		"INSERT INTO user_achievements(user_id, code) VALUES($1, $2) ON CONFLICT DO NOTHING", userID, code,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n > 0, nil
+}
+
+// GetStats computes the figures the rules use from everything the user logged. Days are UTC days.
+func (s *Store) GetStats(userID int) (*models.AchievementStats, error) {
+	const op = "achievements.store.GetStats"
+
+	var stats models.AchievementStats
+	err := s.db.QueryRowx(
+		"SELECT (SELECT COUNT(*) FROM workouts WHERE user_id = $1), "+
+			"(SELECT COUNT(*) FROM personal_records WHERE user_id = $1), "+
+			"(SELECT COALESCE(MAX(value), 0) FROM daily_metrics WHERE user_id = $1 AND metric = 'steps')",
+		userID,
+	).Scan(&stats.Workouts, &stats.Records, &stats.MaxDailySteps)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stats.ActivityDays = []string{}
+	err = s.db.Select(
+		&stats.ActivityDays,
+		"SELECT to_char(day, 'YYYY-MM-DD') FROM ("+
+			"SELECT (started_at AT TIME ZONE 'UTC')::DATE AS day FROM workouts WHERE user_id = $1 "+
+			"UNION SELECT (eaten_at AT TIME ZONE 'UTC')::DATE FROM diary_entries WHERE user_id = $1 "+
+			"UNION SELECT (measured_at AT TIME ZONE 'UTC')::DATE FROM weight_entries WHERE user_id = $1"+
+			") days ORDER BY day",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+// AuditStore appends to the audit log. The table refuses updates, and deletes outside of Purge.
+type AuditStore interface {
+	Append(event *models.AuditEvent) error
+	GetEvents(filter models.AuditFilter, limit int) ([]models.AuditEvent, error)
+	Purge(before time.Time, batch int) (int64, error)
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Append(event *models.AuditEvent) error {
+	const op = "audit.store.Append"
+
+	details := []byte(event.Details)
+	if len(details) == 0 {
+		details = []byte("{}")
+	}
+	err := s.db.QueryRowx(
+		"INSERT INTO audit_events(action, actor_id, subject_id, request_id, ip, user_agent, details) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id, occurred_at",
+		event.Action, event.ActorID, event.SubjectID, event.RequestID, event.IP, event.UserAgent, details,
+	).Scan(&event.ID, &event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetEvents returns the events matching the filter, newest first.
+func (s *Store) GetEvents(filter models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	const op = "audit.store.GetEvents"
+
+	query := "SELECT id, occurred_at, action, actor_id, subject_id, request_id, ip, user_agent, details " +
+		"FROM audit_events WHERE true"
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.ActorID != nil {
+		query += " AND actor_id = " + arg(*filter.ActorID)
+	}
+	if filter.SubjectID != nil {
+		query += " AND subject_id = " + arg(*filter.SubjectID)
+	}
+	if filter.Action != "" {
+		query += " AND action = " + arg(filter.Action)
+	}
+	if filter.From != nil {
+		query += " AND occurred_at >= " + arg(*filter.From)
+	}
+	if filter.To != nil {
+		query += " AND occurred_at < " + arg(*filter.To)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < " + arg(filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(limit)
+
+	list := []models.AuditEvent{}
+	if err := s.db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// Purge deletes the events older than before, batch rows per transaction, and returns how many
+// were deleted. It is the only way rows leave the table.
+func (s *Store) Purge(before time.Time, batch int) (int64, error) {
+	const op = "audit.store.Purge"
+
+	var total int64
+	for {
+		n, err := s.purgeBatch(before, batch)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", op, err)
+		}
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+func (s *Store) purgeBatch(before time.Time, batch int) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET LOCAL audit.purge = 'on'"); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(
+		"DELETE FROM audit_events WHERE id IN "+
+			"(SELECT id FROM audit_events WHERE occurred_at < $1 ORDER BY id LIMIT $2)",
+		before, batch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
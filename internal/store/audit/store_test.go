@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests run against the database of TEST_DATABASE_URL, which they migrate, and are skipped
+// without one. Their events are dated in 2001, before anything another test records, and purged.
+func testStore(t *testing.T) (*Store, *sqlx.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(db)
+	t.Cleanup(func() { s.Purge(time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC), 1000) })
+	return s, db
+}
+
+// insertEvents adds n events per day from the first of January 2001, for days days.
+func insertEvents(t *testing.T, db *sqlx.DB, action string, days int, n int) {
+	t.Helper()
+	for day := range days {
+		at := time.Date(2001, 1, 1+day, 12, 0, 0, 0, time.UTC)
+		for range n {
+			_, err := db.Exec("INSERT INTO audit_events(occurred_at, action) VALUES($1, $2)", at, action)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func countEvents(t *testing.T, db *sqlx.DB, action string) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM audit_events WHERE action = $1", action); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPurge(t *testing.T) {
+	s, db := testStore(t)
+	action := fmt.Sprintf("test.purge_%d", time.Now().UnixNano())
+	insertEvents(t, db, action, 3, 5)
+
+	// Seven events per transaction take two batches for the ten events of the first two days.
+	deleted, err := s.Purge(time.Date(2001, 1, 3, 0, 0, 0, 0, time.UTC), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 10 {
+		t.Errorf("Purge() = %d, want 10", deleted)
+	}
+	if n := countEvents(t, db, action); n != 5 {
+		t.Errorf("%d events left, want the 5 of the last day", n)
+	}
+}
+
+func TestEventsAreAppendOnly(t *testing.T) {
+	s, db := testStore(t)
+	action := fmt.Sprintf("test.append_only_%d", time.Now().UnixNano())
+	insertEvents(t, db, action, 1, 1)
+
+	if _, err := db.Exec("UPDATE audit_events SET ip = '198.51.100.1' WHERE action = $1", action); err == nil {
+		t.Error("UPDATE of an event succeeded")
+	}
+	if _, err := db.Exec("DELETE FROM audit_events WHERE action = $1", action); err == nil {
+		t.Error("DELETE outside of Purge succeeded")
+	}
+	if n := countEvents(t, db, action); n != 1 {
+		t.Fatalf("%d events left, want 1", n)
+	}
+
+	got, err := s.GetEvents(models.AuditFilter{Action: action}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || string(got[0].Details) != "{}" {
+		t.Errorf("GetEvents() = %+v, want the event with empty details", got)
+	}
+}
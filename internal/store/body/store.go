@@ -0,0 +1,237 @@
+package body
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type BodyStore interface {
+	CreateWeightEntry(userID int, payload models.CreateWeightEntryPayload) (*models.WeightEntry, error)
+	GetWeightEntries(userID int, from time.Time, to time.Time) ([]models.WeightEntry, error)
+	GetLatestWeightEntry(userID int) (*models.WeightEntry, error)
+	DeleteWeightEntry(userID int, id int) error
+	CreateMeasurement(userID int, payload models.CreateBodyMeasurementPayload) (*models.BodyMeasurement, error)
+	GetMeasurements(userID int, from time.Time, to time.Time) ([]models.BodyMeasurement, error)
+	GetLatestMeasurement(userID int) (*models.BodyMeasurement, error)
+	DeleteMeasurement(userID int, id int) error
+	CreatePhoto(photo *models.ProgressPhoto) error
+	GetPhotos(userID int, from time.Time, to time.Time) ([]models.ProgressPhoto, error)
+	GetPhoto(id int) (*models.ProgressPhoto, error)
+	DeletePhoto(userID int, id int) (*models.ProgressPhoto, error)
+}
+
+var (
+	WeightEntryNotFound = errors.New("weight entry not found")
+	MeasurementNotFound = errors.New("body measurement not found")
+	PhotoNotFound       = errors.New("progress photo not found")
+)
+
+const (
+	weightColumns      = "id, user_id, measured_at, weight, note, created_at"
+	measurementColumns = "id, user_id, measured_at, waist, hips, chest, arm, thigh, body_fat, body_fat_method, created_at"
+	photoColumns       = "id, user_id, taken_at, pose, blob_key, thumb_key, content_type, width, height, size, created_at"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateWeightEntry(userID int, p models.CreateWeightEntryPayload) (*models.WeightEntry, error) {
+	const op = "body.store.CreateWeightEntry"
+
+	var e models.WeightEntry
+	err := s.db.QueryRowx(
+		"INSERT INTO weight_entries(user_id, measured_at, weight, note) VALUES($1, $2, $3, $4) RETURNING "+
+			weightColumns,
+		userID, p.MeasuredAt, p.Weight, p.Note,
+	).StructScan(&e)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+// GetWeightEntries returns the entries measured in [from, to), oldest first.
+func (s *Store) GetWeightEntries(userID int, from time.Time, to time.Time) ([]models.WeightEntry, error) {
+	const op = "body.store.GetWeightEntries"
+
+	list := []models.WeightEntry{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+weightColumns+" FROM weight_entries WHERE user_id = $1 AND measured_at >= $2 AND measured_at < $3 "+
+			"ORDER BY measured_at",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetLatestWeightEntry(userID int) (*models.WeightEntry, error) {
+	const op = "body.store.GetLatestWeightEntry"
+
+	var e models.WeightEntry
+	err := s.db.Get(
+		&e, "SELECT "+weightColumns+" FROM weight_entries WHERE user_id = $1 ORDER BY measured_at DESC LIMIT 1",
+		userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, WeightEntryNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+func (s *Store) DeleteWeightEntry(userID int, id int) error {
+	const op = "body.store.DeleteWeightEntry"
+
+	return deleteOwned(s.db, op, "weight_entries", userID, id, WeightEntryNotFound)
+}
+
+func (s *Store) CreateMeasurement(userID int, p models.CreateBodyMeasurementPayload) (
+	*models.BodyMeasurement, error,
+) {
+	const op = "body.store.CreateMeasurement"
+
+	var m models.BodyMeasurement
+	err := s.db.QueryRowx(
+		"INSERT INTO body_measurements(user_id, measured_at, waist, hips, chest, arm, thigh, body_fat, "+
+			"body_fat_method) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING "+measurementColumns,
+		userID, p.MeasuredAt, p.Waist, p.Hips, p.Chest, p.Arm, p.Thigh, p.BodyFat, p.BodyFatMethod,
+	).StructScan(&m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &m, nil
+}
+
+// GetMeasurements returns the measurements taken in [from, to), oldest first.
+func (s *Store) GetMeasurements(userID int, from time.Time, to time.Time) ([]models.BodyMeasurement, error) {
+	const op = "body.store.GetMeasurements"
+
+	list := []models.BodyMeasurement{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+measurementColumns+" FROM body_measurements WHERE user_id = $1 AND measured_at >= $2 "+
+			"AND measured_at < $3 ORDER BY measured_at",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetLatestMeasurement(userID int) (*models.BodyMeasurement, error) {
+	const op = "body.store.GetLatestMeasurement"
+
+	var m models.BodyMeasurement
+	err := s.db.Get(
+		&m,
+		"SELECT "+measurementColumns+" FROM body_measurements WHERE user_id = $1 ORDER BY measured_at DESC LIMIT 1",
+		userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, MeasurementNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &m, nil
+}
+
+func (s *Store) DeleteMeasurement(userID int, id int) error {
+	const op = "body.store.DeleteMeasurement"
+
+	return deleteOwned(s.db, op, "body_measurements", userID, id, MeasurementNotFound)
+}
+
+// CreatePhoto saves the photo metadata and fills its id and creation time.
+func (s *Store) CreatePhoto(p *models.ProgressPhoto) error {
+	const op = "body.store.CreatePhoto"
+
+	err := s.db.QueryRow(
+		"INSERT INTO progress_photos(user_id, taken_at, pose, blob_key, thumb_key, content_type, width, height, "+
+			"size) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at",
+		p.UserID, p.TakenAt, p.Pose, p.BlobKey, p.ThumbKey, p.ContentType, p.Width, p.Height, p.Size,
+	).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetPhotos returns the photos taken in [from, to), oldest first.
+func (s *Store) GetPhotos(userID int, from time.Time, to time.Time) ([]models.ProgressPhoto, error) {
+	const op = "body.store.GetPhotos"
+
+	list := []models.ProgressPhoto{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+photoColumns+" FROM progress_photos WHERE user_id = $1 AND taken_at >= $2 AND taken_at < $3 "+
+			"ORDER BY taken_at",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetPhoto is not scoped to a user: it serves signed links, which carry the authorisation.
+func (s *Store) GetPhoto(id int) (*models.ProgressPhoto, error) {
+	const op = "body.store.GetPhoto"
+
+	var p models.ProgressPhoto
+	err := s.db.Get(&p, "SELECT "+photoColumns+" FROM progress_photos WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, PhotoNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &p, nil
+}
+
+// DeletePhoto removes the metadata and returns it so the caller can delete the blobs.
+func (s *Store) DeletePhoto(userID int, id int) (*models.ProgressPhoto, error) {
+	const op = "body.store.DeletePhoto"
+
+	var p models.ProgressPhoto
+	err := s.db.QueryRowx(
+		"DELETE FROM progress_photos WHERE user_id = $1 AND id = $2 RETURNING "+photoColumns, userID, id,
+	).StructScan(&p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, PhotoNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &p, nil
+}
+
+func deleteOwned(db *sqlx.DB, op string, table string, userID int, id int, notFound error) error {
+	res, err := db.Exec("DELETE FROM "+table+" WHERE user_id = $1 AND id = $2", userID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package challenges
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type ChallengeStore interface {
+	CreateChallenge(challenge *models.Challenge) error
+	GetChallenge(userID int, id int) (*models.Challenge, error)
+	GetChallenges(userID int, mine bool, today string) ([]models.Challenge, error)
+	Join(challengeID int, userID int) error
+	Leave(challengeID int, userID int) error
+	GetJoinedChallenges(userID int, metrics []string, day string) ([]models.Challenge, error)
+	AddContribution(challengeID int, userID int, source string, value float64) error
+	RemoveContributions(userID int, source string, keep []int) error
+	GetStandings(challengeID int, limit int) ([]models.Standing, error)
+	GetSnapshot(challengeID int, day string, limit int) ([]models.Standing, error)
+	SaveSnapshots(day string) (int64, error)
+}
+
+var (
+	ChallengeNotFound = errors.New("challenge not found")
+	NotParticipant    = errors.New("not a participant of the challenge")
+)
+
+// challengeColumns expects the challenge as c and the reading user as $1.
+const challengeColumns = "c.id, c.owner_id, c.name, c.description, c.metric, " +
+	"to_char(c.starts_on, 'YYYY-MM-DD') AS starts_on, to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on, c.visibility, " +
+	"c.invite_code, (SELECT COUNT(*) FROM challenge_participants p WHERE p.challenge_id = c.id) AS participants, " +
+	"EXISTS (SELECT 1 FROM challenge_participants p WHERE p.challenge_id = c.id AND p.user_id = $1) AS joined, " +
+	"c.created_at"
+
+const maxChallenges = 100
+
+// backfills copy what a participant logged during the challenge before joining it. The sources
+// are the ones the scorer uses, so later events for the same workouts and days change nothing.
+var backfills = map[string]string{
+	models.ChallengeDistance: "INSERT INTO challenge_contributions(challenge_id, user_id, source, value) " +
+		"SELECT c.id, $2, 'workout:' || w.id, w.distance FROM challenges c JOIN workouts w ON w.user_id = $2 " +
+		"AND w.started_at >= c.starts_on::TIMESTAMP AT TIME ZONE 'UTC' " +
+		"AND w.started_at < (c.ends_on + 1)::TIMESTAMP AT TIME ZONE 'UTC' " +
+		"WHERE c.id = $1 AND w.distance > 0 ON CONFLICT DO NOTHING",
+	models.ChallengeWorkouts: "INSERT INTO challenge_contributions(challenge_id, user_id, source, value) " +
+		"SELECT c.id, $2, 'workout:' || w.id, 1 FROM challenges c JOIN workouts w ON w.user_id = $2 " +
+		"AND w.started_at >= c.starts_on::TIMESTAMP AT TIME ZONE 'UTC' " +
+		"AND w.started_at < (c.ends_on + 1)::TIMESTAMP AT TIME ZONE 'UTC' " +
+		"WHERE c.id = $1 ON CONFLICT DO NOTHING",
+	models.ChallengeSteps: "INSERT INTO challenge_contributions(challenge_id, user_id, source, value) " +
+		"SELECT c.id, $2, 'steps:' || to_char(m.day, 'YYYY-MM-DD'), m.value FROM challenges c " +
+		"JOIN daily_metrics m ON m.user_id = $2 AND m.metric = 'steps' AND m.day BETWEEN c.starts_on AND c.ends_on " +
+		"WHERE c.id = $1 ON CONFLICT DO NOTHING",
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateChallenge saves the challenge and makes its owner the first participant.
+func (s *Store) CreateChallenge(challenge *models.Challenge) error {
+	const op = "challenges.store.CreateChallenge"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"INSERT INTO challenges(owner_id, name, description, metric, starts_on, ends_on, visibility, invite_code) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at",
+		challenge.OwnerID, challenge.Name, challenge.Description, challenge.Metric, challenge.StartsOn,
+		challenge.EndsOn, challenge.Visibility, challenge.InviteCode,
+	).Scan(&challenge.ID, &challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := join(tx, challenge.ID, challenge.OwnerID, challenge.Metric); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	challenge.Participants = 1
+	challenge.Joined = true
+	return nil
+}
+
+func (s *Store) GetChallenge(userID int, id int) (*models.Challenge, error) {
+	const op = "challenges.store.GetChallenge"
+
+	var c models.Challenge
+	err := s.db.Get(&c, "SELECT "+challengeColumns+" FROM challenges c WHERE c.id = $2", userID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ChallengeNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+// GetChallenges returns the challenges the user joined, and unless mine is set, the public
+// challenges that did not end before today as well.
+func (s *Store) GetChallenges(userID int, mine bool, today string) ([]models.Challenge, error) {
+	const op = "challenges.store.GetChallenges"
+
+	query := "SELECT " + challengeColumns + " FROM challenges c WHERE EXISTS " +
+		"(SELECT 1 FROM challenge_participants p WHERE p.challenge_id = c.id AND p.user_id = $1) "
+	args := []any{userID}
+	if !mine {
+		query += "OR (c.visibility = 'public' AND c.ends_on >= $2) "
+		args = append(args, today)
+	}
+	query += fmt.Sprintf("ORDER BY c.starts_on DESC, c.id DESC LIMIT %d", maxChallenges)
+
+	list := []models.Challenge{}
+	if err := s.db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// Join adds the user to the challenge and scores what was already logged during the challenge.
+// Joining twice changes nothing.
+func (s *Store) Join(challengeID int, userID int) error {
+	const op = "challenges.store.Join"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var metric string
+	if err := tx.Get(&metric, "SELECT metric FROM challenges WHERE id = $1", challengeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ChallengeNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := join(tx, challengeID, userID, metric); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Leave removes the participant with the contributions. Snapshots taken before are kept.
+func (s *Store) Leave(challengeID int, userID int) error {
+	const op = "challenges.store.Leave"
+
+	res, err := s.db.Exec(
+		"DELETE FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2", challengeID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return NotParticipant
+	}
+	return nil
+}
+
+// GetJoinedChallenges returns the challenges of the user measured by one of metrics and running on day.
+func (s *Store) GetJoinedChallenges(userID int, metrics []string, day string) ([]models.Challenge, error) {
+	const op = "challenges.store.GetJoinedChallenges"
+
+	list := []models.Challenge{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+challengeColumns+" FROM challenges c JOIN challenge_participants jp ON jp.challenge_id = c.id "+
+			"AND jp.user_id = $1 WHERE c.metric = ANY($2) AND $3::DATE BETWEEN c.starts_on AND c.ends_on",
+		userID, pq.Array(metrics), day,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// AddContribution sets the value a source (a workout, a day of steps) adds to the participant's score
+// and moves the score by the difference with the previous value, so the score is never recomputed.
+// Nothing happens when the user is not a participant anymore.
+func (s *Store) AddContribution(challengeID int, userID int, source string, value float64) error {
+	const op = "challenges.store.AddContribution"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// Locking the participant serialises the updates of one score.
+	var locked int
+	err = tx.Get(
+		&locked,
+		"SELECT 1 FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2 FOR UPDATE",
+		challengeID, userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var prev float64
+	err = tx.Get(
+		&prev,
+		"SELECT value FROM challenge_contributions WHERE challenge_id = $1 AND user_id = $2 AND source = $3",
+		challengeID, userID, source,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err == nil && prev == value {
+		return nil
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO challenge_contributions(challenge_id, user_id, source, value) VALUES($1, $2, $3, $4) "+
+			"ON CONFLICT (challenge_id, user_id, source) DO UPDATE SET value = EXCLUDED.value",
+		challengeID, userID, source, value,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec(
+		"UPDATE challenge_participants SET score = score + $1, updated_at = CURRENT_TIMESTAMP "+
+			"WHERE challenge_id = $2 AND user_id = $3",
+		value-prev, challengeID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RemoveContributions takes the contributions of a source out of the participant's scores, except
+// in the challenges of keep, e.g. when the workout behind them was deleted or moved to another day.
+func (s *Store) RemoveContributions(userID int, source string, keep []int) error {
+	const op = "challenges.store.RemoveContributions"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(keep))
+	for i, id := range keep {
+		ids[i] = int64(id)
+	}
+
+	// The participants are locked first, in the same order as AddContribution does.
+	_, err = tx.Exec(
+		"SELECT 1 FROM challenge_participants WHERE user_id = $1 AND challenge_id IN "+
+			"(SELECT challenge_id FROM challenge_contributions WHERE user_id = $1 AND source = $2 "+
+			"AND NOT challenge_id = ANY($3)) ORDER BY challenge_id FOR UPDATE",
+		userID, source, pq.Array(ids),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec(
+		"WITH removed AS (DELETE FROM challenge_contributions WHERE user_id = $1 AND source = $2 "+
+			"AND NOT challenge_id = ANY($3) RETURNING challenge_id, value) "+
+			"UPDATE challenge_participants p SET score = p.score - r.value, updated_at = CURRENT_TIMESTAMP "+
+			"FROM removed r WHERE p.challenge_id = r.challenge_id AND p.user_id = $1",
+		userID, source, pq.Array(ids),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetStandings returns the live ranking, ties sorted by who joined first.
+func (s *Store) GetStandings(challengeID int, limit int) ([]models.Standing, error) {
+	const op = "challenges.store.GetStandings"
+
+	list := []models.Standing{}
+	err := s.db.Select(
+		&list,
+		"SELECT RANK() OVER (ORDER BY p.score DESC) AS rank, p.user_id, u.username, p.score "+
+			"FROM challenge_participants p JOIN users u ON u.id = p.user_id WHERE p.challenge_id = $1 "+
+			"ORDER BY p.score DESC, p.joined_at LIMIT $2",
+		challengeID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetSnapshot(challengeID int, day string, limit int) ([]models.Standing, error) {
+	const op = "challenges.store.GetSnapshot"
+
+	list := []models.Standing{}
+	err := s.db.Select(
+		&list,
+		"SELECT s.rank, s.user_id, u.username, s.score FROM challenge_snapshots s JOIN users u ON u.id = s.user_id "+
+			"WHERE s.challenge_id = $1 AND s.day = $2 ORDER BY s.rank, u.username LIMIT $3",
+		challengeID, day, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// SaveSnapshots records the standings of the challenges running on day that have no snapshot
+// for it yet, and returns the number of rows written.
+func (s *Store) SaveSnapshots(day string) (int64, error) {
+	const op = "challenges.store.SaveSnapshots"
+
+	res, err := s.db.Exec(
+		"INSERT INTO challenge_snapshots(challenge_id, day, user_id, rank, score) "+
+			"SELECT p.challenge_id, $1::DATE, p.user_id, RANK() OVER (PARTITION BY p.challenge_id ORDER BY p.score DESC), "+
+			"p.score FROM challenge_participants p JOIN challenges c ON c.id = p.challenge_id "+
+			"WHERE $1::DATE BETWEEN c.starts_on AND c.ends_on AND NOT EXISTS "+
+			"(SELECT 1 FROM challenge_snapshots s WHERE s.challenge_id = c.id AND s.day = $1::DATE) "+
+			"ON CONFLICT DO NOTHING",
+		day,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
+// join adds the participant and computes the starting score from the backfilled contributions.
+func join(tx *sqlx.Tx, challengeID int, userID int, metric string) (bool, error) {
+	res, err := tx.Exec(
+		"INSERT INTO challenge_participants(challenge_id, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING",
+		challengeID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec(backfills[metric], challengeID, userID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(
+		"UPDATE challenge_participants SET score = (SELECT COALESCE(SUM(value), 0) FROM challenge_contributions "+
+			"WHERE challenge_id = $1 AND user_id = $2) WHERE challenge_id = $1 AND user_id = $2",
+		challengeID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
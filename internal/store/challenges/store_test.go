@@ -0,0 +1,179 @@
+package challenges
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests run against the database of TEST_DATABASE_URL, which they migrate, and are skipped
+// without one. They remove the users they create, and their challenges with them.
+func testStore(t *testing.T) (*Store, *sqlx.DB, int, int) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := fmt.Sprintf("challenges-test-%d", time.Now().UnixNano())
+	var ids [2]int
+	for i := range ids {
+		err := db.Get(
+			&ids[i],
+			"INSERT INTO users(email, username, password, activation_code) "+
+				"VALUES($1 || '@example.com', $1, '\\x00', '') RETURNING id",
+			fmt.Sprintf("%s-%d", prefix, i),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1 OR id = $2", ids[0], ids[1]) })
+	return NewStore(db), db, ids[0], ids[1]
+}
+
+func score(t *testing.T, db *sqlx.DB, challengeID int, userID int) float64 {
+	t.Helper()
+	var s float64
+	err := db.Get(
+		&s, "SELECT score FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2", challengeID, userID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScoreFollowsContributions(t *testing.T) {
+	s, db, owner, member := testStore(t)
+
+	// A workout logged during the challenge before joining it is scored on joining.
+	var workoutID int
+	err := db.Get(
+		&workoutID,
+		"INSERT INTO workouts(user_id, started_at, distance) VALUES($1, '2024-09-10T07:00:00Z', 5000) RETURNING id",
+		member,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := models.Challenge{
+		OwnerID: owner, Name: "September", Metric: models.ChallengeDistance, StartsOn: "2024-09-01",
+		EndsOn: "2024-09-30", Visibility: models.ChallengePublic, InviteCode: fmt.Sprintf("test-%d", owner),
+	}
+	if err := s.CreateChallenge(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Join(c.ID, member); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Join(c.ID, member); err != nil {
+		t.Fatal(err)
+	}
+	if got := score(t, db, c.ID, member); got != 5000 {
+		t.Fatalf("score after joining = %v, want the backfilled 5000", got)
+	}
+
+	source := fmt.Sprintf("workout:%d", workoutID)
+	steps := []struct {
+		name   string
+		source string
+		value  float64
+		remove bool
+		keep   []int
+		score  float64
+	}{
+		{name: "same workout again", source: source, value: 5000, score: 5000},
+		{name: "workout changed", source: source, value: 8000, score: 8000},
+		{name: "another workout", source: "workout:0", value: 2000, score: 10000},
+		{name: "kept", source: source, remove: true, keep: []int{c.ID}, score: 10000},
+		{name: "removed", source: source, remove: true, score: 2000},
+	}
+	for _, step := range steps {
+		if step.remove {
+			err = s.RemoveContributions(member, step.source, step.keep)
+		} else {
+			err = s.AddContribution(c.ID, member, step.source, step.value)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := score(t, db, c.ID, member); got != step.score {
+			t.Errorf("%s: score = %v, want %v", step.name, got, step.score)
+		}
+	}
+
+	// A contribution of a user who left is dropped.
+	if err := s.Leave(c.ID, member); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddContribution(c.ID, member, source, 1000); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	if err := db.Get(&left, "SELECT COUNT(*) FROM challenge_contributions WHERE challenge_id = $1", c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d contributions remain after leaving", left)
+	}
+}
+
+func TestSaveSnapshotsOncePerDay(t *testing.T) {
+	s, _, owner, member := testStore(t)
+
+	c := models.Challenge{
+		OwnerID: owner, Name: "September", Metric: models.ChallengeWorkouts, StartsOn: "2024-09-01",
+		EndsOn: "2024-09-30", Visibility: models.ChallengePublic, InviteCode: fmt.Sprintf("test-%d", owner),
+	}
+	if err := s.CreateChallenge(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Join(c.ID, member); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddContribution(c.ID, member, "workout:0", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SaveSnapshots("2024-09-15"); err != nil {
+		t.Fatal(err)
+	}
+	// Scores moving after the end of the day do not change the snapshot taken for it.
+	if err := s.AddContribution(c.ID, owner, "workout:0", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddContribution(c.ID, owner, "workout:1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveSnapshots("2024-09-15"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetSnapshot(c.ID, "2024-09-15", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].UserID != member || got[0].Rank != 1 || got[1].Score != 0 {
+		t.Errorf("snapshot = %+v, want the member first with the owner on 0", got)
+	}
+	if live, _ := s.GetStandings(c.ID, 10); len(live) != 2 || live[0].UserID != owner {
+		t.Errorf("standings = %+v, want the owner first", live)
+	}
+	if out, err := s.SaveSnapshots("2024-10-01"); err != nil || out != 0 {
+		t.Errorf("SaveSnapshots() after the end = %d, %v, want nothing written", out, err)
+	}
+}
@@ -0,0 +1,339 @@
+package coaching
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type CoachingStore interface {
+	GetCoach(userID int) (*models.Coach, error)
+	SaveCoach(userID int, bio string) error
+	DeleteCoach(userID int) error
+
+	Invite(coachID int, clientID int, scopes []string) (*models.Coaching, error)
+	GetInvitations(coachID int) ([]models.Coaching, error)
+	GetCoaches(clientID int) ([]models.Coaching, error)
+	Accept(coachID int, clientID int, scopes []string) error
+	SetScopes(coachID int, clientID int, scopes []string) error
+	End(coachID int, clientID int) error
+	GetScopes(coachID int, clientID int) ([]string, error)
+	GetClients(coachID int, from time.Time, to time.Time) ([]models.ClientSummary, error)
+
+	CreateTemplate(template *models.WorkoutTemplate) error
+	GetTemplates(coachID int) ([]models.WorkoutTemplate, error)
+	GetTemplate(coachID int, id int) (*models.WorkoutTemplate, error)
+	PublishTemplate(coachID int, id int) error
+	GetPrograms(limit int) ([]models.WorkoutTemplate, error)
+
+	CreateAssignment(assignment *models.Assignment) error
+	GetAssignments(clientID int, coachID int, limit int) ([]models.Assignment, error)
+	CompleteAssignment(clientID int, id int, workoutID int) error
+
+	CreateNote(note *models.CoachNote) error
+	GetNotes(coachID int, clientID int, limit int) ([]models.CoachNote, error)
+	GetUserNotes(userID int) ([]models.CoachNote, error)
+}
+
+var (
+	CoachNotFound      = errors.New("coach not found")
+	CoachingNotFound   = errors.New("coaching not found")
+	CoachingExists     = errors.New("coaching already exists")
+	TemplateNotFound   = errors.New("template not found")
+	ExerciseNotFound   = errors.New("exercise not found")
+	AssignmentNotFound = errors.New("assignment not found")
+	WorkoutNotFound    = errors.New("workout not found")
+)
+
+const coachingColumns = "cc.id, cc.coach_id, cc.client_id, u.username, cc.status, cc.scopes, cc.invited_at, " +
+	"cc.accepted_at, cc.ended_at"
+
+const assignmentColumns = "id, template_id, coach_id, client_id, to_char(due_on, 'YYYY-MM-DD') AS due_on, note, " +
+	"workout_id, completed_at, assigned_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetCoach(userID int) (*models.Coach, error) {
+	const op = "coaching.store.GetCoach"
+
+	var c models.Coach
+	err := s.db.Get(
+		&c, "SELECT c.user_id, u.username, c.bio, c.created_at FROM coaches c JOIN users u ON u.id = c.user_id "+
+			"WHERE c.user_id = $1",
+		userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, CoachNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+// SaveCoach gives the user the coach role, or updates the bio of a coach.
+func (s *Store) SaveCoach(userID int, bio string) error {
+	const op = "coaching.store.SaveCoach"
+
+	_, err := s.db.Exec(
+		"INSERT INTO coaches(user_id, bio) VALUES($1, $2) ON CONFLICT (user_id) DO UPDATE SET bio = EXCLUDED.bio",
+		userID, bio,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteCoach takes the coach role away and ends the coach's links with clients.
+func (s *Store) DeleteCoach(userID int) error {
+	const op = "coaching.store.DeleteCoach"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM coaches WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return CoachNotFound
+	}
+	_, err = tx.Exec(
+		"UPDATE coach_clients SET status = CASE WHEN status = 'pending' THEN 'declined' ELSE 'revoked' END, "+
+			"ended_at = CURRENT_TIMESTAMP WHERE coach_id = $1 AND status IN ('pending', 'active')",
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Invite creates a pending link asking the client for the scopes. There is at most one pending
+// or active link between a coach and a client.
+func (s *Store) Invite(coachID int, clientID int, scopes []string) (*models.Coaching, error) {
+	const op = "coaching.store.Invite"
+
+	c := models.Coaching{CoachID: coachID, ClientID: clientID, Status: models.CoachingPending, Scopes: scopes}
+	err := s.db.QueryRowx(
+		"INSERT INTO coach_clients(coach_id, client_id, scopes) VALUES($1, $2, $3) RETURNING id, invited_at",
+		coachID, clientID, pq.Array(scopes),
+	).Scan(&c.ID, &c.InvitedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, CoachingExists
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+// GetInvitations returns the pending invitations of the coach, Username being the client.
+func (s *Store) GetInvitations(coachID int) ([]models.Coaching, error) {
+	const op = "coaching.store.GetInvitations"
+
+	list := []models.Coaching{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+coachingColumns+" FROM coach_clients cc JOIN users u ON u.id = cc.client_id "+
+			"WHERE cc.coach_id = $1 AND cc.status = 'pending' ORDER BY cc.invited_at DESC",
+		coachID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetCoaches returns the pending and active links of the client, Username being the coach.
+func (s *Store) GetCoaches(clientID int) ([]models.Coaching, error) {
+	const op = "coaching.store.GetCoaches"
+
+	list := []models.Coaching{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+coachingColumns+" FROM coach_clients cc JOIN users u ON u.id = cc.coach_id "+
+			"WHERE cc.client_id = $1 AND cc.status IN ('pending', 'active') ORDER BY cc.invited_at DESC",
+		clientID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// Accept activates a pending invitation, with the requested scopes when scopes is nil.
+func (s *Store) Accept(coachID int, clientID int, scopes []string) error {
+	const op = "coaching.store.Accept"
+
+	res, err := s.db.Exec(
+		"UPDATE coach_clients SET status = 'active', scopes = COALESCE($3, scopes), accepted_at = CURRENT_TIMESTAMP "+
+			"WHERE coach_id = $1 AND client_id = $2 AND status = 'pending'",
+		coachID, clientID, pq.Array(scopes),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return expectRow(op, res, CoachingNotFound)
+}
+
+func (s *Store) SetScopes(coachID int, clientID int, scopes []string) error {
+	const op = "coaching.store.SetScopes"
+
+	res, err := s.db.Exec(
+		"UPDATE coach_clients SET scopes = $3 WHERE coach_id = $1 AND client_id = $2 AND status = 'active'",
+		coachID, clientID, pq.Array(scopes),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return expectRow(op, res, CoachingNotFound)
+}
+
+// End declines a pending invitation or revokes an active link. Either side can end it.
+func (s *Store) End(coachID int, clientID int) error {
+	const op = "coaching.store.End"
+
+	res, err := s.db.Exec(
+		"UPDATE coach_clients SET status = CASE WHEN status = 'pending' THEN 'declined' ELSE 'revoked' END, "+
+			"ended_at = CURRENT_TIMESTAMP WHERE coach_id = $1 AND client_id = $2 AND status IN ('pending', 'active')",
+		coachID, clientID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return expectRow(op, res, CoachingNotFound)
+}
+
+// GetScopes returns the scopes of the active link. It is read on every request of the coach,
+// so that a revoked consent applies at once.
+func (s *Store) GetScopes(coachID int, clientID int) ([]string, error) {
+	const op = "coaching.store.GetScopes"
+
+	var scopes pq.StringArray
+	err := s.db.Get(
+		&scopes,
+		"SELECT scopes FROM coach_clients WHERE coach_id = $1 AND client_id = $2 AND status = 'active'",
+		coachID, clientID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, CoachingNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return scopes, nil
+}
+
+// GetClients summarises the activity of the active clients between from and to (exclusive).
+// A figure is only computed when the client granted the scope it comes from.
+func (s *Store) GetClients(coachID int, from time.Time, to time.Time) ([]models.ClientSummary, error) {
+	const op = "coaching.store.GetClients"
+
+	list := []models.ClientSummary{}
+	err := s.db.Select(
+		&list,
+		"SELECT cc.client_id, u.username, cc.scopes, cc.accepted_at AS since, "+
+			"CASE WHEN 'view_workouts' = ANY(cc.scopes) THEN (SELECT COUNT(*) FROM workouts w "+
+			"WHERE w.user_id = cc.client_id AND w.started_at >= $2 AND w.started_at < $3) END AS workouts, "+
+			"CASE WHEN 'view_workouts' = ANY(cc.scopes) THEN (SELECT MAX(w.started_at) FROM workouts w "+
+			"WHERE w.user_id = cc.client_id) END AS last_workout_at, "+
+			"CASE WHEN 'view_diary' = ANY(cc.scopes) THEN (SELECT COUNT(DISTINCT (d.eaten_at AT TIME ZONE 'UTC')::DATE) "+
+			"FROM diary_entries d WHERE d.user_id = cc.client_id AND d.eaten_at >= $2 AND d.eaten_at < $3) "+
+			"END AS diary_days, "+
+			"CASE WHEN 'assign_programs' = ANY(cc.scopes) THEN (SELECT COUNT(*) FROM template_assignments a "+
+			"WHERE a.coach_id = cc.coach_id AND a.client_id = cc.client_id AND a.due_on >= ($2 AT TIME ZONE 'UTC')::DATE "+
+			"AND a.due_on < ($3 AT TIME ZONE 'UTC')::DATE) END AS assignments_due, "+
+			"CASE WHEN 'assign_programs' = ANY(cc.scopes) THEN (SELECT COUNT(*) FROM template_assignments a "+
+			"WHERE a.coach_id = cc.coach_id AND a.client_id = cc.client_id AND a.due_on >= ($2 AT TIME ZONE 'UTC')::DATE "+
+			"AND a.due_on < ($3 AT TIME ZONE 'UTC')::DATE AND a.completed_at IS NOT NULL) END AS assignments_completed "+
+			"FROM coach_clients cc JOIN users u ON u.id = cc.client_id "+
+			"WHERE cc.coach_id = $1 AND cc.status = 'active' ORDER BY u.username",
+		coachID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) CreateNote(note *models.CoachNote) error {
+	const op = "coaching.store.CreateNote"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO coach_notes(coach_id, client_id, body) VALUES($1, $2, $3) RETURNING id, created_at",
+		note.CoachID, note.ClientID, note.Body,
+	).Scan(&note.ID, &note.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetNotes returns the latest notes the coach left for the client, newest first.
+func (s *Store) GetNotes(coachID int, clientID int, limit int) ([]models.CoachNote, error) {
+	const op = "coaching.store.GetNotes"
+
+	list := []models.CoachNote{}
+	err := s.db.Select(
+		&list,
+		"SELECT id, coach_id, client_id, body, created_at FROM coach_notes WHERE coach_id = $1 AND client_id = $2 "+
+			"ORDER BY created_at DESC, id DESC LIMIT $3",
+		coachID, clientID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetUserNotes returns the notes the user wrote as a coach or received as a client, oldest first.
+func (s *Store) GetUserNotes(userID int) ([]models.CoachNote, error) {
+	const op = "coaching.store.GetUserNotes"
+
+	list := []models.CoachNote{}
+	err := s.db.Select(
+		&list,
+		"SELECT id, coach_id, client_id, body, created_at FROM coach_notes WHERE coach_id = $1 OR client_id = $1 "+
+			"ORDER BY created_at, id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// expectRow returns notFound when res changed no row.
+func expectRow(op string, res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package coaching
+
+import (
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+const templateColumns = "id, coach_id, name, notes, created_at, published_at"
+
+func (s *Store) CreateTemplate(template *models.WorkoutTemplate) error {
+	const op = "coaching.store.CreateTemplate"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"INSERT INTO workout_templates(coach_id, name, notes) VALUES($1, $2, $3) RETURNING id, created_at",
+		template.CoachID, template.Name, template.Notes,
+	).Scan(&template.ID, &template.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range template.Sets {
+		set := &template.Sets[i]
+		set.TemplateID, set.Position = template.ID, i
+		_, err = tx.Exec(
+			"INSERT INTO workout_template_sets(template_id, position, exercise_id, reps, weight, duration, distance) "+
+				"VALUES($1, $2, $3, $4, $5, $6, $7)",
+			set.TemplateID, set.Position, set.ExerciseID, set.Reps, set.Weight, set.Duration, set.Distance,
+		)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return ExerciseNotFound
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetTemplates(coachID int) ([]models.WorkoutTemplate, error) {
+	const op = "coaching.store.GetTemplates"
+
+	list := []models.WorkoutTemplate{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+templateColumns+" FROM workout_templates WHERE coach_id = $1 ORDER BY name, id",
+		coachID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadSets(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetTemplate(coachID int, id int) (*models.WorkoutTemplate, error) {
+	const op = "coaching.store.GetTemplate"
+
+	list := []models.WorkoutTemplate{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+templateColumns+" FROM workout_templates WHERE coach_id = $1 AND id = $2",
+		coachID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(list) == 0 {
+		return nil, TemplateNotFound
+	}
+	if err := s.loadSets(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &list[0], nil
+}
+
+// PublishTemplate adds a template of the coach to the program catalog. Publishing twice keeps the first date.
+func (s *Store) PublishTemplate(coachID int, id int) error {
+	const op = "coaching.store.PublishTemplate"
+
+	res, err := s.db.Exec(
+		"UPDATE workout_templates SET published_at = COALESCE(published_at, CURRENT_TIMESTAMP) "+
+			"WHERE coach_id = $1 AND id = $2",
+		coachID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return expectRow(op, res, TemplateNotFound)
+}
+
+// GetPrograms returns the published templates, latest first.
+func (s *Store) GetPrograms(limit int) ([]models.WorkoutTemplate, error) {
+	const op = "coaching.store.GetPrograms"
+
+	list := []models.WorkoutTemplate{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+templateColumns+" FROM workout_templates WHERE published_at IS NOT NULL "+
+			"ORDER BY published_at DESC, id DESC LIMIT $1",
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadSets(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) CreateAssignment(assignment *models.Assignment) error {
+	const op = "coaching.store.CreateAssignment"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO template_assignments(template_id, coach_id, client_id, due_on, note) "+
+			"VALUES($1, $2, $3, $4, $5) RETURNING id, assigned_at",
+		assignment.TemplateID, assignment.CoachID, assignment.ClientID, assignment.DueOn, assignment.Note,
+	).Scan(&assignment.ID, &assignment.AssignedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetAssignments returns the latest assignments of the client with their templates, only the ones
+// given by coachID unless it is 0.
+func (s *Store) GetAssignments(clientID int, coachID int, limit int) ([]models.Assignment, error) {
+	const op = "coaching.store.GetAssignments"
+
+	list := []models.Assignment{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+assignmentColumns+" FROM template_assignments WHERE client_id = $1 AND ($2 = 0 OR coach_id = $2) "+
+			"ORDER BY assigned_at DESC, id DESC LIMIT $3",
+		clientID, coachID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	ids := make([]int64, len(list))
+	for i, a := range list {
+		ids[i] = int64(a.TemplateID)
+	}
+	var templates []models.WorkoutTemplate
+	err = s.db.Select(
+		&templates, "SELECT "+templateColumns+" FROM workout_templates WHERE id = ANY($1)",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadSets(templates); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	index := make(map[int]*models.WorkoutTemplate, len(templates))
+	for i := range templates {
+		index[templates[i].ID] = &templates[i]
+	}
+	for i := range list {
+		list[i].Template = index[list[i].TemplateID]
+	}
+	return list, nil
+}
+
+// CompleteAssignment links a workout of the client to the assignment. Completing it again
+// replaces the workout.
+func (s *Store) CompleteAssignment(clientID int, id int, workoutID int) error {
+	const op = "coaching.store.CompleteAssignment"
+
+	var owned bool
+	err := s.db.Get(
+		&owned, "SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND user_id = $2)", workoutID, clientID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !owned {
+		return WorkoutNotFound
+	}
+
+	res, err := s.db.Exec(
+		"UPDATE template_assignments SET workout_id = $3, completed_at = COALESCE(completed_at, CURRENT_TIMESTAMP) "+
+			"WHERE id = $2 AND client_id = $1",
+		clientID, id, workoutID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return expectRow(op, res, AssignmentNotFound)
+}
+
+func (s *Store) loadSets(templates []models.WorkoutTemplate) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(templates))
+	index := make(map[int]int, len(templates))
+	for i, t := range templates {
+		ids[i] = int64(t.ID)
+		index[t.ID] = i
+		templates[i].Sets = []models.WorkoutTemplateSet{}
+	}
+
+	var sets []models.WorkoutTemplateSet
+	err := s.db.Select(
+		&sets,
+		"SELECT template_id, position, exercise_id, reps, weight, duration, distance FROM workout_template_sets "+
+			"WHERE template_id = ANY($1) ORDER BY template_id, position",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		i := index[set.TemplateID]
+		templates[i].Sets = append(templates[i].Sets, set)
+	}
+	return nil
+}
@@ -0,0 +1,575 @@
+package devicesync
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SyncStore keeps the records of devices in sync. Every synced record has a row in sync_changes
+// holding its version: when and on which device it was last changed, and the sequence number the
+// server gave that change. Changes made through the rest of the API are versioned by triggers.
+type SyncStore interface {
+	Push(userID int, deviceID string, changes []models.SyncChange) (*models.SyncPushResult, error)
+	Pull(userID int, cursor int64, limit int) (*models.SyncPage, error)
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+var tables = map[string]string{
+	models.SyncWorkout:     "workouts",
+	models.SyncWorkoutSet:  "workout_sets",
+	models.SyncDiaryEntry:  "diary_entries",
+	models.SyncWeightEntry: "weight_entries",
+}
+
+type version struct {
+	UserID    int       `db:"user_id"`
+	Deleted   bool      `db:"deleted"`
+	UpdatedAt time.Time `db:"updated_at"`
+	DeviceID  string    `db:"device_id"`
+}
+
+// wins reports whether a change made at updatedAt on deviceID replaces the version. The later
+// change wins, and of two changes made at the same time the one of the greater device id, so every
+// device comes to the same result whatever the order changes reach the server.
+func (v version) wins(updatedAt time.Time, deviceID string) bool {
+	if updatedAt.Equal(v.UpdatedAt) {
+		return deviceID > v.DeviceID
+	}
+	return updatedAt.After(v.UpdatedAt)
+}
+
+// Push applies the changes in order, all of them or none. Pushes of a user are serialized.
+func (s *Store) Push(userID int, deviceID string, changes []models.SyncChange) (*models.SyncPushResult, error) {
+	const op = "devicesync.store.Push"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// The triggers would version the changes as made on the server.
+	if _, err := tx.Exec("SET LOCAL sync.applying = 'on'"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('sync'), $1)", userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := &models.SyncPushResult{Results: make([]models.SyncResult, 0, len(changes))}
+	pushed := newTouched()
+	now := time.Now()
+	for _, c := range changes {
+		result, err := s.apply(tx, userID, deviceID, c, now, pushed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		res.Results = append(res.Results, result)
+	}
+	if res.Created, err = loadRecords(tx, pushed.created); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if res.Updated, err = loadRecords(tx, pushed.changed()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res.Deleted = pushed.deleted
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// touched collects the ids of the records a push created and updated, and the records it deleted.
+type touched struct {
+	created map[string][]int64
+	updated map[string][]int64
+	deleted models.SyncedRecords
+}
+
+func newTouched() *touched {
+	return &touched{created: map[string][]int64{}, updated: map[string][]int64{}}
+}
+
+func (t *touched) merge(o *touched) {
+	for entity, ids := range o.created {
+		t.created[entity] = append(t.created[entity], ids...)
+	}
+	for entity, ids := range o.updated {
+		t.updated[entity] = append(t.updated[entity], ids...)
+	}
+	t.deleted.Workouts = append(t.deleted.Workouts, o.deleted.Workouts...)
+	t.deleted.DiaryEntries = append(t.deleted.DiaryEntries, o.deleted.DiaryEntries...)
+	t.deleted.WeightEntries = append(t.deleted.WeightEntries, o.deleted.WeightEntries...)
+}
+
+// changed returns the ids of the updated records, each once, leaving out the ones the push
+// created: they are reported as created in their final state.
+func (t *touched) changed() map[string][]int64 {
+	ids := map[string][]int64{}
+	for entity, list := range t.updated {
+		for _, id := range list {
+			if !slices.Contains(t.created[entity], id) && !slices.Contains(ids[entity], id) {
+				ids[entity] = append(ids[entity], id)
+			}
+		}
+	}
+	return ids
+}
+
+func (s *Store) apply(
+	tx *sqlx.Tx, userID int, deviceID string, c models.SyncChange, now time.Time, pushed *touched,
+) (models.SyncResult, error) {
+	c.ID = strings.ToLower(c.ID)
+	result := models.SyncResult{Entity: c.Entity, ID: c.ID}
+
+	// A device whose clock runs ahead must not win every conflict to come.
+	updatedAt := c.UpdatedAt.Truncate(time.Microsecond)
+	if updatedAt.After(now) {
+		updatedAt = now
+	}
+
+	var current version
+	err := tx.Get(
+		&current,
+		"SELECT user_id, deleted, updated_at, device_id FROM sync_changes WHERE entity = $1 AND record_id = $2 "+
+			"FOR UPDATE",
+		c.Entity, c.ID,
+	)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return result, err
+	}
+
+	if exists {
+		if current.UserID != userID {
+			result.Decision, result.Reason = models.SyncRejected, "id is already taken"
+			return result, nil
+		}
+		if updatedAt.Equal(current.UpdatedAt) && deviceID == current.DeviceID {
+			result.Decision = models.SyncIgnored
+			return result, nil
+		}
+		if !current.wins(updatedAt, deviceID) {
+			server, err := s.record(tx, userID, c.Entity, c.ID, current)
+			if err != nil {
+				return result, err
+			}
+			result.Decision, result.Server = models.SyncServerWins, server
+			return result, nil
+		}
+	}
+
+	if _, err := tx.Exec("SAVEPOINT sync_change"); err != nil {
+		return result, err
+	}
+
+	// What the change touched only counts once it is applied.
+	change := newTouched()
+	live := exists && !current.Deleted
+	var reason string
+	if c.Op == models.SyncDelete {
+		err = s.delete(tx, userID, c, live, updatedAt, deviceID, change)
+	} else {
+		reason, err = s.upsert(tx, userID, c, live, change)
+	}
+	if err == nil && reason == "" {
+		err = saveVersion(tx, userID, c.Entity, c.ID, c.Op == models.SyncDelete, updatedAt, deviceID)
+	}
+
+	if pgErr, ok := err.(*pq.Error); ok && (pgErr.Code == "23503" || pgErr.Code == "23514") {
+		reason, err = "references a missing record or holds an invalid value", nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if reason != "" {
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT sync_change"); err != nil {
+			return result, err
+		}
+		result.Decision, result.Reason = models.SyncRejected, reason
+		return result, nil
+	}
+	if _, err := tx.Exec("RELEASE SAVEPOINT sync_change"); err != nil {
+		return result, err
+	}
+	pushed.merge(change)
+
+	result.Decision = models.SyncApplied
+	return result, nil
+}
+
+// upsert writes the record of the change and adds its id to the created or updated ones of
+// change; writing a set updates its workout. A non-empty reason rejects the change.
+func (s *Store) upsert(tx *sqlx.Tx, userID int, c models.SyncChange, live bool, change *touched) (string, error) {
+	var id int
+	var err error
+
+	switch data := c.Record.(type) {
+	case *models.SyncWorkoutData:
+		if live {
+			err = tx.QueryRow(
+				"UPDATE workouts SET name = $1, started_at = $2, duration = $3, notes = $4 WHERE uuid = $5 "+
+					"RETURNING id",
+				data.Name, data.StartedAt, data.Duration, data.Notes, c.ID,
+			).Scan(&id)
+			break
+		}
+		err = tx.QueryRow(
+			"INSERT INTO workouts(uuid, user_id, name, started_at, duration, notes, source) "+
+				"VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+			c.ID, userID, data.Name, data.StartedAt, data.Duration, data.Notes, models.WorkoutSourceManual,
+		).Scan(&id)
+
+	case *models.SyncWorkoutSetData:
+		var workoutID int
+		err = tx.Get(&workoutID, "SELECT id FROM workouts WHERE uuid = $1 AND user_id = $2", data.WorkoutID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "workout not found", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if live {
+			_, err = tx.Exec(
+				"UPDATE workout_sets SET workout_id = $1, exercise_id = $2, position = $3, reps = $4, weight = $5, "+
+					"rpe = $6, duration = $7, distance = $8 WHERE uuid = $9",
+				workoutID, data.ExerciseID, data.Position, data.Reps, data.Weight, data.RPE, data.Duration,
+				data.Distance, c.ID,
+			)
+		} else {
+			_, err = tx.Exec(
+				"INSERT INTO workout_sets(uuid, workout_id, exercise_id, position, reps, weight, rpe, duration, "+
+					"distance) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+				c.ID, workoutID, data.ExerciseID, data.Position, data.Reps, data.Weight, data.RPE, data.Duration,
+				data.Distance,
+			)
+		}
+		if err == nil {
+			change.updated[models.SyncWorkout] = append(change.updated[models.SyncWorkout], int64(workoutID))
+		}
+		return "", err
+
+	case *models.SyncDiaryEntryData:
+		if live {
+			err = tx.QueryRow(
+				"UPDATE diary_entries SET eaten_at = $1, meal = $2, name = $3, kcal = $4, protein = $5, carbs = $6, "+
+					"fat = $7, food_id = $8, grams = $9, recipe_id = $10, servings = $11 WHERE uuid = $12 RETURNING id",
+				data.EatenAt, data.Meal, data.Name, data.Kcal, data.Protein, data.Carbs, data.Fat, data.FoodID,
+				data.Grams, data.RecipeID, data.Servings, c.ID,
+			).Scan(&id)
+			break
+		}
+		err = tx.QueryRow(
+			"INSERT INTO diary_entries(uuid, user_id, eaten_at, meal, name, kcal, protein, carbs, fat, food_id, "+
+				"grams, recipe_id, servings) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id",
+			c.ID, userID, data.EatenAt, data.Meal, data.Name, data.Kcal, data.Protein, data.Carbs, data.Fat,
+			data.FoodID, data.Grams, data.RecipeID, data.Servings,
+		).Scan(&id)
+
+	case *models.SyncWeightEntryData:
+		if live {
+			err = tx.QueryRow(
+				"UPDATE weight_entries SET measured_at = $1, weight = $2, note = $3 WHERE uuid = $4 RETURNING id",
+				data.MeasuredAt, data.Weight, data.Note, c.ID,
+			).Scan(&id)
+			break
+		}
+		err = tx.QueryRow(
+			"INSERT INTO weight_entries(uuid, user_id, measured_at, weight, note) VALUES($1, $2, $3, $4, $5) "+
+				"RETURNING id",
+			c.ID, userID, data.MeasuredAt, data.Weight, data.Note,
+		).Scan(&id)
+
+	default:
+		return "", fmt.Errorf("unexpected %s record %T", c.Entity, c.Record)
+	}
+	if err != nil {
+		return "", err
+	}
+	if live {
+		change.updated[c.Entity] = append(change.updated[c.Entity], int64(id))
+	} else {
+		change.created[c.Entity] = append(change.created[c.Entity], int64(id))
+	}
+	return "", nil
+}
+
+// loadRecords reads the records by their ids as they are now, leaving out the ones deleted since.
+func loadRecords(q sqlx.Queryer, ids map[string][]int64) (models.SyncedRecords, error) {
+	var res models.SyncedRecords
+
+	res.Workouts = []models.Workout{}
+	err := sqlx.Select(
+		q, &res.Workouts,
+		"SELECT id, user_id, name, started_at, duration, notes, source, distance, elevation_gain, avg_heart_rate, "+
+			"max_heart_rate, avg_cadence, created_at FROM workouts WHERE id = ANY($1) ORDER BY id",
+		pq.Array(ids[models.SyncWorkout]),
+	)
+	if err != nil {
+		return res, err
+	}
+	if len(res.Workouts) > 0 {
+		var sets []models.WorkoutSet
+		err := sqlx.Select(
+			q, &sets,
+			"SELECT id, workout_id, exercise_id, position, reps, weight, rpe, duration, distance FROM workout_sets "+
+				"WHERE workout_id = ANY($1) ORDER BY workout_id, position",
+			pq.Array(ids[models.SyncWorkout]),
+		)
+		if err != nil {
+			return res, err
+		}
+		index := make(map[int]int, len(res.Workouts))
+		for i, w := range res.Workouts {
+			index[w.ID] = i
+			res.Workouts[i].Sets = []models.WorkoutSet{}
+		}
+		for _, set := range sets {
+			w := &res.Workouts[index[set.WorkoutID]]
+			w.Sets = append(w.Sets, set)
+		}
+	}
+
+	res.DiaryEntries = []models.DiaryEntry{}
+	err = sqlx.Select(
+		q, &res.DiaryEntries,
+		"SELECT id, user_id, eaten_at, meal, name, kcal, protein, carbs, fat, food_id, grams, recipe_id, servings, "+
+			"created_at FROM diary_entries WHERE id = ANY($1) ORDER BY id",
+		pq.Array(ids[models.SyncDiaryEntry]),
+	)
+	if err != nil {
+		return res, err
+	}
+
+	res.WeightEntries = []models.WeightEntry{}
+	err = sqlx.Select(
+		q, &res.WeightEntries,
+		"SELECT id, user_id, measured_at, weight, note, created_at FROM weight_entries WHERE id = ANY($1) ORDER BY id",
+		pq.Array(ids[models.SyncWeightEntry]),
+	)
+	return res, err
+}
+
+// delete removes the record of the change and adds it, as it was, to the deleted ones of change;
+// deleting a set updates its workout. The sets of a deleted workout are deleted with it and get
+// the same version.
+func (s *Store) delete(
+	tx *sqlx.Tx, userID int, c models.SyncChange, live bool, updatedAt time.Time, deviceID string, change *touched,
+) error {
+	if !live {
+		return nil
+	}
+
+	if c.Entity == models.SyncWorkoutSet {
+		var workoutID int64
+		err := tx.Get(&workoutID, "DELETE FROM workout_sets WHERE uuid = $1 RETURNING workout_id", c.ID)
+		if err != nil {
+			return err
+		}
+		change.updated[models.SyncWorkout] = append(change.updated[models.SyncWorkout], workoutID)
+		return nil
+	}
+
+	var id int64
+	if err := tx.Get(&id, "SELECT id FROM "+tables[c.Entity]+" WHERE uuid = $1", c.ID); err != nil {
+		return err
+	}
+	deleted, err := loadRecords(tx, map[string][]int64{c.Entity: {id}})
+	if err != nil {
+		return err
+	}
+	change.deleted = deleted
+
+	if c.Entity == models.SyncWorkout {
+		var sets []string
+		err := tx.Select(&sets, "SELECT uuid FROM workout_sets WHERE workout_id = $1", id)
+		if err != nil {
+			return err
+		}
+		for _, setID := range sets {
+			if err := saveVersion(tx, userID, models.SyncWorkoutSet, setID, true, updatedAt, deviceID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM "+tables[c.Entity]+" WHERE id = $1", id)
+	return err
+}
+
+func saveVersion(
+	tx *sqlx.Tx, userID int, entity string, id string, deleted bool, updatedAt time.Time, deviceID string,
+) error {
+	_, err := tx.Exec(
+		"INSERT INTO sync_changes(entity, record_id, user_id, deleted, updated_at, device_id) "+
+			"VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (entity, record_id) DO UPDATE SET seq = EXCLUDED.seq, "+
+			"deleted = EXCLUDED.deleted, updated_at = EXCLUDED.updated_at, device_id = EXCLUDED.device_id",
+		entity, id, userID, deleted, updatedAt, deviceID,
+	)
+	return err
+}
+
+// record returns the server's version of a record.
+func (s *Store) record(q sqlx.Queryer, userID int, entity string, id string, v version) (*models.SyncRecord, error) {
+	r := &models.SyncRecord{Entity: entity, ID: id, Deleted: v.Deleted, UpdatedAt: v.UpdatedAt}
+	if v.Deleted {
+		return r, nil
+	}
+
+	data, err := loadData(q, userID, entity, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	r.Data = data[id]
+	return r, nil
+}
+
+type change struct {
+	Seq      int64  `db:"seq"`
+	Entity   string `db:"entity"`
+	RecordID string `db:"record_id"`
+	version
+}
+
+// Pull returns the changes made after the cursor, oldest first. Only the latest change of a record
+// is kept, so a page holds the current state of the records it names.
+func (s *Store) Pull(userID int, cursor int64, limit int) (*models.SyncPage, error) {
+	const op = "devicesync.store.Pull"
+
+	var list []change
+	err := s.db.Select(
+		&list,
+		"SELECT seq, entity, record_id, user_id, deleted, updated_at, device_id FROM sync_changes "+
+			"WHERE user_id = $1 AND seq > $2 ORDER BY seq LIMIT $3",
+		userID, cursor, limit+1,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := &models.SyncPage{Changes: []models.SyncRecord{}, Cursor: cursor}
+	if len(list) > limit {
+		list, page.HasMore = list[:limit], true
+	}
+
+	ids := map[string][]string{}
+	for _, c := range list {
+		if !c.Deleted {
+			ids[c.Entity] = append(ids[c.Entity], c.RecordID)
+		}
+	}
+	data := map[string]map[string]any{}
+	for entity, list := range ids {
+		if data[entity], err = loadData(s.db, userID, entity, list); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	for _, c := range list {
+		r := models.SyncRecord{Entity: c.Entity, ID: c.RecordID, Deleted: c.Deleted, UpdatedAt: c.UpdatedAt}
+		if !c.Deleted {
+			r.Data = data[c.Entity][c.RecordID]
+			// Deleted since the changes were read, the tombstone follows on a later page.
+			r.Deleted = r.Data == nil
+		}
+		page.Changes = append(page.Changes, r)
+		page.Cursor = c.Seq
+	}
+	return page, nil
+}
+
+// loadData returns the data of the records of an entity by their id.
+func loadData(q sqlx.Queryer, userID int, entity string, ids []string) (map[string]any, error) {
+	data := make(map[string]any, len(ids))
+
+	switch entity {
+	case models.SyncWorkout:
+		var list []struct {
+			ID string `db:"id"`
+			models.SyncWorkoutData
+		}
+		err := sqlx.Select(
+			q, &list,
+			"SELECT uuid AS id, name, started_at, duration, notes FROM workouts "+
+				"WHERE user_id = $1 AND uuid = ANY($2::uuid[])",
+			userID, pq.Array(ids),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			data[r.ID] = r.SyncWorkoutData
+		}
+
+	case models.SyncWorkoutSet:
+		var list []struct {
+			ID string `db:"id"`
+			models.SyncWorkoutSetData
+		}
+		err := sqlx.Select(
+			q, &list,
+			"SELECT s.uuid AS id, w.uuid AS workout_id, s.exercise_id, s.position, s.reps, s.weight, s.rpe, "+
+				"s.duration, s.distance FROM workout_sets s JOIN workouts w ON w.id = s.workout_id "+
+				"WHERE w.user_id = $1 AND s.uuid = ANY($2::uuid[])",
+			userID, pq.Array(ids),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			data[r.ID] = r.SyncWorkoutSetData
+		}
+
+	case models.SyncDiaryEntry:
+		var list []struct {
+			ID string `db:"id"`
+			models.SyncDiaryEntryData
+		}
+		err := sqlx.Select(
+			q, &list,
+			"SELECT uuid AS id, eaten_at, meal, name, kcal, protein, carbs, fat, food_id, grams, recipe_id, servings "+
+				"FROM diary_entries WHERE user_id = $1 AND uuid = ANY($2::uuid[])",
+			userID, pq.Array(ids),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			data[r.ID] = r.SyncDiaryEntryData
+		}
+
+	case models.SyncWeightEntry:
+		var list []struct {
+			ID string `db:"id"`
+			models.SyncWeightEntryData
+		}
+		err := sqlx.Select(
+			q, &list,
+			"SELECT uuid AS id, measured_at, weight, note FROM weight_entries "+
+				"WHERE user_id = $1 AND uuid = ANY($2::uuid[])",
+			userID, pq.Array(ids),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			data[r.ID] = r.SyncWeightEntryData
+		}
+	}
+	return data, nil
+}
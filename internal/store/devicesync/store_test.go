@@ -0,0 +1,214 @@
+package devicesync
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVersionWins(t *testing.T) {
+	at := time.Date(2024, 10, 3, 12, 0, 0, 0, time.UTC)
+	v := version{UpdatedAt: at, DeviceID: "phone-b"}
+
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		deviceID  string
+		want      bool
+	}{
+		{name: "later", updatedAt: at.Add(time.Second), deviceID: "phone-a", want: true},
+		{name: "earlier", updatedAt: at.Add(-time.Second), deviceID: "phone-c", want: false},
+		{name: "same time, greater device", updatedAt: at, deviceID: "phone-c", want: true},
+		{name: "same time, lesser device", updatedAt: at, deviceID: "phone-a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := v.wins(tt.updatedAt, tt.deviceID); got != tt.want {
+					t.Errorf("wins() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+// The tests below run against the database of TEST_DATABASE_URL, which they migrate, and are
+// skipped without one. They remove the users they create, and their records with them.
+func testStore(t *testing.T) (*Store, *sqlx.DB, int) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var userID int
+	err = db.Get(
+		&userID,
+		"INSERT INTO users(email, username, password, activation_code) "+
+			"VALUES($1 || '@example.com', $1, '\\x00', '') RETURNING id",
+		fmt.Sprintf("sync-test-%d", time.Now().UnixNano()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", userID) })
+	return NewStore(db), db, userID
+}
+
+func weightChange(id string, updatedAt time.Time, weight float64) models.SyncChange {
+	return models.SyncChange{
+		Entity: models.SyncWeightEntry, ID: id, Op: models.SyncUpsert, UpdatedAt: updatedAt,
+		Record: &models.SyncWeightEntryData{MeasuredAt: updatedAt, Weight: weight},
+	}
+}
+
+func push(t *testing.T, s *Store, userID int, deviceID string, changes ...models.SyncChange) []models.SyncResult {
+	t.Helper()
+	res, err := s.Push(userID, deviceID, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Results
+}
+
+func TestPushConflicts(t *testing.T) {
+	s, _, userID := testStore(t)
+	_, _, otherID := testStore(t)
+	id := uuid.NewString()
+	at := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+
+	steps := []struct {
+		name         string
+		userID       int
+		deviceID     string
+		change       models.SyncChange
+		wantDecision string
+	}{
+		{
+			name: "created", userID: userID, deviceID: "b", change: weightChange(id, at, 80),
+			wantDecision: models.SyncApplied,
+		},
+		{
+			name: "pushed again", userID: userID, deviceID: "b", change: weightChange(id, at, 80),
+			wantDecision: models.SyncIgnored,
+		},
+		{
+			name: "older change", userID: userID, deviceID: "a", change: weightChange(id, at.Add(-time.Minute), 79),
+			wantDecision: models.SyncServerWins,
+		},
+		{
+			name: "same time, lesser device", userID: userID, deviceID: "a", change: weightChange(id, at, 78),
+			wantDecision: models.SyncServerWins,
+		},
+		{
+			name: "same time, greater device", userID: userID, deviceID: "c", change: weightChange(id, at, 81),
+			wantDecision: models.SyncApplied,
+		},
+		{
+			name: "id of another user", userID: otherID, deviceID: "d", change: weightChange(id, at, 60),
+			wantDecision: models.SyncRejected,
+		},
+	}
+
+	// The steps run in order, each one against the version the previous one left.
+	for _, step := range steps {
+		got := push(t, s, step.userID, step.deviceID, step.change)[0]
+		if got.Decision != step.wantDecision {
+			t.Fatalf("%s: decision = %s (%s), want %s", step.name, got.Decision, got.Reason, step.wantDecision)
+		}
+		if step.wantDecision != models.SyncServerWins {
+			continue
+		}
+		data, ok := got.Server.Data.(models.SyncWeightEntryData)
+		if !ok || data.Weight != 80 {
+			t.Errorf("%s: server version = %+v, want the weight of 80", step.name, got.Server)
+		}
+	}
+}
+
+// pullAll pulls from cursor, limit changes at a time, until no page follows.
+func pullAll(t *testing.T, s *Store, userID int, cursor int64, limit int) ([]models.SyncRecord, int64, int) {
+	t.Helper()
+	var all []models.SyncRecord
+	pages := 0
+	for {
+		page, err := s.Pull(userID, cursor, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		if page.Cursor < cursor {
+			t.Fatalf("cursor went back from %d to %d", cursor, page.Cursor)
+		}
+		all, cursor = append(all, page.Changes...), page.Cursor
+		if !page.HasMore {
+			return all, cursor, pages
+		}
+	}
+}
+
+func TestPullCursor(t *testing.T) {
+	s, db, userID := testStore(t)
+	at := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	ids := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+
+	push(
+		t, s, userID, "phone",
+		weightChange(ids[0], at, 80), weightChange(ids[1], at, 81), weightChange(ids[2], at, 82),
+	)
+	changes, cursor, pages := pullAll(t, s, userID, 0, 2)
+	if len(changes) != 3 || pages != 2 {
+		t.Fatalf("first pull = %d changes in %d pages, want 3 in 2", len(changes), pages)
+	}
+
+	// Nothing changed: the cursor stays where it is.
+	if changes, next, _ := pullAll(t, s, userID, cursor, 2); len(changes) != 0 || next != cursor {
+		t.Fatalf("pull without changes = %d changes, cursor %d, want none and cursor %d", len(changes), next, cursor)
+	}
+
+	// A record changed twice since the cursor is pulled once, in its latest state, with the
+	// changes made through the rest of the API and the deletions.
+	push(t, s, userID, "phone", weightChange(ids[0], at.Add(time.Minute), 79))
+	push(t, s, userID, "phone", weightChange(ids[0], at.Add(2*time.Minute), 78))
+	push(
+		t, s, userID, "phone",
+		models.SyncChange{
+			Entity: models.SyncWeightEntry, ID: ids[1], Op: models.SyncDelete, UpdatedAt: at.Add(time.Minute),
+		},
+	)
+	_, err := db.Exec(
+		"INSERT INTO weight_entries(user_id, measured_at, weight) VALUES($1, $2, 77)", userID, at.Add(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, _, _ = pullAll(t, s, userID, cursor, 10)
+	if len(changes) != 3 {
+		t.Fatalf("pull after changes = %+v, want 3 changes", changes)
+	}
+	if c := changes[0]; c.ID != ids[0] || c.Data.(models.SyncWeightEntryData).Weight != 78 {
+		t.Errorf("first change = %+v, want %s at 78", c, ids[0])
+	}
+	if c := changes[1]; c.ID != ids[1] || !c.Deleted || c.Data != nil {
+		t.Errorf("second change = %+v, want the tombstone of %s", c, ids[1])
+	}
+	if c := changes[2]; c.Deleted || c.Data.(models.SyncWeightEntryData).Weight != 77 {
+		t.Errorf("third change = %+v, want the entry added through the API", c)
+	}
+}
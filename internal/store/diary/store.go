@@ -0,0 +1,90 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type DiaryStore interface {
+	CreateEntry(userID int, payload models.CreateDiaryEntryPayload) (*models.DiaryEntry, error)
+	GetEntries(userID int, from time.Time, to time.Time) ([]models.DiaryEntry, error)
+	DeleteEntry(userID int, id int) error
+}
+
+var (
+	EntryNotFound = errors.New("diary entry not found")
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateEntry(userID int, p models.CreateDiaryEntryPayload) (*models.DiaryEntry, error) {
+	const op = "diary.store.CreateEntry"
+
+	e := models.DiaryEntry{
+		UserID:   userID,
+		EatenAt:  p.EatenAt,
+		Meal:     p.Meal,
+		Name:     p.Name,
+		Kcal:     p.Kcal,
+		Protein:  p.Protein,
+		Carbs:    p.Carbs,
+		Fat:      p.Fat,
+		FoodID:   p.FoodID,
+		Grams:    p.Grams,
+		RecipeID: p.RecipeID,
+		Servings: p.Servings,
+	}
+	err := s.db.QueryRowx(
+		"INSERT INTO diary_entries(user_id, eaten_at, meal, name, kcal, protein, carbs, fat, food_id, grams, "+
+			"recipe_id, servings) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at",
+		e.UserID, e.EatenAt, e.Meal, e.Name, e.Kcal, e.Protein, e.Carbs, e.Fat, e.FoodID, e.Grams, e.RecipeID,
+		e.Servings,
+	).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+// GetEntries returns the entries eaten in [from, to), oldest first.
+func (s *Store) GetEntries(userID int, from time.Time, to time.Time) ([]models.DiaryEntry, error) {
+	const op = "diary.store.GetEntries"
+
+	entries := []models.DiaryEntry{}
+	err := s.db.Select(
+		&entries,
+		"SELECT id, user_id, eaten_at, meal, name, kcal, protein, carbs, fat, food_id, grams, recipe_id, "+
+			"servings, created_at FROM diary_entries WHERE user_id = $1 AND eaten_at >= $2 AND eaten_at < $3 ORDER BY eaten_at, id",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return entries, nil
+}
+
+func (s *Store) DeleteEntry(userID int, id int) error {
+	const op = "diary.store.DeleteEntry"
+
+	res, err := s.db.Exec("DELETE FROM diary_entries WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return EntryNotFound
+	}
+	return nil
+}
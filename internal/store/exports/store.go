@@ -0,0 +1,142 @@
+package exports
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type ExportStore interface {
+	CreateExport(userID int) (*models.DataExport, error)
+	GetExports(userID int) ([]models.DataExport, error)
+	GetExportByToken(tokenHash string) (*models.DataExport, error)
+	ClaimPending(lease time.Duration) (*models.DataExport, error)
+	CompleteExport(id int, tokenHash string, blobKey string, expiresAt time.Time) error
+	FailExport(id int, errMsg string) error
+	ExpireExports(now time.Time) ([]string, error)
+}
+
+var (
+	ExportNotFound = errors.New("export not found")
+)
+
+const exportColumns = "id, user_id, status, error, token_hash, blob_key, created_at, finished_at, expires_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateExport(userID int) (*models.DataExport, error) {
+	const op = "exports.store.CreateExport"
+
+	var e models.DataExport
+	err := s.db.QueryRowx("INSERT INTO data_exports(user_id) VALUES($1) RETURNING "+exportColumns, userID).
+		StructScan(&e)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+func (s *Store) GetExports(userID int) ([]models.DataExport, error) {
+	const op = "exports.store.GetExports"
+
+	list := []models.DataExport{}
+	err := s.db.Select(
+		&list, "SELECT "+exportColumns+" FROM data_exports WHERE user_id = $1 ORDER BY created_at DESC", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetExportByToken(tokenHash string) (*models.DataExport, error) {
+	const op = "exports.store.GetExportByToken"
+
+	var e models.DataExport
+	err := s.db.Get(&e, "SELECT "+exportColumns+" FROM data_exports WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ExportNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+// ClaimPending marks the oldest pending export as processing for lease and returns it. An export
+// still processing after its lease belonged to a worker that died and is claimed again.
+func (s *Store) ClaimPending(lease time.Duration) (*models.DataExport, error) {
+	const op = "exports.store.ClaimPending"
+
+	var e models.DataExport
+	err := s.db.QueryRowx(
+		"UPDATE data_exports SET status = $1, claimed_until = $3 WHERE id = ("+
+			"SELECT id FROM data_exports WHERE status = $2 OR status = $1 AND claimed_until < CURRENT_TIMESTAMP "+
+			"ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED"+
+			") RETURNING "+exportColumns,
+		models.ExportProcessing, models.ExportPending, time.Now().Add(lease),
+	).StructScan(&e)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ExportNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &e, nil
+}
+
+func (s *Store) CompleteExport(id int, tokenHash string, blobKey string, expiresAt time.Time) error {
+	const op = "exports.store.CompleteExport"
+
+	_, err := s.db.Exec(
+		"UPDATE data_exports SET status = $1, token_hash = $2, blob_key = $3, expires_at = $4, "+
+			"finished_at = CURRENT_TIMESTAMP WHERE id = $5",
+		models.ExportReady, tokenHash, blobKey, expiresAt, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) FailExport(id int, errMsg string) error {
+	const op = "exports.store.FailExport"
+
+	_, err := s.db.Exec(
+		"UPDATE data_exports SET status = $1, error = $2, finished_at = CURRENT_TIMESTAMP WHERE id = $3",
+		models.ExportFailed, errMsg, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ExpireExports invalidates the download links that expired before now and returns
+// the blob keys of the archives that should be deleted.
+func (s *Store) ExpireExports(now time.Time) ([]string, error) {
+	const op = "exports.store.ExpireExports"
+
+	var keys []string
+	err := s.db.Select(
+		&keys,
+		"WITH expired AS ("+
+			"SELECT id, blob_key FROM data_exports WHERE status = $1 AND expires_at < $2 FOR UPDATE SKIP LOCKED"+
+			") UPDATE data_exports d SET status = $3, token_hash = NULL, blob_key = '' FROM expired "+
+			"WHERE d.id = expired.id RETURNING expired.blob_key",
+		models.ExportReady, now, models.ExportExpired,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,249 @@
+package foods
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/nutrition"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type FoodStore interface {
+	UpsertFoods(foods []models.Food) error
+	CreateFood(ownerID int, payload models.CreateFoodPayload) (*models.Food, error)
+	PublishFood(id int) (*models.Food, error)
+	GetFoodByID(userID int, id int) (*models.Food, error)
+	GetFoodsByIDs(userID int, ids []int) (map[int]models.Food, error)
+	GetFoodByBarcode(userID int, barcode string) (*models.Food, error)
+	SearchFoods(userID int, query string, limit int) ([]models.Food, error)
+	GetPlanCandidates(userID int, prefs models.Preferences, seed int64, limit int) ([]models.Food, error)
+}
+
+var (
+	FoodNotFound = errors.New("food not found")
+	FoodExists   = errors.New("a shared food with this barcode already exists")
+)
+
+const foodColumns = "id, owner_id, barcode, name, brand, source, kcal, protein, carbs, fat, saturated_fat, sugars, " +
+	"fiber, sodium, calcium, iron, potassium, vitamin_c, allergens, diets, created_at, updated_at"
+
+// visible restricts queries to the shared database and the user's own foods.
+const visible = "(owner_id IS NULL OR owner_id = $1)"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// UpsertFoods inserts shared foods in one transaction, updating the ones whose barcode already exists.
+func (s *Store) UpsertFoods(foods []models.Food) error {
+	const op = "foods.store.UpsertFoods"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Preparex(
+		"INSERT INTO foods(barcode, name, brand, source, kcal, protein, carbs, fat, saturated_fat, sugars, fiber, " +
+			"sodium, calcium, iron, potassium, vitamin_c, allergens, diets) " +
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, " +
+			"COALESCE($17, '{}'::TEXT[]), COALESCE($18, '{}'::TEXT[])) " +
+			"ON CONFLICT (barcode) WHERE owner_id IS NULL AND barcode IS NOT NULL DO UPDATE SET " +
+			"name = EXCLUDED.name, brand = EXCLUDED.brand, source = EXCLUDED.source, kcal = EXCLUDED.kcal, " +
+			"protein = EXCLUDED.protein, carbs = EXCLUDED.carbs, fat = EXCLUDED.fat, " +
+			"saturated_fat = EXCLUDED.saturated_fat, sugars = EXCLUDED.sugars, fiber = EXCLUDED.fiber, " +
+			"sodium = EXCLUDED.sodium, calcium = EXCLUDED.calcium, iron = EXCLUDED.iron, " +
+			"potassium = EXCLUDED.potassium, vitamin_c = EXCLUDED.vitamin_c, allergens = EXCLUDED.allergens, " +
+			"diets = EXCLUDED.diets, updated_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, f := range foods {
+		n := f.Nutrients
+		_, err := stmt.Exec(
+			f.Barcode, f.Name, f.Brand, f.Source, n.Kcal, n.Protein, n.Carbs, n.Fat, n.SaturatedFat, n.Sugars,
+			n.Fiber, n.Sodium, n.Calcium, n.Iron, n.Potassium, n.VitaminC, f.Allergens, f.Diets,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CreateFood saves a private food only visible to its owner.
+func (s *Store) CreateFood(ownerID int, p models.CreateFoodPayload) (*models.Food, error) {
+	const op = "foods.store.CreateFood"
+
+	n := p.Nutrients
+	var f models.Food
+	err := s.db.QueryRowx(
+		"INSERT INTO foods(owner_id, barcode, name, brand, source, kcal, protein, carbs, fat, saturated_fat, "+
+			"sugars, fiber, sodium, calcium, iron, potassium, vitamin_c, allergens, diets) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, "+
+			"COALESCE($18, '{}'::TEXT[]), COALESCE($19, '{}'::TEXT[])) "+
+			"RETURNING "+foodColumns,
+		ownerID, p.Barcode, p.Name, p.Brand, models.FoodSourceUser, n.Kcal, n.Protein, n.Carbs, n.Fat,
+		n.SaturatedFat, n.Sugars, n.Fiber, n.Sodium, n.Calcium, n.Iron, n.Potassium, n.VitaminC,
+		pq.Array(p.Allergens), pq.Array(p.Diets),
+	).StructScan(&f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// PublishFood moves a food of a user to the shared database, where every user sees it.
+func (s *Store) PublishFood(id int) (*models.Food, error) {
+	const op = "foods.store.PublishFood"
+
+	var f models.Food
+	err := s.db.QueryRowx(
+		"UPDATE foods SET owner_id = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND owner_id IS NOT NULL "+
+			"RETURNING "+foodColumns,
+		id,
+	).StructScan(&f)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, FoodNotFound
+		}
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, FoodExists
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+func (s *Store) GetFoodByID(userID int, id int) (*models.Food, error) {
+	const op = "foods.store.GetFoodByID"
+
+	var f models.Food
+	err := s.db.Get(&f, "SELECT "+foodColumns+" FROM foods WHERE "+visible+" AND id = $2", userID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, FoodNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// GetFoodsByIDs returns the visible foods among ids, keyed by id.
+func (s *Store) GetFoodsByIDs(userID int, ids []int) (map[int]models.Food, error) {
+	const op = "foods.store.GetFoodsByIDs"
+
+	ids64 := make([]int64, len(ids))
+	for i, id := range ids {
+		ids64[i] = int64(id)
+	}
+
+	var list []models.Food
+	err := s.db.Select(
+		&list, "SELECT "+foodColumns+" FROM foods WHERE "+visible+" AND id = ANY($2)", userID, pq.Array(ids64),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := make(map[int]models.Food, len(list))
+	for _, f := range list {
+		res[f.ID] = f
+	}
+	return res, nil
+}
+
+// GetFoodByBarcode prefers the user's own food over the shared database entry.
+func (s *Store) GetFoodByBarcode(userID int, barcode string) (*models.Food, error) {
+	const op = "foods.store.GetFoodByBarcode"
+
+	var f models.Food
+	err := s.db.Get(
+		&f,
+		"SELECT "+foodColumns+" FROM foods WHERE "+visible+" AND barcode = $2 ORDER BY owner_id NULLS LAST LIMIT 1",
+		userID, barcode,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, FoodNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// SearchFoods runs a prefix full-text search over names and brands ranked by relevance.
+func (s *Store) SearchFoods(userID int, query string, limit int) ([]models.Food, error) {
+	const op = "foods.store.SearchFoods"
+
+	list := []models.Food{}
+	tsQuery := prefixQuery(query)
+	if tsQuery == "" {
+		return list, nil
+	}
+
+	err := s.db.Select(
+		&list,
+		"SELECT "+foodColumns+" FROM foods WHERE "+visible+" AND search @@ to_tsquery('simple', $2) "+
+			"ORDER BY ts_rank(search, to_tsquery('simple', $2)) DESC, owner_id NULLS LAST, name LIMIT $3",
+		userID, tsQuery, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetPlanCandidates samples foods fitting the preferences for the meal planner. The user's own foods
+// come first, the shared ones are shuffled by the seed so the same seed yields the same sample.
+func (s *Store) GetPlanCandidates(userID int, prefs models.Preferences, seed int64, limit int) (
+	[]models.Food, error,
+) {
+	const op = "foods.store.GetPlanCandidates"
+
+	query := "SELECT " + foodColumns + " FROM foods WHERE " + visible + " AND kcal >= 20 AND " +
+		"protein + carbs + fat > 0 AND NOT allergens && $2"
+	args := []any{userID, pq.Array(append([]string{}, prefs.Allergens...)), strconv.FormatInt(seed, 10), limit}
+	if labels := nutrition.DietLabels(prefs.Diet); labels != nil {
+		query += " AND diets && $5"
+		args = append(args, pq.Array(labels))
+	}
+	query += " ORDER BY owner_id NULLS LAST, md5(id::TEXT || $3), id LIMIT $4"
+
+	list := []models.Food{}
+	if err := s.db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// prefixQuery turns free text into a tsquery matching every word as a prefix, e.g. "greek yog" -> "greek:* & yog:*".
+// Only letters and digits are kept so user input can never break the tsquery syntax.
+func prefixQuery(query string) string {
+	words := strings.FieldsFunc(
+		strings.ToLower(query), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		},
+	)
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
@@ -0,0 +1,206 @@
+package goals
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type GoalStore interface {
+	CreateGoal(goal *models.Goal) error
+	GetGoals(userID int, status string) ([]models.Goal, error)
+	GetGoal(userID int, id int) (*models.Goal, error)
+	GetMilestones(goalID int) ([]models.GoalMilestone, error)
+	SaveProgress(goal models.Goal) error
+	AddMilestone(goalID int, percent int) (bool, error)
+	FinishGoal(goalID int, status string) (bool, error)
+	AbandonGoal(userID int, id int) error
+	ClaimOffTrackNotice(goalID int, notifiedBefore time.Time) (bool, error)
+	GetUsersToEvaluate(evaluatedBefore time.Time, afterID int, limit int) ([]int, error)
+}
+
+var (
+	GoalNotFound = errors.New("goal not found")
+)
+
+const goalColumns = "id, user_id, kind, target, start_value, to_char(starts_on, 'YYYY-MM-DD') AS starts_on, " +
+	"to_char(ends_on, 'YYYY-MM-DD') AS ends_on, status, progress, current_value, off_track, off_track_notified_at, " +
+	"evaluated_at, completed_at, created_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateGoal(goal *models.Goal) error {
+	const op = "goals.store.CreateGoal"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO goals(user_id, kind, target, start_value, starts_on, ends_on) VALUES($1, $2, $3, $4, $5, $6) "+
+			"RETURNING "+goalColumns,
+		goal.UserID, goal.Kind, goal.Target, goal.StartValue, goal.StartsOn, goal.EndsOn,
+	).StructScan(goal)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetGoals returns the user's goals with the given status, or all of them when status is empty.
+func (s *Store) GetGoals(userID int, status string) ([]models.Goal, error) {
+	const op = "goals.store.GetGoals"
+
+	list := []models.Goal{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+goalColumns+" FROM goals WHERE user_id = $1 AND ($2 = '' OR status = $2) ORDER BY ends_on, id",
+		userID, status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetGoal(userID int, id int) (*models.Goal, error) {
+	const op = "goals.store.GetGoal"
+
+	var g models.Goal
+	err := s.db.Get(&g, "SELECT "+goalColumns+" FROM goals WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, GoalNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &g, nil
+}
+
+func (s *Store) GetMilestones(goalID int) ([]models.GoalMilestone, error) {
+	const op = "goals.store.GetMilestones"
+
+	list := []models.GoalMilestone{}
+	err := s.db.Select(
+		&list, "SELECT goal_id, percent, reached_at FROM goal_milestones WHERE goal_id = $1 ORDER BY percent", goalID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// SaveProgress stores the outcome of an evaluation. Goals that are no longer active are left untouched.
+func (s *Store) SaveProgress(goal models.Goal) error {
+	const op = "goals.store.SaveProgress"
+
+	_, err := s.db.Exec(
+		"UPDATE goals SET progress = $1, current_value = $2, off_track = $3, evaluated_at = CURRENT_TIMESTAMP "+
+			"WHERE id = $4 AND status = 'active'",
+		goal.Progress, goal.Current, goal.OffTrack, goal.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// AddMilestone records that the goal reached percent. It reports false when the milestone
+// had already been reached, so every milestone is announced once.
+func (s *Store) AddMilestone(goalID int, percent int) (bool, error) {
+	const op = "goals.store.AddMilestone"
+
+	res, err := s.db.Exec(
+		"INSERT INTO goal_milestones(goal_id, percent) VALUES($1, $2) ON CONFLICT DO NOTHING", goalID, percent,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n > 0, nil
+}
+
+// FinishGoal moves an active goal to status. It reports false when the goal was not active anymore.
+func (s *Store) FinishGoal(goalID int, status string) (bool, error) {
+	const op = "goals.store.FinishGoal"
+
+	res, err := s.db.Exec(
+		"UPDATE goals SET status = $1, completed_at = CASE WHEN $1 = 'completed' THEN CURRENT_TIMESTAMP END, "+
+			"off_track = FALSE, evaluated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = 'active'",
+		status, goalID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n > 0, nil
+}
+
+func (s *Store) AbandonGoal(userID int, id int) error {
+	const op = "goals.store.AbandonGoal"
+
+	res, err := s.db.Exec(
+		"UPDATE goals SET status = 'abandoned', off_track = FALSE WHERE id = $1 AND user_id = $2 AND status = 'active'",
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return GoalNotFound
+	}
+	return nil
+}
+
+// ClaimOffTrackNotice marks the goal as notified unless a notice was sent after notifiedBefore.
+// Only the caller that gets true sends the notice.
+func (s *Store) ClaimOffTrackNotice(goalID int, notifiedBefore time.Time) (bool, error) {
+	const op = "goals.store.ClaimOffTrackNotice"
+
+	res, err := s.db.Exec(
+		"UPDATE goals SET off_track_notified_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'active' "+
+			"AND (off_track_notified_at IS NULL OR off_track_notified_at < $2)",
+		goalID, notifiedBefore,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n > 0, nil
+}
+
+// GetUsersToEvaluate returns users having an active goal that was not evaluated since evaluatedBefore,
+// in the order of their ids starting after afterID.
+func (s *Store) GetUsersToEvaluate(evaluatedBefore time.Time, afterID int, limit int) ([]int, error) {
+	const op = "goals.store.GetUsersToEvaluate"
+
+	ids := []int{}
+	err := s.db.Select(
+		&ids,
+		"SELECT DISTINCT user_id FROM goals WHERE status = 'active' "+
+			"AND (evaluated_at IS NULL OR evaluated_at < $1) AND user_id > $2 ORDER BY user_id LIMIT $3",
+		evaluatedBefore, afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
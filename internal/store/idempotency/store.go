@@ -0,0 +1,113 @@
+package idempotency
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type IdempotencyStore interface {
+	Reserve(
+		scope string, key string, requestHash string, lockedUntil time.Time, expiresAt time.Time,
+	) (*models.IdempotencyKey, bool, error)
+	Complete(k *models.IdempotencyKey, statusCode int, header []byte, body []byte) error
+	Release(k *models.IdempotencyKey) error
+	DeleteExpired() (int64, error)
+}
+
+var KeyNotFound = errors.New("idempotency key not found")
+
+const keyColumns = "scope, key, request_hash, status_code, header, body, created_at, locked_until, expires_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// Reserve claims the key for a new request until lockedUntil. It reports true when the caller got
+// the key, which includes taking over an expired one or one whose request did not complete before
+// its lock ran out, e.g. because the server crashed. Otherwise it returns the request that holds
+// the key; when that one was released in the meantime, KeyNotFound is returned.
+func (s *Store) Reserve(
+	scope string, key string, requestHash string, lockedUntil time.Time, expiresAt time.Time,
+) (*models.IdempotencyKey, bool, error) {
+	const op = "idempotency.store.Reserve"
+
+	var k models.IdempotencyKey
+	err := s.db.Get(
+		&k,
+		"INSERT INTO idempotency_keys(scope, key, request_hash, locked_until, expires_at) VALUES($1, $2, $3, $4, $5) "+
+			"ON CONFLICT (scope, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = NULL, "+
+			"header = NULL, body = NULL, created_at = CURRENT_TIMESTAMP, locked_until = EXCLUDED.locked_until, "+
+			"expires_at = EXCLUDED.expires_at "+
+			"WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP OR "+
+			"idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= CURRENT_TIMESTAMP "+
+			"RETURNING "+keyColumns,
+		scope, key, requestHash, lockedUntil, expiresAt,
+	)
+	if err == nil {
+		return &k, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.Get(&k, "SELECT "+keyColumns+" FROM idempotency_keys WHERE scope = $1 AND key = $2", scope, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("%s: %w", op, KeyNotFound)
+		}
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return &k, false, nil
+}
+
+// Complete stores the response of the request holding the key. A request whose key was taken
+// over after its lock ran out does not overwrite the one that took it over.
+func (s *Store) Complete(k *models.IdempotencyKey, statusCode int, header []byte, body []byte) error {
+	const op = "idempotency.store.Complete"
+
+	_, err := s.db.Exec(
+		"UPDATE idempotency_keys SET status_code = $1, header = $2, body = $3 "+
+			"WHERE scope = $4 AND key = $5 AND created_at = $6",
+		statusCode, header, body, k.Scope, k.Key, k.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Release frees a key whose request did not complete, so that it can be retried.
+func (s *Store) Release(k *models.IdempotencyKey) error {
+	const op = "idempotency.store.Release"
+
+	_, err := s.db.Exec(
+		"DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code IS NULL",
+		k.Scope, k.Key, k.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteExpired() (int64, error) {
+	const op = "idempotency.store.DeleteExpired"
+
+	res, err := s.db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
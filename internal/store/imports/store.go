@@ -0,0 +1,119 @@
+package imports
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type ImportStore interface {
+	CreateImport(userID int, filename string, format string, data []byte) (*models.Import, error)
+	GetImport(userID int, id int) (*models.Import, error)
+	ClaimPending(lease time.Duration) (*models.Import, []byte, error)
+	FinishImport(id int, status string, workoutID *int, errMsg string) error
+}
+
+var (
+	ImportNotFound = errors.New("import not found")
+)
+
+const importColumns = "id, user_id, filename, format, status, error, workout_id, created_at, finished_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateImport(userID int, filename string, format string, data []byte) (*models.Import, error) {
+	const op = "imports.store.CreateImport"
+
+	var imp models.Import
+	err := s.db.QueryRowx(
+		"INSERT INTO imports(user_id, filename, format, data) VALUES($1, $2, $3, $4) RETURNING "+importColumns,
+		userID, filename, format, data,
+	).StructScan(&imp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &imp, nil
+}
+
+func (s *Store) GetImport(userID int, id int) (*models.Import, error) {
+	const op = "imports.store.GetImport"
+
+	var imp models.Import
+	err := s.db.Get(&imp, "SELECT "+importColumns+" FROM imports WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ImportNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &imp, nil
+}
+
+// ClaimPending marks the oldest pending import as processing for lease and returns it with its
+// file. Rows locked by other workers are skipped, so several instances can run side by side, and
+// an import still processing after its lease belonged to a worker that died and is claimed again.
+func (s *Store) ClaimPending(lease time.Duration) (*models.Import, []byte, error) {
+	const op = "imports.store.ClaimPending"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var row struct {
+		models.Import
+		Data []byte `db:"data"`
+	}
+	err = tx.Get(
+		&row,
+		"SELECT "+importColumns+", data FROM imports "+
+			"WHERE status = $1 OR status = $2 AND claimed_until < CURRENT_TIMESTAMP "+
+			"ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED",
+		models.ImportPending, models.ImportProcessing,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ImportNotFound
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE imports SET status = $1, claimed_until = $2 WHERE id = $3",
+		models.ImportProcessing, time.Now().Add(lease), row.ID,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row.Import.Status = models.ImportProcessing
+	return &row.Import, row.Data, nil
+}
+
+// FinishImport records the outcome and drops the uploaded file.
+func (s *Store) FinishImport(id int, status string, workoutID *int, errMsg string) error {
+	const op = "imports.store.FinishImport"
+
+	_, err := s.db.Exec(
+		"UPDATE imports SET status = $1, workout_id = $2, error = $3, data = NULL, finished_at = CURRENT_TIMESTAMP "+
+			"WHERE id = $4",
+		status, workoutID, errMsg, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
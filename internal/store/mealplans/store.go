@@ -0,0 +1,144 @@
+package mealplans
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type MealPlanStore interface {
+	CreatePlan(plan *models.MealPlan) error
+	GetPlan(userID int, id int) (*models.MealPlan, error)
+	GetPlans(userID int) ([]models.MealPlan, error)
+	UpdatePlanDays(plan *models.MealPlan) error
+}
+
+var (
+	MealPlanNotFound = errors.New("meal plan not found")
+)
+
+// planRow holds the JSONB columns before they are decoded into the plan.
+type planRow struct {
+	ID        int       `db:"id"`
+	UserID    int       `db:"user_id"`
+	Seed      int64     `db:"seed"`
+	Targets   []byte    `db:"targets"`
+	Days      []byte    `db:"days"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+const planColumns = "id, user_id, seed, targets, days, created_at, updated_at"
+
+func (row planRow) decode() (*models.MealPlan, error) {
+	plan := models.MealPlan{
+		ID: row.ID, UserID: row.UserID, Seed: row.Seed, CreatedAt: row.CreatedAt, UpdatedAt: row.UpdatedAt,
+	}
+	if err := json.Unmarshal(row.Targets, &plan.Targets); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(row.Days, &plan.Days); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreatePlan saves the plan and fills its id and timestamps.
+func (s *Store) CreatePlan(plan *models.MealPlan) error {
+	const op = "mealplans.store.CreatePlan"
+
+	targets, err := json.Marshal(plan.Targets)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	days, err := json.Marshal(plan.Days)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.QueryRow(
+		"INSERT INTO meal_plans(user_id, seed, targets, days) VALUES($1, $2, $3, $4) "+
+			"RETURNING id, created_at, updated_at",
+		plan.UserID, plan.Seed, targets, days,
+	).Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetPlan(userID int, id int) (*models.MealPlan, error) {
+	const op = "mealplans.store.GetPlan"
+
+	var row planRow
+	err := s.db.Get(&row, "SELECT "+planColumns+" FROM meal_plans WHERE user_id = $1 AND id = $2", userID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, MealPlanNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	plan, err := row.decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return plan, nil
+}
+
+// GetPlans returns every meal plan of the user, newest first.
+func (s *Store) GetPlans(userID int) ([]models.MealPlan, error) {
+	const op = "mealplans.store.GetPlans"
+
+	var rows []planRow
+	err := s.db.Select(
+		&rows, "SELECT "+planColumns+" FROM meal_plans WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	list := make([]models.MealPlan, 0, len(rows))
+	for _, row := range rows {
+		plan, err := row.decode()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		list = append(list, *plan)
+	}
+	return list, nil
+}
+
+func (s *Store) UpdatePlanDays(plan *models.MealPlan) error {
+	const op = "mealplans.store.UpdatePlanDays"
+
+	days, err := json.Marshal(plan.Days)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.QueryRow(
+		"UPDATE meal_plans SET days = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2 AND id = $3 "+
+			"RETURNING updated_at",
+		days, plan.UserID, plan.ID,
+	).Scan(&plan.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MealPlanNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type MetricStore interface {
+	SaveMetrics(userID int, source string, entries []models.MetricEntryPayload) ([]models.DailyMetric, error)
+	GetMetrics(userID int, from string, to string) ([]models.DailyMetric, error)
+	GetHistory(userID int) ([]models.DailyMetric, error)
+	GetGoals(userID int) ([]models.MetricGoal, error)
+	SaveGoals(userID int, goals map[string]float64) error
+	DeleteGoal(userID int, metric string) error
+}
+
+var (
+	GoalNotFound = errors.New("metric goal not found")
+)
+
+const metricColumns = "user_id, to_char(day, 'YYYY-MM-DD') AS day, metric, value, source, updated_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// SaveMetrics upserts the entries in one transaction and returns the rows whose value changed,
+// so replaying the same batch is a no-op.
+func (s *Store) SaveMetrics(userID int, source string, entries []models.MetricEntryPayload) (
+	[]models.DailyMetric, error,
+) {
+	const op = "metrics.store.SaveMetrics"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Preparex(
+		"INSERT INTO daily_metrics(user_id, day, metric, value, source) VALUES($1, $2, $3, $4, $5) " +
+			"ON CONFLICT (user_id, metric, day) DO UPDATE SET value = EXCLUDED.value, source = EXCLUDED.source, " +
+			"updated_at = CURRENT_TIMESTAMP WHERE daily_metrics.value IS DISTINCT FROM EXCLUDED.value " +
+			"RETURNING " + metricColumns,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	changed := []models.DailyMetric{}
+	for _, e := range entries {
+		rows, err := stmt.Queryx(userID, e.Date, e.Metric, e.Value, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for rows.Next() {
+			var m models.DailyMetric
+			if err := rows.StructScan(&m); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			changed = append(changed, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return changed, nil
+}
+
+// GetMetrics returns the metrics of the days in [from, to], both YYYY-MM-DD.
+func (s *Store) GetMetrics(userID int, from string, to string) ([]models.DailyMetric, error) {
+	const op = "metrics.store.GetMetrics"
+
+	list := []models.DailyMetric{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+metricColumns+" FROM daily_metrics WHERE user_id = $1 AND day BETWEEN $2 AND $3 "+
+			"ORDER BY day, metric",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetHistory(userID int) ([]models.DailyMetric, error) {
+	const op = "metrics.store.GetHistory"
+
+	list := []models.DailyMetric{}
+	err := s.db.Select(
+		&list, "SELECT "+metricColumns+" FROM daily_metrics WHERE user_id = $1 ORDER BY day, metric", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetGoals(userID int) ([]models.MetricGoal, error) {
+	const op = "metrics.store.GetGoals"
+
+	list := []models.MetricGoal{}
+	err := s.db.Select(&list, "SELECT metric, target FROM metric_goals WHERE user_id = $1 ORDER BY metric", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) SaveGoals(userID int, goals map[string]float64) error {
+	const op = "metrics.store.SaveGoals"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for metric, target := range goals {
+		_, err := tx.Exec(
+			"INSERT INTO metric_goals(user_id, metric, target) VALUES($1, $2, $3) "+
+				"ON CONFLICT (user_id, metric) DO UPDATE SET target = EXCLUDED.target, updated_at = CURRENT_TIMESTAMP",
+			userID, metric, target,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteGoal(userID int, metric string) error {
+	const op = "metrics.store.DeleteGoal"
+
+	res, err := s.db.Exec("DELETE FROM metric_goals WHERE user_id = $1 AND metric = $2", userID, metric)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return GoalNotFound
+	}
+	return nil
+}
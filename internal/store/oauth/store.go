@@ -0,0 +1,335 @@
+package oauth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type OAuthStore interface {
+	CreateClient(c *models.OAuthClient) error
+	GetClient(clientID string) (*models.OAuthClient, error)
+	GetClients(appID int) ([]models.OAuthClient, error)
+	DeleteClient(appID int, clientID string) error
+	GetConsent(userID int, appID int) ([]string, error)
+	SaveConsent(userID int, appID int, scopes []string) error
+	CreateCode(c models.OAuthCode) error
+	UseCode(codeHash string) (*models.OAuthCode, error)
+	CreateToken(t *models.OAuthToken) error
+	RotateToken(oldID int64, next *models.OAuthToken) error
+	GetToken(id int64) (*models.OAuthToken, error)
+	GetTokenByRefresh(refreshHash string) (*models.OAuthToken, error)
+	IsActive(id int64) (bool, error)
+	RevokeToken(id int64) error
+}
+
+var (
+	AppNotFound    = errors.New("partner app not found")
+	ClientNotFound = errors.New("oauth client not found")
+	CodeNotFound   = errors.New("authorization code not found")
+	CodeUsed       = errors.New("authorization code already used")
+	TokenNotFound  = errors.New("token not found")
+)
+
+const (
+	clientColumns = "c.id, c.app_id, a.name AS app_name, c.client_id, c.secret_hash, c.redirect_uris, " +
+		"c.secret_hash IS NOT NULL AS confidential, c.created_at"
+	codeColumns  = "code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, used_at"
+	tokenColumns = "id, client_id, user_id, scopes, refresh_hash, code_hash, created_at, expires_at, revoked_at"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateClient(c *models.OAuthClient) error {
+	const op = "oauth.store.CreateClient"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO oauth_clients(app_id, client_id, secret_hash, redirect_uris) VALUES($1, $2, $3, $4) "+
+			"RETURNING id, created_at, (SELECT name FROM partner_apps WHERE id = $1)",
+		c.AppID, c.ClientID, c.SecretHash, c.RedirectURIs,
+	).Scan(&c.ID, &c.CreatedAt, &c.AppName)
+	c.Confidential = c.SecretHash != nil
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, AppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetClient(clientID string) (*models.OAuthClient, error) {
+	const op = "oauth.store.GetClient"
+
+	var c models.OAuthClient
+	err := s.db.Get(
+		&c,
+		"SELECT "+clientColumns+" FROM oauth_clients c JOIN partner_apps a ON a.id = c.app_id WHERE c.client_id = $1",
+		clientID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, ClientNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+func (s *Store) GetClients(appID int) ([]models.OAuthClient, error) {
+	const op = "oauth.store.GetClients"
+
+	list := []models.OAuthClient{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+clientColumns+" FROM oauth_clients c JOIN partner_apps a ON a.id = c.app_id WHERE c.app_id = $1 "+
+			"ORDER BY c.id",
+		appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// DeleteClient removes the credentials together with the codes and tokens issued to them.
+func (s *Store) DeleteClient(appID int, clientID string) error {
+	const op = "oauth.store.DeleteClient"
+
+	res, err := s.db.Exec("DELETE FROM oauth_clients WHERE app_id = $1 AND client_id = $2", appID, clientID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ClientNotFound)
+	}
+	return nil
+}
+
+// GetConsent returns the scopes the user granted to the app, nil when the app is not connected.
+func (s *Store) GetConsent(userID int, appID int) ([]string, error) {
+	const op = "oauth.store.GetConsent"
+
+	var scopes pq.StringArray
+	err := s.db.Get(
+		&scopes, "SELECT scopes FROM partner_app_users WHERE app_id = $1 AND user_id = $2", appID, userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return scopes, nil
+}
+
+// SaveConsent connects the app to the user, adding the scopes to the ones granted before.
+func (s *Store) SaveConsent(userID int, appID int, scopes []string) error {
+	const op = "oauth.store.SaveConsent"
+
+	_, err := s.db.Exec(
+		"INSERT INTO partner_app_users(app_id, user_id, scopes) VALUES($1, $2, $3) "+
+			"ON CONFLICT (app_id, user_id) DO UPDATE SET scopes = "+
+			"ARRAY(SELECT DISTINCT unnest(partner_app_users.scopes || EXCLUDED.scopes) ORDER BY 1)",
+		appID, userID, pq.StringArray(scopes),
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, AppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) CreateCode(c models.OAuthCode) error {
+	const op = "oauth.store.CreateCode"
+
+	_, err := s.db.NamedExec(
+		"INSERT INTO oauth_codes(code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at) "+
+			"VALUES(:code_hash, :client_id, :user_id, :redirect_uri, :scopes, :code_challenge, :expires_at)",
+		c,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseCode redeems an authorization code, which works once. Presenting a redeemed code again
+// returns CodeUsed and revokes the tokens issued for it, since the code has leaked.
+func (s *Store) UseCode(codeHash string) (*models.OAuthCode, error) {
+	const op = "oauth.store.UseCode"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var c models.OAuthCode
+	err = tx.Get(&c, "SELECT "+codeColumns+" FROM oauth_codes WHERE code_hash = $1 FOR UPDATE", codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, CodeNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if c.UsedAt != nil {
+		_, err := tx.Exec(
+			"UPDATE oauth_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE code_hash = $1 AND revoked_at IS NULL",
+			codeHash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return nil, fmt.Errorf("%s: %w", op, CodeUsed)
+	}
+
+	err = tx.QueryRowx(
+		"UPDATE oauth_codes SET used_at = CURRENT_TIMESTAMP WHERE code_hash = $1 AND expires_at > CURRENT_TIMESTAMP "+
+			"RETURNING used_at",
+		codeHash,
+	).Scan(&c.UsedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, CodeNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+func (s *Store) CreateToken(t *models.OAuthToken) error {
+	const op = "oauth.store.CreateToken"
+
+	if err := createToken(s.db, t); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RotateToken revokes the token and issues next in its place, for refreshing. It returns
+// TokenNotFound when the token was revoked in the meantime.
+func (s *Store) RotateToken(oldID int64, next *models.OAuthToken) error {
+	const op = "oauth.store.RotateToken"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"UPDATE oauth_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", oldID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, TokenNotFound)
+	}
+
+	if err := createToken(tx, next); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func createToken(q sqlx.Queryer, t *models.OAuthToken) error {
+	return q.QueryRowx(
+		"INSERT INTO oauth_tokens(client_id, user_id, scopes, refresh_hash, code_hash, expires_at) "+
+			"VALUES($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		t.ClientID, t.UserID, t.Scopes, t.RefreshHash, t.CodeHash, t.ExpiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+}
+
+func (s *Store) GetToken(id int64) (*models.OAuthToken, error) {
+	const op = "oauth.store.GetToken"
+
+	var t models.OAuthToken
+	err := s.db.Get(&t, "SELECT "+tokenColumns+" FROM oauth_tokens WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, TokenNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &t, nil
+}
+
+func (s *Store) GetTokenByRefresh(refreshHash string) (*models.OAuthToken, error) {
+	const op = "oauth.store.GetTokenByRefresh"
+
+	var t models.OAuthToken
+	err := s.db.Get(&t, "SELECT "+tokenColumns+" FROM oauth_tokens WHERE refresh_hash = $1", refreshHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, TokenNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &t, nil
+}
+
+// IsActive reports whether the token is neither revoked nor issued to an app the user has
+// disconnected since. The expiry of the refresh token is not checked, access tokens expire on
+// their own.
+func (s *Store) IsActive(id int64) (bool, error) {
+	const op = "oauth.store.IsActive"
+
+	var active bool
+	err := s.db.Get(
+		&active,
+		"SELECT EXISTS(SELECT 1 FROM oauth_tokens t JOIN oauth_clients c ON c.id = t.client_id "+
+			"JOIN partner_app_users u ON u.app_id = c.app_id AND u.user_id = t.user_id "+
+			"WHERE t.id = $1 AND t.revoked_at IS NULL)",
+		id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return active, nil
+}
+
+// RevokeToken revokes the token. Revoking twice is a no-op.
+func (s *Store) RevokeToken(id int64) error {
+	const op = "oauth.store.RevokeToken"
+
+	_, err := s.db.Exec(
+		"UPDATE oauth_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests run against the database of TEST_DATABASE_URL, which they migrate, and are skipped
+// without one. They remove the user and the app they create.
+func testStore(t *testing.T) (*Store, *models.OAuthClient, int) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := fmt.Sprintf("oauth-test-%d", time.Now().UnixNano())
+	var userID, appID int
+	err = db.Get(
+		&userID,
+		"INSERT INTO users(email, username, password, activation_code) VALUES($1 || '@example.com', $1, '\\x00', '') "+
+			"RETURNING id",
+		prefix,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get(&appID, "INSERT INTO partner_apps(name) VALUES($1) RETURNING id", prefix); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(
+		func() {
+			db.Exec("DELETE FROM partner_apps WHERE id = $1", appID)
+			db.Exec("DELETE FROM users WHERE id = $1", userID)
+		},
+	)
+
+	s := NewStore(db)
+	client := &models.OAuthClient{AppID: appID, ClientID: prefix, RedirectURIs: []string{"https://example.com/cb"}}
+	if err := s.CreateClient(client); err != nil {
+		t.Fatal(err)
+	}
+	return s, client, userID
+}
+
+func createTestCode(t *testing.T, s *Store, client *models.OAuthClient, userID int, hash string, ttl time.Duration) {
+	t.Helper()
+	err := s.CreateCode(
+		models.OAuthCode{
+			CodeHash: hash, ClientID: client.ID, UserID: userID, RedirectURI: client.RedirectURIs[0],
+			Scopes: []string{models.ScopeProfile}, CodeChallenge: "challenge", ExpiresAt: time.Now().Add(ttl),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUseCodeOnce(t *testing.T) {
+	s, client, userID := testStore(t)
+	hash := client.ClientID + "-code"
+	createTestCode(t, s, client, userID, hash, time.Minute)
+
+	if _, err := s.UseCode(hash); err != nil {
+		t.Fatalf("UseCode() error = %v", err)
+	}
+	token := models.OAuthToken{
+		ClientID: client.ID, UserID: userID, Scopes: []string{models.ScopeProfile}, RefreshHash: hash + "-refresh",
+		CodeHash: &hash, ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := s.CreateToken(&token); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UseCode(hash); !errors.Is(err, CodeUsed) {
+		t.Fatalf("UseCode() reused error = %v, want CodeUsed", err)
+	}
+	got, err := s.GetToken(token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RevokedAt == nil {
+		t.Error("token issued for the reused code was not revoked")
+	}
+}
+
+func TestUseCodeExpired(t *testing.T) {
+	s, client, userID := testStore(t)
+	hash := client.ClientID + "-code"
+	createTestCode(t, s, client, userID, hash, -time.Second)
+
+	if _, err := s.UseCode(hash); !errors.Is(err, CodeNotFound) {
+		t.Errorf("UseCode() expired error = %v, want CodeNotFound", err)
+	}
+	if _, err := s.UseCode(client.ClientID + "-unknown"); !errors.Is(err, CodeNotFound) {
+		t.Errorf("UseCode() unknown error = %v, want CodeNotFound", err)
+	}
+}
@@ -0,0 +1,58 @@
+package preferences
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type PreferencesStore interface {
+	GetPreferences(userID int) (*models.Preferences, error)
+	SavePreferences(userID int, payload models.UpdatePreferencesPayload) (*models.Preferences, error)
+}
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetPreferences returns the defaults, no diet and no allergens, for users who never saved any.
+func (s *Store) GetPreferences(userID int) (*models.Preferences, error) {
+	const op = "preferences.store.GetPreferences"
+
+	var p models.Preferences
+	err := s.db.Get(
+		&p, "SELECT user_id, diet, allergens, updated_at FROM user_preferences WHERE user_id = $1", userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.Preferences{UserID: userID, Diet: models.DietNone, Allergens: pq.StringArray{}}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &p, nil
+}
+
+func (s *Store) SavePreferences(userID int, payload models.UpdatePreferencesPayload) (*models.Preferences, error) {
+	const op = "preferences.store.SavePreferences"
+
+	allergens := append([]string{}, payload.Allergens...)
+
+	var p models.Preferences
+	err := s.db.QueryRowx(
+		"INSERT INTO user_preferences(user_id, diet, allergens) VALUES($1, $2, $3) "+
+			"ON CONFLICT (user_id) DO UPDATE SET diet = EXCLUDED.diet, allergens = EXCLUDED.allergens, "+
+			"updated_at = CURRENT_TIMESTAMP RETURNING user_id, diet, allergens, updated_at",
+		userID, payload.Diet, pq.Array(allergens),
+	).StructScan(&p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,235 @@
+package recipes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type RecipeStore interface {
+	CreateRecipe(ownerID int, payload models.CreateRecipePayload) (*models.Recipe, error)
+	UpdateRecipe(ownerID int, id int, payload models.CreateRecipePayload) (*models.Recipe, error)
+	DeleteRecipe(ownerID int, id int) error
+	GetRecipe(ownerID int, id int) (*models.Recipe, error)
+	GetRecipes(ownerID int) ([]models.Recipe, error)
+	GetSharedRecipe(token string) (*models.Recipe, error)
+	SetShareToken(ownerID int, id int, token *string) error
+}
+
+var (
+	RecipeNotFound = errors.New("recipe not found")
+)
+
+const recipeColumns = "id, owner_id, name, servings, cooked_weight, share_token, created_at, updated_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateRecipe(ownerID int, p models.CreateRecipePayload) (*models.Recipe, error) {
+	const op = "recipes.store.CreateRecipe"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var r models.Recipe
+	err = tx.QueryRowx(
+		"INSERT INTO recipes(owner_id, name, servings, cooked_weight) VALUES($1, $2, $3, $4) RETURNING "+
+			recipeColumns,
+		ownerID, p.Name, max(p.Servings, 1), p.CookedWeight,
+	).StructScan(&r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if r.Ingredients, err = insertIngredients(tx, r.ID, p.Ingredients); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &r, nil
+}
+
+// UpdateRecipe replaces the recipe details and its whole ingredient list.
+func (s *Store) UpdateRecipe(ownerID int, id int, p models.CreateRecipePayload) (*models.Recipe, error) {
+	const op = "recipes.store.UpdateRecipe"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var r models.Recipe
+	err = tx.QueryRowx(
+		"UPDATE recipes SET name = $1, servings = $2, cooked_weight = $3, updated_at = CURRENT_TIMESTAMP "+
+			"WHERE id = $4 AND owner_id = $5 RETURNING "+recipeColumns,
+		p.Name, max(p.Servings, 1), p.CookedWeight, id, ownerID,
+	).StructScan(&r)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, RecipeNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM recipe_ingredients WHERE recipe_id = $1", r.ID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if r.Ingredients, err = insertIngredients(tx, r.ID, p.Ingredients); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &r, nil
+}
+
+func (s *Store) DeleteRecipe(ownerID int, id int) error {
+	const op = "recipes.store.DeleteRecipe"
+
+	res, err := s.db.Exec("DELETE FROM recipes WHERE id = $1 AND owner_id = $2", id, ownerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return RecipeNotFound
+	}
+	return nil
+}
+
+func insertIngredients(tx *sqlx.Tx, recipeID int, payload []models.RecipeIngredientPayload) (
+	[]models.RecipeIngredient, error,
+) {
+	ingredients := make([]models.RecipeIngredient, 0, len(payload))
+	for i, ing := range payload {
+		_, err := tx.Exec(
+			"INSERT INTO recipe_ingredients(recipe_id, position, food_id, grams) VALUES($1, $2, $3, $4)",
+			recipeID, i, ing.FoodID, ing.Grams,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ingredients = append(
+			ingredients, models.RecipeIngredient{RecipeID: recipeID, Position: i, FoodID: ing.FoodID, Grams: ing.Grams},
+		)
+	}
+	return ingredients, nil
+}
+
+func (s *Store) GetRecipe(ownerID int, id int) (*models.Recipe, error) {
+	const op = "recipes.store.GetRecipe"
+
+	var r models.Recipe
+	err := s.db.Get(&r, "SELECT "+recipeColumns+" FROM recipes WHERE id = $1 AND owner_id = $2", id, ownerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, RecipeNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	list := []models.Recipe{r}
+	if err := s.loadIngredients(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &list[0], nil
+}
+
+func (s *Store) GetRecipes(ownerID int) ([]models.Recipe, error) {
+	const op = "recipes.store.GetRecipes"
+
+	list := []models.Recipe{}
+	err := s.db.Select(&list, "SELECT "+recipeColumns+" FROM recipes WHERE owner_id = $1 ORDER BY name", ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadIngredients(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetSharedRecipe(token string) (*models.Recipe, error) {
+	const op = "recipes.store.GetSharedRecipe"
+
+	var r models.Recipe
+	err := s.db.Get(&r, "SELECT "+recipeColumns+" FROM recipes WHERE share_token = $1", token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, RecipeNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	list := []models.Recipe{r}
+	if err := s.loadIngredients(list); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &list[0], nil
+}
+
+// SetShareToken enables link sharing with the given token, or disables it when token is nil.
+func (s *Store) SetShareToken(ownerID int, id int, token *string) error {
+	const op = "recipes.store.SetShareToken"
+
+	res, err := s.db.Exec("UPDATE recipes SET share_token = $1 WHERE id = $2 AND owner_id = $3", token, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return RecipeNotFound
+	}
+	return nil
+}
+
+func (s *Store) loadIngredients(list []models.Recipe) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(list))
+	index := make(map[int]int, len(list))
+	for i, r := range list {
+		ids[i] = int64(r.ID)
+		index[r.ID] = i
+		list[i].Ingredients = []models.RecipeIngredient{}
+	}
+
+	var ingredients []models.RecipeIngredient
+	err := s.db.Select(
+		&ingredients,
+		"SELECT recipe_id, position, food_id, grams FROM recipe_ingredients WHERE recipe_id = ANY($1) "+
+			"ORDER BY recipe_id, position",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	for _, ing := range ingredients {
+		i := index[ing.RecipeID]
+		list[i].Ingredients = append(list[i].Ingredients, ing)
+	}
+	return nil
+}
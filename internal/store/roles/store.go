@@ -0,0 +1,265 @@
+package roles
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type RoleStore interface {
+	GetPermissions() ([]models.Permission, error)
+	GetRoles() ([]models.Role, error)
+	GetRole(name string) (*models.Role, error)
+	CreateRole(role *models.Role) error
+	UpdateRole(role *models.Role) error
+	DeleteRole(name string) error
+
+	GetUserRoles(userID int) ([]string, error)
+	GetUserPermissions(userID int) ([]string, error)
+	GrantRole(userID int, role string) error
+	RevokeRole(userID int, role string) error
+}
+
+var (
+	RoleNotFound       = errors.New("role not found")
+	RoleExists         = errors.New("role already exists")
+	RoleBuiltin        = errors.New("built-in roles cannot be changed")
+	PermissionNotFound = errors.New("permission not found")
+	LastAdmin          = errors.New("cannot revoke the role of the last admin")
+)
+
+const roleQuery = "SELECT r.name, r.description, r.builtin, r.created_at, " +
+	"COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}') " +
+	"AS permissions FROM roles r LEFT JOIN role_permissions rp ON rp.role = r.name "
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetPermissions() ([]models.Permission, error) {
+	const op = "roles.store.GetPermissions"
+
+	list := []models.Permission{}
+	if err := s.db.Select(&list, "SELECT name, description FROM permissions ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetRoles() ([]models.Role, error) {
+	const op = "roles.store.GetRoles"
+
+	list := []models.Role{}
+	if err := s.db.Select(&list, roleQuery+"GROUP BY r.name ORDER BY r.name"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetRole(name string) (*models.Role, error) {
+	const op = "roles.store.GetRole"
+
+	var role models.Role
+	if err := s.db.Get(&role, roleQuery+"WHERE r.name = $1 GROUP BY r.name", name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, RoleNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &role, nil
+}
+
+func (s *Store) CreateRole(role *models.Role) error {
+	const op = "roles.store.CreateRole"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"INSERT INTO roles(name, description) VALUES($1, $2) RETURNING created_at", role.Name, role.Description,
+	).Scan(&role.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return RoleExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := setPermissions(tx, role.Name, role.Permissions); err != nil {
+		if errors.Is(err, PermissionNotFound) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpdateRole replaces the description and the permissions of a role that is not built in.
+func (s *Store) UpdateRole(role *models.Role) error {
+	const op = "roles.store.UpdateRole"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"UPDATE roles SET description = $2 WHERE name = $1 RETURNING builtin, created_at", role.Name, role.Description,
+	).Scan(&role.Builtin, &role.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RoleNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role.Builtin {
+		return RoleBuiltin
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = $1", role.Name); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := setPermissions(tx, role.Name, role.Permissions); err != nil {
+		if errors.Is(err, PermissionNotFound) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteRole deletes a role that is not built in, taking it away from its users.
+func (s *Store) DeleteRole(name string) error {
+	const op = "roles.store.DeleteRole"
+
+	res, err := s.db.Exec("DELETE FROM roles WHERE name = $1 AND NOT builtin", name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := s.GetRole(name); err != nil {
+		return err
+	}
+	return RoleBuiltin
+}
+
+func (s *Store) GetUserRoles(userID int) ([]string, error) {
+	const op = "roles.store.GetUserRoles"
+
+	list := []string{}
+	if err := s.db.Select(&list, "SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role", userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetUserPermissions returns the permissions of all the roles of the user.
+func (s *Store) GetUserPermissions(userID int) ([]string, error) {
+	const op = "roles.store.GetUserPermissions"
+
+	list := []string{}
+	err := s.db.Select(
+		&list,
+		"SELECT DISTINCT rp.permission FROM user_roles ur JOIN role_permissions rp ON rp.role = ur.role "+
+			"WHERE ur.user_id = $1 ORDER BY rp.permission",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GrantRole is idempotent: granting a role the user has changes nothing.
+func (s *Store) GrantRole(userID int, role string) error {
+	const op = "roles.store.GrantRole"
+
+	_, err := s.db.Exec(
+		"INSERT INTO user_roles(user_id, role) VALUES($1, $2) ON CONFLICT DO NOTHING", userID, role,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return RoleNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeRole takes a role away from the user. The admin role always keeps at least one user.
+func (s *Store) RevokeRole(userID int, role string) error {
+	const op = "roles.store.RevokeRole"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if role == models.RoleAdmin {
+		// Locking the admins keeps two revocations from removing the last two admins together.
+		var admins []int
+		err := tx.Select(&admins, "SELECT user_id FROM user_roles WHERE role = $1 FOR UPDATE", models.RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(admins) == 1 && admins[0] == userID {
+			return LastAdmin
+		}
+	}
+
+	res, err := tx.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return RoleNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func setPermissions(tx *sqlx.Tx, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(
+		"INSERT INTO role_permissions(role, permission) SELECT $1, unnest($2::TEXT[])", role, pq.Array(permissions),
+	)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+		return PermissionNotFound
+	}
+	return err
+}
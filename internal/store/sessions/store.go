@@ -0,0 +1,153 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type SessionStore interface {
+	CreateSession(s *models.Session) error
+	GetSessions(userID int) ([]models.Session, error)
+	GetSessionHistory(userID int) ([]models.Session, error)
+	RevokeSession(userID int, id int64) (*models.RevokedSession, error)
+	RevokeOtherSessions(userID int, keepID int64) ([]models.RevokedSession, error)
+	GetRevoked(since time.Time) ([]models.RevokedSession, error)
+	TouchSessions(seen map[int64]time.Time) error
+}
+
+var SessionNotFound = errors.New("session not found")
+
+const sessionColumns = "id, user_id, device_name, user_agent, ip, created_at, last_active_at, expires_at, revoked_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateSession(session *models.Session) error {
+	const op = "sessions.store.CreateSession"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO sessions(user_id, device_name, user_agent, ip, expires_at) VALUES($1, $2, $3, $4, $5) "+
+			"RETURNING id, created_at, last_active_at",
+		session.UserID, session.DeviceName, session.UserAgent, session.IP, session.ExpiresAt,
+	).Scan(&session.ID, &session.CreatedAt, &session.LastActiveAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetSessions returns the sessions of the user that are neither revoked nor expired, most
+// recently active first.
+func (s *Store) GetSessions(userID int) ([]models.Session, error) {
+	const op = "sessions.store.GetSessions"
+
+	list := []models.Session{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND revoked_at IS NULL "+
+			"AND expires_at > CURRENT_TIMESTAMP ORDER BY last_active_at DESC, id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetSessionHistory returns every session of the user, the revoked and expired ones included,
+// oldest first.
+func (s *Store) GetSessionHistory(userID int) ([]models.Session, error) {
+	const op = "sessions.store.GetSessionHistory"
+
+	list := []models.Session{}
+	err := s.db.Select(&list, "SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) RevokeSession(userID int, id int64) (*models.RevokedSession, error) {
+	const op = "sessions.store.RevokeSession"
+
+	list := []models.RevokedSession{}
+	err := s.db.Select(
+		&list,
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 "+
+			"AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP RETURNING id, revoked_at, expires_at",
+		id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, SessionNotFound)
+	}
+	return &list[0], nil
+}
+
+// RevokeOtherSessions revokes every active session of the user but keepID.
+func (s *Store) RevokeOtherSessions(userID int, keepID int64) ([]models.RevokedSession, error) {
+	const op = "sessions.store.RevokeOtherSessions"
+
+	list := []models.RevokedSession{}
+	err := s.db.Select(
+		&list,
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND id <> $2 "+
+			"AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP RETURNING id, revoked_at, expires_at",
+		userID, keepID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetRevoked returns the sessions revoked since the time whose tokens have not expired yet.
+func (s *Store) GetRevoked(since time.Time) ([]models.RevokedSession, error) {
+	const op = "sessions.store.GetRevoked"
+
+	list := []models.RevokedSession{}
+	err := s.db.Select(
+		&list,
+		"SELECT id, revoked_at, expires_at FROM sessions WHERE revoked_at >= $1 AND expires_at > CURRENT_TIMESTAMP",
+		since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// TouchSessions moves last_active_at of the sessions forward to the times the revocation cache
+// saw them. Each instance flushes its own batch, so an older time than the stored one, seen by
+// another instance, is ignored.
+func (s *Store) TouchSessions(seen map[int64]time.Time) error {
+	const op = "sessions.store.TouchSessions"
+
+	ids := make([]int64, 0, len(seen))
+	times := make([]string, 0, len(seen))
+	for id, t := range seen {
+		ids = append(ids, id)
+		times = append(times, t.Format(time.RFC3339Nano))
+	}
+
+	_, err := s.db.Exec(
+		"UPDATE sessions SET last_active_at = v.t FROM unnest($1::bigint[], $2::timestamptz[]) AS v(id, t) "+
+			"WHERE sessions.id = v.id AND sessions.last_active_at < v.t",
+		pq.Array(ids), pq.StringArray(times),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
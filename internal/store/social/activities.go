@@ -0,0 +1,366 @@
+package social
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+// activityColumns expects the activity as a, its author as u and the reading user as $1.
+const activityColumns = "a.id, a.user_id, u.username, a.kind, a.ref, a.visibility, a.payload, a.occurred_at, " +
+	"a.kudos_count, a.comments_count, EXISTS (SELECT 1 FROM kudos k WHERE k.activity_id = a.id AND k.user_id = $1) " +
+	"AS kudoed, a.created_at"
+
+// SaveActivity creates the activity and fans it out to the feeds of the author and the followers.
+// Saving an activity with the same kind and ref again only appends the records of a record activity,
+// which groups the records set in one workout.
+func (s *Store) SaveActivity(activity *models.Activity) error {
+	const op = "social.store.SaveActivity"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var inserted bool
+	err = tx.QueryRowx(
+		"INSERT INTO activities(user_id, kind, ref, visibility, payload, occurred_at) VALUES($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (user_id, kind, ref) DO UPDATE SET payload = CASE WHEN activities.kind = 'record' "+
+			"THEN jsonb_set(activities.payload, '{records}', "+
+			"(activities.payload -> 'records') || (EXCLUDED.payload -> 'records')) ELSE activities.payload END "+
+			"RETURNING id, visibility, created_at, xmax = 0",
+		activity.UserID, activity.Kind, activity.Ref, activity.Visibility, []byte(activity.Payload), activity.OccurredAt,
+	).Scan(&activity.ID, &activity.Visibility, &activity.CreatedAt, &inserted)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if inserted {
+		if err := fanOut(tx, activity.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpdateActivity replaces the payload and the time of the activity of the same user, kind and ref,
+// in the feeds too. There is nothing to update when it was never saved.
+func (s *Store) UpdateActivity(activity *models.Activity) error {
+	const op = "social.store.UpdateActivity"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"UPDATE activities SET payload = $1, occurred_at = $2 WHERE user_id = $3 AND kind = $4 AND ref = $5 "+
+			"RETURNING id, visibility, created_at",
+		[]byte(activity.Payload), activity.OccurredAt, activity.UserID, activity.Kind, activity.Ref,
+	).Scan(&activity.ID, &activity.Visibility, &activity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ActivityNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec("UPDATE feed_items SET occurred_at = $1 WHERE activity_id = $2", activity.OccurredAt, activity.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteActivities deletes the activities of userID with one of the kinds and the ref, with their
+// feed items, kudos and comments.
+func (s *Store) DeleteActivities(userID int, ref string, kinds []string) error {
+	const op = "social.store.DeleteActivities"
+
+	_, err := s.db.Exec(
+		"DELETE FROM activities WHERE user_id = $1 AND ref = $2 AND kind = ANY($3)", userID, ref, pq.Array(kinds),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetActivity returns the activity whatever its visibility, the caller decides who may see it.
+func (s *Store) GetActivity(viewerID int, id int64) (*models.Activity, error) {
+	const op = "social.store.GetActivity"
+
+	var a models.Activity
+	err := s.db.Get(
+		&a, "SELECT "+activityColumns+" FROM activities a JOIN users u ON u.id = a.user_id WHERE a.id = $2",
+		viewerID, id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ActivityNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &a, nil
+}
+
+// SetVisibility changes who sees an activity of userID and updates the followers' feeds accordingly.
+func (s *Store) SetVisibility(userID int, id int64, visibility string) error {
+	const op = "social.store.SetVisibility"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE activities SET visibility = $1 WHERE id = $2 AND user_id = $3", visibility, id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return ActivityNotFound
+	}
+
+	if visibility == models.VisibilityPrivate {
+		_, err = tx.Exec("DELETE FROM feed_items WHERE activity_id = $1 AND owner_id <> actor_id", id)
+	} else {
+		err = fanOut(tx, id)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetFeed returns a page of the user's feed, newest first. Reading is a range scan of the
+// user's feed items whatever the number of follows.
+func (s *Store) GetFeed(userID int, after *cursor.Cursor, limit int) ([]models.Activity, error) {
+	const op = "social.store.GetFeed"
+
+	query := "SELECT " + activityColumns + " FROM feed_items f JOIN activities a ON a.id = f.activity_id " +
+		"JOIN users u ON u.id = a.user_id WHERE f.owner_id = $1 "
+	args := []any{userID}
+	if after != nil {
+		query += "AND (f.occurred_at, f.activity_id) < ($3, $4) "
+		args = append(args, limit, after.At, after.ID)
+	} else {
+		args = append(args, limit)
+	}
+	query += "ORDER BY f.occurred_at DESC, f.activity_id DESC LIMIT $2"
+
+	list := []models.Activity{}
+	if err := s.db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetUserActivities returns a page of the activities of userID having one of the visibilities, newest first.
+func (s *Store) GetUserActivities(
+	viewerID int, userID int, visibilities []string, after *cursor.Cursor, limit int,
+) ([]models.Activity, error) {
+	const op = "social.store.GetUserActivities"
+
+	query := "SELECT " + activityColumns + " FROM activities a JOIN users u ON u.id = a.user_id " +
+		"WHERE a.user_id = $2 AND a.visibility = ANY($3) "
+	args := []any{viewerID, userID, pq.Array(visibilities), limit}
+	if after != nil {
+		query += "AND (a.occurred_at, a.id) < ($5, $6) "
+		args = append(args, after.At, after.ID)
+	}
+	query += "ORDER BY a.occurred_at DESC, a.id DESC LIMIT $4"
+
+	list := []models.Activity{}
+	if err := s.db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// AddKudos is idempotent: giving kudos twice counts once.
+func (s *Store) AddKudos(activityID int64, userID int) error {
+	const op = "social.store.AddKudos"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO kudos(activity_id, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING", activityID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := adjustCount(tx, res, "kudos_count", activityID, 1); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveKudos(activityID int64, userID int) error {
+	const op = "social.store.RemoveKudos"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM kudos WHERE activity_id = $1 AND user_id = $2", activityID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := adjustCount(tx, res, "kudos_count", activityID, -1); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) CreateComment(comment *models.Comment) error {
+	const op = "social.store.CreateComment"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"INSERT INTO comments(activity_id, user_id, body) VALUES($1, $2, $3) RETURNING id, created_at",
+		comment.ActivityID, comment.UserID, comment.Body,
+	).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec("UPDATE activities SET comments_count = comments_count + 1 WHERE id = $1", comment.ActivityID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetComments returns the comments of the activity in the order they were written, starting after afterID.
+func (s *Store) GetComments(activityID int64, afterID int64, limit int) ([]models.Comment, error) {
+	const op = "social.store.GetComments"
+
+	list := []models.Comment{}
+	err := s.db.Select(
+		&list,
+		"SELECT c.id, c.activity_id, c.user_id, u.username, c.body, c.created_at FROM comments c "+
+			"JOIN users u ON u.id = c.user_id WHERE c.activity_id = $1 AND c.id > $2 ORDER BY c.id LIMIT $3",
+		activityID, afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetUserComments returns every comment written by the user, oldest first.
+func (s *Store) GetUserComments(userID int) ([]models.Comment, error) {
+	const op = "social.store.GetUserComments"
+
+	list := []models.Comment{}
+	err := s.db.Select(
+		&list,
+		"SELECT c.id, c.activity_id, c.user_id, u.username, c.body, c.created_at FROM comments c "+
+			"JOIN users u ON u.id = c.user_id WHERE c.user_id = $1 ORDER BY c.id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// DeleteComment deletes a comment written by userID or made on an activity of userID.
+func (s *Store) DeleteComment(id int64, userID int) error {
+	const op = "social.store.DeleteComment"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var activityID int64
+	err = tx.Get(
+		&activityID,
+		"DELETE FROM comments c USING activities a WHERE c.id = $1 AND a.id = c.activity_id "+
+			"AND (c.user_id = $2 OR a.user_id = $2) RETURNING c.activity_id",
+		id, userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CommentNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec("UPDATE activities SET comments_count = comments_count - 1 WHERE id = $1", activityID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// fanOut delivers the activity to its author's feed and, unless private, to the accepted followers' feeds.
+func fanOut(tx *sqlx.Tx, activityID int64) error {
+	_, err := tx.Exec(
+		"INSERT INTO feed_items(owner_id, activity_id, actor_id, occurred_at) "+
+			"SELECT a.user_id, a.id, a.user_id, a.occurred_at FROM activities a WHERE a.id = $1 "+
+			"UNION ALL SELECT f.follower_id, a.id, a.user_id, a.occurred_at FROM activities a "+
+			"JOIN follows f ON f.followee_id = a.user_id AND f.status = 'accepted' "+
+			"WHERE a.id = $1 AND a.visibility <> 'private' ON CONFLICT DO NOTHING",
+		activityID,
+	)
+	return err
+}
+
+// adjustCount moves a denormalised counter of the activity by delta when res changed a row.
+func adjustCount(tx *sqlx.Tx, res sql.Result, column string, activityID int64, delta int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return err
+	}
+	_, err = tx.Exec("UPDATE activities SET "+column+" = "+column+" + $1 WHERE id = $2", delta, activityID)
+	return err
+}
@@ -0,0 +1,172 @@
+package social
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The benchmarks run against the database of TEST_DATABASE_URL, which they migrate, and are
+// skipped without one. They remove the users they create.
+const (
+	benchFollows            = 5000
+	benchActivitiesPerActor = 4
+	benchPageSize           = 20
+)
+
+func benchStore(b *testing.B) (*Store, *sqlx.DB) {
+	b.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		b.Fatal(err)
+	}
+	return NewStore(db), db
+}
+
+// createUsers inserts n users whose emails start with prefix and deletes them, with everything
+// they own, when the benchmark ends.
+func createUsers(b *testing.B, db *sqlx.DB, prefix string, n int) []int64 {
+	b.Helper()
+
+	var ids []int64
+	err := db.Select(
+		&ids,
+		"INSERT INTO users(email, username, password, activation_code) "+
+			"SELECT $1 || g || '@example.com', $1 || g, '\\x00', '' FROM generate_series(1, $2) g RETURNING id",
+		prefix, n,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(
+		func() {
+			if _, err := db.Exec("DELETE FROM users WHERE email LIKE $1 || '%'", prefix); err != nil {
+				b.Error(err)
+			}
+		},
+	)
+	return ids
+}
+
+func benchPrefix(name string) string {
+	return fmt.Sprintf("bench-%s-%d-", name, time.Now().UnixNano())
+}
+
+// BenchmarkGetFeed reads the feed of a user following benchFollows users, who posted
+// benchActivitiesPerActor activities each.
+func BenchmarkGetFeed(b *testing.B) {
+	store, db := benchStore(b)
+	prefix := benchPrefix("feed")
+
+	reader := createUsers(b, db, prefix+"reader-", 1)[0]
+	followees := createUsers(b, db, prefix+"followee-", benchFollows)
+
+	_, err := db.Exec(
+		"INSERT INTO follows(follower_id, followee_id, status, accepted_at) "+
+			"SELECT $1, unnest($2::int[]), 'accepted', CURRENT_TIMESTAMP",
+		reader, pq.Array(followees),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = db.Exec(
+		"WITH a AS (INSERT INTO activities(user_id, kind, ref, visibility, occurred_at) "+
+			"SELECT u, 'workout', g::text, 'followers', CURRENT_TIMESTAMP - random() * interval '365 days' "+
+			"FROM unnest($2::int[]) u, generate_series(1, $3) g RETURNING id, user_id, occurred_at) "+
+			"INSERT INTO feed_items(owner_id, activity_id, actor_id, occurred_at) "+
+			"SELECT $1, id, user_id, occurred_at FROM a",
+		reader, pq.Array(followees), benchActivitiesPerActor,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := db.Exec("ANALYZE feed_items"); err != nil {
+		b.Fatal(err)
+	}
+
+	// The deep page starts halfway down the feed.
+	var deep cursor.Cursor
+	err = db.QueryRowx(
+		"SELECT occurred_at, activity_id FROM feed_items WHERE owner_id = $1 "+
+			"ORDER BY occurred_at DESC, activity_id DESC OFFSET $2 LIMIT 1",
+		reader, benchFollows*benchActivitiesPerActor/2,
+	).Scan(&deep.At, &deep.ID)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bc := range []struct {
+		name  string
+		after *cursor.Cursor
+	}{
+		{"first page", nil},
+		{"deep page", &deep},
+	} {
+		b.Run(
+			bc.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					list, err := store.GetFeed(int(reader), bc.after, benchPageSize+1)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(list) != benchPageSize+1 {
+						b.Fatalf("got %d activities, want %d", len(list), benchPageSize+1)
+					}
+				}
+			},
+		)
+	}
+}
+
+// BenchmarkFanOut saves activities of a user followed by benchFollows users, each one written to
+// every follower's feed.
+func BenchmarkFanOut(b *testing.B) {
+	store, db := benchStore(b)
+	prefix := benchPrefix("fanout")
+
+	author := createUsers(b, db, prefix+"author-", 1)[0]
+	followers := createUsers(b, db, prefix+"follower-", benchFollows)
+
+	_, err := db.Exec(
+		"INSERT INTO follows(follower_id, followee_id, status, accepted_at) "+
+			"SELECT unnest($1::int[]), $2, 'accepted', CURRENT_TIMESTAMP",
+		pq.Array(followers), author,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := db.Exec("ANALYZE follows"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a := models.Activity{
+			UserID:     int(author),
+			Kind:       models.ActivityWorkout,
+			Ref:        strconv.Itoa(i),
+			Visibility: models.VisibilityFollowers,
+			Payload:    []byte("{}"),
+			OccurredAt: time.Now(),
+		}
+		if err := store.SaveActivity(&a); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package social
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/cursor"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+)
+
+type SocialStore interface {
+	GetSettings(userID int) (*models.SocialSettings, error)
+	SaveSettings(settings models.SocialSettings) error
+
+	GetFollow(followerID int, followeeID int) (*models.Follow, error)
+	CreateFollow(followerID int, followeeID int, status string) error
+	AcceptFollow(followerID int, followeeID int) error
+	AcceptPendingFollows(followeeID int) error
+	DeleteFollow(followerID int, followeeID int) error
+	GetFollowers(userID int, status string) ([]models.Follow, error)
+	GetFollowing(userID int) ([]models.Follow, error)
+
+	IsBlocked(userID int, otherID int) (bool, error)
+	Block(blockerID int, blockedID int) error
+	Unblock(blockerID int, blockedID int) error
+
+	SaveActivity(activity *models.Activity) error
+	UpdateActivity(activity *models.Activity) error
+	DeleteActivities(userID int, ref string, kinds []string) error
+	GetActivity(viewerID int, id int64) (*models.Activity, error)
+	SetVisibility(userID int, id int64, visibility string) error
+	GetFeed(userID int, after *cursor.Cursor, limit int) ([]models.Activity, error)
+	GetUserActivities(
+		viewerID int, userID int, visibilities []string, after *cursor.Cursor, limit int,
+	) ([]models.Activity, error)
+
+	AddKudos(activityID int64, userID int) error
+	RemoveKudos(activityID int64, userID int) error
+	CreateComment(comment *models.Comment) error
+	GetComments(activityID int64, afterID int64, limit int) ([]models.Comment, error)
+	GetUserComments(userID int) ([]models.Comment, error)
+	DeleteComment(id int64, userID int) error
+}
+
+var (
+	FollowNotFound   = errors.New("follow not found")
+	ActivityNotFound = errors.New("activity not found")
+	CommentNotFound  = errors.New("comment not found")
+)
+
+// backfillLimit is how many past activities of a followed user are copied to the follower's feed.
+const backfillLimit = 50
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetSettings returns the defaults for users who never changed their settings.
+func (s *Store) GetSettings(userID int) (*models.SocialSettings, error) {
+	const op = "social.store.GetSettings"
+
+	settings := models.SocialSettings{UserID: userID, DefaultVisibility: models.VisibilityFollowers}
+	err := s.db.Get(
+		&settings, "SELECT user_id, private, default_visibility FROM social_settings WHERE user_id = $1", userID,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &settings, nil
+}
+
+func (s *Store) SaveSettings(settings models.SocialSettings) error {
+	const op = "social.store.SaveSettings"
+
+	_, err := s.db.Exec(
+		"INSERT INTO social_settings(user_id, private, default_visibility) VALUES($1, $2, $3) "+
+			"ON CONFLICT (user_id) DO UPDATE SET private = EXCLUDED.private, "+
+			"default_visibility = EXCLUDED.default_visibility, updated_at = CURRENT_TIMESTAMP",
+		settings.UserID, settings.Private, settings.DefaultVisibility,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetFollow(followerID int, followeeID int) (*models.Follow, error) {
+	const op = "social.store.GetFollow"
+
+	var f models.Follow
+	err := s.db.Get(
+		&f,
+		"SELECT f.follower_id, f.followee_id, u.username, f.status, f.created_at, f.accepted_at FROM follows f "+
+			"JOIN users u ON u.id = f.followee_id WHERE f.follower_id = $1 AND f.followee_id = $2",
+		followerID, followeeID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, FollowNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// CreateFollow adds the relation unless it exists. An accepted follow brings the recent
+// activities of the followee into the follower's feed.
+func (s *Store) CreateFollow(followerID int, followeeID int, status string) error {
+	const op = "social.store.CreateFollow"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO follows(follower_id, followee_id, status, accepted_at) "+
+			"VALUES($1, $2, $3, CASE WHEN $3 = 'accepted' THEN CURRENT_TIMESTAMP END) ON CONFLICT DO NOTHING",
+		followerID, followeeID, status,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if status == models.FollowAccepted {
+		if err := backfill(tx, followerID, followeeID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) AcceptFollow(followerID int, followeeID int) error {
+	const op = "social.store.AcceptFollow"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"UPDATE follows SET status = 'accepted', accepted_at = CURRENT_TIMESTAMP "+
+			"WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'",
+		followerID, followeeID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return FollowNotFound
+	}
+	if err := backfill(tx, followerID, followeeID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// AcceptPendingFollows accepts every request to a profile that stopped being private.
+func (s *Store) AcceptPendingFollows(followeeID int) error {
+	const op = "social.store.AcceptPendingFollows"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var followers []int
+	err = tx.Select(
+		&followers,
+		"UPDATE follows SET status = 'accepted', accepted_at = CURRENT_TIMESTAMP "+
+			"WHERE followee_id = $1 AND status = 'pending' RETURNING follower_id",
+		followeeID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, followerID := range followers {
+		if err := backfill(tx, followerID, followeeID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteFollow removes the relation, pending or not, and the followee's activities from the follower's feed.
+func (s *Store) DeleteFollow(followerID int, followeeID int) error {
+	const op = "social.store.DeleteFollow"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2", followerID, followeeID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return FollowNotFound
+	}
+	if err := unfeed(tx, followerID, followeeID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetFollowers returns the users following userID with the given status, Username being the follower's.
+func (s *Store) GetFollowers(userID int, status string) ([]models.Follow, error) {
+	const op = "social.store.GetFollowers"
+
+	list := []models.Follow{}
+	err := s.db.Select(
+		&list,
+		"SELECT f.follower_id, f.followee_id, u.username, f.status, f.created_at, f.accepted_at FROM follows f "+
+			"JOIN users u ON u.id = f.follower_id WHERE f.followee_id = $1 AND f.status = $2 ORDER BY f.created_at DESC",
+		userID, status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// GetFollowing returns the users userID follows or asked to follow, Username being the followee's.
+func (s *Store) GetFollowing(userID int) ([]models.Follow, error) {
+	const op = "social.store.GetFollowing"
+
+	list := []models.Follow{}
+	err := s.db.Select(
+		&list,
+		"SELECT f.follower_id, f.followee_id, u.username, f.status, f.created_at, f.accepted_at FROM follows f "+
+			"JOIN users u ON u.id = f.followee_id WHERE f.follower_id = $1 ORDER BY f.created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (s *Store) IsBlocked(userID int, otherID int) (bool, error) {
+	const op = "social.store.IsBlocked"
+
+	var blocked bool
+	err := s.db.Get(
+		&blocked,
+		"SELECT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id = $1 AND blocked_id = $2) "+
+			"OR (blocker_id = $2 AND blocked_id = $1))",
+		userID, otherID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return blocked, nil
+}
+
+// Block cuts every tie between the two users: follows in both directions and each other's
+// activities in their feeds.
+func (s *Store) Block(blockerID int, blockedID int) error {
+	const op = "social.store.Block"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO blocks(blocker_id, blocked_id) VALUES($1, $2) ON CONFLICT DO NOTHING", blockerID, blockedID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec(
+		"DELETE FROM follows WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1)",
+		blockerID, blockedID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := unfeed(tx, blockerID, blockedID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := unfeed(tx, blockedID, blockerID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) Unblock(blockerID int, blockedID int) error {
+	const op = "social.store.Unblock"
+
+	_, err := s.db.Exec("DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// backfill copies the recent shared activities of followeeID to the feed of followerID.
+func backfill(tx *sqlx.Tx, followerID int, followeeID int) error {
+	_, err := tx.Exec(
+		"INSERT INTO feed_items(owner_id, activity_id, actor_id, occurred_at) "+
+			"SELECT $1, id, user_id, occurred_at FROM activities WHERE user_id = $2 AND visibility <> 'private' "+
+			"ORDER BY occurred_at DESC LIMIT $3 ON CONFLICT DO NOTHING",
+		followerID, followeeID, backfillLimit,
+	)
+	return err
+}
+
+// unfeed removes the activities of actorID from the feed of ownerID.
+func unfeed(tx *sqlx.Tx, ownerID int, actorID int) error {
+	_, err := tx.Exec("DELETE FROM feed_items WHERE owner_id = $1 AND actor_id = $2", ownerID, actorID)
+	return err
+}
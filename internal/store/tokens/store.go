@@ -0,0 +1,126 @@
+package tokens
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/lib/tokenhash"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type TokenStore interface {
+	CreateToken(t *models.PersonalToken, token string) error
+	GetTokens(userID int) ([]models.PersonalToken, error)
+	DeleteToken(userID int, id int) error
+	GetTokenBySecret(token string) (*models.PersonalToken, error)
+	TouchTokens(used map[int]time.Time) error
+}
+
+var (
+	TokenNotFound = errors.New("token not found")
+	TokenExists   = errors.New("token with this name already exists")
+)
+
+const tokenColumns = "id, user_id, name, hint, scopes, expires_at, last_used_at, created_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateToken stores the token hashed; it cannot be read back.
+func (s *Store) CreateToken(t *models.PersonalToken, token string) error {
+	const op = "tokens.store.CreateToken"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO personal_tokens(user_id, name, token_hash, hint, scopes, expires_at) "+
+			"VALUES($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		t.UserID, t.Name, tokenhash.Sum(token), t.Hint, t.Scopes, t.ExpiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, TokenExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetTokens(userID int) ([]models.PersonalToken, error) {
+	const op = "tokens.store.GetTokens"
+
+	list := []models.PersonalToken{}
+	err := s.db.Select(
+		&list, "SELECT "+tokenColumns+" FROM personal_tokens WHERE user_id = $1 ORDER BY id DESC", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) DeleteToken(userID int, id int) error {
+	const op = "tokens.store.DeleteToken"
+
+	res, err := s.db.Exec("DELETE FROM personal_tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, TokenNotFound)
+	}
+	return nil
+}
+
+// GetTokenBySecret finds the token a request was made with. Expired tokens are not found.
+func (s *Store) GetTokenBySecret(token string) (*models.PersonalToken, error) {
+	const op = "tokens.store.GetTokenBySecret"
+
+	var t models.PersonalToken
+	err := s.db.Get(
+		&t,
+		"SELECT "+tokenColumns+" FROM personal_tokens WHERE token_hash = $1 "+
+			"AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)",
+		tokenhash.Sum(token),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, TokenNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &t, nil
+}
+
+// TouchTokens sets last_used_at of the personal tokens used since the previous flush. A token
+// that was never used has no last_used_at yet, and a token deleted in the meantime is skipped.
+func (s *Store) TouchTokens(used map[int]time.Time) error {
+	const op = "tokens.store.TouchTokens"
+
+	ids := make([]int64, 0, len(used))
+	times := make([]string, 0, len(used))
+	for id, t := range used {
+		ids = append(ids, int64(id))
+		times = append(times, t.Format(time.RFC3339Nano))
+	}
+
+	_, err := s.db.Exec(
+		"UPDATE personal_tokens SET last_used_at = v.t FROM unnest($1::int[], $2::timestamptz[]) AS v(id, t) "+
+			"WHERE personal_tokens.id = v.id AND (last_used_at IS NULL OR last_used_at < v.t)",
+		pq.Array(ids), pq.StringArray(times),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
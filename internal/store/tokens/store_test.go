@@ -0,0 +1,84 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/database"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// The test runs against the database of TEST_DATABASE_URL, which it migrates, and is skipped
+// without one. It removes the user it creates.
+func TestGetTokenBySecret(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := database.Migrate(db, true); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := fmt.Sprintf("tokens-test-%d", time.Now().UnixNano())
+	var userID int
+	err = db.Get(
+		&userID,
+		"INSERT INTO users(email, username, password, activation_code) VALUES($1 || '@example.com', $1, '\\x00', '') "+
+			"RETURNING id",
+		prefix,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", userID) })
+
+	s := NewStore(db)
+	expired := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	create := func(name string, expiresAt *time.Time) *models.PersonalToken {
+		tok := &models.PersonalToken{UserID: userID, Name: name, Hint: "afp_", Scopes: []string{"profile"}}
+		tok.ExpiresAt = expiresAt
+		if err := s.CreateToken(tok, prefix+name); err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	create("never", nil)
+	create("later", &later)
+	create("expired", &expired)
+	revoked := create("revoked", nil)
+	if err := s.DeleteToken(userID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		wantErr error
+	}{
+		{name: "never", wantErr: nil},
+		{name: "later", wantErr: nil},
+		{name: "expired", wantErr: TokenNotFound},
+		{name: "revoked", wantErr: TokenNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := s.GetTokenBySecret(prefix + tt.name)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetTokenBySecret() error = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && got.Name != tt.name {
+					t.Errorf("GetTokenBySecret() = %s, want %s", got.Name, tt.name)
+				}
+			},
+		)
+	}
+}
@@ -14,6 +14,8 @@ type UserStore interface {
 	GetUserByID(id int) (*models.User, error)
 	CreateUser(userData models.RegisterUserPayload, passwordHash []byte) (int, string, error)
 	UpdateUser(id int, userData models.User) error
+	UpdatePassword(id int, passwordHash []byte) error
+	GetUserIDs(afterID int, limit int) ([]int, error)
 }
 
 var (
@@ -21,6 +23,10 @@ var (
 	UserNotFound     = errors.New("users not found")
 )
 
+// userColumns are read in the order of scanRowIntoUser.
+const userColumns = "id, email, username, password, created_at, is_active, is_male, age, height, weight, goal, " +
+	"weight_goal, activation_code"
+
 type Store struct {
 	db *sqlx.DB
 }
@@ -32,7 +38,7 @@ func NewStore(db *sqlx.DB) *Store {
 func (s *Store) GetUserByEmail(email string) (*models.User, error) {
 	const op = "users.store.GetUserByEmail"
 
-	rows, err := s.db.Queryx("SELECT * FROM users WHERE email = $1", email)
+	rows, err := s.db.Queryx("SELECT "+userColumns+" FROM users WHERE email = $1", email)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -50,7 +56,7 @@ func (s *Store) GetUserByEmail(email string) (*models.User, error) {
 func (s *Store) GetUserByID(id int) (*models.User, error) {
 	const op = "users.store.GetUserByID"
 
-	rows, err := s.db.Queryx("SELECT * FROM users WHERE id = $1", id)
+	rows, err := s.db.Queryx("SELECT "+userColumns+" FROM users WHERE id = $1", id)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -62,7 +68,7 @@ func (s *Store) GetUserByID(id int) (*models.User, error) {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 	}
-	if u.ID == 0 {
+	if u == nil {
 		return nil, fmt.Errorf("%s: %w", op, UserNotFound)
 	}
 	return u, nil
@@ -117,6 +123,23 @@ func (s *Store) UpdateUser(id int, userData models.User) error {
 	return nil
 }
 
+func (s *Store) UpdatePassword(id int, passHash []byte) error {
+	const op = "users.store.UpdatePassword"
+
+	res, err := s.db.Exec("UPDATE users SET password = $1 WHERE id = $2", passHash, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, UserNotFound)
+	}
+	return nil
+}
+
 func scanRowIntoUser(rows *sqlx.Rows) (*models.User, error) {
 	user := new(models.User)
 
@@ -127,7 +150,6 @@ func scanRowIntoUser(rows *sqlx.Rows) (*models.User, error) {
 		&user.Password,
 		&user.CreatedAt,
 		&user.IsActive,
-		&user.IsSuperuser,
 		&user.IsMale,
 		&user.Age,
 		&user.Height,
@@ -142,3 +164,15 @@ func scanRowIntoUser(rows *sqlx.Rows) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetUserIDs pages through the ids of all users in ascending order, starting after afterID.
+func (s *Store) GetUserIDs(afterID int, limit int) ([]int, error) {
+	const op = "users.store.GetUserIDs"
+
+	ids := []int{}
+	err := s.db.Select(&ids, "SELECT id FROM users WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,347 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type WebhookStore interface {
+	CreateApp(name string) (*models.PartnerApp, error)
+	GetApps() ([]models.PartnerApp, error)
+	GetApp(id int) (*models.PartnerApp, error)
+	ConnectApp(userID int, appID int) error
+	DisconnectApp(userID int, appID int) error
+	GetConnectedApps(userID int) ([]models.PartnerApp, error)
+	CreateWebhook(w *models.Webhook) error
+	GetWebhooks(owner models.WebhookOwner) ([]models.Webhook, error)
+	GetWebhook(owner models.WebhookOwner, id int) (*models.Webhook, error)
+	DeleteWebhook(owner models.WebhookOwner, id int) error
+	GetSubscribers(userID int, event string) ([]models.Webhook, error)
+	Enqueue(webhookIDs []int, event string, payload []byte) error
+	CreateDelivery(webhookID int, event string, payload []byte) (*models.WebhookDelivery, error)
+	ClaimDue(limit int, lease time.Duration) ([]models.DueDelivery, error)
+	RecordAttempt(id int64, statusCode *int, attemptErr string, next *time.Time) (*models.WebhookDelivery, error)
+	GetDeliveries(webhookID int, limit int) ([]models.WebhookDelivery, error)
+	PurgeDeliveries(before time.Time) (int64, error)
+}
+
+var (
+	AppNotFound      = errors.New("partner app not found")
+	AppExists        = errors.New("partner app already exists")
+	WebhookNotFound  = errors.New("webhook not found")
+	DeliveryNotFound = errors.New("delivery not found")
+)
+
+const (
+	webhookColumns  = "id, user_id, app_id, url, secret, events, created_at"
+	deliveryColumns = "id, webhook_id, event, payload, status, attempts, last_status_code, last_error, " +
+		"next_attempt_at, created_at, delivered_at"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateApp(name string) (*models.PartnerApp, error) {
+	const op = "webhooks.store.CreateApp"
+
+	var app models.PartnerApp
+	err := s.db.Get(&app, "INSERT INTO partner_apps(name) VALUES($1) RETURNING id, name, created_at", name)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("%s: %w", op, AppExists)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &app, nil
+}
+
+func (s *Store) GetApps() ([]models.PartnerApp, error) {
+	const op = "webhooks.store.GetApps"
+
+	list := []models.PartnerApp{}
+	if err := s.db.Select(&list, "SELECT id, name, created_at FROM partner_apps ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetApp(id int) (*models.PartnerApp, error) {
+	const op = "webhooks.store.GetApp"
+
+	var app models.PartnerApp
+	err := s.db.Get(&app, "SELECT id, name, created_at FROM partner_apps WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, AppNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &app, nil
+}
+
+// ConnectApp lets the app receive the user's events. Connecting twice is a no-op.
+func (s *Store) ConnectApp(userID int, appID int) error {
+	const op = "webhooks.store.ConnectApp"
+
+	_, err := s.db.Exec(
+		"INSERT INTO partner_app_users(app_id, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING", appID, userID,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, AppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) DisconnectApp(userID int, appID int) error {
+	const op = "webhooks.store.DisconnectApp"
+
+	res, err := s.db.Exec("DELETE FROM partner_app_users WHERE app_id = $1 AND user_id = $2", appID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, AppNotFound)
+	}
+	return nil
+}
+
+func (s *Store) GetConnectedApps(userID int) ([]models.PartnerApp, error) {
+	const op = "webhooks.store.GetConnectedApps"
+
+	list := []models.PartnerApp{}
+	err := s.db.Select(
+		&list,
+		"SELECT a.id, a.name, a.created_at FROM partner_apps a JOIN partner_app_users u ON u.app_id = a.id "+
+			"WHERE u.user_id = $1 ORDER BY a.name",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) CreateWebhook(w *models.Webhook) error {
+	const op = "webhooks.store.CreateWebhook"
+
+	err := s.db.QueryRowx(
+		"INSERT INTO webhooks(user_id, app_id, url, secret, events) VALUES($1, $2, $3, $4, $5) "+
+			"RETURNING id, created_at",
+		w.UserID, w.AppID, w.URL, w.Secret, w.Events,
+	).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) GetWebhooks(owner models.WebhookOwner) ([]models.Webhook, error) {
+	const op = "webhooks.store.GetWebhooks"
+
+	list := []models.Webhook{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+webhookColumns+" FROM webhooks "+
+			"WHERE user_id IS NOT DISTINCT FROM $1 AND app_id IS NOT DISTINCT FROM $2 ORDER BY id",
+		owner.UserID, owner.AppID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetWebhook(owner models.WebhookOwner, id int) (*models.Webhook, error) {
+	const op = "webhooks.store.GetWebhook"
+
+	var w models.Webhook
+	err := s.db.Get(
+		&w,
+		"SELECT "+webhookColumns+" FROM webhooks "+
+			"WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2 AND app_id IS NOT DISTINCT FROM $3",
+		id, owner.UserID, owner.AppID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, WebhookNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &w, nil
+}
+
+// DeleteWebhook deletes the webhook with its delivery log.
+func (s *Store) DeleteWebhook(owner models.WebhookOwner, id int) error {
+	const op = "webhooks.store.DeleteWebhook"
+
+	res, err := s.db.Exec(
+		"DELETE FROM webhooks WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2 AND app_id IS NOT DISTINCT FROM $3",
+		id, owner.UserID, owner.AppID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, WebhookNotFound)
+	}
+	return nil
+}
+
+// GetSubscribers returns the webhooks to notify of an event of the user: the user's own and
+// those of the apps the user connected.
+func (s *Store) GetSubscribers(userID int, event string) ([]models.Webhook, error) {
+	const op = "webhooks.store.GetSubscribers"
+
+	list := []models.Webhook{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE $2 = ANY(events) AND (user_id = $1 OR app_id IN "+
+			"(SELECT app_id FROM partner_app_users WHERE user_id = $1))",
+		userID, event,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// Enqueue schedules a delivery of the payload to each webhook.
+func (s *Store) Enqueue(webhookIDs []int, event string, payload []byte) error {
+	const op = "webhooks.store.Enqueue"
+
+	ids := make([]int64, len(webhookIDs))
+	for i, id := range webhookIDs {
+		ids[i] = int64(id)
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO webhook_deliveries(webhook_id, event, payload) SELECT unnest($1::int[]), $2, $3",
+		pq.Array(ids), event, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Store) CreateDelivery(webhookID int, event string, payload []byte) (*models.WebhookDelivery, error) {
+	const op = "webhooks.store.CreateDelivery"
+
+	var d models.WebhookDelivery
+	err := s.db.Get(
+		&d,
+		"INSERT INTO webhook_deliveries(webhook_id, event, payload) VALUES($1, $2, $3) RETURNING "+deliveryColumns,
+		webhookID, event, payload,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &d, nil
+}
+
+// ClaimDue returns up to limit pending deliveries whose attempt is due. They are not due again
+// for lease, so a crashed worker's deliveries are retried afterwards.
+func (s *Store) ClaimDue(limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	const op = "webhooks.store.ClaimDue"
+
+	list := []models.DueDelivery{}
+	err := s.db.Select(
+		&list,
+		"UPDATE webhook_deliveries d SET next_attempt_at = $1 FROM webhooks w WHERE w.id = d.webhook_id AND d.id IN "+
+			"(SELECT id FROM webhook_deliveries WHERE status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP "+
+			"ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED) "+
+			"RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status_code, "+
+			"d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, w.url, w.secret",
+		time.Now().Add(lease), models.DeliveryPending, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// RecordAttempt logs an attempt. A successful one has no attemptErr and delivers the delivery,
+// a failed one is retried at next or, when next is nil, marks the delivery as failed.
+func (s *Store) RecordAttempt(
+	id int64, statusCode *int, attemptErr string, next *time.Time,
+) (*models.WebhookDelivery, error) {
+	const op = "webhooks.store.RecordAttempt"
+
+	status := models.DeliveryDelivered
+	switch {
+	case attemptErr != "" && next != nil:
+		status = models.DeliveryPending
+	case attemptErr != "":
+		status = models.DeliveryFailed
+	}
+
+	var d models.WebhookDelivery
+	err := s.db.Get(
+		&d,
+		"UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = $3, "+
+			"next_attempt_at = COALESCE($4, next_attempt_at), "+
+			"delivered_at = CASE WHEN $1 = 'delivered' THEN CURRENT_TIMESTAMP END "+
+			"WHERE id = $5 RETURNING "+deliveryColumns,
+		status, statusCode, attemptErr, next, id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, DeliveryNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &d, nil
+}
+
+// GetDeliveries returns the latest deliveries of the webhook, newest first.
+func (s *Store) GetDeliveries(webhookID int, limit int) ([]models.WebhookDelivery, error) {
+	const op = "webhooks.store.GetDeliveries"
+
+	list := []models.WebhookDelivery{}
+	err := s.db.Select(
+		&list,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2",
+		webhookID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return list, nil
+}
+
+// PurgeDeliveries deletes the finished deliveries created before the given time.
+func (s *Store) PurgeDeliveries(before time.Time) (int64, error) {
+	const op = "webhooks.store.PurgeDeliveries"
+
+	res, err := s.db.Exec(
+		"DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> $2", before, models.DeliveryPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
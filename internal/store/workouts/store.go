@@ -0,0 +1,329 @@
+package workouts
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stanislavCasciuc/atom-fit-go/internal/models"
+	"time"
+)
+
+type WorkoutStore interface {
+	CreateWorkout(userID int, payload models.CreateWorkoutPayload) (*models.Workout, error)
+	SaveWorkout(w *models.Workout) error
+	FindWorkoutByStart(userID int, startedAt time.Time, tolerance time.Duration) (*models.Workout, error)
+	GetWorkouts(userID int, limit int) ([]models.Workout, error)
+	GetWorkoutsBetween(userID int, from time.Time, to time.Time) ([]models.Workout, error)
+	GetExercises() ([]models.Exercise, error)
+	GetExerciseByName(name string) (*models.Exercise, error)
+	GetExerciseByID(id int) (*models.Exercise, error)
+	GetExerciseHistory(userID int, exerciseID int, limit int) ([]models.ExerciseSession, error)
+	GetRecords(userID int) ([]models.PersonalRecord, error)
+	SaveRecord(record models.PersonalRecord) error
+}
+
+var (
+	ExerciseNotFound = errors.New("exercise not found")
+	WorkoutNotFound  = errors.New("workout not found")
+)
+
+const workoutColumns = "id, user_id, name, started_at, duration, notes, source, distance, elevation_gain, " +
+	"avg_heart_rate, max_heart_rate, avg_cadence, created_at"
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateWorkout(userID int, payload models.CreateWorkoutPayload) (*models.Workout, error) {
+	w := models.Workout{
+		UserID:    userID,
+		Name:      payload.Name,
+		StartedAt: payload.StartedAt,
+		Duration:  payload.Duration,
+		Notes:     payload.Notes,
+		Source:    models.WorkoutSourceManual,
+	}
+	for i, p := range payload.Sets {
+		w.Sets = append(
+			w.Sets, models.WorkoutSet{
+				ExerciseID: p.ExerciseID,
+				Position:   i,
+				Reps:       p.Reps,
+				Weight:     p.Weight,
+				RPE:        p.RPE,
+				Duration:   p.Duration,
+				Distance:   p.Distance,
+			},
+		)
+	}
+
+	if err := s.SaveWorkout(&w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// SaveWorkout inserts the workout with its sets and fills in the generated ids.
+func (s *Store) SaveWorkout(w *models.Workout) error {
+	const op = "workouts.store.SaveWorkout"
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(
+		"INSERT INTO workouts(user_id, name, started_at, duration, notes, source, distance, elevation_gain, "+
+			"avg_heart_rate, max_heart_rate, avg_cadence) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) "+
+			"RETURNING id, created_at",
+		w.UserID, w.Name, w.StartedAt, w.Duration, w.Notes, w.Source, w.Distance, w.ElevationGain, w.AvgHeartRate,
+		w.MaxHeartRate, w.AvgCadence,
+	).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := tx.Preparex(
+		"INSERT INTO workout_sets(workout_id, exercise_id, position, reps, weight, rpe, duration, distance) " +
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for i := range w.Sets {
+		set := &w.Sets[i]
+		set.WorkoutID = w.ID
+		err = stmt.QueryRow(
+			set.WorkoutID, set.ExerciseID, set.Position, set.Reps, set.Weight, set.RPE, set.Duration, set.Distance,
+		).Scan(&set.ID)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return ExerciseNotFound
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// FindWorkoutByStart returns a workout of the user started within tolerance of the given time.
+func (s *Store) FindWorkoutByStart(userID int, startedAt time.Time, tolerance time.Duration) (*models.Workout, error) {
+	const op = "workouts.store.FindWorkoutByStart"
+
+	var list []models.Workout
+	err := s.db.Select(
+		&list,
+		"SELECT "+workoutColumns+" FROM workouts WHERE user_id = $1 AND started_at BETWEEN $2 AND $3 "+
+			"ORDER BY ABS(EXTRACT(EPOCH FROM started_at - $4)) LIMIT 1",
+		userID, startedAt.Add(-tolerance), startedAt.Add(tolerance), startedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, WorkoutNotFound)
+	}
+	return &list[0], nil
+}
+
+func (s *Store) GetWorkouts(userID int, limit int) ([]models.Workout, error) {
+	const op = "workouts.store.GetWorkouts"
+
+	workouts := []models.Workout{}
+	err := s.db.Select(
+		&workouts,
+		"SELECT "+workoutColumns+" FROM workouts "+
+			"WHERE user_id = $1 ORDER BY started_at DESC LIMIT $2",
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadSets(workouts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return workouts, nil
+}
+
+// GetWorkoutsBetween returns the workouts started in [from, to) with their sets.
+func (s *Store) GetWorkoutsBetween(userID int, from time.Time, to time.Time) ([]models.Workout, error) {
+	const op = "workouts.store.GetWorkoutsBetween"
+
+	workouts := []models.Workout{}
+	err := s.db.Select(
+		&workouts,
+		"SELECT "+workoutColumns+" FROM workouts "+
+			"WHERE user_id = $1 AND started_at >= $2 AND started_at < $3 ORDER BY started_at",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.loadSets(workouts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return workouts, nil
+}
+
+func (s *Store) loadSets(workouts []models.Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(workouts))
+	index := make(map[int]int, len(workouts))
+	for i, w := range workouts {
+		ids[i] = int64(w.ID)
+		index[w.ID] = i
+		workouts[i].Sets = []models.WorkoutSet{}
+	}
+
+	var sets []models.WorkoutSet
+	err := s.db.Select(
+		&sets,
+		"SELECT id, workout_id, exercise_id, position, reps, weight, rpe, duration, distance FROM workout_sets "+
+			"WHERE workout_id = ANY($1) ORDER BY workout_id, position",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		i := index[set.WorkoutID]
+		workouts[i].Sets = append(workouts[i].Sets, set)
+	}
+
+	return nil
+}
+
+func (s *Store) GetExercises() ([]models.Exercise, error) {
+	const op = "workouts.store.GetExercises"
+
+	var exercises []models.Exercise
+	err := s.db.Select(&exercises, "SELECT id, name, category, met, met_profile FROM exercises ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return exercises, nil
+}
+
+func (s *Store) GetExerciseByID(id int) (*models.Exercise, error) {
+	const op = "workouts.store.GetExerciseByID"
+
+	var exercises []models.Exercise
+	err := s.db.Select(&exercises, "SELECT id, name, category, met, met_profile FROM exercises WHERE id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(exercises) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ExerciseNotFound)
+	}
+	return &exercises[0], nil
+}
+
+func (s *Store) GetExerciseByName(name string) (*models.Exercise, error) {
+	const op = "workouts.store.GetExerciseByName"
+
+	var exercises []models.Exercise
+	err := s.db.Select(&exercises, "SELECT id, name, category, met, met_profile FROM exercises WHERE name = $1", name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(exercises) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ExerciseNotFound)
+	}
+	return &exercises[0], nil
+}
+
+// GetExerciseHistory returns the latest sessions of an exercise, newest first.
+func (s *Store) GetExerciseHistory(userID int, exerciseID int, limit int) ([]models.ExerciseSession, error) {
+	const op = "workouts.store.GetExerciseHistory"
+
+	rows, err := s.db.Queryx(
+		"SELECT w.id, w.started_at, ws.id, ws.position, ws.reps, ws.weight, ws.rpe, ws.duration, ws.distance "+
+			"FROM workout_sets ws JOIN workouts w ON w.id = ws.workout_id "+
+			"WHERE w.user_id = $1 AND ws.exercise_id = $2 AND w.id IN ("+
+			"SELECT w2.id FROM workouts w2 JOIN workout_sets ws2 ON ws2.workout_id = w2.id "+
+			"WHERE w2.user_id = $1 AND ws2.exercise_id = $2 "+
+			"GROUP BY w2.id ORDER BY w2.started_at DESC LIMIT $3) "+
+			"ORDER BY w.started_at DESC, w.id DESC, ws.position",
+		userID, exerciseID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	sessions := []models.ExerciseSession{}
+	for rows.Next() {
+		set := models.WorkoutSet{ExerciseID: exerciseID}
+		var session models.ExerciseSession
+		err := rows.Scan(
+			&session.WorkoutID, &session.StartedAt, &set.ID, &set.Position, &set.Reps, &set.Weight, &set.RPE,
+			&set.Duration, &set.Distance,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		set.WorkoutID = session.WorkoutID
+
+		if n := len(sessions); n == 0 || sessions[n-1].WorkoutID != session.WorkoutID {
+			sessions = append(sessions, session)
+		}
+		last := &sessions[len(sessions)-1]
+		last.Sets = append(last.Sets, set)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func (s *Store) GetRecords(userID int) ([]models.PersonalRecord, error) {
+	const op = "workouts.store.GetRecords"
+
+	records := []models.PersonalRecord{}
+	err := s.db.Select(
+		&records,
+		"SELECT user_id, exercise_id, kind, value, workout_id, achieved_at FROM personal_records "+
+			"WHERE user_id = $1 ORDER BY exercise_id, kind",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return records, nil
+}
+
+// SaveRecord stores the record unless an equal or better value is already saved.
+func (s *Store) SaveRecord(r models.PersonalRecord) error {
+	const op = "workouts.store.SaveRecord"
+
+	_, err := s.db.Exec(
+		"INSERT INTO personal_records(user_id, exercise_id, kind, value, workout_id, achieved_at) "+
+			"VALUES($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (user_id, exercise_id, kind) DO UPDATE "+
+			"SET value = EXCLUDED.value, workout_id = EXCLUDED.workout_id, achieved_at = EXCLUDED.achieved_at "+
+			"WHERE personal_records.value < EXCLUDED.value",
+		r.UserID, r.ExerciseID, r.Kind, r.Value, r.WorkoutID, r.AchievedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -12,7 +12,7 @@ CREATE TABLE IF NOT EXISTS users (
     weight INTEGER NOT NULL DEFAULT 70,
     goal TEXT NOT NULL DEFAULT 'lose' CHECK (goal IN ('lose', 'maintain', 'gain')),
     weight_goal INTEGER NOT NULL DEFAULT 65,
-    activation_code TEXT NOT NULL,
+    activation_code TEXT NOT NULL
 );
 
 CREATE INDEX IF NOT EXISTS idx_email ON users (email);
\ No newline at end of file
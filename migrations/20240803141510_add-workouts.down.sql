@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS personal_records;
+DROP TABLE IF EXISTS workout_sets;
+DROP TABLE IF EXISTS workouts;
+DROP TABLE IF EXISTS exercises;
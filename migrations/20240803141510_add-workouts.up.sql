@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS exercises (
+    id       SERIAL PRIMARY KEY,
+    name     TEXT NOT NULL UNIQUE,
+    category TEXT NOT NULL DEFAULT 'strength' CHECK (category IN ('strength', 'bodyweight', 'cardio'))
+);
+
+CREATE TABLE IF NOT EXISTS workouts (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration   INTEGER NOT NULL DEFAULT 0,
+    notes      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_started ON workouts (user_id, started_at);
+
+CREATE TABLE IF NOT EXISTS workout_sets (
+    id          SERIAL PRIMARY KEY,
+    workout_id  INTEGER NOT NULL REFERENCES workouts (id) ON DELETE CASCADE,
+    exercise_id INTEGER NOT NULL REFERENCES exercises (id),
+    position    INTEGER NOT NULL DEFAULT 0,
+    reps        INTEGER NOT NULL DEFAULT 0,
+    weight      NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    rpe         NUMERIC(3, 1),
+    duration    INTEGER NOT NULL DEFAULT 0,
+    distance    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_sets_workout ON workout_sets (workout_id);
+CREATE INDEX IF NOT EXISTS idx_workout_sets_exercise ON workout_sets (exercise_id);
+
+CREATE TABLE IF NOT EXISTS personal_records (
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    exercise_id INTEGER NOT NULL REFERENCES exercises (id),
+    kind        TEXT NOT NULL CHECK (kind IN ('estimated_1rm', 'max_weight', 'max_reps', 'max_volume')),
+    value       NUMERIC(10, 2) NOT NULL,
+    workout_id  INTEGER REFERENCES workouts (id) ON DELETE SET NULL,
+    achieved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, exercise_id, kind)
+);
+
+INSERT INTO exercises (name, category)
+VALUES ('Bench Press', 'strength'),
+       ('Back Squat', 'strength'),
+       ('Deadlift', 'strength'),
+       ('Overhead Press', 'strength'),
+       ('Barbell Row', 'strength'),
+       ('Pull-up', 'bodyweight'),
+       ('Push-up', 'bodyweight'),
+       ('Running', 'cardio'),
+       ('Cycling', 'cardio'),
+       ('Rowing', 'cardio')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS diary_entries;
+
+ALTER TABLE exercises
+    DROP COLUMN IF EXISTS met_profile,
+    DROP COLUMN IF EXISTS met;
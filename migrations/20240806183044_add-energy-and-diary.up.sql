@@ -0,0 +1,30 @@
+ALTER TABLE exercises
+    ADD COLUMN IF NOT EXISTS met NUMERIC(4, 1) NOT NULL DEFAULT 5.0,
+    ADD COLUMN IF NOT EXISTS met_profile TEXT CHECK (met_profile IN ('walking', 'running', 'cycling'));
+
+UPDATE exercises SET met = 5.0 WHERE name IN ('Bench Press', 'Overhead Press', 'Barbell Row');
+UPDATE exercises SET met = 6.0 WHERE name IN ('Back Squat', 'Deadlift');
+UPDATE exercises SET met = 8.0 WHERE name = 'Pull-up';
+UPDATE exercises SET met = 3.8 WHERE name = 'Push-up';
+UPDATE exercises SET met = 9.8, met_profile = 'running' WHERE name = 'Running';
+UPDATE exercises SET met = 7.5, met_profile = 'cycling' WHERE name = 'Cycling';
+UPDATE exercises SET met = 7.0 WHERE name = 'Rowing';
+
+INSERT INTO exercises (name, category, met, met_profile)
+VALUES ('Walking', 'cardio', 3.5, 'walking')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS diary_entries (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    eaten_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    meal       TEXT NOT NULL DEFAULT 'snack' CHECK (meal IN ('breakfast', 'lunch', 'dinner', 'snack')),
+    name       TEXT NOT NULL,
+    kcal       NUMERIC(7, 1) NOT NULL DEFAULT 0,
+    protein    NUMERIC(6, 1) NOT NULL DEFAULT 0,
+    carbs      NUMERIC(6, 1) NOT NULL DEFAULT 0,
+    fat        NUMERIC(6, 1) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_diary_entries_user_eaten ON diary_entries (user_id, eaten_at);
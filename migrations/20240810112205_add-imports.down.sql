@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS imports;
+
+ALTER TABLE workouts
+    DROP COLUMN IF EXISTS avg_cadence,
+    DROP COLUMN IF EXISTS max_heart_rate,
+    DROP COLUMN IF EXISTS avg_heart_rate,
+    DROP COLUMN IF EXISTS elevation_gain,
+    DROP COLUMN IF EXISTS distance,
+    DROP COLUMN IF EXISTS source;
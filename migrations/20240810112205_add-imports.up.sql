@@ -0,0 +1,24 @@
+ALTER TABLE workouts
+    ADD COLUMN IF NOT EXISTS source         TEXT NOT NULL DEFAULT 'manual',
+    ADD COLUMN IF NOT EXISTS distance       INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS elevation_gain INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS avg_heart_rate INTEGER,
+    ADD COLUMN IF NOT EXISTS max_heart_rate INTEGER,
+    ADD COLUMN IF NOT EXISTS avg_cadence    INTEGER;
+
+CREATE TABLE IF NOT EXISTS imports (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    filename      TEXT NOT NULL,
+    format        TEXT NOT NULL CHECK (format IN ('gpx', 'tcx', 'fit')),
+    status        TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'done', 'duplicate', 'failed')),
+    error         TEXT NOT NULL DEFAULT '',
+    workout_id    INTEGER REFERENCES workouts (id) ON DELETE SET NULL,
+    data          BYTEA,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at   TIMESTAMP WITH TIME ZONE,
+    claimed_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_imports_pending ON imports (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_imports_processing ON imports (claimed_until) WHERE status = 'processing';
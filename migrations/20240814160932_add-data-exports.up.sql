@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status        TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'ready', 'failed', 'expired')),
+    error         TEXT NOT NULL DEFAULT '',
+    token_hash    TEXT UNIQUE,
+    blob_key      TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at   TIMESTAMP WITH TIME ZONE,
+    expires_at    TIMESTAMP WITH TIME ZONE,
+    claimed_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_processing ON data_exports (claimed_until) WHERE status = 'processing';
@@ -0,0 +1,7 @@
+ALTER TABLE diary_entries
+    DROP COLUMN IF EXISTS grams,
+    DROP COLUMN IF EXISTS food_id;
+
+DROP TABLE IF EXISTS recipe_ingredients;
+DROP TABLE IF EXISTS recipes;
+DROP TABLE IF EXISTS foods;
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS foods (
+    id            SERIAL PRIMARY KEY,
+    owner_id      INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    barcode       TEXT,
+    name          TEXT NOT NULL,
+    brand         TEXT NOT NULL DEFAULT '',
+    kcal          NUMERIC(7, 2) NOT NULL DEFAULT 0,
+    protein       NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    carbs         NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    fat           NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    saturated_fat NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    sugars        NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    fiber         NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    sodium        NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    calcium       NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    iron          NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    potassium     NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    vitamin_c     NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    source        TEXT NOT NULL DEFAULT 'user' CHECK (source IN ('user', 'off')),
+    search        TSVECTOR GENERATED ALWAYS AS (
+                      setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', brand), 'B')
+                  ) STORED,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_foods_public_barcode ON foods (barcode)
+    WHERE owner_id IS NULL AND barcode IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_foods_owner_barcode ON foods (owner_id, barcode) WHERE owner_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_foods_search ON foods USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS recipes (
+    id         SERIAL PRIMARY KEY,
+    owner_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipes_owner ON recipes (owner_id);
+
+CREATE TABLE IF NOT EXISTS recipe_ingredients (
+    recipe_id INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    position  INTEGER NOT NULL,
+    food_id   INTEGER NOT NULL REFERENCES foods (id),
+    grams     NUMERIC(7, 2) NOT NULL CHECK (grams > 0),
+    PRIMARY KEY (recipe_id, position)
+);
+
+ALTER TABLE diary_entries
+    ADD COLUMN IF NOT EXISTS food_id INTEGER REFERENCES foods (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS grams   NUMERIC(7, 2);
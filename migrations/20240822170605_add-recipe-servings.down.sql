@@ -0,0 +1,9 @@
+ALTER TABLE diary_entries
+    DROP COLUMN IF EXISTS servings,
+    DROP COLUMN IF EXISTS recipe_id;
+
+ALTER TABLE recipes
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS share_token,
+    DROP COLUMN IF EXISTS cooked_weight,
+    DROP COLUMN IF EXISTS servings;
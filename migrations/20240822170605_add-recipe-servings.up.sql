@@ -0,0 +1,9 @@
+ALTER TABLE recipes
+    ADD COLUMN IF NOT EXISTS servings      INTEGER NOT NULL DEFAULT 1 CHECK (servings > 0),
+    ADD COLUMN IF NOT EXISTS cooked_weight NUMERIC(8, 2) CHECK (cooked_weight > 0),
+    ADD COLUMN IF NOT EXISTS share_token   TEXT UNIQUE,
+    ADD COLUMN IF NOT EXISTS updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE diary_entries
+    ADD COLUMN IF NOT EXISTS recipe_id INTEGER REFERENCES recipes (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS servings  NUMERIC(5, 2);
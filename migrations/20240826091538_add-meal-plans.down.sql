@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS meal_plans;
+DROP TABLE IF EXISTS user_preferences;
+
+ALTER TABLE foods
+    DROP COLUMN IF EXISTS diets,
+    DROP COLUMN IF EXISTS allergens;
@@ -0,0 +1,22 @@
+ALTER TABLE foods
+    ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS diets     TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id    INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    diet       TEXT NOT NULL DEFAULT 'none' CHECK (diet IN ('none', 'vegetarian', 'vegan', 'pescatarian')),
+    allergens  TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS meal_plans (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    seed       BIGINT NOT NULL,
+    targets    JSONB NOT NULL,
+    days       JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_meal_plans_user ON meal_plans (user_id, created_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS metric_goals;
+DROP TABLE IF EXISTS daily_metrics;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS daily_metrics (
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    day        DATE NOT NULL,
+    metric     TEXT NOT NULL CHECK (metric IN ('water_ml', 'sleep_minutes', 'sleep_quality', 'steps', 'resting_hr')),
+    value      NUMERIC(10, 2) NOT NULL CHECK (value >= 0),
+    source     TEXT NOT NULL DEFAULT 'manual',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, metric, day)
+);
+
+CREATE TABLE IF NOT EXISTS metric_goals (
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    metric     TEXT NOT NULL CHECK (metric IN ('water_ml', 'sleep_minutes', 'sleep_quality', 'steps', 'resting_hr')),
+    target     NUMERIC(10, 2) NOT NULL CHECK (target > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, metric)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS progress_photos;
+DROP TABLE IF EXISTS body_measurements;
+DROP TABLE IF EXISTS weight_entries;
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS weight_entries (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    measured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    weight      NUMERIC(5, 2) NOT NULL CHECK (weight > 0),
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_weight_entries_user ON weight_entries (user_id, measured_at);
+
+CREATE TABLE IF NOT EXISTS body_measurements (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    measured_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    waist           NUMERIC(5, 1),
+    hips            NUMERIC(5, 1),
+    chest           NUMERIC(5, 1),
+    arm             NUMERIC(5, 1),
+    thigh           NUMERIC(5, 1),
+    body_fat        NUMERIC(4, 1) CHECK (body_fat BETWEEN 1 AND 75),
+    body_fat_method TEXT CHECK (body_fat_method IN ('caliper', 'bia', 'dexa', 'navy', 'visual', 'other')),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((body_fat IS NULL) = (body_fat_method IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_body_measurements_user ON body_measurements (user_id, measured_at);
+
+CREATE TABLE IF NOT EXISTS progress_photos (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    taken_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    pose         TEXT NOT NULL DEFAULT 'front' CHECK (pose IN ('front', 'side', 'back')),
+    blob_key     TEXT NOT NULL,
+    thumb_key    TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width        INTEGER NOT NULL,
+    height       INTEGER NOT NULL,
+    size         INTEGER NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_progress_photos_user ON progress_photos (user_id, taken_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS goal_milestones;
+DROP TABLE IF EXISTS goals;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS goals (
+    id                    SERIAL PRIMARY KEY,
+    user_id               INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind                  TEXT NOT NULL CHECK (kind IN ('target_weight', 'workouts_per_week', 'daily_steps', 'protein_per_day')),
+    target                NUMERIC(10, 2) NOT NULL CHECK (target > 0),
+    start_value           NUMERIC(10, 2),
+    starts_on             DATE NOT NULL,
+    ends_on               DATE NOT NULL,
+    status                TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'missed', 'abandoned')),
+    progress              NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    current_value         NUMERIC(10, 2),
+    off_track             BOOLEAN NOT NULL DEFAULT FALSE,
+    off_track_notified_at TIMESTAMP WITH TIME ZONE,
+    evaluated_at          TIMESTAMP WITH TIME ZONE,
+    completed_at          TIMESTAMP WITH TIME ZONE,
+    created_at            TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_on > starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_user_active ON goals (user_id) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS goal_milestones (
+    goal_id    INTEGER NOT NULL REFERENCES goals (id) ON DELETE CASCADE,
+    percent    SMALLINT NOT NULL CHECK (percent IN (25, 50, 75, 100)),
+    reached_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (goal_id, percent)
+);
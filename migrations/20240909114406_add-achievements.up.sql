@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS achievements (
+    code        TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL,
+    rule        TEXT NOT NULL CHECK (rule IN ('workout_count', 'record_count', 'activity_streak', 'daily_steps')),
+    threshold   NUMERIC(10, 2) NOT NULL CHECK (threshold > 0),
+    position    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code       TEXT NOT NULL REFERENCES achievements (code) ON DELETE CASCADE,
+    awarded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, code)
+);
+
+INSERT INTO achievements (code, name, description, rule, threshold, position)
+VALUES ('first_workout', 'First workout', 'Log your first workout.', 'workout_count', 1, 10),
+       ('first_record', 'First personal record', 'Set your first personal record.', 'record_count', 1, 20),
+       ('streak_7_days', '7-day streak', 'Log a workout, a meal or your weight 7 days in a row.', 'activity_streak', 7, 30),
+       ('steps_10k', '10k steps', 'Walk 10,000 steps in a day.', 'daily_steps', 10000, 40),
+       ('workouts_100', '100 workouts', 'Log 100 workouts.', 'workout_count', 100, 50)
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS kudos;
+DROP TABLE IF EXISTS feed_items;
+DROP TABLE IF EXISTS activities;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS social_settings;
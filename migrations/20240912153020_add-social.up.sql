@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS social_settings (
+    user_id            INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    private            BOOLEAN NOT NULL DEFAULT FALSE,
+    default_visibility TEXT NOT NULL DEFAULT 'followers' CHECK (default_visibility IN ('public', 'followers', 'private')),
+    updated_at         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status      TEXT NOT NULL CHECK (status IN ('pending', 'accepted')),
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee ON follows (followee_id, status);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    blocker_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocks_blocked ON blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS activities (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind           TEXT NOT NULL CHECK (kind IN ('workout', 'record', 'achievement')),
+    ref            TEXT NOT NULL,
+    visibility     TEXT NOT NULL CHECK (visibility IN ('public', 'followers', 'private')),
+    payload        JSONB NOT NULL DEFAULT '{}',
+    occurred_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    kudos_count    INTEGER NOT NULL DEFAULT 0,
+    comments_count INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, kind, ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_activities_user_occurred ON activities (user_id, occurred_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS feed_items (
+    owner_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    activity_id BIGINT NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+    actor_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (owner_id, activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_items_owner_occurred ON feed_items (owner_id, occurred_at DESC, activity_id DESC);
+CREATE INDEX IF NOT EXISTS idx_feed_items_actor ON feed_items (actor_id, owner_id);
+
+CREATE TABLE IF NOT EXISTS kudos (
+    activity_id BIGINT NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (activity_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id          BIGSERIAL PRIMARY KEY,
+    activity_id BIGINT NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_activity ON comments (activity_id, id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS challenge_snapshots;
+DROP TABLE IF EXISTS challenge_contributions;
+DROP TABLE IF EXISTS challenge_participants;
+DROP TABLE IF EXISTS challenges;
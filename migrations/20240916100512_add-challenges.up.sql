@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS challenges (
+    id          SERIAL PRIMARY KEY,
+    owner_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    metric      TEXT NOT NULL CHECK (metric IN ('distance', 'workouts', 'steps')),
+    starts_on   DATE NOT NULL,
+    ends_on     DATE NOT NULL,
+    visibility  TEXT NOT NULL CHECK (visibility IN ('public', 'invite_only')),
+    invite_code TEXT NOT NULL UNIQUE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenges_public ON challenges (ends_on) WHERE visibility = 'public';
+
+CREATE TABLE IF NOT EXISTS challenge_participants (
+    challenge_id INTEGER NOT NULL REFERENCES challenges (id) ON DELETE CASCADE,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    score        NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    joined_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (challenge_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_participants_user ON challenge_participants (user_id);
+CREATE INDEX IF NOT EXISTS idx_challenge_participants_score ON challenge_participants (challenge_id, score DESC);
+
+CREATE TABLE IF NOT EXISTS challenge_contributions (
+    challenge_id INTEGER NOT NULL,
+    user_id      INTEGER NOT NULL,
+    source       TEXT NOT NULL,
+    value        NUMERIC(14, 2) NOT NULL,
+    PRIMARY KEY (challenge_id, user_id, source),
+    FOREIGN KEY (challenge_id, user_id) REFERENCES challenge_participants (challenge_id, user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS challenge_snapshots (
+    challenge_id INTEGER NOT NULL REFERENCES challenges (id) ON DELETE CASCADE,
+    day          DATE NOT NULL,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    rank         INTEGER NOT NULL,
+    score        NUMERIC(14, 2) NOT NULL,
+    PRIMARY KEY (challenge_id, day, user_id)
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS coach_notes;
+DROP TABLE IF EXISTS template_assignments;
+DROP TABLE IF EXISTS workout_template_sets;
+DROP TABLE IF EXISTS workout_templates;
+DROP TABLE IF EXISTS coach_clients;
+DROP TABLE IF EXISTS coaches;
@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS coaches (
+    user_id    INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    bio        TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coach_clients (
+    id          SERIAL PRIMARY KEY,
+    coach_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status      TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active', 'declined', 'revoked')),
+    scopes      TEXT[] NOT NULL DEFAULT '{}',
+    invited_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    ended_at    TIMESTAMP WITH TIME ZONE,
+    CHECK (coach_id <> client_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coach_clients_open ON coach_clients (coach_id, client_id)
+    WHERE status IN ('pending', 'active');
+CREATE INDEX IF NOT EXISTS idx_coach_clients_client ON coach_clients (client_id, status);
+
+CREATE TABLE IF NOT EXISTS workout_templates (
+    id         SERIAL PRIMARY KEY,
+    coach_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    notes      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_templates_coach ON workout_templates (coach_id);
+
+CREATE TABLE IF NOT EXISTS workout_template_sets (
+    template_id INTEGER NOT NULL REFERENCES workout_templates (id) ON DELETE CASCADE,
+    position    INTEGER NOT NULL,
+    exercise_id INTEGER NOT NULL REFERENCES exercises (id),
+    reps        INTEGER NOT NULL DEFAULT 0,
+    weight      NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    duration    INTEGER NOT NULL DEFAULT 0,
+    distance    INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (template_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS template_assignments (
+    id           SERIAL PRIMARY KEY,
+    template_id  INTEGER NOT NULL REFERENCES workout_templates (id) ON DELETE CASCADE,
+    coach_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    due_on       DATE,
+    note         TEXT NOT NULL DEFAULT '',
+    workout_id   INTEGER REFERENCES workouts (id) ON DELETE SET NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    assigned_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_assignments_client ON template_assignments (client_id, assigned_at);
+
+CREATE TABLE IF NOT EXISTS coach_notes (
+    id         SERIAL PRIMARY KEY,
+    coach_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_coach_notes_client ON coach_notes (coach_id, client_id, created_at);
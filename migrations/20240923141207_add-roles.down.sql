@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_workout_templates_published;
+
+ALTER TABLE workout_templates DROP COLUMN IF EXISTS published_at;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_superuser BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET is_superuser = true WHERE id IN (SELECT user_id FROM user_roles WHERE role = 'admin');
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
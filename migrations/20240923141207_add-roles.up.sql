@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    builtin     BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role       TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role       TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    granted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles (role);
+
+INSERT INTO permissions (name, description)
+VALUES ('users:read', 'View user accounts and their roles'),
+       ('users:write', 'Manage user accounts, roles and the coach role'),
+       ('foods:publish', 'Publish user foods to the shared food database'),
+       ('programs:publish', 'Publish workout templates to the program catalog'),
+       ('audit:read', 'Read the audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, builtin)
+VALUES ('admin', 'Every permission', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'admin' FROM users WHERE is_superuser
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_superuser;
+
+ALTER TABLE workout_templates ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_workout_templates_published ON workout_templates (published_at)
+    WHERE published_at IS NOT NULL;
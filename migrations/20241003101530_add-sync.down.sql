@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS weight_entries_sync ON weight_entries;
+DROP TRIGGER IF EXISTS diary_entries_sync ON diary_entries;
+DROP TRIGGER IF EXISTS workout_sets_sync ON workout_sets;
+DROP TRIGGER IF EXISTS workouts_sync ON workouts;
+DROP FUNCTION IF EXISTS sync_track_change();
+DROP TABLE IF EXISTS sync_changes;
+DROP SEQUENCE IF EXISTS sync_changes_seq;
+
+ALTER TABLE weight_entries DROP COLUMN IF EXISTS uuid;
+ALTER TABLE diary_entries DROP COLUMN IF EXISTS uuid;
+ALTER TABLE workout_sets DROP COLUMN IF EXISTS uuid;
+ALTER TABLE workouts DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1,91 @@
+ALTER TABLE workouts
+    ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE workout_sets
+    ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE diary_entries
+    ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE weight_entries
+    ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_uuid ON workouts (uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_sets_uuid ON workout_sets (uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_diary_entries_uuid ON diary_entries (uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_weight_entries_uuid ON weight_entries (uuid);
+
+CREATE SEQUENCE IF NOT EXISTS sync_changes_seq;
+
+CREATE TABLE IF NOT EXISTS sync_changes (
+    entity     TEXT NOT NULL CHECK (entity IN ('workout', 'workout_set', 'diary_entry', 'weight_entry')),
+    record_id  UUID NOT NULL,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL DEFAULT nextval('sync_changes_seq'),
+    deleted    BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    device_id  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (entity, record_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_changes_user_seq ON sync_changes (user_id, seq);
+
+CREATE OR REPLACE FUNCTION sync_track_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    rec   RECORD;
+    owner INTEGER;
+BEGIN
+    IF current_setting('sync.applying', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+
+    IF TG_ARGV[0] = 'workout_set' THEN
+        SELECT user_id INTO owner FROM workouts WHERE id = rec.workout_id;
+    ELSE
+        owner := rec.user_id;
+    END IF;
+    IF owner IS NULL OR NOT EXISTS (SELECT 1 FROM users WHERE id = owner) THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext('sync'), owner);
+    INSERT INTO sync_changes (entity, record_id, user_id, deleted, updated_at)
+    VALUES (TG_ARGV[0], rec.uuid, owner, TG_OP = 'DELETE', CURRENT_TIMESTAMP)
+    ON CONFLICT (entity, record_id) DO UPDATE
+        SET seq        = EXCLUDED.seq,
+            deleted    = EXCLUDED.deleted,
+            updated_at = EXCLUDED.updated_at,
+            device_id  = EXCLUDED.device_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER workouts_sync
+    AFTER INSERT OR UPDATE OR DELETE ON workouts
+    FOR EACH ROW EXECUTE FUNCTION sync_track_change('workout');
+CREATE TRIGGER workout_sets_sync
+    AFTER INSERT OR UPDATE OR DELETE ON workout_sets
+    FOR EACH ROW EXECUTE FUNCTION sync_track_change('workout_set');
+CREATE TRIGGER diary_entries_sync
+    AFTER INSERT OR UPDATE OR DELETE ON diary_entries
+    FOR EACH ROW EXECUTE FUNCTION sync_track_change('diary_entry');
+CREATE TRIGGER weight_entries_sync
+    AFTER INSERT OR UPDATE OR DELETE ON weight_entries
+    FOR EACH ROW EXECUTE FUNCTION sync_track_change('weight_entry');
+
+INSERT INTO sync_changes (entity, record_id, user_id, updated_at)
+SELECT 'workout', uuid, user_id, created_at FROM workouts
+ON CONFLICT DO NOTHING;
+INSERT INTO sync_changes (entity, record_id, user_id, updated_at)
+SELECT 'workout_set', s.uuid, w.user_id, w.created_at FROM workout_sets s JOIN workouts w ON w.id = s.workout_id
+ON CONFLICT DO NOTHING;
+INSERT INTO sync_changes (entity, record_id, user_id, updated_at)
+SELECT 'diary_entry', uuid, user_id, created_at FROM diary_entries
+ON CONFLICT DO NOTHING;
+INSERT INTO sync_changes (entity, record_id, user_id, updated_at)
+SELECT 'weight_entry', uuid, user_id, created_at FROM weight_entries
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS partner_app_users;
+DROP TABLE IF EXISTS partner_apps;
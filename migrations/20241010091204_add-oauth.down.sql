@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS oauth_tokens;
+DROP TABLE IF EXISTS oauth_codes;
+DROP TABLE IF EXISTS oauth_clients;
+ALTER TABLE partner_app_users DROP COLUMN IF EXISTS scopes;
@@ -0,0 +1,38 @@
+ALTER TABLE partner_app_users ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id            SERIAL PRIMARY KEY,
+    app_id        INTEGER NOT NULL REFERENCES partner_apps (id) ON DELETE CASCADE,
+    client_id     TEXT NOT NULL UNIQUE,
+    secret_hash   TEXT,
+    redirect_uris TEXT[] NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_app ON oauth_clients (app_id);
+
+CREATE TABLE IF NOT EXISTS oauth_codes (
+    code_hash      TEXT PRIMARY KEY,
+    client_id      INTEGER NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri   TEXT NOT NULL,
+    scopes         TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    expires_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at        TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS oauth_tokens (
+    id           BIGSERIAL PRIMARY KEY,
+    client_id    INTEGER NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scopes       TEXT[] NOT NULL,
+    refresh_hash TEXT NOT NULL UNIQUE,
+    code_hash    TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at   TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_tokens_code ON oauth_tokens (code_hash);
+CREATE INDEX IF NOT EXISTS idx_oauth_tokens_user ON oauth_tokens (user_id, client_id);
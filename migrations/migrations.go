@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations into the binaries, so they run without the
+// directory next to them.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS